@@ -39,6 +39,21 @@ type Config struct {
 
 	// OAuth Redirect Whitelist
 	AllowedOAuthRedirects []string
+
+	// Public base URL of this API (used in links sent by email)
+	APIBaseURL string
+	// Public base URL of the web app (links to posts in digest emails)
+	AppBaseURL string
+
+	// Secret for HMAC-signed links (falls back to the Supabase JWT secret)
+	SigningSecret string
+
+	// Let webhooks target private and loopback addresses (local development only)
+	WebhookAllowPrivateNetworks bool
+
+	// Networks of the reverse proxies whose forwarding headers are believed
+	// (empty means loopback and private networks)
+	TrustedProxies []string
 }
 
 func Load() *Config {
@@ -77,6 +92,15 @@ func Load() *Config {
 
 		// OAuth Redirect Whitelist
 		AllowedOAuthRedirects: parseStringSlice(getEnv("ALLOWED_OAUTH_REDIRECTS", "http://localhost:5173,http://localhost:3000")),
+
+		// Links sent by email
+		APIBaseURL:    strings.TrimSuffix(getEnv("API_BASE_URL", "http://localhost:8080"), "/"),
+		AppBaseURL:    strings.TrimSuffix(getEnv("APP_BASE_URL", "http://localhost:5173"), "/"),
+		SigningSecret: getEnv("SIGNING_SECRET", getEnv("SUPABASE_JWT_SECRET", "")),
+
+		WebhookAllowPrivateNetworks: getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "") == "true",
+
+		TrustedProxies: parseStringSlice(getEnv("TRUSTED_PROXIES", "")),
 	}
 }
 
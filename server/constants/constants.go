@@ -30,10 +30,18 @@ const (
 	MaxBioLength         = 500
 	MaxNameLength        = 100
 	MaxLocationLength    = 100
+	MaxPronounsLength    = 30
+	MaxEmployerLength    = 100
+	MaxSkills            = 20
+	MaxSkillLength       = 30
 	MaxSearchQueryLength = 100
 	MaxTagsPerPost       = 10
 	MaxTagLength         = 50
 	MaxMediaPerPost      = 10
+	MaxMessageLength     = 5000
+	MaxMediaPerMessage   = 4
+	MaxGroupParticipants = 10 // Including the creator
+	MaxConversationTitle = 100
 	MaxOffset            = 10000           // Maximum offset for pagination
 	MaxJSONBodySize      = 1 * 1024 * 1024 // 1MB max JSON body
 )
@@ -57,3 +65,131 @@ const (
 	MinUserIDLength   = 1
 	MaxUserIDLength   = 128
 )
+
+// Data export constants
+const (
+	DataExportLinkExpiry   = 48 * time.Hour    // Emailed download link lifetime
+	DataExportCooldown     = 24 * time.Hour    // One export request per day
+	DataExportPollInterval = 1 * time.Minute   // Worker poll interval for pending exports
+	DataExportMaxMediaSize = 100 * 1024 * 1024 // Skip media beyond this total per archive
+)
+
+// Account deletion constants
+const (
+	AccountDeletionGracePeriod  = 14 * 24 * time.Hour // Logging in during this window cancels the deletion
+	AccountDeletionPollInterval = 1 * time.Hour       // Worker poll interval for due deletions
+	DeletedUserName             = "[deleted user]"    // Display name of anonymized accounts
+)
+
+// Email change constants
+const (
+	EmailChangeCodeExpiry   = 10 * time.Minute   // Codes sent to the old and new address (matches OTP lifetime)
+	EmailChangeRevertWindow = 7 * 24 * time.Hour // The old address can undo the change for this long
+)
+
+// Email verification constants
+const (
+	EmailVerificationLinkExpiry = 48 * time.Hour     // Lifetime of the emailed verification link
+	UnverifiedAccountRetention  = 7 * 24 * time.Hour // Never-verified signups are removed after this
+)
+
+// Notification email constants
+const (
+	NotificationEmailPollInterval = 1 * time.Minute // Worker poll interval for immediate notification emails
+	NotificationEmailMaxAge       = 1 * time.Hour   // Older unsent notifications are not emailed any more
+	DigestPollInterval            = 1 * time.Hour   // Worker poll interval for due digests
+	DigestBatchSize               = 200             // Users handled per digest frequency and run
+	DigestTopPosts                = 5               // Top posts from followed users per digest
+)
+
+// Webhook constants
+const (
+	WebhookPollInterval      = 10 * time.Second // Worker poll interval for due deliveries
+	WebhookBatchSize         = 20               // Deliveries sent concurrently per poll
+	WebhookTimeout           = 10 * time.Second // Per-request timeout; slower endpoints count as failed
+	WebhookMaxAttempts       = 8                // Attempts per delivery before it is marked failed
+	WebhookRetryBaseDelay    = 30 * time.Second // Doubled after each failed attempt
+	WebhookRetryMaxDelay     = 6 * time.Hour    // Cap on the backoff between attempts
+	WebhookDisableAfter      = 50               // Consecutive failed attempts before a webhook is disabled
+	WebhookMaxResponseLogged = 1024             // Bytes of the response body kept in the delivery log
+)
+
+// Live stream constants
+const (
+	StreamHeartbeatInterval = 25 * time.Second // Keeps proxies from closing idle streams
+	StreamWriteTimeout      = 10 * time.Second // Per-write deadline once the server WriteTimeout is lifted
+	StreamTicketExpiry      = 1 * time.Minute  // Lifetime of the ticket browsers connect with
+	StreamMaxPosts          = 20               // Posts one connection can watch for comments and likes
+	StreamReplayLength      = 200              // Events kept per channel for Last-Event-ID resume
+	StreamReplayRetention   = 24 * time.Hour   // Idle channels' replay logs expire after this
+)
+
+// Report constants
+const (
+	MaxReportReasonLength = 1000
+	MaxReportNoteLength   = 2000 // Admin notes and resolution summaries
+	MaxAuditReasonLength  = 1000 // Longer X-Audit-Reason headers are truncated
+)
+
+// Suspension constants
+const (
+	MaxSuspensionReasonLength = 1000
+	MaxSuspensionHours        = 5 * 365 * 24     // Longer suspensions must be indefinite
+	SuspensionCacheTTL        = 30 * time.Second // How long the auth layer trusts a cached suspension status
+	SuspensionPollInterval    = 1 * time.Minute  // Worker poll interval for expired suspensions
+	MaxShadowBanReasonLength  = 1000
+	MaxAppealMessageLength    = 2000
+	MaxAppealNoteLength       = 2000
+	AppealWindow              = 30 * 24 * time.Hour // Upheld reports can be appealed for this long
+)
+
+// Content filter constants
+const (
+	MaxFilterPatternLength      = 500
+	MaxFilterDescriptionLength  = 500
+	MaxFilterTestTextLength     = 20000
+	MaxQueueNoteLength          = 2000
+	ContentFilterReloadInterval = 30 * time.Second // How often each instance checks the rules table for changes
+)
+
+// Trust level constants
+const (
+	TrustPremoderatedPosts  = 3                    // A new account's first posts are held for review
+	TrustUpheldReportWindow = 180 * 24 * time.Hour // Upheld reports older than this no longer count
+	TrustRecomputeInterval  = 1 * time.Hour        // How often trust levels are recomputed
+)
+
+// Spam classifier constants
+const (
+	SpamHoldThreshold       = 0.97             // Content scoring at least this is held for review
+	SpamFlagThreshold       = 0.85             // Content scoring at least this is published and reported
+	SpamMinExamples         = 20               // Spam and ham examples each needed before the model scores anything
+	SpamMaxTrainingExamples = 20000            // Most recent moderation outcomes trained on
+	SpamMinTokenExamples    = 2                // Tokens seen in fewer examples are left out of the model
+	SpamMaxVocabulary       = 20000            // Most frequent tokens kept
+	SpamMaxMisclassified    = 200              // Misclassified examples kept with each model
+	SpamModelsKept          = 10               // Older models are deleted after retraining
+	SpamRetrainInterval     = 24 * time.Hour   // Scheduled retraining
+	SpamModelReloadInterval = 30 * time.Second // How often each instance checks for a newer model
+	MaxSpamTestTextLength   = 20000
+)
+
+// Bulk moderation constants
+const (
+	BulkMaxUsers         = 1000             // User IDs per suspend_users request
+	BulkMaxTargets       = 50000            // Operations matching more are refused; narrow the criteria
+	BulkPreviewTargets   = 200              // Targets returned with an operation
+	BulkMaxFailuresKept  = 200              // Failures recorded per operation
+	BulkPollInterval     = 5 * time.Second  // Worker poll interval for queued operations
+	BulkItemTimeout      = 1 * time.Minute  // Per-target deadline
+	BulkStaleAfter       = 10 * time.Minute // Running operations without progress this long are resumed
+	MaxBulkSummaryLength = 2000
+)
+
+// Handle constants
+const (
+	MinHandleLength         = 3
+	MaxHandleLength         = 30
+	HandleChangeCooldown    = 30 * 24 * time.Hour  // One handle change per 30 days
+	HandleRedirectRetention = 180 * 24 * time.Hour // Old handles redirect for 180 days
+)
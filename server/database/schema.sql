@@ -8,12 +8,19 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 CREATE TABLE IF NOT EXISTS public.users (
     id UUID PRIMARY KEY REFERENCES auth.users(id) ON DELETE CASCADE,
     name TEXT NOT NULL,
+    handle TEXT,
+    handle_skeleton TEXT,
+    handle_changed_at TIMESTAMPTZ,
     email TEXT UNIQUE NOT NULL,
     avatar TEXT,
     cover_photo TEXT,
     bio TEXT,
     location TEXT,
     website TEXT,
+    social_links JSONB DEFAULT '{}'::jsonb,
+    skills TEXT[] DEFAULT '{}',
+    pronouns TEXT,
+    employer TEXT,
     is_admin BOOLEAN DEFAULT FALSE,
     is_verified BOOLEAN DEFAULT FALSE,
     is_active BOOLEAN DEFAULT TRUE,
@@ -22,10 +29,35 @@ CREATE TABLE IF NOT EXISTS public.users (
     posts_count INTEGER DEFAULT 0,
     followers_count INTEGER DEFAULT 0,
     following_count INTEGER DEFAULT 0,
+    reputation INTEGER DEFAULT 0, -- Sum of reputation_events
+    email_verified_at TIMESTAMPTZ, -- NULL until the signup verification link is followed
+    trust_level SMALLINT NOT NULL DEFAULT 0 CHECK (trust_level BETWEEN 0 AND 3), -- 0 new .. 3 trusted, recomputed hourly
+    trust_level_updated_at TIMESTAMPTZ,
+    shadow_banned_at TIMESTAMPTZ, -- Content hidden from everyone but the user while set
+    shadow_banned_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    shadow_ban_reason TEXT,
+    deleted_at TIMESTAMPTZ, -- Set when the account was anonymized
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Confusable-character skeleton for handles (must match utils.HandleSkeleton)
+CREATE OR REPLACE FUNCTION public.handle_skeleton(h TEXT)
+RETURNS TEXT AS $$
+    SELECT trim(both '_' FROM regexp_replace(
+        replace(replace(translate(lower(h), '01345789i', 'oleastbgl'), 'rn', 'm'), 'vv', 'w'),
+        '_+', '_', 'g'
+    ));
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Handle history (former handles redirect to the current one)
+CREATE TABLE IF NOT EXISTS public.handle_history (
+    handle TEXT PRIMARY KEY, -- lower-cased former handle
+    skeleton TEXT NOT NULL,
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    changed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 -- Posts table
 CREATE TABLE IF NOT EXISTS public.posts (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -43,6 +75,8 @@ CREATE TABLE IF NOT EXISTS public.posts (
     is_hot BOOLEAN DEFAULT FALSE,
     location TEXT,
     content_hash TEXT, -- For duplicate detection
+    accepted_comment_id UUID, -- Comment accepted as the answer (FK added after comments)
+    accepted_at TIMESTAMPTZ,
     published_at TIMESTAMPTZ DEFAULT NOW(),
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW()
@@ -60,6 +94,10 @@ CREATE TABLE IF NOT EXISTS public.comments (
     updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+ALTER TABLE public.posts DROP CONSTRAINT IF EXISTS posts_accepted_comment_id_fkey;
+ALTER TABLE public.posts ADD CONSTRAINT posts_accepted_comment_id_fkey
+    FOREIGN KEY (accepted_comment_id) REFERENCES public.comments(id) ON DELETE SET NULL;
+
 -- Likes table (for both posts and comments)
 CREATE TABLE IF NOT EXISTS public.likes (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -67,7 +105,7 @@ CREATE TABLE IF NOT EXISTS public.likes (
     comment_id UUID REFERENCES public.comments(id) ON DELETE CASCADE,
     user_id UUID REFERENCES public.users(id) ON DELETE CASCADE,
     created_at TIMESTAMPTZ DEFAULT NOW(),
-    CHECK ((post_id IS NOT NULL AND comment_id IS NULL) OR (post_id IS NULL AND comment_id IS NOT NULL))
+    CONSTRAINT reports_check CHECK (post_id IS NULL OR comment_id IS NULL)
 );
 
 -- Bookmarks table
@@ -88,6 +126,7 @@ CREATE TABLE IF NOT EXISTS public.media (
     type TEXT NOT NULL,
     name TEXT,
     size INTEGER,
+    moderation_status TEXT NOT NULL DEFAULT 'approved' CHECK (moderation_status IN ('pending', 'approved')), -- New accounts' uploads await review
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
@@ -130,7 +169,7 @@ CREATE TABLE IF NOT EXISTS public.otps (
     user_id UUID REFERENCES public.users(id) ON DELETE CASCADE,
     email TEXT NOT NULL,
     code TEXT NOT NULL,
-    type TEXT NOT NULL CHECK (type IN ('2fa', 'password_reset')),
+    type TEXT NOT NULL CHECK (type IN ('2fa', 'password_reset', 'email_change')),
     expires_at TIMESTAMPTZ NOT NULL,
     used BOOLEAN DEFAULT FALSE,
     attempts INTEGER DEFAULT 0,
@@ -170,17 +209,265 @@ CREATE TABLE IF NOT EXISTS public.follows (
 CREATE INDEX IF NOT EXISTS idx_follows_follower ON public.follows(follower_id);
 CREATE INDEX IF NOT EXISTS idx_follows_following ON public.follows(following_id);
 
+-- Follow suggestions (precomputed daily by SuggestionService)
+CREATE TABLE IF NOT EXISTS public.follow_suggestions (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    candidate_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    reason TEXT NOT NULL, -- mutual_follows, shared_interests, verified, active
+    mutual_count INTEGER DEFAULT 0,
+    computed_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, candidate_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_user_score ON public.follow_suggestions(user_id, score DESC);
+
+-- User blocks (mutual invisibility) and mutes (one-way hide)
+CREATE TABLE IF NOT EXISTS public.user_blocks (
+    blocker_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    blocked_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id != blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON public.user_blocks(blocked_id);
+
+CREATE TABLE IF NOT EXISTS public.user_mutes (
+    muter_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    muted_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id != muted_id)
+);
+
+-- Data export (takeout) jobs
+CREATE TABLE IF NOT EXISTS public.data_exports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'ready', 'failed', 'expired')),
+    object_path TEXT,
+    size_bytes BIGINT,
+    error TEXT,
+    attempts INTEGER DEFAULT 0,
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON public.data_exports(user_id, requested_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON public.data_exports(status, requested_at);
+-- At most one export in progress per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_in_progress ON public.data_exports(user_id)
+    WHERE status IN ('pending', 'processing');
+
+-- Reputation ledger (one row per reputation-changing event)
+CREATE TABLE IF NOT EXISTS public.reputation_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    event_type TEXT NOT NULL,
+    points INTEGER NOT NULL,
+    source_type TEXT NOT NULL,
+    source_id UUID NOT NULL,
+    actor_id UUID NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reputation_events_unique ON public.reputation_events(user_id, event_type, source_id, actor_id);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_user ON public.reputation_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_created ON public.reputation_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_source ON public.reputation_events(source_id);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_actor ON public.reputation_events(actor_id);
+
+-- Badges earned by users (rules are defined in the server)
+CREATE TABLE IF NOT EXISTS public.user_badges (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    badge_id TEXT NOT NULL,
+    awarded_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, badge_id)
+);
+
+-- Per-user privacy settings (a missing row means the defaults)
+CREATE TABLE IF NOT EXISTS public.user_privacy_settings (
+    user_id UUID PRIMARY KEY REFERENCES public.users(id) ON DELETE CASCADE,
+    profile_visibility TEXT NOT NULL DEFAULT 'public' CHECK (profile_visibility IN ('public', 'members', 'followers')),
+    searchable BOOLEAN NOT NULL DEFAULT true,
+    comment_permission TEXT NOT NULL DEFAULT 'everyone' CHECK (comment_permission IN ('everyone', 'followers', 'nobody')),
+    mention_permission TEXT NOT NULL DEFAULT 'everyone' CHECK (mention_permission IN ('everyone', 'followers', 'nobody')),
+    message_permission TEXT NOT NULL DEFAULT 'everyone' CHECK (message_permission IN ('everyone', 'followers', 'nobody')),
+    show_likes BOOLEAN NOT NULL DEFAULT true,
+    show_bookmarks BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_privacy_settings_hidden ON public.user_privacy_settings(user_id)
+    WHERE searchable = false OR profile_visibility <> 'public';
+
+-- Email changes (confirmed on both addresses; revertible from the old one for 7 days)
+CREATE TABLE IF NOT EXISTS public.email_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    old_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled', 'reverted')),
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    revert_expires_at TIMESTAMPTZ,
+    reverted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user ON public.email_changes(user_id, requested_at DESC);
+
+-- In-app notifications (rows sharing a group_key are shown as one entry)
+CREATE TABLE IF NOT EXISTS public.notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('like', 'comment', 'reply', 'mention', 'follow')),
+    actor_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    post_id UUID REFERENCES public.posts(id) ON DELETE CASCADE,
+    comment_id UUID REFERENCES public.comments(id) ON DELETE CASCADE,
+    group_key TEXT NOT NULL,
+    read_at TIMESTAMPTZ,
+    emailed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, group_key, actor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON public.notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON public.notifications(user_id, group_key) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notifications_unemailed ON public.notifications(created_at)
+    WHERE emailed_at IS NULL AND read_at IS NULL;
+
+-- Notification delivery channel per user and type (server defaults apply when missing)
+CREATE TABLE IF NOT EXISTS public.notification_preferences (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('like', 'comment', 'reply', 'mention', 'follow', 'top_posts')),
+    channel TEXT NOT NULL CHECK (channel IN ('in_app', 'email', 'daily', 'weekly', 'off')),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, type)
+);
+
+-- Last daily/weekly digest per user
+CREATE TABLE IF NOT EXISTS public.notification_digests (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    last_sent_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, frequency)
+);
+
+-- Direct message conversations (direct_key is set for 1:1 conversations only)
+CREATE TABLE IF NOT EXISTS public.conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    is_group BOOLEAN NOT NULL DEFAULT false,
+    title TEXT,
+    direct_key TEXT UNIQUE,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_message_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS public.conversation_participants (
+    conversation_id UUID REFERENCES public.conversations(id) ON DELETE CASCADE NOT NULL,
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    left_at TIMESTAMPTZ,
+    last_read_at TIMESTAMPTZ,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user ON public.conversation_participants(user_id)
+    WHERE left_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS public.messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID REFERENCES public.conversations(id) ON DELETE CASCADE NOT NULL,
+    sender_id UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    content TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created ON public.messages(conversation_id, created_at DESC, id DESC);
+
+-- Message attachments (uploaded through /media/upload)
+ALTER TABLE public.media ADD COLUMN IF NOT EXISTS message_id UUID REFERENCES public.messages(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_media_message ON public.media(message_id) WHERE message_id IS NOT NULL;
+
+-- Outgoing webhook endpoints (payloads signed with secret)
+CREATE TABLE IF NOT EXISTS public.webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    description TEXT,
+    is_active BOOLEAN DEFAULT true,
+    consecutive_failures INTEGER DEFAULT 0,
+    disabled_at TIMESTAMPTZ,
+    disabled_reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_events ON public.webhooks USING gin (events) WHERE is_active = true;
+
+-- Webhook delivery queue and log
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID REFERENCES public.webhooks(id) ON DELETE CASCADE NOT NULL,
+    event_id UUID NOT NULL,
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+    last_attempt_at TIMESTAMPTZ,
+    response_status INTEGER,
+    response_body TEXT,
+    error TEXT,
+    duration_ms INTEGER,
+    redelivery_of UUID REFERENCES public.webhook_deliveries(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON public.webhook_deliveries(next_attempt_at)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON public.webhook_deliveries(webhook_id, created_at DESC);
+
+-- Account deletion requests (kept after the user is gone)
+CREATE TABLE IF NOT EXISTS public.account_deletions (
+    user_id UUID PRIMARY KEY,
+    mode TEXT NOT NULL CHECK (mode IN ('delete', 'anonymize')),
+    status TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'processing', 'completed', 'cancelled', 'failed')), -- failed: gave up after repeated errors
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    attempts INTEGER DEFAULT 0,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_due ON public.account_deletions(status, scheduled_for);
+
 -- Reports table (for content reporting)
 CREATE TABLE IF NOT EXISTS public.reports (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     reporter_id UUID REFERENCES public.users(id) ON DELETE CASCADE,
-    post_id UUID REFERENCES public.posts(id) ON DELETE CASCADE,
-    comment_id UUID REFERENCES public.comments(id) ON DELETE CASCADE,
+    post_id UUID REFERENCES public.posts(id) ON DELETE SET NULL,
+    comment_id UUID REFERENCES public.comments(id) ON DELETE SET NULL,
+    target_type TEXT CHECK (target_type IN ('post', 'comment')), -- Still set once the content is removed
+    target_id UUID,
     reason TEXT NOT NULL,
     status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'reviewed', 'resolved', 'dismissed')),
-    snapshot JSONB,
+    snapshot JSONB, -- The reported content at report time
+    actions TEXT[] NOT NULL DEFAULT '{}', -- remove, warn, suspend
     admin_notes TEXT,
     resolution_summary TEXT,
+    spam BOOLEAN NOT NULL DEFAULT false, -- Removed as spam; the spam classifier trains on these
     reporter_notified_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
@@ -188,11 +475,185 @@ CREATE TABLE IF NOT EXISTS public.reports (
     reviewed_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
     resolved_at TIMESTAMPTZ,
     resolved_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
-    CHECK ((post_id IS NOT NULL AND comment_id IS NULL) OR (post_id IS NULL AND comment_id IS NOT NULL))
+    CHECK (post_id IS NULL OR comment_id IS NULL) -- Both are NULL once the content is removed
 );
 
 CREATE INDEX IF NOT EXISTS idx_reports_status ON public.reports(status);
 CREATE INDEX IF NOT EXISTS idx_reports_created ON public.reports(created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_reporter_target ON public.reports(reporter_id, target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_reports_open_target ON public.reports(target_type, target_id)
+    WHERE status IN ('pending', 'reviewed');
+
+-- Account suspensions. 'full' also sets users.is_active = false; expires_at
+-- NULL means until lifted. At most one active (unlifted) row per user.
+CREATE TABLE IF NOT EXISTS public.user_suspensions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    scope TEXT NOT NULL CHECK (scope IN ('read_only', 'full')),
+    reason TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    report_id UUID REFERENCES public.reports(id) ON DELETE SET NULL,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    lifted_at TIMESTAMPTZ,
+    lifted_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    lift_reason TEXT -- 'expired' when lifted by the expiry job, 'appeal' when an appeal was accepted
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_suspensions_active ON public.user_suspensions(user_id)
+    WHERE lifted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_expiry ON public.user_suspensions(expires_at)
+    WHERE lifted_at IS NULL AND expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user ON public.user_suspensions(user_id, created_at DESC);
+
+-- One appeal per action. action_id is a user_suspensions or reports ID but
+-- has no foreign key, so appeals outlive the action; action keeps what the
+-- user saw when they appealed.
+CREATE TABLE IF NOT EXISTS public.appeals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    action_type TEXT NOT NULL CHECK (action_type IN ('suspension', 'report')),
+    action_id UUID NOT NULL,
+    action JSONB NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'denied')),
+    decision_note TEXT,
+    decided_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (action_type, action_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appeals_status ON public.appeals(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_appeals_user ON public.appeals(user_id, created_at DESC);
+
+-- Admin-managed filter rules checked on posts, comments and profile
+-- updates. domain_allow rules exempt a domain from domain_block rules and
+-- have no action.
+CREATE TABLE IF NOT EXISTS public.content_filter_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('word', 'regex', 'domain_block', 'domain_allow')),
+    pattern TEXT NOT NULL,
+    action TEXT CHECK (action IN ('reject', 'hold', 'flag', 'mask')),
+    applies_to TEXT[] NOT NULL DEFAULT '{post,comment,profile}',
+    description TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((kind = 'domain_allow') = (action IS NULL))
+);
+
+-- Writes held for review; payload is the request replayed on approval
+CREATE TABLE IF NOT EXISTS public.moderation_queue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('post', 'post_update', 'comment', 'comment_update', 'profile', 'media')),
+    source TEXT NOT NULL DEFAULT 'filter' CHECK (source IN ('filter', 'trust', 'classifier')), -- Held by a filter rule, the author's trust level or the spam classifier
+    target_id UUID, -- Post commented on, post or comment being edited, or upload
+    payload JSONB NOT NULL,
+    reason TEXT NOT NULL,
+    rule_ids UUID[] NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMPTZ,
+    reviewed_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    review_note TEXT,
+    spam BOOLEAN NOT NULL DEFAULT false, -- Rejected as spam; the spam classifier trains on these
+    published_id UUID -- The post or comment created on approval
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_status ON public.moderation_queue(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_user ON public.moderation_queue(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_source ON public.moderation_queue(source, status, created_at);
+
+-- Trained spam classifier models, newest used. token_counts maps each token
+-- to how many spam and ham examples contained it.
+CREATE TABLE IF NOT EXISTS public.spam_models (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    spam_examples INTEGER NOT NULL,
+    ham_examples INTEGER NOT NULL,
+    token_counts JSONB NOT NULL,
+    evaluation JSONB NOT NULL,
+    misclassifications JSONB NOT NULL DEFAULT '[]', -- Training examples it got wrong when left out
+    trained_by UUID REFERENCES public.users(id) ON DELETE SET NULL, -- NULL for scheduled retraining
+    trained_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_spam_models_trained ON public.spam_models(trained_at DESC);
+
+-- Append-only, hash-chained audit log of admin actions (no foreign keys so
+-- entries outlive the accounts they mention)
+CREATE TABLE IF NOT EXISTS public.admin_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID,
+    actor_role TEXT,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    reason TEXT,
+    ip_address TEXT,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    prev_hash TEXT NOT NULL, -- hash of the previous entry
+    hash TEXT NOT NULL UNIQUE -- sha256 over this entry and prev_hash
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor ON public.admin_audit_log(actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_action ON public.admin_audit_log(action, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target ON public.admin_audit_log(target_type, target_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created ON public.admin_audit_log(created_at);
+
+CREATE OR REPLACE FUNCTION public.reject_admin_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS admin_audit_log_append_only ON public.admin_audit_log;
+CREATE TRIGGER admin_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON public.admin_audit_log
+    FOR EACH ROW EXECUTE FUNCTION public.reject_admin_audit_log_change();
+
+DROP TRIGGER IF EXISTS admin_audit_log_no_truncate ON public.admin_audit_log;
+CREATE TRIGGER admin_audit_log_no_truncate
+    BEFORE TRUNCATE ON public.admin_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION public.reject_admin_audit_log_change();
+
+-- Super admins' bulk operations. targets is resolved when the job starts
+-- and processed counts how many of them have been acted on, so an
+-- interrupted job resumes where it stopped. The actor columns are what
+-- every audit entry the job writes is recorded with.
+CREATE TABLE IF NOT EXISTS public.bulk_operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('suspend_users', 'remove_content', 'resolve_reports', 'purge')),
+    params JSONB NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT false,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
+    targets JSONB,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    succeeded INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    failures JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    actor_role TEXT,
+    ip_address TEXT,
+    request_id TEXT,
+    audit_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_status ON public.bulk_operations(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_created ON public.bulk_operations(created_at DESC);
 
 -- Counters table (for efficient counting)
 CREATE TABLE IF NOT EXISTS public.counters (
@@ -205,7 +666,14 @@ CREATE TABLE IF NOT EXISTS public.counters (
 CREATE INDEX IF NOT EXISTS idx_users_email ON public.users(email);
 CREATE INDEX IF NOT EXISTS idx_users_email_lower ON public.users(LOWER(email));
 CREATE INDEX IF NOT EXISTS idx_users_role ON public.users(role);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle_lower ON public.users(LOWER(handle));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle_skeleton ON public.users(handle_skeleton);
+CREATE INDEX IF NOT EXISTS idx_handle_history_user ON public.handle_history(user_id);
+CREATE INDEX IF NOT EXISTS idx_handle_history_skeleton ON public.handle_history(skeleton);
 CREATE INDEX IF NOT EXISTS idx_users_created ON public.users(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_users_reputation ON public.users(reputation DESC);
+CREATE INDEX IF NOT EXISTS idx_users_unverified ON public.users(created_at) WHERE email_verified_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_shadow_banned ON public.users(shadow_banned_at DESC) WHERE shadow_banned_at IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_posts_author ON public.posts(author_id);
 CREATE INDEX IF NOT EXISTS idx_posts_author_created ON public.posts(author_id, created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_posts_category ON public.posts(category);
@@ -220,8 +688,12 @@ CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_post_user ON public.likes(post_id, u
 CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_comment_user ON public.likes(comment_id, user_id) WHERE comment_id IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_bookmarks_post_user ON public.bookmarks(post_id, user_id);
 CREATE INDEX IF NOT EXISTS idx_bookmarks_user ON public.bookmarks(user_id);
+CREATE INDEX IF NOT EXISTS idx_likes_user_created ON public.likes(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_tags ON public.posts USING gin (tags);
 CREATE INDEX IF NOT EXISTS idx_sessions_user ON public.sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_sessions_expires ON public.sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_ip ON public.sessions(ip_address);
+CREATE INDEX IF NOT EXISTS idx_security_events_ip ON public.security_events(ip_address);
 CREATE INDEX IF NOT EXISTS idx_otps_email_type ON public.otps(email, type);
 CREATE INDEX IF NOT EXISTS idx_otps_expires ON public.otps(expires_at);
 CREATE INDEX IF NOT EXISTS idx_otps_user_type ON public.otps(user_id, type);
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+	userService    *services.UserService
+}
+
+func NewAccountHandler(accountService *services.AccountService, db *sql.DB) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		userService:    services.NewUserService(db),
+	}
+}
+
+// DeleteAccount handles DELETE /api/v1/users/me
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := h.accountService.Reauthenticate(r.Context(), user, req.Password, req.Code); err != nil {
+		if errors.Is(err, services.ErrReauthRequired) {
+			respondWithError(w, r, http.StatusBadRequest, "Confirm with your password, or a verification code for social logins")
+			return
+		}
+		respondWithError(w, r, http.StatusUnauthorized, "Invalid password or verification code")
+		return
+	}
+
+	deletion, err := h.accountService.RequestDeletion(r.Context(), user, req.Mode)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidDeletionMode):
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrLastSuperAdmin):
+			respondWithError(w, r, http.StatusConflict, "Cannot delete the last super admin")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to schedule account deletion")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusAccepted, map[string]interface{}{
+		"message":  "Your account is scheduled for deletion. Log in before the scheduled date to cancel.",
+		"deletion": deletion,
+	})
+}
+
+// GetDeletion handles GET /api/v1/users/me/deletion
+func (h *AccountHandler) GetDeletion(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	deletion, err := h.accountService.GetDeletion(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNoDeletionScheduled) {
+			respondWithError(w, r, http.StatusNotFound, "No account deletion is scheduled")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get account deletion")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, deletion)
+}
+
+// CancelDeletion handles DELETE /api/v1/users/me/deletion
+func (h *AccountHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	cancelled, err := h.accountService.CancelDeletion(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to cancel account deletion")
+		return
+	}
+	if !cancelled {
+		respondWithError(w, r, http.StatusNotFound, "No account deletion is scheduled")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{
+		"message": "Account deletion cancelled",
+	})
+}
+
+// ChangeEmail handles POST /api/v1/users/me/email. A code is sent to both
+// the current and the new address; confirm with POST /users/me/email/confirm.
+func (h *AccountHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.Provider == "" || user.Provider == "email" {
+		if err := h.accountService.Reauthenticate(r.Context(), user, req.Password, ""); err != nil {
+			if errors.Is(err, services.ErrReauthRequired) {
+				respondWithError(w, r, http.StatusBadRequest, "Confirm with your password")
+				return
+			}
+			respondWithError(w, r, http.StatusUnauthorized, "Invalid password")
+			return
+		}
+	}
+
+	change, err := h.accountService.RequestEmailChange(r.Context(), user, req.NewEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailManagedByProvider):
+			respondWithError(w, r, http.StatusForbidden, "Your email is managed by your sign-in provider")
+		case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrEmailUnchanged):
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrEmailTaken):
+			respondWithError(w, r, http.StatusConflict, "That email is already in use")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to start email change")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusAccepted, map[string]interface{}{
+		"message": "We sent a verification code to your current and your new email address",
+		"change":  change,
+	})
+}
+
+// ConfirmEmailChange handles POST /api/v1/users/me/email/confirm
+func (h *AccountHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.ConfirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	change, err := h.accountService.ConfirmEmailChange(r.Context(), user, req.OldCode, req.NewCode, req.RefreshToken, accessToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoEmailChangePending):
+			respondWithError(w, r, http.StatusNotFound, "No email change is pending. Request a new one.")
+		case errors.Is(err, services.ErrEmailChangeCode):
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrEmailTaken):
+			respondWithError(w, r, http.StatusConflict, "That email is already in use")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to change email")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{
+		"message": "Your email address has been changed. Other sessions have been signed out.",
+		"change":  change,
+	})
+}
+
+// ConfirmEmailChangeRevert handles GET /api/v1/email-changes/{id}/revert?expires=&sig=.
+// The link is signed and emailed to the previous address, so no session is
+// needed. Opening it only asks for confirmation; the page posts back to
+// RevertEmailChange.
+func (h *AccountHandler) ConfirmEmailChangeRevert(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	err := h.accountService.CheckEmailChangeRevert(r.Context(), mux.Vars(r)["id"], query.Get("expires"), query.Get("sig"))
+	if err != nil {
+		if errors.Is(err, services.ErrRevertUnavailable) {
+			respondToLink(w, r, http.StatusGone, "Link expired", "This link is invalid or has expired.")
+			return
+		}
+		respondToLink(w, r, http.StatusInternalServerError, "Something went wrong", "Please try again later.")
+		return
+	}
+
+	respondWithLinkPage(w, r, http.StatusOK, "Restore your old email address?",
+		"This restores the email address your account had before the change, signs you out everywhere and clears your password, so you'll reset it to sign in again.",
+		"Restore my email address")
+}
+
+// RevertEmailChange handles POST /api/v1/email-changes/{id}/revert?expires=&sig=
+func (h *AccountHandler) RevertEmailChange(w http.ResponseWriter, r *http.Request) {
+	changeID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	if err := h.accountService.RevertEmailChange(r.Context(), changeID, query.Get("expires"), query.Get("sig")); err != nil {
+		switch {
+		case errors.Is(err, services.ErrRevertUnavailable):
+			respondToLink(w, r, http.StatusGone, "Link expired", "This link is invalid or has expired")
+		case errors.Is(err, services.ErrEmailTaken):
+			respondToLink(w, r, http.StatusConflict, "Email address in use", "Your old email address is now used by another account. Contact support.")
+		default:
+			respondToLink(w, r, http.StatusInternalServerError, "Something went wrong", "Failed to restore your email address")
+		}
+		return
+	}
+
+	respondToLink(w, r, http.StatusOK, "Email address restored",
+		"Your old email address has been restored, all sessions were signed out and your password was cleared. Reset your password to sign in again.")
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AppealHandler struct {
+	appealService *services.AppealService
+}
+
+func NewAppealHandler(appealService *services.AppealService) *AppealHandler {
+	return &AppealHandler{appealService: appealService}
+}
+
+// GetAppealableActions handles GET /api/v1/users/me/appealable
+func (h *AppealHandler) GetAppealableActions(w http.ResponseWriter, r *http.Request) {
+	actions, err := h.appealService.ListAppealable(r.Context(), middleware.GetUserID(r.Context()))
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get appealable actions")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, actions)
+}
+
+// CreateAppeal handles POST /api/v1/users/me/appeals
+func (h *AppealHandler) CreateAppeal(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAppealRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	appeal, err := h.appealService.CreateAppeal(r.Context(), middleware.GetUserID(r.Context()), &req)
+	if err != nil {
+		if status, ok := appealErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create appeal")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, appeal)
+}
+
+// GetMyAppeals handles GET /api/v1/users/me/appeals
+func (h *AppealHandler) GetMyAppeals(w http.ResponseWriter, r *http.Request) {
+	appeals, err := h.appealService.ListMyAppeals(r.Context(), middleware.GetUserID(r.Context()))
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get appeals")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, appeals)
+}
+
+// GetAppeals handles GET /api/v1/admin/appeals?status=&limit=&offset= (Admin only)
+func (h *AppealHandler) GetAppeals(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	appeals, err := h.appealService.ListAppeals(r.Context(), query.Get("status"), limit, offset)
+	if err != nil {
+		if status, ok := appealErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get appeals")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, appeals)
+}
+
+// GetAppeal handles GET /api/v1/admin/appeals/{id} (Admin only)
+func (h *AppealHandler) GetAppeal(w http.ResponseWriter, r *http.Request) {
+	appeal, err := h.appealService.GetAppeal(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if status, ok := appealErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get appeal")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, appeal)
+}
+
+// AcceptAppeal handles POST /api/v1/admin/appeals/{id}/accept (Admin only)
+func (h *AppealHandler) AcceptAppeal(w http.ResponseWriter, r *http.Request) {
+	h.decideAppeal(w, r, h.appealService.Accept, "Failed to accept appeal")
+}
+
+// DenyAppeal handles POST /api/v1/admin/appeals/{id}/deny (Admin only)
+func (h *AppealHandler) DenyAppeal(w http.ResponseWriter, r *http.Request) {
+	h.decideAppeal(w, r, h.appealService.Deny, "Failed to deny appeal")
+}
+
+func (h *AppealHandler) decideAppeal(w http.ResponseWriter, r *http.Request,
+	decide func(ctx context.Context, appealID, adminID, note string) (*models.Appeal, error), failure string) {
+	// The body is optional; it only carries a note
+	var req models.DecideAppealRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	appeal, err := decide(r.Context(), mux.Vars(r)["id"], middleware.GetUserID(r.Context()), req.Note)
+	if err != nil {
+		if status, ok := appealErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, failure)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, appeal)
+}
+
+func appealErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrAppealNotFound), errors.Is(err, services.ErrAppealActionNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrAlreadyAppealed), errors.Is(err, services.ErrAppealClosed):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrInvalidAppealAction), errors.Is(err, services.ErrInvalidAppealStatus),
+		errors.Is(err, services.ErrInvalidAppealMessage), errors.Is(err, services.ErrInvalidAppealNote):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tech-bant-community/server/services"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(db *sql.DB) *AuditHandler {
+	return &AuditHandler{auditService: services.NewAuditService(db)}
+}
+
+// GetAuditLog handles GET /api/v1/admin/audit?actor=&action=&target_type=&target_id=&from=&to=&cursor=&limit=
+// (Super Admin only). from and to are RFC 3339 times.
+func (h *AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.AuditFilter{
+		ActorID:    query.Get("actor"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid from time")
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid to time")
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	page, err := h.auditService.List(r.Context(), filter, query.Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get audit log")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, page)
+}
+
+// VerifyAuditLog handles GET /api/v1/admin/audit/verify (Super Admin only).
+// It recomputes the whole hash chain and reports the first broken entry.
+func (h *AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	result, err := h.auditService.Verify(r.Context())
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to verify audit log")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, result)
+}
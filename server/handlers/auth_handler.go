@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 
@@ -12,10 +13,11 @@ import (
 )
 
 type AuthHandler struct {
-	authService  *services.AuthService
-	cfg          *config.Config
-	emailService *services.EmailService
-	twoFAService *services.TwoFAService
+	authService         *services.AuthService
+	cfg                 *config.Config
+	emailService        *services.EmailService
+	twoFAService        *services.TwoFAService
+	verificationService *services.VerificationService
 }
 
 func NewAuthHandler(cfg *config.Config, emailService *services.EmailService, twoFAService *services.TwoFAService) *AuthHandler {
@@ -42,6 +44,11 @@ func (h *AuthHandler) SetAuthService(authService *services.AuthService) {
 	h.authService = authService
 }
 
+// SetVerificationService enables sending the verification email at signup
+func (h *AuthHandler) SetVerificationService(verificationService *services.VerificationService) {
+	h.verificationService = verificationService
+}
+
 // Signup handles POST /api/v1/auth/signup
 func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	var req models.AuthRequest
@@ -59,6 +66,14 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account is usable for reading right away; posting waits until the
+	// address is verified (see middleware.RequireVerifiedEmail)
+	if h.verificationService != nil {
+		if err := h.verificationService.SendVerification(r.Context(), response.User); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", response.User.ID, err)
+		}
+	}
+
 	respondWithJSON(w, r, http.StatusCreated, response)
 }
 
@@ -237,15 +252,7 @@ func (h *AuthHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Reques
 
 // Helper functions
 func getClientIP(r *http.Request) string {
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		return forwarded
-	}
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-	return r.RemoteAddr
+	return middleware.ClientIP(r)
 }
 
 func extractToken(authHeader string) string {
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/services"
+	"tech-bant-community/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type BlockHandler struct {
+	blockService *services.BlockService
+}
+
+func NewBlockHandler(db *sql.DB) *BlockHandler {
+	return &BlockHandler{
+		blockService: services.NewBlockService(db),
+	}
+}
+
+// BlockUser handles POST /api/v1/users/{id}/block
+func (h *BlockHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	h.updateList(w, r, h.blockService.BlockUser, "User blocked successfully")
+}
+
+// UnblockUser handles DELETE /api/v1/users/{id}/block
+func (h *BlockHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	h.updateList(w, r, h.blockService.UnblockUser, "User unblocked successfully")
+}
+
+// MuteUser handles POST /api/v1/users/{id}/mute
+func (h *BlockHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	h.updateList(w, r, h.blockService.MuteUser, "User muted successfully")
+}
+
+// UnmuteUser handles DELETE /api/v1/users/{id}/mute
+func (h *BlockHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	h.updateList(w, r, h.blockService.UnmuteUser, "User unmuted successfully")
+}
+
+// GetBlockedUsers handles GET /api/v1/users/me/blocks
+func (h *BlockHandler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	users, err := h.blockService.GetBlockedUsers(r.Context(), userID, limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get blocked users")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{"users": users})
+}
+
+// GetMutedUsers handles GET /api/v1/users/me/mutes
+func (h *BlockHandler) GetMutedUsers(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	users, err := h.blockService.GetMutedUsers(r.Context(), userID, limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get muted users")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{"users": users})
+}
+
+// updateList applies a block/mute change from the caller to the {id} user
+func (h *BlockHandler) updateList(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userID, targetID string) error, message string) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetID := mux.Vars(r)["id"]
+	if !utils.ValidateUserID(targetID) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := apply(r.Context(), userID, targetID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCannotBlockSelf):
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrUserNotFound):
+			respondWithError(w, r, http.StatusNotFound, "User not found")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update user list")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": message})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type BulkHandler struct {
+	bulkService *services.BulkService
+}
+
+func NewBulkHandler(bulkService *services.BulkService) *BulkHandler {
+	return &BulkHandler{bulkService: bulkService}
+}
+
+// SuspendUsers handles POST /api/v1/admin/bulk/suspend (Super admin only)
+func (h *BulkHandler) SuspendUsers(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, models.BulkKindSuspendUsers)
+}
+
+// RemoveContent handles POST /api/v1/admin/bulk/remove-content (Super admin only)
+func (h *BulkHandler) RemoveContent(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, models.BulkKindRemoveContent)
+}
+
+// ResolveReports handles POST /api/v1/admin/bulk/resolve-reports (Super admin only)
+func (h *BulkHandler) ResolveReports(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, models.BulkKindResolveReports)
+}
+
+// Purge handles POST /api/v1/admin/bulk/purge (Super admin only)
+func (h *BulkHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	h.start(w, r, models.BulkKindPurge)
+}
+
+// start queues an operation of the given kind; it runs in the background
+func (h *BulkHandler) start(w http.ResponseWriter, r *http.Request, kind string) {
+	var req models.BulkOperationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Kind = kind
+
+	op, err := h.bulkService.Start(r.Context(), middleware.GetUserID(r.Context()), &req)
+	if err != nil {
+		if status, ok := bulkErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to start bulk operation")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusAccepted, op)
+}
+
+// GetOperations handles GET /api/v1/admin/bulk?limit=&offset= (Super admin only)
+func (h *BulkHandler) GetOperations(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	ops, err := h.bulkService.ListOperations(r.Context(), limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get bulk operations")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, ops)
+}
+
+// GetOperation handles GET /api/v1/admin/bulk/{id} (Super admin only)
+func (h *BulkHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	op, err := h.bulkService.GetOperation(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if status, ok := bulkErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get bulk operation")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, op)
+}
+
+// CancelOperation handles POST /api/v1/admin/bulk/{id}/cancel (Super admin only)
+func (h *BulkHandler) CancelOperation(w http.ResponseWriter, r *http.Request) {
+	op, err := h.bulkService.Cancel(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if status, ok := bulkErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to cancel bulk operation")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, op)
+}
+
+func bulkErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrBulkOperationNotFound), errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrBulkOperationFinished):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrInvalidBulkKind), errors.Is(err, services.ErrInvalidBulkUsers),
+		errors.Is(err, services.ErrInvalidBulkSince), errors.Is(err, services.ErrInvalidBulkStatus),
+		errors.Is(err, services.ErrInvalidBulkSummary), errors.Is(err, services.ErrInvalidBulkIP),
+		errors.Is(err, services.ErrInvalidBulkCriteria), errors.Is(err, services.ErrInvalidBulkWindow),
+		errors.Is(err, services.ErrInvalidSuspensionScope), errors.Is(err, services.ErrInvalidSuspensionReason),
+		errors.Is(err, services.ErrInvalidSuspensionDuration):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
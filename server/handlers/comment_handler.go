@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -40,10 +42,24 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Note: Nested comments (parent_id) support will be added later
-	// For now, CreateComment doesn't support parentID parameter
+	// Set parentId to reply to another comment on the post
 	comment, err := h.commentService.CreateComment(r.Context(), userID, postID, &req)
 	if err != nil {
+		if respondWithFilterResult(w, r, err) {
+			return
+		}
+		if errors.Is(err, services.ErrParentCommentNotFound) {
+			respondWithError(w, r, http.StatusBadRequest, "The comment you are replying to does not exist on this post")
+			return
+		}
+		if errors.Is(err, services.ErrUserBlocked) {
+			respondWithError(w, r, http.StatusForbidden, "You cannot comment on this post")
+			return
+		}
+		if errors.Is(err, services.ErrCommentsRestricted) {
+			respondWithError(w, r, http.StatusForbidden, "The author has limited who can comment on this post")
+			return
+		}
 		respondWithError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -65,7 +81,8 @@ func (h *CommentHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	comments, err := h.commentService.GetComments(r.Context(), postID, limit, offset)
+	viewerID := middleware.GetUserID(r.Context())
+	comments, err := h.commentService.GetComments(r.Context(), viewerID, postID, limit, offset)
 	if err != nil {
 		respondWithError(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -112,6 +129,9 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 
 	comment, err := h.commentService.UpdateComment(r.Context(), userID, commentID, &req)
 	if err != nil {
+		if respondWithFilterResult(w, r, err) {
+			return
+		}
 		if err.Error() == "unauthorized: you can only update your own comments" {
 			respondWithError(w, r, http.StatusForbidden, err.Error())
 			return
@@ -146,3 +166,35 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Comment deleted successfully"})
 }
+
+// AcceptAnswer handles POST /api/v1/comments/{id}/accept
+func (h *CommentHandler) AcceptAnswer(w http.ResponseWriter, r *http.Request) {
+	h.setAccepted(w, r, h.commentService.AcceptAnswer, "Answer accepted")
+}
+
+// UnacceptAnswer handles DELETE /api/v1/comments/{id}/accept
+func (h *CommentHandler) UnacceptAnswer(w http.ResponseWriter, r *http.Request) {
+	h.setAccepted(w, r, h.commentService.UnacceptAnswer, "Answer unaccepted")
+}
+
+func (h *CommentHandler) setAccepted(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, userID, commentID string) error, message string) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := action(r.Context(), userID, mux.Vars(r)["id"]); err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotPostAuthor):
+			respondWithError(w, r, http.StatusForbidden, err.Error())
+		case errors.Is(err, sql.ErrNoRows):
+			respondWithError(w, r, http.StatusNotFound, "Comment not found")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update accepted answer")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": message})
+}
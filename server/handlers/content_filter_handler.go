@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type ContentFilterHandler struct {
+	filterService *services.ContentFilterService
+	queueService  *services.ModerationQueueService
+}
+
+func NewContentFilterHandler(db *sql.DB, filterService *services.ContentFilterService, cache *services.CacheService, cfg *config.Config) *ContentFilterHandler {
+	return &ContentFilterHandler{
+		filterService: filterService,
+		queueService:  services.NewModerationQueueService(db, cache, cfg),
+	}
+}
+
+// GetFilterRules handles GET /api/v1/admin/filter-rules
+func (h *ContentFilterHandler) GetFilterRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.filterService.ListRules(r.Context())
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get filter rules")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, rules)
+}
+
+// CreateFilterRule handles POST /api/v1/admin/filter-rules
+func (h *ContentFilterHandler) CreateFilterRule(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateFilterRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.filterService.CreateRule(r.Context(), userID, &req)
+	if err != nil {
+		if status, ok := filterErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create filter rule")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, rule)
+}
+
+// UpdateFilterRule handles PUT /api/v1/admin/filter-rules/{id}
+func (h *ContentFilterHandler) UpdateFilterRule(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateFilterRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.filterService.UpdateRule(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		if status, ok := filterErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update filter rule")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, rule)
+}
+
+// DeleteFilterRule handles DELETE /api/v1/admin/filter-rules/{id}
+func (h *ContentFilterHandler) DeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+	if err := h.filterService.DeleteRule(r.Context(), mux.Vars(r)["id"]); err != nil {
+		if status, ok := filterErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete filter rule")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Filter rule deleted"})
+}
+
+// TestFilterRules handles POST /api/v1/admin/filter-rules/test. Nothing is
+// saved or published.
+func (h *ContentFilterHandler) TestFilterRules(w http.ResponseWriter, r *http.Request) {
+	var req models.FilterTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.filterService.TestRules(&req)
+	if err != nil {
+		if status, ok := filterErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to test filter rules")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, result)
+}
+
+// GetQueue handles GET /api/v1/admin/queue?status=&source=&limit=&offset=
+func (h *ContentFilterHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	items, err := h.queueService.ListQueue(r.Context(), query.Get("status"), query.Get("source"), limit, offset)
+	if err != nil {
+		if status, ok := queueErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get moderation queue")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, items)
+}
+
+// GetQueueItem handles GET /api/v1/admin/queue/{id}
+func (h *ContentFilterHandler) GetQueueItem(w http.ResponseWriter, r *http.Request) {
+	item, err := h.queueService.GetQueueItem(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if status, ok := queueErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get queue item")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, item)
+}
+
+// ApproveQueueItem handles POST /api/v1/admin/queue/{id}/approve
+func (h *ContentFilterHandler) ApproveQueueItem(w http.ResponseWriter, r *http.Request) {
+	h.reviewQueueItem(w, r, func(ctx context.Context, itemID, adminID string, req *models.ReviewQueueItemRequest) (*models.QueueItem, error) {
+		return h.queueService.Approve(ctx, itemID, adminID, req.Note)
+	})
+}
+
+// RejectQueueItem handles POST /api/v1/admin/queue/{id}/reject
+func (h *ContentFilterHandler) RejectQueueItem(w http.ResponseWriter, r *http.Request) {
+	h.reviewQueueItem(w, r, func(ctx context.Context, itemID, adminID string, req *models.ReviewQueueItemRequest) (*models.QueueItem, error) {
+		return h.queueService.Reject(ctx, itemID, adminID, req.Note, req.Spam)
+	})
+}
+
+func (h *ContentFilterHandler) reviewQueueItem(w http.ResponseWriter, r *http.Request,
+	review func(ctx context.Context, itemID, adminID string, req *models.ReviewQueueItemRequest) (*models.QueueItem, error)) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// The body is optional; it only carries a note and the spam tag
+	var req models.ReviewQueueItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	item, err := review(r.Context(), mux.Vars(r)["id"], userID, &req)
+	if err != nil {
+		if status, ok := queueErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to review queue item")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, item)
+}
+
+// respondWithFilterResult answers writes that a filter rule rejected or
+// held for review, and reports whether it did
+func respondWithFilterResult(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrContentRejected):
+		respondWithError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return true
+	case errors.Is(err, services.ErrContentHeld):
+		respondWithJSON(w, r, http.StatusAccepted, map[string]string{"message": err.Error()})
+		return true
+	}
+	return false
+}
+
+// filterErrorStatus maps filter rule errors to HTTP statuses
+func filterErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrFilterRuleNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrInvalidFilterKind), errors.Is(err, services.ErrInvalidFilterPattern),
+		errors.Is(err, services.ErrInvalidFilterAction), errors.Is(err, services.ErrInvalidFilterTarget),
+		errors.Is(err, services.ErrInvalidFilterDescription), errors.Is(err, services.ErrInvalidFilterTestText):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
+
+// queueErrorStatus maps moderation queue errors to HTTP statuses
+func queueErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrQueueItemNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrQueueItemClosed), errors.Is(err, services.ErrQueuePublishFailed):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrInvalidQueueStatus), errors.Is(err, services.ErrInvalidQueueSource),
+		errors.Is(err, services.ErrInvalidQueueNote):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// RequestExport handles POST /api/v1/users/me/export
+func (h *ExportHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := h.exportService.RequestExport(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrExportInProgress):
+			respondWithError(w, r, http.StatusConflict, "An export is already being prepared")
+		case errors.Is(err, services.ErrExportTooSoon):
+			respondWithError(w, r, http.StatusTooManyRequests, "You can request one export every 24 hours")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to request export")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusAccepted, map[string]interface{}{
+		"message": "Your export is being prepared. We'll email you a download link when it's ready.",
+		"export":  export,
+	})
+}
+
+// GetExports handles GET /api/v1/users/me/exports
+func (h *ExportHandler) GetExports(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	exports, err := h.exportService.GetExports(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get exports")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{"exports": exports})
+}
+
+// DownloadExport handles GET /api/v1/exports/{id}/download. The emailed link
+// is signed, so no session is needed; the archive itself is served by storage.
+func (h *ExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	url, err := h.exportService.ResolveDownload(r.Context(), exportID, query.Get("expires"), query.Get("sig"))
+	if err != nil {
+		if errors.Is(err, services.ErrExportUnavailable) {
+			respondWithError(w, r, http.StatusGone, "This download link is invalid or has expired")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to prepare download")
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
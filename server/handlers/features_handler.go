@@ -1,28 +1,38 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
 	"tech-bant-community/server/services"
+	"tech-bant-community/server/utils"
 
 	"github.com/gorilla/mux"
 )
 
 type FeaturesHandler struct {
-	followService *services.FollowService
-	reportService *services.ReportService
-	banService    *services.BanService
+	followService     *services.FollowService
+	reportService     *services.ReportService
+	banService        *services.BanService
+	suspensionService *services.SuspensionService
+	privacyService    *services.PrivacyService
 }
 
-func NewFeaturesHandler(db *sql.DB) *FeaturesHandler {
+func NewFeaturesHandler(db *sql.DB, emailService *services.EmailService, cache *services.CacheService, suspensionService *services.SuspensionService) *FeaturesHandler {
 	return &FeaturesHandler{
-		followService: services.NewFollowService(),
-		reportService: services.NewReportService(),
-		banService:    services.NewBanService(),
+		followService:     services.NewFollowService(),
+		reportService:     services.NewReportService(emailService, cache),
+		banService:        services.NewBanService(),
+		suspensionService: suspensionService,
+		privacyService:    services.NewPrivacyService(db),
 	}
 }
 
@@ -36,16 +46,29 @@ func (h *FeaturesHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	followingID := vars["id"]
+	if !utils.ValidateUserID(followingID) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
 
 	if err := h.followService.FollowUser(r.Context(), userID, followingID); err != nil {
-		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		switch {
+		case errors.Is(err, services.ErrCannotFollowSelf):
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrUserNotFound):
+			respondWithError(w, r, http.StatusNotFound, "User not found")
+		case errors.Is(err, services.ErrUserBlocked):
+			respondWithError(w, r, http.StatusForbidden, "You cannot follow this user")
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to follow user")
+		}
 		return
 	}
 
 	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "User followed successfully"})
 }
 
-// UnfollowUser handles POST /api/v1/users/{id}/unfollow
+// UnfollowUser handles POST /api/v1/users/{id}/unfollow and DELETE /api/v1/users/{id}/follow
 func (h *FeaturesHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
 	if userID == "" {
@@ -56,14 +79,64 @@ func (h *FeaturesHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	followingID := vars["id"]
 
+	if !utils.ValidateUserID(followingID) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
 	if err := h.followService.UnfollowUser(r.Context(), userID, followingID); err != nil {
-		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to unfollow user")
 		return
 	}
 
 	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "User unfollowed successfully"})
 }
 
+// GetFollowers handles GET /api/v1/users/{id}/followers
+func (h *FeaturesHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, h.followService.GetFollowers)
+}
+
+// GetFollowing handles GET /api/v1/users/{id}/following
+func (h *FeaturesHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, h.followService.GetFollowing)
+}
+
+// GetMutuals handles GET /api/v1/users/{id}/mutuals
+func (h *FeaturesHandler) GetMutuals(w http.ResponseWriter, r *http.Request) {
+	h.listFollows(w, r, h.followService.GetMutuals)
+}
+
+// listFollows parses the user ID and cursor/limit query params shared by the follow list endpoints
+func (h *FeaturesHandler) listFollows(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, viewerID, userID, cursor string, limit int) (*models.FollowListResponse, error)) {
+	userID := mux.Vars(r)["id"]
+	if !utils.ValidateUserID(userID) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	visible, err := h.privacyService.CanViewProfile(r.Context(), middleware.GetUserID(r.Context()), userID)
+	if err != nil || !visible {
+		respondWithError(w, r, http.StatusForbidden, "This profile is private")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	cursor := r.URL.Query().Get("cursor")
+
+	page, err := list(r.Context(), middleware.GetUserID(r.Context()), userID, cursor, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get users")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, page)
+}
+
 // ReportPost handles POST /api/v1/posts/{id}/report
 func (h *FeaturesHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
@@ -89,6 +162,10 @@ func (h *FeaturesHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.reportService.ReportPost(r.Context(), userID, postID, req.Reason); err != nil {
+		if status, ok := reportErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
 		respondWithError(w, r, http.StatusInternalServerError, "Failed to report post")
 		return
 	}
@@ -121,6 +198,10 @@ func (h *FeaturesHandler) ReportComment(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := h.reportService.ReportComment(r.Context(), userID, commentID, req.Reason); err != nil {
+		if status, ok := reportErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
 		respondWithError(w, r, http.StatusInternalServerError, "Failed to report comment")
 		return
 	}
@@ -128,30 +209,96 @@ func (h *FeaturesHandler) ReportComment(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Comment reported successfully"})
 }
 
-// BanUser handles POST /api/v1/admin/users/{id}/ban (Admin only)
+// SuspendUser handles POST /api/v1/admin/users/{id}/suspend (Admin only)
+func (h *FeaturesHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	var req models.SuspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	h.suspend(w, r, &req)
+}
+
+// BanUser handles POST /api/v1/admin/users/{id}/ban (Admin only). The older
+// endpoint took no body, so without one it suspends fully until lifted with
+// a default reason; a body is read as for /suspend.
 func (h *FeaturesHandler) BanUser(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID := vars["id"]
+	var req models.SuspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		req.Reason = defaultBanReason
+	}
 
-	if err := h.banService.BanUser(r.Context(), userID); err != nil {
-		respondWithError(w, r, http.StatusInternalServerError, "Failed to ban user")
+	h.suspend(w, r, &req)
+}
+
+// defaultBanReason is given to /ban requests without a reason
+const defaultBanReason = "Banned by an administrator"
+
+func (h *FeaturesHandler) suspend(w http.ResponseWriter, r *http.Request, req *models.SuspendUserRequest) {
+	suspension, err := h.suspensionService.Suspend(r.Context(), mux.Vars(r)["id"], middleware.GetUserID(r.Context()), req)
+	if err != nil {
+		if status, ok := suspensionErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to suspend user")
 		return
 	}
 
-	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "User banned successfully"})
+	respondWithJSON(w, r, http.StatusOK, suspension)
 }
 
-// UnbanUser handles POST /api/v1/admin/users/{id}/unban (Admin only)
-func (h *FeaturesHandler) UnbanUser(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID := vars["id"]
+// UnsuspendUser handles POST /api/v1/admin/users/{id}/unsuspend (and the
+// older /unban) (Admin only)
+func (h *FeaturesHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.suspensionService.Lift(r.Context(), mux.Vars(r)["id"], middleware.GetUserID(r.Context())); err != nil {
+		if status, ok := suspensionErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to lift suspension")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Suspension lifted"})
+}
+
+// GetUserSuspensions handles GET /api/v1/admin/users/{id}/suspensions (Admin only)
+func (h *FeaturesHandler) GetUserSuspensions(w http.ResponseWriter, r *http.Request) {
+	suspensions, err := h.suspensionService.ListSuspensions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get suspensions")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{"suspensions": suspensions})
+}
+
+// GetMySuspension handles GET /api/v1/users/me/suspension. suspension is
+// null when the account is not suspended.
+func (h *FeaturesHandler) GetMySuspension(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
-	if err := h.banService.UnbanUser(r.Context(), userID); err != nil {
-		respondWithError(w, r, http.StatusInternalServerError, "Failed to unban user")
+	suspension, err := h.suspensionService.ActiveSuspension(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get suspension")
 		return
 	}
 
-	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "User unbanned successfully"})
+	response := map[string]interface{}{"suspension": services.SuspensionForUser(suspension)}
+	if suspension != nil {
+		response["notice"] = services.SuspensionNotice(suspension)
+	}
+	respondWithJSON(w, r, http.StatusOK, response)
 }
 
 // VerifyUser handles POST /api/v1/admin/users/{id}/verify (Admin only)
@@ -160,6 +307,10 @@ func (h *FeaturesHandler) VerifyUser(w http.ResponseWriter, r *http.Request) {
 	userID := vars["id"]
 
 	if err := h.banService.VerifyUser(r.Context(), userID); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			respondWithError(w, r, http.StatusNotFound, "User not found")
+			return
+		}
 		respondWithError(w, r, http.StatusInternalServerError, "Failed to verify user")
 		return
 	}
@@ -181,6 +332,10 @@ func (h *FeaturesHandler) PromoteToAdmin(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := h.banService.PromoteToAdmin(r.Context(), userID, req.Role); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			respondWithError(w, r, http.StatusNotFound, "User not found")
+			return
+		}
 		respondWithError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -203,6 +358,21 @@ func (h *FeaturesHandler) GetReports(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{"reports": reports})
 }
 
+// GetReport handles GET /api/v1/admin/reports/{id} (Admin only)
+func (h *FeaturesHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reportService.GetReport(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if status, ok := reportErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get report")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, report)
+}
+
 // ResolveReport handles POST /api/v1/admin/reports/{id}/resolve (Admin only)
 func (h *FeaturesHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -214,23 +384,51 @@ func (h *FeaturesHandler) ResolveReport(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var req struct {
-		Status string `json:"status"` // "resolved" or "rejected"
-	}
+	var req models.ResolveReportRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.Status != "resolved" && req.Status != "rejected" {
-		respondWithError(w, r, http.StatusBadRequest, "Status must be 'resolved' or 'rejected'")
+	report, err := h.reportService.ResolveReport(r.Context(), reportID, adminID, &req)
+	if err != nil {
+		if status, ok := reportErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to resolve report")
 		return
 	}
 
-	if err := h.reportService.ResolveReport(r.Context(), reportID, req.Status, adminID); err != nil {
-		respondWithError(w, r, http.StatusInternalServerError, "Failed to resolve report")
-		return
+	respondWithJSON(w, r, http.StatusOK, report)
+}
+
+// reportErrorStatus maps report service errors to HTTP statuses
+func reportErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrReportNotFound), errors.Is(err, services.ErrReportTargetNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrAlreadyReported), errors.Is(err, services.ErrReportClosed):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrCannotReportOwnContent), errors.Is(err, services.ErrInvalidReportReason),
+		errors.Is(err, services.ErrInvalidReportStatus), errors.Is(err, services.ErrInvalidReportAction),
+		errors.Is(err, services.ErrInvalidReportSpam), errors.Is(err, services.ErrInvalidReportNote), errors.Is(err, services.ErrInvalidSuspensionScope),
+		errors.Is(err, services.ErrInvalidSuspensionDuration):
+		return http.StatusBadRequest, true
 	}
+	return 0, false
+}
 
-	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Report resolved successfully"})
+// suspensionErrorStatus maps suspension service errors to HTTP statuses
+func suspensionErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrNotSuspended):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrInvalidSuspensionScope), errors.Is(err, services.ErrInvalidSuspensionReason),
+		errors.Is(err, services.ErrInvalidSuspensionDuration):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
 }
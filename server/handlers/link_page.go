@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// linkPageTemplate is shown for signed links opened from an email. Mail
+// scanners and link prefetchers open every link in a message, so opening
+// one only shows this page; the change is made by the form's POST back to
+// the same URL.
+var linkPageTemplate = template.Must(template.New("link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>body{font-family:sans-serif;max-width:32rem;margin:4rem auto;padding:0 1rem;line-height:1.5}button{font-size:1rem;padding:.5rem 1rem}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Button}}<form method="post" action="{{.Action}}"><button type="submit">{{.Button}}</button></form>{{end}}
+</body>
+</html>
+`))
+
+type linkPage struct {
+	Title   string
+	Message string
+	Action  string
+	Button  string
+}
+
+// respondWithLinkPage renders the page for a signed email link. With a
+// button, the page posts back to the URL it was opened at.
+func respondWithLinkPage(w http.ResponseWriter, r *http.Request, code int, title, message, button string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = linkPageTemplate.Execute(w, linkPage{Title: title, Message: message, Action: r.URL.RequestURI(), Button: button})
+}
+
+// isFormPost reports whether the request is a browser form submission,
+// which gets a page back instead of JSON
+func isFormPost(r *http.Request) bool {
+	return r.Method == http.MethodPost &&
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+// respondToLink answers a signed email link: a page when opened in a
+// browser, JSON otherwise
+func respondToLink(w http.ResponseWriter, r *http.Request, code int, title, message string) {
+	if r.Method == http.MethodGet || isFormPost(r) {
+		respondWithLinkPage(w, r, code, title, message, "")
+		return
+	}
+	if code >= 400 {
+		respondWithError(w, r, code, message)
+		return
+	}
+	respondWithJSON(w, r, code, map[string]string{"message": message})
+}
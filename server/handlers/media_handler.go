@@ -113,6 +113,12 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New accounts' uploads wait for review before they can be attached
+	if media.Status == "pending" {
+		respondWithJSON(w, r, http.StatusAccepted, media)
+		return
+	}
+
 	respondWithJSON(w, r, http.StatusOK, media)
 }
 
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type MessageHandler struct {
+	messageService *services.MessageService
+}
+
+func NewMessageHandler(db *sql.DB) *MessageHandler {
+	return &MessageHandler{messageService: services.NewMessageService(db)}
+}
+
+// GetConversations handles GET /api/v1/conversations?cursor=&limit=
+func (h *MessageHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := h.messageService.ListConversations(r.Context(), userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get conversations")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, page)
+}
+
+// CreateConversation handles POST /api/v1/conversations. Starting a 1:1
+// conversation that already exists returns it.
+func (h *MessageHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	conversation, err := h.messageService.CreateConversation(r.Context(), userID, &req)
+	if err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create conversation")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, conversation)
+}
+
+// GetConversation handles GET /api/v1/conversations/{id}
+func (h *MessageHandler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	conversation, err := h.messageService.GetConversation(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, conversation)
+}
+
+// GetMessages handles GET /api/v1/conversations/{id}/messages?cursor=&limit=
+func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := h.messageService.ListMessages(r.Context(), userID, mux.Vars(r)["id"], r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get messages")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, page)
+}
+
+// SendMessage handles POST /api/v1/conversations/{id}/messages
+func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	message, err := h.messageService.SendMessage(r.Context(), userID, mux.Vars(r)["id"], &req)
+	if err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to send message")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, message)
+}
+
+// MarkRead handles POST /api/v1/conversations/{id}/read
+func (h *MessageHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.messageService.MarkRead(r.Context(), userID, mux.Vars(r)["id"]); err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to mark conversation as read")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Conversation marked as read"})
+}
+
+// LeaveConversation handles POST /api/v1/conversations/{id}/leave
+func (h *MessageHandler) LeaveConversation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.messageService.LeaveConversation(r.Context(), userID, mux.Vars(r)["id"]); err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to leave conversation")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Left conversation"})
+}
+
+// EditMessage handles PUT /api/v1/messages/{id}
+func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	message, err := h.messageService.EditMessage(r.Context(), userID, mux.Vars(r)["id"], &req)
+	if err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to edit message")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, message)
+}
+
+// DeleteMessage handles DELETE /api/v1/messages/{id}
+func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.messageService.DeleteMessage(r.Context(), userID, mux.Vars(r)["id"]); err != nil {
+		if status, ok := messageErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Message deleted"})
+}
+
+// GetUnreadCount handles GET /api/v1/messages/unread-count
+func (h *MessageHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	conversations, messages, err := h.messageService.UnreadCount(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get unread count")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]int{"conversations": conversations, "messages": messages})
+}
+
+// messageErrorStatus maps message service errors to HTTP statuses
+func messageErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrConversationNotFound), errors.Is(err, services.ErrMessageNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrCannotMessage), errors.Is(err, services.ErrNotMessageSender):
+		return http.StatusForbidden, true
+	case errors.Is(err, services.ErrInvalidConversation), errors.Is(err, services.ErrInvalidConversationTitle),
+		errors.Is(err, services.ErrInvalidMessage), errors.Is(err, services.ErrInvalidMessageMedia):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
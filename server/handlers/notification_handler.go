@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type NotificationHandler struct {
+	notificationService      *services.NotificationService
+	notificationEmailService *services.NotificationEmailService
+}
+
+func NewNotificationHandler(notificationEmailService *services.NotificationEmailService, db *sql.DB) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService:      services.NewNotificationService(db),
+		notificationEmailService: notificationEmailService,
+	}
+}
+
+// GetNotifications handles GET /api/v1/notifications?cursor=&limit=
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := h.notificationService.List(r.Context(), userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get notifications")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, page)
+}
+
+// GetUnreadCount handles GET /api/v1/notifications/unread-count
+func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	count, err := h.notificationService.UnreadCount(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get unread count")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]int{"unread": count})
+}
+
+// MarkRead handles POST /api/v1/notifications/{id}/read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	err := h.notificationService.MarkRead(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			respondWithError(w, r, http.StatusNotFound, "Notification not found")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to mark notification as read")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Notification marked as read"})
+}
+
+// MarkAllRead handles POST /api/v1/notifications/read-all
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.notificationService.MarkAllRead(r.Context(), userID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to mark notifications as read")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "All notifications marked as read"})
+}
+
+// GetPreferences handles GET /api/v1/users/me/notification-preferences
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	prefs, err := h.notificationService.GetPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get notification preferences")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, prefs)
+}
+
+// UpdatePreferences handles PUT /api/v1/users/me/notification-preferences.
+// The body maps notification types to channels; types left out keep theirs.
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var changes map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	prefs, err := h.notificationService.UpdatePreferences(r.Context(), userID, changes)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidNotificationPreference) {
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update notification preferences")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, prefs)
+}
+
+// ConfirmUnsubscribe handles GET /api/v1/unsubscribe?uid=&types=&sig=.
+// Opening the link only asks for confirmation, since mail scanners and
+// prefetchers open it too; the page posts back to Unsubscribe.
+func (h *NotificationHandler) ConfirmUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if err := h.notificationEmailService.CheckUnsubscribeLink(query.Get("uid"), query.Get("types"), query.Get("sig")); err != nil {
+		respondToLink(w, r, http.StatusBadRequest, "Invalid link", "This unsubscribe link is invalid.")
+		return
+	}
+
+	respondWithLinkPage(w, r, http.StatusOK, "Unsubscribe?",
+		"You will stop getting these emails. You can change this in your notification settings.", "Unsubscribe")
+}
+
+// Unsubscribe handles POST /api/v1/unsubscribe?uid=&types=&sig=, from the
+// confirmation page or a mail client's one-click unsubscribe (RFC 8058)
+func (h *NotificationHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	err := h.notificationEmailService.Unsubscribe(r.Context(), query.Get("uid"), query.Get("types"), query.Get("sig"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnsubscribeLinkInvalid) {
+			respondToLink(w, r, http.StatusBadRequest, "Invalid link", "This unsubscribe link is invalid")
+			return
+		}
+		respondToLink(w, r, http.StatusInternalServerError, "Something went wrong", "Failed to unsubscribe")
+		return
+	}
+
+	respondToLink(w, r, http.StatusOK, "Unsubscribed",
+		"You will no longer get these emails. You can change this in your notification settings.")
+}
@@ -17,18 +17,21 @@ import (
 )
 
 type PostHandler struct {
-	postService *services.PostService
+	postService  *services.PostService
+	blockService *services.BlockService
 }
 
 func NewPostHandler(db *sql.DB) *PostHandler {
 	return &PostHandler{
-		postService: services.NewPostService(db),
+		postService:  services.NewPostService(db),
+		blockService: services.NewBlockService(db),
 	}
 }
 
-func NewPostHandlerWithService(postService *services.PostService) *PostHandler {
+func NewPostHandlerWithService(postService *services.PostService, blockService *services.BlockService) *PostHandler {
 	return &PostHandler{
-		postService: postService,
+		postService:  postService,
+		blockService: blockService,
 	}
 }
 
@@ -89,6 +92,9 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 
 	post, err := h.postService.CreatePost(r.Context(), userID, &req)
 	if err != nil {
+		if respondWithFilterResult(w, r, err) {
+			return
+		}
 		// FIXED: Issue #17 - Sanitize error messages to prevent information leakage
 		respondWithError(w, r, http.StatusInternalServerError, "Failed to create post")
 		return
@@ -114,6 +120,17 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Blocked users can't see each other's posts
+	if blocked, err := h.blockService.IsBlockedEither(r.Context(), middleware.GetUserID(r.Context()), post.AuthorID); err != nil || blocked {
+		respondWithError(w, r, http.StatusNotFound, "Post not found")
+		return
+	}
+	// Nor can anyone but the author see a shadow-banned user's posts
+	if hidden, err := services.ShadowBanHides(r.Context(), middleware.GetUserID(r.Context()), post.AuthorID); err != nil || hidden {
+		respondWithError(w, r, http.StatusNotFound, "Post not found")
+		return
+	}
+
 	respondWithJSON(w, r, http.StatusOK, post)
 }
 
@@ -137,14 +154,15 @@ func (h *PostHandler) GetPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID := middleware.GetUserID(r.Context())
 	category := r.URL.Query().Get("category")
 	var posts []*models.Post
 	var err error
 
 	if category != "" {
-		posts, err = h.postService.GetPostsByCategory(r.Context(), category, limit, offset)
+		posts, err = h.postService.GetPostsByCategory(r.Context(), viewerID, category, limit, offset)
 	} else {
-		posts, err = h.postService.GetPosts(r.Context(), limit, offset)
+		posts, err = h.postService.GetPosts(r.Context(), viewerID, limit, offset)
 	}
 
 	if err != nil {
@@ -233,6 +251,9 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 
 	post, err := h.postService.UpdatePost(r.Context(), userID, postID, &req)
 	if err != nil {
+		if respondWithFilterResult(w, r, err) {
+			return
+		}
 		if err.Error() == "unauthorized: you can only update your own posts" {
 			respondWithError(w, r, http.StatusForbidden, err.Error())
 			return
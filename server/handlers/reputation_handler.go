@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+)
+
+type ReputationHandler struct {
+	reputationService *services.ReputationService
+	auditService      *services.AuditService
+}
+
+func NewReputationHandler(db *sql.DB) *ReputationHandler {
+	return &ReputationHandler{
+		reputationService: services.NewReputationService(db),
+		auditService:      services.NewAuditService(db),
+	}
+}
+
+// GetLeaderboard handles GET /api/v1/leaderboard?period=week|month|all
+func (h *ReputationHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "all"
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	entries, err := h.reputationService.GetLeaderboard(r.Context(), middleware.GetUserID(r.Context()), period, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPeriod) {
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get leaderboard")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{
+		"period":  period,
+		"entries": entries,
+	})
+}
+
+// RecomputeReputation handles POST /api/v1/admin/reputation/recompute.
+// The rebuild can outlast the request, so it runs in the background.
+func (h *ReputationHandler) RecomputeReputation(w http.ResponseWriter, r *http.Request) {
+	err := h.auditService.Record(r.Context(), models.AuditActionReputationRecompute, "reputation", "all", nil, nil)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to start reputation recompute")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+		defer cancel()
+		if err := h.reputationService.Recompute(ctx); err != nil {
+			log.Printf("Reputation recompute failed: %v", err)
+		}
+	}()
+
+	respondWithJSON(w, r, http.StatusAccepted, map[string]string{
+		"message": "Reputation recompute started",
+	})
+}
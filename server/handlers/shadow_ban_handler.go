@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type ShadowBanHandler struct {
+	shadowBanService *services.ShadowBanService
+}
+
+func NewShadowBanHandler(shadowBanService *services.ShadowBanService) *ShadowBanHandler {
+	return &ShadowBanHandler{shadowBanService: shadowBanService}
+}
+
+// ShadowBanUser handles POST /api/v1/admin/users/{id}/shadow-ban (Admin only)
+func (h *ShadowBanHandler) ShadowBanUser(w http.ResponseWriter, r *http.Request) {
+	var req models.ShadowBanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ban, err := h.shadowBanService.ShadowBan(r.Context(), mux.Vars(r)["id"], middleware.GetUserID(r.Context()), req.Reason)
+	if err != nil {
+		if status, ok := shadowBanErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to shadow-ban user")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, ban)
+}
+
+// UnshadowBanUser handles POST /api/v1/admin/users/{id}/unshadow-ban (Admin only)
+func (h *ShadowBanHandler) UnshadowBanUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.shadowBanService.Lift(r.Context(), mux.Vars(r)["id"]); err != nil {
+		if status, ok := shadowBanErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to lift shadow ban")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Shadow ban lifted"})
+}
+
+// GetShadowBans handles GET /api/v1/admin/shadow-bans?limit=&offset= (Admin only)
+func (h *ShadowBanHandler) GetShadowBans(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	bans, err := h.shadowBanService.ListShadowBans(r.Context(), limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get shadow bans")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, bans)
+}
+
+// GetShadowBannedContent handles GET /api/v1/admin/shadow-bans/content?userId=&limit=&offset=
+// (Admin only)
+func (h *ShadowBanHandler) GetShadowBannedContent(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	items, err := h.shadowBanService.ListContent(r.Context(), query.Get("userId"), limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get shadow-banned content")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, items)
+}
+
+// shadowBanErrorStatus maps shadow ban errors to HTTP statuses
+func shadowBanErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrAlreadyShadowBanned), errors.Is(err, services.ErrNotShadowBanned):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrCannotShadowBanAdmin):
+		return http.StatusForbidden, true
+	case errors.Is(err, services.ErrInvalidShadowBanReason):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+)
+
+type SpamClassifierHandler struct {
+	spamService *services.SpamClassifierService
+}
+
+func NewSpamClassifierHandler(spamService *services.SpamClassifierService) *SpamClassifierHandler {
+	return &SpamClassifierHandler{spamService: spamService}
+}
+
+// GetSpamModel handles GET /api/v1/admin/spam-classifier?limit= (Admin only)
+func (h *SpamClassifierHandler) GetSpamModel(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	weights, err := h.spamService.GetWeights(limit)
+	if err != nil {
+		if status, ok := spamErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get spam model")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, weights)
+}
+
+// GetMisclassifications handles
+// GET /api/v1/admin/spam-classifier/misclassifications?label=&limit=&offset= (Admin only)
+func (h *SpamClassifierHandler) GetMisclassifications(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	items, err := h.spamService.GetMisclassifications(r.Context(), query.Get("label"), limit, offset)
+	if err != nil {
+		if status, ok := spamErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get misclassifications")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, items)
+}
+
+// RetrainSpamModel handles POST /api/v1/admin/spam-classifier/retrain (Admin only)
+func (h *SpamClassifierHandler) RetrainSpamModel(w http.ResponseWriter, r *http.Request) {
+	model, err := h.spamService.Retrain(r.Context(), middleware.GetUserID(r.Context()))
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrain spam model")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, model)
+}
+
+// TestSpamClassifier handles POST /api/v1/admin/spam-classifier/test (Admin only)
+func (h *SpamClassifierHandler) TestSpamClassifier(w http.ResponseWriter, r *http.Request) {
+	var req models.SpamTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	resp, err := h.spamService.Test(&req)
+	if err != nil {
+		if status, ok := spamErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to test spam classifier")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, resp)
+}
+
+// spamErrorStatus maps spam classifier errors to HTTP statuses
+func spamErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrNoSpamModel):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrInvalidSpamLabel), errors.Is(err, services.ErrInvalidSpamTestText):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
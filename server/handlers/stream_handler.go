@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+	"tech-bant-community/server/utils"
+
+	"golang.org/x/net/websocket"
+)
+
+type StreamHandler struct {
+	streamService *services.StreamService
+	userService   *services.UserService
+	cfg           *config.Config
+}
+
+func NewStreamHandler(streamService *services.StreamService, cfg *config.Config, db *sql.DB) *StreamHandler {
+	return &StreamHandler{
+		streamService: streamService,
+		userService:   services.NewUserService(db),
+		cfg:           cfg,
+	}
+}
+
+// streamCommand is sent by WebSocket clients to change the posts they watch
+type streamCommand struct {
+	Action string   `json:"action"` // watch or unwatch
+	Posts  []string `json:"posts"`
+}
+
+// CreateTicket handles POST /api/v1/stream/ticket. Browsers can't send an
+// Authorization header with EventSource or WebSocket, so they connect with
+// ?ticket= instead.
+func (h *StreamHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ticket, expiresAt := h.streamService.IssueTicket(userID)
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{
+		"ticket":    ticket,
+		"expiresAt": expiresAt,
+	})
+}
+
+// Stream handles GET /api/v1/stream?posts=&ticket=. It pushes the caller's
+// notifications and new comments and like counts on the watched posts as
+// server-sent events, or over a WebSocket when the request asks to upgrade.
+// Reconnecting clients resume with the Last-Event-ID header (or ?lastEventId=).
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		var ok bool
+		if userID, ok = h.streamService.VerifyTicket(r.URL.Query().Get("ticket")); !ok {
+			respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+	}
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil || !user.IsActive {
+		respondWithError(w, r, http.StatusForbidden, "Account is not active")
+		return
+	}
+
+	postIDs, ok := parseStreamPosts(r.URL.Query().Get("posts"))
+	if !ok {
+		respondWithError(w, r, http.StatusBadRequest, fmt.Sprintf("posts must be up to %d comma-separated post IDs", constants.StreamMaxPosts))
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	sub, err := h.streamService.Subscribe(r.Context(), userID, postIDs)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to open stream")
+		return
+	}
+	defer sub.Close()
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// the send loops skip anything already replayed
+	backlog, err := h.streamService.Replay(r.Context(), sub, lastEventID)
+	if err != nil {
+		log.Printf("Failed to replay stream for user %s: %v", userID, err)
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		h.serveWebSocket(w, r, sub, backlog, lastEventID)
+		return
+	}
+	h.serveSSE(w, r, sub, backlog, lastEventID)
+}
+
+// serveSSE streams events as text/event-stream until the client goes away
+func (h *StreamHandler) serveSSE(w http.ResponseWriter, r *http.Request, sub *services.StreamSubscription, backlog []*models.StreamEvent, lastEventID string) {
+	rc := http.NewResponseController(w)
+	// The server's WriteTimeout would cut the stream off; lift it and bound
+	// each write instead
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	_ = rc.SetReadDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	write := func(chunk string) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(constants.StreamWriteTimeout))
+		if _, err := fmt.Fprint(w, chunk); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	send := func(event *models.StreamEvent) error {
+		if !services.StreamEventAfter(event.ID, lastEventID) {
+			return nil
+		}
+		lastEventID = event.ID
+		return write(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data))
+	}
+
+	if err := write("retry: 3000\n\n"); err != nil {
+		return
+	}
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(constants.StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(": ping\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveWebSocket streams events as JSON messages. Clients may send
+// {"action": "watch"|"unwatch", "posts": [...]} to change the watched posts.
+func (h *StreamHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, sub *services.StreamSubscription, backlog []*models.StreamEvent, lastEventID string) {
+	hijackable := unwrapHijacker(w)
+	if hijackable == nil {
+		respondWithError(w, r, http.StatusInternalServerError, "WebSockets are not supported")
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = 4096
+			// The hijacked connection keeps the server's read and write
+			// deadlines; clear them and bound each write instead
+			_ = ws.SetDeadline(time.Time{})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					var cmd streamCommand
+					if err := websocket.JSON.Receive(ws, &cmd); err != nil {
+						return
+					}
+					h.applyCommand(r, sub, cmd)
+				}
+			}()
+
+			send := func(v interface{}) error {
+				_ = ws.SetWriteDeadline(time.Now().Add(constants.StreamWriteTimeout))
+				return websocket.JSON.Send(ws, v)
+			}
+			sendEvent := func(event *models.StreamEvent) error {
+				if !services.StreamEventAfter(event.ID, lastEventID) {
+					return nil
+				}
+				lastEventID = event.ID
+				return send(event)
+			}
+
+			for _, event := range backlog {
+				if err := sendEvent(event); err != nil {
+					return
+				}
+			}
+
+			heartbeat := time.NewTicker(constants.StreamHeartbeatInterval)
+			defer heartbeat.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case event, ok := <-sub.Events:
+					if !ok {
+						return
+					}
+					if err := sendEvent(event); err != nil {
+						return
+					}
+				case <-heartbeat.C:
+					if err := send(map[string]string{"type": "ping"}); err != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(hijackable, r)
+}
+
+// applyCommand changes the posts a WebSocket subscription watches
+func (h *StreamHandler) applyCommand(r *http.Request, sub *services.StreamSubscription, cmd streamCommand) {
+	postIDs, ok := parseStreamPosts(strings.Join(cmd.Posts, ","))
+	if !ok {
+		return
+	}
+	var err error
+	switch cmd.Action {
+	case "watch":
+		err = sub.Watch(r.Context(), postIDs)
+	case "unwatch":
+		err = sub.Unwatch(r.Context(), postIDs)
+	}
+	if err != nil && !errors.Is(err, services.ErrTooManyStreamPosts) {
+		log.Printf("Failed to apply stream command %q: %v", cmd.Action, err)
+	}
+}
+
+// checkOrigin only lets allowed origins open a WebSocket. Non-browser
+// clients, which send no Origin, are authenticated by token or ticket alone.
+func (h *StreamHandler) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range h.cfg.AllowedOrigins {
+		if origin == allowed {
+			parsed, err := url.Parse(origin)
+			if err != nil {
+				return err
+			}
+			config.Origin = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+// unwrapHijacker finds the writer underneath middleware wrappers that can
+// hand over the connection
+func unwrapHijacker(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		if _, ok := w.(http.Hijacker); ok {
+			return w
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// parseStreamPosts parses a comma-separated list of post IDs
+func parseStreamPosts(raw string) ([]string, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	ids := strings.Split(raw, ",")
+	if len(ids) > constants.StreamMaxPosts {
+		return nil, false
+	}
+	for i, id := range ids {
+		ids[i] = strings.TrimSpace(id)
+		if !utils.ValidatePostID(ids[i]) {
+			return nil, false
+		}
+	}
+	return ids, true
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type TrustHandler struct {
+	trustService *services.TrustService
+}
+
+func NewTrustHandler(trustService *services.TrustService) *TrustHandler {
+	return &TrustHandler{trustService: trustService}
+}
+
+// GetMyTrust handles GET /api/v1/users/me/trust
+func (h *TrustHandler) GetMyTrust(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	h.respondWithTrust(w, r, userID)
+}
+
+// GetUserTrust handles GET /api/v1/admin/users/{id}/trust (Admin only)
+func (h *TrustHandler) GetUserTrust(w http.ResponseWriter, r *http.Request) {
+	h.respondWithTrust(w, r, mux.Vars(r)["id"])
+}
+
+func (h *TrustHandler) respondWithTrust(w http.ResponseWriter, r *http.Request, userID string) {
+	status, err := h.trustService.GetTrust(r.Context(), userID)
+	if err == services.ErrUserNotFound {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get trust level")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, status)
+}
@@ -123,13 +123,12 @@ func (h *TwoFAHandler) SendOTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find user by email
-	users, err := h.userService.SearchUsers(r.Context(), req.Email, 1)
-	if err != nil || len(users) == 0 {
+	user, err := h.userService.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
 		respondWithError(w, r, http.StatusNotFound, "User not found")
 		return
 	}
 
-	user := users[0]
 	if err := h.twoFAService.SendLoginOTP(r.Context(), h.emailService, h.rateLimitService, user.ID, user.Email); err != nil {
 		respondWithError(w, r, http.StatusInternalServerError, "Failed to send verification code")
 		return
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"tech-bant-community/server/middleware"
@@ -15,12 +18,22 @@ import (
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService       *services.UserService
+	followService     *services.FollowService
+	suggestionService *services.SuggestionService
+	blockService      *services.BlockService
+	reputationService *services.ReputationService
+	privacyService    *services.PrivacyService
 }
 
 func NewUserHandler(db *sql.DB) *UserHandler {
 	return &UserHandler{
-		userService: services.NewUserService(db),
+		userService:       services.NewUserService(db),
+		followService:     services.NewFollowService(),
+		suggestionService: services.NewSuggestionService(db),
+		blockService:      services.NewBlockService(db),
+		reputationService: services.NewReputationService(db),
+		privacyService:    services.NewPrivacyService(db),
 	}
 }
 
@@ -37,10 +50,30 @@ func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, r, http.StatusNotFound, "User not found")
 		return
 	}
+	h.setBadges(r, user)
 
 	respondWithJSON(w, r, http.StatusOK, user)
 }
 
+// GetSuggestions handles GET /api/v1/users/me/suggestions
+func (h *UserHandler) GetSuggestions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	suggestions, err := h.suggestionService.GetSuggestions(r.Context(), userID, limit)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get suggestions")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]interface{}{"suggestions": suggestions})
+}
+
 // GetUser handles GET /api/v1/users/{id}
 // FIXED: Issue #34 - Validate user ID format
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -54,10 +87,15 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := h.userService.GetUser(r.Context(), userID)
-	if err != nil {
+	if err != nil || h.isHidden(r, user.ID) {
 		respondWithError(w, r, http.StatusNotFound, "User not found")
 		return
 	}
+	user = h.applyPrivacy(r, user)
+	h.setRelationship(r, user)
+	if !user.IsPrivate {
+		h.setBadges(r, user)
+	}
 
 	respondWithJSON(w, r, http.StatusOK, user)
 }
@@ -79,17 +117,30 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	// FIXED: Issue #18 - Filter out sensitive fields that users cannot update
 	// Users cannot update: isAdmin, isVerified, role, provider, ID, email
-	// Only allow: name, bio, location, website, avatar
+	// Only allow: name, bio, location, website, avatar, cover photo,
+	// social links, skills, pronouns, employer
 	filteredReq := models.UpdateProfileRequest{
-		Name:     req.Name,
-		Bio:      req.Bio,
-		Location: req.Location,
-		Website:  req.Website,
-		Avatar:   req.Avatar,
+		Name:        req.Name,
+		Bio:         req.Bio,
+		Location:    req.Location,
+		Website:     req.Website,
+		Avatar:      req.Avatar,
+		CoverPhoto:  req.CoverPhoto,
+		SocialLinks: req.SocialLinks,
+		Skills:      req.Skills,
+		Pronouns:    req.Pronouns,
+		Employer:    req.Employer,
 	}
 
 	user, err := h.userService.UpdateUser(r.Context(), userID, &filteredReq)
 	if err != nil {
+		if respondWithFilterResult(w, r, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidProfile) {
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
 		respondWithError(w, r, http.StatusInternalServerError, "Failed to update profile")
 		return
 	}
@@ -110,11 +161,17 @@ func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 		limit = 10
 	}
 
-	users, err := h.userService.SearchUsers(r.Context(), query, limit)
+	users, err := h.userService.SearchUsers(r.Context(), middleware.GetUserID(r.Context()), query, limit)
 	if err != nil {
 		respondWithError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	viewerID := middleware.GetUserID(r.Context())
+	for _, user := range users {
+		if user.ID != viewerID {
+			user.Email = ""
+		}
+	}
 
 	respondWithJSON(w, r, http.StatusOK, users)
 }
@@ -140,7 +197,16 @@ func (h *UserHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	posts, err := h.userService.GetUserPosts(r.Context(), userID, limit, offset)
+	if h.isHidden(r, userID) {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+	if !h.allowed(r, userID, h.privacyService.CanViewProfile) {
+		respondWithError(w, r, http.StatusForbidden, "This profile is private")
+		return
+	}
+
+	posts, err := h.userService.GetUserPosts(r.Context(), middleware.GetUserID(r.Context()), userID, limit, offset)
 	if err != nil {
 		respondWithError(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -148,3 +214,260 @@ func (h *UserHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 
 	respondWithJSON(w, r, http.StatusOK, posts)
 }
+
+// GetUserByHandle handles GET /api/v1/u/{handle}
+// Former handles redirect (301) to the user's current handle.
+func (h *UserHandler) GetUserByHandle(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.resolveHandle(w, r, "")
+	if !ok {
+		return
+	}
+	user = h.applyPrivacy(r, user)
+	h.setRelationship(r, user)
+	if !user.IsPrivate {
+		h.setBadges(r, user)
+	}
+
+	respondWithJSON(w, r, http.StatusOK, user)
+}
+
+// GetUserPostsByHandle handles GET /api/v1/u/{handle}/posts
+func (h *UserHandler) GetUserPostsByHandle(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.resolveHandle(w, r, "/posts")
+	if !ok {
+		return
+	}
+	if !h.allowed(r, user.ID, h.privacyService.CanViewProfile) {
+		respondWithError(w, r, http.StatusForbidden, "This profile is private")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	posts, err := h.userService.GetUserPosts(r.Context(), middleware.GetUserID(r.Context()), user.ID, limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get user posts")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, posts)
+}
+
+// GetUserLikes handles GET /api/v1/users/{id}/likes
+func (h *UserHandler) GetUserLikes(w http.ResponseWriter, r *http.Request) {
+	h.listActivity(w, r, h.privacyService.CanViewLikes, h.userService.GetUserLikedPosts, "This user's likes are private")
+}
+
+// GetUserBookmarks handles GET /api/v1/users/{id}/bookmarks
+func (h *UserHandler) GetUserBookmarks(w http.ResponseWriter, r *http.Request) {
+	h.listActivity(w, r, h.privacyService.CanViewBookmarks, h.userService.GetUserBookmarkedPosts, "This user's bookmarks are private")
+}
+
+// listActivity serves a user's liked or bookmarked posts once the owner's
+// privacy settings allow the caller to see them
+func (h *UserHandler) listActivity(
+	w http.ResponseWriter, r *http.Request,
+	canView func(ctx context.Context, viewerID, ownerID string) (bool, error),
+	list func(ctx context.Context, viewerID, userID string, limit, offset int) ([]*models.Post, error),
+	private string,
+) {
+	userID := mux.Vars(r)["id"]
+	if !utils.ValidateUserID(userID) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	if h.isHidden(r, userID) {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+	if !h.allowed(r, userID, canView) {
+		respondWithError(w, r, http.StatusForbidden, private)
+		return
+	}
+
+	posts, err := list(r.Context(), middleware.GetUserID(r.Context()), userID, limit, offset)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get posts")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, posts)
+}
+
+// GetPrivacySettings handles GET /api/v1/users/me/privacy
+func (h *UserHandler) GetPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	settings, err := h.privacyService.GetSettings(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get privacy settings")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, settings)
+}
+
+// UpdatePrivacySettings handles PUT /api/v1/users/me/privacy
+func (h *UserHandler) UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.UpdatePrivacySettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	settings, err := h.privacyService.UpdateSettings(r.Context(), userID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPrivacySetting) {
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update privacy settings")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, settings)
+}
+
+// UpdateHandle handles PUT /api/v1/users/me/handle
+func (h *UserHandler) UpdateHandle(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.UpdateHandleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.ChangeHandle(r.Context(), userID, req.Handle)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidHandle):
+			respondWithError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrHandleTaken):
+			respondWithError(w, r, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrHandleChangeTooSoon):
+			respondWithError(w, r, http.StatusTooManyRequests, err.Error())
+		default:
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update handle")
+		}
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, user)
+}
+
+// resolveHandle looks up the {handle} route variable. It writes a redirect
+// for former handles (preserving suffix) or an error response, returning
+// ok=false when the caller should stop.
+func (h *UserHandler) resolveHandle(w http.ResponseWriter, r *http.Request, suffix string) (*models.User, bool) {
+	handle := utils.NormalizeHandle(mux.Vars(r)["handle"])
+	if !utils.ValidateLength(handle, 1, 64) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid handle")
+		return nil, false
+	}
+
+	user, current, err := h.userService.GetUserByHandle(r.Context(), handle)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	if user == nil {
+		location := "/api/v1/u/" + url.PathEscape(current) + suffix
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return nil, false
+	}
+	if h.isHidden(r, user.ID) {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// setRelationship fills isFollowing/followsYou for an authenticated viewer
+func (h *UserHandler) setRelationship(r *http.Request, user *models.User) {
+	viewerID := middleware.GetUserID(r.Context())
+	if viewerID == "" || viewerID == user.ID {
+		return
+	}
+
+	isFollowing, followsYou, err := h.followService.GetRelationship(r.Context(), viewerID, user.ID)
+	if err != nil {
+		return
+	}
+	user.IsFollowing = &isFollowing
+	user.FollowsYou = &followsYou
+}
+
+// setBadges attaches the user's earned badges to a profile response
+func (h *UserHandler) setBadges(r *http.Request, user *models.User) {
+	badges, err := h.reputationService.GetBadges(r.Context(), user.ID)
+	if err != nil || len(badges) == 0 {
+		return
+	}
+	user.Badges = badges
+}
+
+// isHidden reports whether a block in either direction hides userID from the caller
+func (h *UserHandler) isHidden(r *http.Request, userID string) bool {
+	blocked, err := h.blockService.IsBlockedEither(r.Context(), middleware.GetUserID(r.Context()), userID)
+	return err != nil || blocked
+}
+
+// applyPrivacy returns the profile as the caller may see it: the email is
+// only shown to its owner, and viewers outside the owner's profile
+// visibility get a limited profile flagged isPrivate
+func (h *UserHandler) applyPrivacy(r *http.Request, user *models.User) *models.User {
+	viewerID := middleware.GetUserID(r.Context())
+	if viewerID == user.ID {
+		return user
+	}
+	user.Email = ""
+
+	if h.allowed(r, user.ID, h.privacyService.CanViewProfile) {
+		return user
+	}
+	return &models.User{
+		ID:         user.ID,
+		Name:       user.Name,
+		Handle:     user.Handle,
+		Avatar:     user.Avatar,
+		IsVerified: user.IsVerified,
+		IsActive:   user.IsActive,
+		IsPrivate:  true,
+	}
+}
+
+// allowed runs a privacy check for the caller against ownerID, failing closed on errors
+func (h *UserHandler) allowed(r *http.Request, ownerID string, check func(ctx context.Context, viewerID, ownerID string) (bool, error)) bool {
+	ok, err := check(r.Context(), middleware.GetUserID(r.Context()), ownerID)
+	return err == nil && ok
+}
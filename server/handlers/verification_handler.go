@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/services"
+)
+
+type VerificationHandler struct {
+	verificationService *services.VerificationService
+	userService         *services.UserService
+}
+
+func NewVerificationHandler(verificationService *services.VerificationService, db *sql.DB) *VerificationHandler {
+	return &VerificationHandler{
+		verificationService: verificationService,
+		userService:         services.NewUserService(db),
+	}
+}
+
+// VerifyEmail handles GET /api/v1/auth/verify-email?uid=&expires=&sig=.
+// The link is signed and emailed at signup, so no session is needed.
+func (h *VerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	err := h.verificationService.VerifyEmail(r.Context(), query.Get("uid"), query.Get("expires"), query.Get("sig"))
+	if err != nil {
+		if errors.Is(err, services.ErrVerificationLinkInvalid) {
+			respondWithError(w, r, http.StatusGone, "This verification link is invalid or has expired. Log in to request a new one.")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{
+		"message": "Your email address is verified",
+	})
+}
+
+// ResendVerification handles POST /api/v1/auth/verify-email/resend
+func (h *VerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := h.verificationService.SendVerification(r.Context(), user); err != nil {
+		if errors.Is(err, services.ErrAlreadyVerified) {
+			respondWithError(w, r, http.StatusConflict, "Your email is already verified")
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{
+		"message": "Verification email sent",
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"tech-bant-community/server/middleware"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// GetWebhooks handles GET /api/v1/admin/webhooks
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookService.ListWebhooks(r.Context())
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get webhooks")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, webhooks)
+}
+
+// CreateWebhook handles POST /api/v1/admin/webhooks. The response carries
+// the signing secret, which is not shown again.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(r.Context(), userID, &req)
+	if err != nil {
+		if status, ok := webhookErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, webhook)
+}
+
+// UpdateWebhook handles PUT /api/v1/admin/webhooks/{id}
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		if status, ok := webhookErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, webhook)
+}
+
+// DeleteWebhook handles DELETE /api/v1/admin/webhooks/{id}
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.webhookService.DeleteWebhook(r.Context(), mux.Vars(r)["id"]); err != nil {
+		if status, ok := webhookErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// GetDeliveries handles GET /api/v1/admin/webhooks/{id}/deliveries?status=&limit=&offset=
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), mux.Vars(r)["id"], query.Get("status"), limit, offset)
+	if err != nil {
+		if status, ok := webhookErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to get webhook deliveries")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, deliveries)
+}
+
+// Redeliver handles POST /api/v1/admin/webhooks/{id}/deliveries/{deliveryId}/redeliver
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	delivery, err := h.webhookService.Redeliver(r.Context(), vars["id"], vars["deliveryId"])
+	if err != nil {
+		if status, ok := webhookErrorStatus(err); ok {
+			respondWithError(w, r, status, err.Error())
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to redeliver webhook")
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusAccepted, delivery)
+}
+
+// webhookErrorStatus maps webhook service errors to HTTP statuses
+func webhookErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, services.ErrWebhookDisabled):
+		return http.StatusConflict, true
+	case errors.Is(err, services.ErrInvalidWebhookURL), errors.Is(err, services.ErrInvalidWebhookEvents),
+		errors.Is(err, services.ErrInvalidWebhookDesc):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
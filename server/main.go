@@ -32,6 +32,7 @@ func main() {
 
 	// Initialize Supabase auth middleware with JWT secret
 	middleware.InitSupabaseAuth(cfg)
+	middleware.InitTrustedProxies(cfg.TrustedProxies)
 
 	// Initialize Redis/Rate Limiting
 	rateLimitService, err := services.NewRateLimitService(cfg)
@@ -63,12 +64,41 @@ func main() {
 	authHandler := handlers.NewAuthHandlerWithService(cfg, authService, emailService, twoFAService)
 	twoFAHandler := handlers.NewTwoFAHandler(twoFAService, emailService, rateLimitService, supabase.GetDB())
 	oauthHandler := handlers.NewOAuthHandler(oauthService)
-	postHandler := handlers.NewPostHandlerWithService(services.NewPostServiceWithCache(supabase.GetDB(), cacheService))
+	postHandler := handlers.NewPostHandlerWithService(services.NewPostServiceWithCache(supabase.GetDB(), cacheService), services.NewBlockService(supabase.GetDB()))
 	userHandler := handlers.NewUserHandler(supabase.GetDB())
 	commentHandler := handlers.NewCommentHandler(supabase.GetDB())
 	mediaHandler := handlers.NewMediaHandler(supabase.GetDB(), cfg)
 	adminHandler := handlers.NewAdminHandler(supabase.GetDB(), cfg)
-	featuresHandler := handlers.NewFeaturesHandler(supabase.GetDB())
+	suspensionService := services.NewSuspensionService(supabase.GetDB(), emailService)
+	middleware.InitSuspensionCheck(suspensionService)
+	featuresHandler := handlers.NewFeaturesHandler(supabase.GetDB(), emailService, cacheService, suspensionService)
+	blockHandler := handlers.NewBlockHandler(supabase.GetDB())
+	exportService := services.NewExportService(supabase.GetDB(), cfg, emailService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	accountService := services.NewAccountService(supabase.GetDB(), cfg, twoFAService, emailService)
+	accountHandler := handlers.NewAccountHandler(accountService, supabase.GetDB())
+	reputationHandler := handlers.NewReputationHandler(supabase.GetDB())
+	verificationService := services.NewVerificationService(supabase.GetDB(), cfg, emailService)
+	verificationHandler := handlers.NewVerificationHandler(verificationService, supabase.GetDB())
+	authHandler.SetVerificationService(verificationService)
+	notificationEmailService := services.NewNotificationEmailService(supabase.GetDB(), cfg, emailService)
+	notificationHandler := handlers.NewNotificationHandler(notificationEmailService, supabase.GetDB())
+	streamService := services.NewStreamService(cfg)
+	webhookService := services.NewWebhookService(supabase.GetDB(), cfg)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	messageHandler := handlers.NewMessageHandler(supabase.GetDB())
+	auditHandler := handlers.NewAuditHandler(supabase.GetDB())
+	contentFilterService := services.NewContentFilterService(supabase.GetDB())
+	contentFilterHandler := handlers.NewContentFilterHandler(supabase.GetDB(), contentFilterService, cacheService, cfg)
+	trustService := services.NewTrustService(supabase.GetDB())
+	trustHandler := handlers.NewTrustHandler(trustService)
+	spamClassifierService := services.NewSpamClassifierService(supabase.GetDB())
+	spamClassifierHandler := handlers.NewSpamClassifierHandler(spamClassifierService)
+	appealHandler := handlers.NewAppealHandler(services.NewAppealService(supabase.GetDB(), emailService))
+	bulkService := services.NewBulkService(supabase.GetDB(), cacheService, suspensionService, services.NewReportService(emailService, cacheService))
+	bulkHandler := handlers.NewBulkHandler(bulkService)
+	shadowBanHandler := handlers.NewShadowBanHandler(services.NewShadowBanService(supabase.GetDB(), cacheService))
+	streamHandler := handlers.NewStreamHandler(streamService, cfg, supabase.GetDB())
 
 	// Setup router
 	router := mux.NewRouter()
@@ -122,13 +152,35 @@ func main() {
 		api.HandleFunc("/auth/reset-password/confirm", authHandler.ConfirmPasswordReset).Methods("POST")
 	}
 
-	// Public routes (optional auth)
-	api.HandleFunc("/posts", postHandler.GetPosts).Methods("GET")
-	api.HandleFunc("/posts/{id}", postHandler.GetPost).Methods("GET")
-	api.HandleFunc("/posts/{id}/comments", commentHandler.GetComments).Methods("GET")
-	api.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
-	api.HandleFunc("/users/{id}/posts", userHandler.GetUserPosts).Methods("GET")
-	api.HandleFunc("/users/search", userHandler.SearchUsers).Methods("GET")
+	// Email verification link (signed, no session needed)
+	api.HandleFunc("/auth/verify-email", verificationHandler.VerifyEmail).Methods("GET")
+
+	// One-click unsubscribe from notification emails (signed, no session needed)
+	api.HandleFunc("/unsubscribe", notificationHandler.ConfirmUnsubscribe).Methods("GET")
+	api.HandleFunc("/unsubscribe", notificationHandler.Unsubscribe).Methods("POST")
+
+	// Public routes (optional auth). The caller, if any, is resolved so
+	// blocks/mutes can be applied and profiles can report isFollowing/followsYou.
+	public := api.PathPrefix("").Subrouter()
+	public.Use(middleware.OptionalAuthMiddleware)
+	public.HandleFunc("/posts", postHandler.GetPosts).Methods("GET")
+	public.HandleFunc("/posts/{id}", postHandler.GetPost).Methods("GET")
+	public.HandleFunc("/posts/{id}/comments", commentHandler.GetComments).Methods("GET")
+	public.HandleFunc("/stream", streamHandler.Stream).Methods("GET") // Bearer token or ?ticket=
+	public.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
+	public.HandleFunc("/users/{id}/posts", userHandler.GetUserPosts).Methods("GET")
+	public.HandleFunc("/users/{id}/followers", featuresHandler.GetFollowers).Methods("GET")
+	public.HandleFunc("/users/{id}/following", featuresHandler.GetFollowing).Methods("GET")
+	public.HandleFunc("/users/{id}/mutuals", featuresHandler.GetMutuals).Methods("GET")
+	public.HandleFunc("/users/{id}/likes", userHandler.GetUserLikes).Methods("GET")
+	public.HandleFunc("/users/{id}/bookmarks", userHandler.GetUserBookmarks).Methods("GET")
+	public.HandleFunc("/users/search", userHandler.SearchUsers).Methods("GET")
+	public.HandleFunc("/u/{handle}", userHandler.GetUserByHandle).Methods("GET")
+	public.HandleFunc("/u/{handle}/posts", userHandler.GetUserPostsByHandle).Methods("GET")
+	public.HandleFunc("/exports/{id}/download", exportHandler.DownloadExport).Methods("GET")
+	public.HandleFunc("/email-changes/{id}/revert", accountHandler.ConfirmEmailChangeRevert).Methods("GET")
+	public.HandleFunc("/email-changes/{id}/revert", accountHandler.RevertEmailChange).Methods("POST")
+	public.HandleFunc("/leaderboard", reputationHandler.GetLeaderboard).Methods("GET")
 
 	// Protected routes (require auth)
 	protected := api.PathPrefix("").Subrouter()
@@ -136,37 +188,129 @@ func main() {
 
 	protected.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
 	protected.HandleFunc("/auth/change-password", authHandler.ChangePassword).Methods("POST")
+	protected.HandleFunc("/auth/verify-email/resend", verificationHandler.ResendVerification).Methods("POST")
 
 	// 2FA routes (protected)
 	protected.HandleFunc("/auth/2fa/enable", twoFAHandler.Enable2FA).Methods("POST")
 	protected.HandleFunc("/auth/2fa/verify", twoFAHandler.Verify2FA).Methods("POST")
 	protected.HandleFunc("/auth/2fa/disable", twoFAHandler.Disable2FA).Methods("POST")
 
-	protected.HandleFunc("/posts", postHandler.CreatePost).Methods("POST")
+	protected.Handle("/posts", middleware.RequireVerifiedEmail(http.HandlerFunc(postHandler.CreatePost))).Methods("POST")
 	protected.HandleFunc("/posts/{id}", postHandler.UpdatePost).Methods("PUT")
 	protected.HandleFunc("/posts/{id}", postHandler.DeletePost).Methods("DELETE")
 	protected.HandleFunc("/posts/{id}/like", postHandler.LikePost).Methods("POST")
 	protected.HandleFunc("/posts/{id}/bookmark", postHandler.BookmarkPost).Methods("POST")
-	protected.HandleFunc("/posts/{id}/comments", commentHandler.CreateComment).Methods("POST")
+	protected.HandleFunc("/posts/{id}/report", featuresHandler.ReportPost).Methods("POST")
+	protected.Handle("/posts/{id}/comments", middleware.RequireVerifiedEmail(http.HandlerFunc(commentHandler.CreateComment))).Methods("POST")
 	protected.HandleFunc("/comments/{id}", commentHandler.UpdateComment).Methods("PUT")
 	protected.HandleFunc("/comments/{id}", commentHandler.DeleteComment).Methods("DELETE")
 	protected.HandleFunc("/comments/{id}/like", commentHandler.LikeComment).Methods("POST")
+	protected.HandleFunc("/comments/{id}/report", featuresHandler.ReportComment).Methods("POST")
+	protected.HandleFunc("/comments/{id}/accept", commentHandler.AcceptAnswer).Methods("POST")
+	protected.HandleFunc("/comments/{id}/accept", commentHandler.UnacceptAnswer).Methods("DELETE")
 	protected.HandleFunc("/users/me", userHandler.GetCurrentUser).Methods("GET")
 	protected.HandleFunc("/users/me", userHandler.UpdateUser).Methods("PUT")
-	protected.HandleFunc("/media/upload", mediaHandler.UploadMedia).Methods("POST")
+	protected.HandleFunc("/users/me", accountHandler.DeleteAccount).Methods("DELETE")
+	protected.HandleFunc("/users/me/suspension", featuresHandler.GetMySuspension).Methods("GET")
+	protected.HandleFunc("/users/me/appealable", appealHandler.GetAppealableActions).Methods("GET")
+	protected.HandleFunc("/users/me/appeals", appealHandler.CreateAppeal).Methods("POST")
+	protected.HandleFunc("/users/me/appeals", appealHandler.GetMyAppeals).Methods("GET")
+	protected.HandleFunc("/users/me/trust", trustHandler.GetMyTrust).Methods("GET")
+	protected.HandleFunc("/users/me/deletion", accountHandler.GetDeletion).Methods("GET")
+	protected.HandleFunc("/users/me/deletion", accountHandler.CancelDeletion).Methods("DELETE")
+	protected.HandleFunc("/users/me/email", accountHandler.ChangeEmail).Methods("POST")
+	protected.HandleFunc("/users/me/email/confirm", accountHandler.ConfirmEmailChange).Methods("POST")
+	protected.HandleFunc("/users/me/handle", userHandler.UpdateHandle).Methods("PUT")
+	protected.HandleFunc("/users/me/suggestions", userHandler.GetSuggestions).Methods("GET")
+	protected.HandleFunc("/users/me/privacy", userHandler.GetPrivacySettings).Methods("GET")
+	protected.HandleFunc("/users/me/privacy", userHandler.UpdatePrivacySettings).Methods("PUT")
+	protected.HandleFunc("/users/{id}/follow", featuresHandler.FollowUser).Methods("POST")
+	protected.HandleFunc("/users/{id}/follow", featuresHandler.UnfollowUser).Methods("DELETE")
+	protected.HandleFunc("/users/{id}/unfollow", featuresHandler.UnfollowUser).Methods("POST")
+	protected.HandleFunc("/users/me/blocks", blockHandler.GetBlockedUsers).Methods("GET")
+	protected.HandleFunc("/users/me/mutes", blockHandler.GetMutedUsers).Methods("GET")
+	protected.HandleFunc("/users/{id}/block", blockHandler.BlockUser).Methods("POST")
+	protected.HandleFunc("/users/{id}/block", blockHandler.UnblockUser).Methods("DELETE")
+	protected.HandleFunc("/users/{id}/mute", blockHandler.MuteUser).Methods("POST")
+	protected.HandleFunc("/users/{id}/mute", blockHandler.UnmuteUser).Methods("DELETE")
+	protected.HandleFunc("/stream/ticket", streamHandler.CreateTicket).Methods("POST")
+	protected.HandleFunc("/notifications", notificationHandler.GetNotifications).Methods("GET")
+	protected.HandleFunc("/notifications/unread-count", notificationHandler.GetUnreadCount).Methods("GET")
+	protected.HandleFunc("/notifications/read-all", notificationHandler.MarkAllRead).Methods("POST")
+	protected.HandleFunc("/notifications/{id}/read", notificationHandler.MarkRead).Methods("POST")
+	protected.HandleFunc("/users/me/notification-preferences", notificationHandler.GetPreferences).Methods("GET")
+	protected.HandleFunc("/users/me/notification-preferences", notificationHandler.UpdatePreferences).Methods("PUT")
+	protected.HandleFunc("/users/me/export", exportHandler.RequestExport).Methods("POST")
+	protected.HandleFunc("/users/me/exports", exportHandler.GetExports).Methods("GET")
+	protected.Handle("/media/upload", middleware.RequireVerifiedEmail(http.HandlerFunc(mediaHandler.UploadMedia))).Methods("POST")
+
+	// Direct messages. The global limiter runs before auth and only sees
+	// IPs, so starting conversations and sending messages share a
+	// per-user budget applied here.
+	createConversation := middleware.RequireVerifiedEmail(http.HandlerFunc(messageHandler.CreateConversation))
+	sendMessage := middleware.RequireVerifiedEmail(http.HandlerFunc(messageHandler.SendMessage))
+	if rateLimitService != nil {
+		const messagesEndpoint = "/api/v1/conversations/{id}/messages"
+		perUser := middleware.RateLimitMiddleware(rateLimitService, messagesEndpoint, services.DefaultLimits[messagesEndpoint], false)
+		createConversation = perUser(createConversation)
+		sendMessage = perUser(sendMessage)
+	}
+	protected.HandleFunc("/conversations", messageHandler.GetConversations).Methods("GET")
+	protected.Handle("/conversations", createConversation).Methods("POST")
+	protected.HandleFunc("/conversations/{id}", messageHandler.GetConversation).Methods("GET")
+	protected.HandleFunc("/conversations/{id}/messages", messageHandler.GetMessages).Methods("GET")
+	protected.Handle("/conversations/{id}/messages", sendMessage).Methods("POST")
+	protected.HandleFunc("/conversations/{id}/read", messageHandler.MarkRead).Methods("POST")
+	protected.HandleFunc("/conversations/{id}/leave", messageHandler.LeaveConversation).Methods("POST")
+	protected.HandleFunc("/messages/unread-count", messageHandler.GetUnreadCount).Methods("GET")
+	protected.HandleFunc("/messages/{id}", messageHandler.EditMessage).Methods("PUT")
+	protected.HandleFunc("/messages/{id}", messageHandler.DeleteMessage).Methods("DELETE")
 
 	// Admin routes (require auth + admin role with RBAC)
 	admin := api.PathPrefix("/admin").Subrouter()
 	admin.Use(middleware.SupabaseAuthMiddleware)
 	admin.Use(middleware.RoleMiddleware(models.RoleAdmin, models.RoleSuperAdmin))
+	admin.Use(middleware.AuditContext) // Actor, IP and request ID for the audit log
 
 	admin.HandleFunc("/stats", adminHandler.GetStats).Methods("GET")
 	admin.HandleFunc("/admins", adminHandler.GetAdmins).Methods("GET")
+	admin.HandleFunc("/users/{id}/suspend", featuresHandler.SuspendUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/unsuspend", featuresHandler.UnsuspendUser).Methods("POST")
 	admin.HandleFunc("/users/{id}/ban", featuresHandler.BanUser).Methods("POST")
-	admin.HandleFunc("/users/{id}/unban", featuresHandler.UnbanUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/unban", featuresHandler.UnsuspendUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/suspensions", featuresHandler.GetUserSuspensions).Methods("GET")
 	admin.HandleFunc("/users/{id}/verify", featuresHandler.VerifyUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/trust", trustHandler.GetUserTrust).Methods("GET")
+	admin.HandleFunc("/users/{id}/shadow-ban", shadowBanHandler.ShadowBanUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/unshadow-ban", shadowBanHandler.UnshadowBanUser).Methods("POST")
+	admin.HandleFunc("/shadow-bans", shadowBanHandler.GetShadowBans).Methods("GET")
+	admin.HandleFunc("/shadow-bans/content", shadowBanHandler.GetShadowBannedContent).Methods("GET")
+	admin.HandleFunc("/appeals", appealHandler.GetAppeals).Methods("GET")
+	admin.HandleFunc("/appeals/{id}", appealHandler.GetAppeal).Methods("GET")
+	admin.HandleFunc("/appeals/{id}/accept", appealHandler.AcceptAppeal).Methods("POST")
+	admin.HandleFunc("/appeals/{id}/deny", appealHandler.DenyAppeal).Methods("POST")
 	admin.HandleFunc("/reports", featuresHandler.GetReports).Methods("GET")
+	admin.HandleFunc("/reports/{id}", featuresHandler.GetReport).Methods("GET")
 	admin.HandleFunc("/reports/{id}/resolve", featuresHandler.ResolveReport).Methods("POST")
+	admin.HandleFunc("/webhooks", webhookHandler.GetWebhooks).Methods("GET")
+	admin.HandleFunc("/webhooks", webhookHandler.CreateWebhook).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}", webhookHandler.UpdateWebhook).Methods("PUT")
+	admin.HandleFunc("/webhooks/{id}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	admin.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.GetDeliveries).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/redeliver", webhookHandler.Redeliver).Methods("POST")
+	admin.HandleFunc("/filter-rules", contentFilterHandler.GetFilterRules).Methods("GET")
+	admin.HandleFunc("/filter-rules", contentFilterHandler.CreateFilterRule).Methods("POST")
+	admin.HandleFunc("/filter-rules/test", contentFilterHandler.TestFilterRules).Methods("POST")
+	admin.HandleFunc("/filter-rules/{id}", contentFilterHandler.UpdateFilterRule).Methods("PUT")
+	admin.HandleFunc("/filter-rules/{id}", contentFilterHandler.DeleteFilterRule).Methods("DELETE")
+	admin.HandleFunc("/queue", contentFilterHandler.GetQueue).Methods("GET")
+	admin.HandleFunc("/queue/{id}", contentFilterHandler.GetQueueItem).Methods("GET")
+	admin.HandleFunc("/queue/{id}/approve", contentFilterHandler.ApproveQueueItem).Methods("POST")
+	admin.HandleFunc("/queue/{id}/reject", contentFilterHandler.RejectQueueItem).Methods("POST")
+	admin.HandleFunc("/spam-classifier", spamClassifierHandler.GetSpamModel).Methods("GET")
+	admin.HandleFunc("/spam-classifier/misclassifications", spamClassifierHandler.GetMisclassifications).Methods("GET")
+	admin.HandleFunc("/spam-classifier/retrain", spamClassifierHandler.RetrainSpamModel).Methods("POST")
+	admin.HandleFunc("/spam-classifier/test", spamClassifierHandler.TestSpamClassifier).Methods("POST")
 
 	// Super admin only routes
 	superAdmin := admin.PathPrefix("").Subrouter()
@@ -175,6 +319,16 @@ func main() {
 	superAdmin.HandleFunc("/admins/{id}/role", adminHandler.UpdateAdminRole).Methods("PUT")
 	superAdmin.HandleFunc("/admins/{id}", adminHandler.DeleteAdmin).Methods("DELETE")
 	superAdmin.HandleFunc("/users/{id}/promote", featuresHandler.PromoteToAdmin).Methods("POST")
+	superAdmin.HandleFunc("/reputation/recompute", reputationHandler.RecomputeReputation).Methods("POST")
+	superAdmin.HandleFunc("/audit", auditHandler.GetAuditLog).Methods("GET")
+	superAdmin.HandleFunc("/audit/verify", auditHandler.VerifyAuditLog).Methods("GET")
+	superAdmin.HandleFunc("/bulk", bulkHandler.GetOperations).Methods("GET")
+	superAdmin.HandleFunc("/bulk/suspend", bulkHandler.SuspendUsers).Methods("POST")
+	superAdmin.HandleFunc("/bulk/remove-content", bulkHandler.RemoveContent).Methods("POST")
+	superAdmin.HandleFunc("/bulk/resolve-reports", bulkHandler.ResolveReports).Methods("POST")
+	superAdmin.HandleFunc("/bulk/purge", bulkHandler.Purge).Methods("POST")
+	superAdmin.HandleFunc("/bulk/{id}", bulkHandler.GetOperation).Methods("GET")
+	superAdmin.HandleFunc("/bulk/{id}/cancel", bulkHandler.CancelOperation).Methods("POST")
 
 	// Health check
 	// FIXED: Issue #43 - Add Redis health check
@@ -207,15 +361,50 @@ func main() {
 	}).Methods("GET")
 
 	// FIXED: Issues #45, #46 - Start cleanup job for expired OTPs and sessions
-	cleanupService := services.NewCleanupService(supabase.GetDB())
+	cleanupService := services.NewCleanupService(supabase.GetDB(), cfg)
 	cleanupCtx, cleanupCancel := context.WithCancel(context.Background())
 	defer cleanupCancel()
 	cleanupService.StartCleanupJob(cleanupCtx)
 
+	// Precompute who-to-follow suggestions daily
+	suggestionService := services.NewSuggestionService(supabase.GetDB())
+	suggestionService.StartSuggestionJob(cleanupCtx)
+
+	// Build queued data exports and expire old archives
+	exportService.StartExportJob(cleanupCtx)
+
+	// Carry out account deletions once their grace period has ended
+	accountService.StartAccountDeletionJob(cleanupCtx)
+
+	// Rebuild reputation from scratch daily to correct any drift
+	services.NewReputationService(supabase.GetDB()).StartReputationJob(cleanupCtx)
+
+	// Email notifications as they happen and send daily and weekly digests
+	notificationEmailService.StartNotificationEmailJob(cleanupCtx)
+
+	// Send queued webhook deliveries, retrying failures with backoff
+	webhookService.StartWebhookJob(cleanupCtx)
+
+	// Lift suspensions once they expire
+	suspensionService.StartSuspensionJob(cleanupCtx)
+
+	// Load the content filter rules and pick up changes made on other instances
+	contentFilterService.StartContentFilterJob(cleanupCtx)
+
+	// Promote and demote users as their trust stats change
+	trustService.StartTrustJob(cleanupCtx)
+
+	// Load the spam model, pick up models trained elsewhere and retrain daily
+	spamClassifierService.StartSpamClassifierJob(cleanupCtx)
+
+	// Run queued bulk moderation operations
+	bulkService.StartBulkJob(cleanupCtx)
+
 	// Apply CORS middleware
 	handler := middleware.CORS(cfg)(router)
 
-	// Setup server
+	// Setup server. /api/v1/stream lifts these timeouts for its own
+	// long-lived connections and bounds each write instead.
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      handler,
@@ -223,6 +412,8 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Live streams never finish on their own; end them so Shutdown can complete
+	srv.RegisterOnShutdown(func() { streamService.Close() })
 
 	// Start server in a goroutine
 	go func() {
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"tech-bant-community/server/services"
+)
+
+// AuditContext stores who is making an admin request, from which IP, under
+// which request ID and why (the optional X-Audit-Reason header), for the
+// services that write the audit log. Use after RoleMiddleware.
+func AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := services.AuditActor{
+			ID:        GetUserID(r.Context()),
+			IP:        ClientIP(r),
+			RequestID: r.Header.Get("X-Request-ID"),
+			Reason:    r.Header.Get("X-Audit-Reason"),
+		}
+		if roles := GetUserRoles(r.Context()); len(roles) > 0 {
+			actor.Role = roles[0]
+		}
+
+		ctx := services.WithAuditActor(r.Context(), actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
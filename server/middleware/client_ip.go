@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// defaultTrustedProxies are the networks a reverse proxy in front of the
+// server usually connects from
+var defaultTrustedProxies = []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
+var trustedProxies = parseProxyNetworks(defaultTrustedProxies)
+
+// InitTrustedProxies sets the networks (CIDRs or single addresses) whose
+// X-Forwarded-For and X-Real-IP headers ClientIP believes. An empty list
+// keeps the default of loopback and private networks.
+func InitTrustedProxies(networks []string) {
+	if len(networks) > 0 {
+		trustedProxies = parseProxyNetworks(networks)
+	}
+}
+
+func parseProxyNetworks(networks []string) []*net.IPNet {
+	var parsed []*net.IPNet
+	for _, network := range networks {
+		if !strings.Contains(network, "/") {
+			if ip := net.ParseIP(network); ip != nil && ip.To4() != nil {
+				network += "/32"
+			} else {
+				network += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted proxy %q: %v", network, err)
+			continue
+		}
+		parsed = append(parsed, ipNet)
+	}
+	return parsed
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that made the request,
+// normalized. Forwarding headers are only believed when the connection
+// comes from a trusted proxy: X-Forwarded-For is then read from the right,
+// skipping trusted proxies, so entries a client sent itself are never used,
+// and X-Real-IP is used when there is no X-Forwarded-For. Otherwise the
+// connection's address is the client. Rate limiting, the IP whitelist,
+// request logs, sessions, security events and the audit log all use it.
+func ClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil || !isTrustedProxy(peerIP) {
+		return normalizeIP(peer)
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		entries := strings.Split(forwarded, ",")
+		for i := len(entries) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(entries[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !isTrustedProxy(ip) {
+				return ip.String()
+			}
+		}
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return normalizeIP(peer)
+}
+
+func normalizeIP(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
@@ -11,8 +11,8 @@ import (
 // FIXED: Issue #79 - Add gzip compression
 func CompressionMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if client supports gzip
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// Check if client supports gzip; live streams are never buffered
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isStreamRequest(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -31,6 +31,14 @@ func CompressionMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// isStreamRequest reports whether r opens a long-lived event stream or
+// WebSocket, whose responses must reach the client as they are written
+func isStreamRequest(r *http.Request) bool {
+	return r.URL.Path == "/api/v1/stream" ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
 type gzipResponseWriter struct {
 	http.ResponseWriter
 	io.Writer
@@ -10,8 +10,8 @@ import (
 // FIXED: Issue #80 - ETag support
 func ETagMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only add ETag for GET requests
-		if r.Method != "GET" {
+		// Only add ETag for GET requests that end
+		if r.Method != "GET" || isStreamRequest(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
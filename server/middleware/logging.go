@@ -13,7 +13,7 @@ func RequestLoggingMiddleware(next http.Handler) http.Handler {
 		start := time.Now()
 
 		// Log request
-		log.Printf("[%s] %s %s from %s", r.Method, r.URL.Path, r.URL.RawQuery, getClientIP(r))
+		log.Printf("[%s] %s %s from %s", r.Method, r.URL.Path, r.URL.RawQuery, ClientIP(r))
 
 		// Wrap response writer to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
@@ -36,3 +36,9 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush or hijack live streams
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -82,7 +82,7 @@ func getRateLimitKey(r *http.Request, endpoint string) string {
 	}
 
 	// Fall back to IP address
-	ip := getClientIP(r)
+	ip := ClientIP(r)
 	return fmt.Sprintf("ip:%s:%s", ip, endpoint)
 }
 
@@ -3,7 +3,8 @@ package middleware
 import (
 	"context"
 	"net/http"
-	"strings"
+
+	"github.com/google/uuid"
 )
 
 // SecurityHeadersMiddleware adds security headers to responses
@@ -71,7 +72,7 @@ func IPWhitelistMiddleware(allowedIPs []string) func(http.Handler) http.Handler
 				return
 			}
 
-			clientIP := getClientIP(r)
+			clientIP := ClientIP(r)
 			allowed := false
 			for _, ip := range allowedIPs {
 				if ip == clientIP {
@@ -90,31 +91,6 @@ func IPWhitelistMiddleware(allowedIPs []string) func(http.Handler) http.Handler
 	}
 }
 
-// getClientIP extracts the real client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (for proxies/load balancers)
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	realIP := r.Header.Get("X-Real-IP")
-	if realIP != "" {
-		return realIP
-	}
-
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-	return ip
-}
-
 // RequestIDMiddleware adds a unique request ID to each request
 // FIXED: Issue #42 - Store request ID in context for logging
 func RequestIDMiddleware(next http.Handler) http.Handler {
@@ -129,17 +105,6 @@ func RequestIDMiddleware(next http.Handler) http.Handler {
 }
 
 func generateRequestID() string {
-	// Simple request ID generation
-	// In production, use a UUID library
-	return "req_" + randomString(16)
-}
-
-func randomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[i%len(charset)]
-	}
-	return string(b)
+	return "req_" + uuid.NewString()
 }
 
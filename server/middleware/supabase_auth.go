@@ -24,7 +24,8 @@ func InitSupabaseAuth(cfg *config.Config) {
 	}
 }
 
-// SupabaseAuthMiddleware validates Supabase JWT tokens
+// SupabaseAuthMiddleware validates Supabase JWT tokens and turns away
+// suspended users (see checkSuspension)
 // Note: contextKey, UserIDKey, and UserEmailKey are already defined in auth.go
 // Note: Must call InitSupabaseAuth before using this middleware
 func SupabaseAuthMiddleware(next http.Handler) http.Handler {
@@ -84,8 +85,14 @@ func SupabaseAuthMiddleware(next http.Handler) http.Handler {
 		if email != "" {
 			ctx = context.WithValue(ctx, UserEmailKey, email)
 		}
+		r = r.WithContext(ctx)
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		// Tokens stay valid after a suspension, so it is enforced here
+		if !checkSuspension(w, r, userID) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/services"
+
+	"github.com/gorilla/mux"
+)
+
+var suspensionService *services.SuspensionService
+
+// InitSuspensionCheck enables suspension enforcement in
+// SupabaseAuthMiddleware. Until it is called, suspensions are not checked.
+func InitSuspensionCheck(svc *services.SuspensionService) {
+	suspensionService = svc
+}
+
+// suspensionExempt are the routes every suspended user keeps: the
+// suspension notice and appeals, signing out, and exporting or deleting
+// their account
+var suspensionExempt = map[string]bool{
+	"GET /api/v1/users/me/suspension":  true,
+	"GET /api/v1/users/me/appealable":  true,
+	"POST /api/v1/users/me/appeals":    true,
+	"GET /api/v1/users/me/appeals":     true,
+	"GET /api/v1/users/me":             true,
+	"POST /api/v1/auth/logout":         true,
+	"DELETE /api/v1/users/me":          true,
+	"GET /api/v1/users/me/deletion":    true,
+	"DELETE /api/v1/users/me/deletion": true,
+	"POST /api/v1/users/me/export":     true,
+	"GET /api/v1/users/me/exports":     true,
+}
+
+// readOnlyAllowed are the writes a read-only suspension still allows:
+// account security, privacy and safety settings, and marking things read
+var readOnlyAllowed = map[string]bool{
+	"POST /api/v1/auth/change-password":             true,
+	"POST /api/v1/auth/2fa/enable":                  true,
+	"POST /api/v1/auth/2fa/verify":                  true,
+	"POST /api/v1/auth/2fa/disable":                 true,
+	"PUT /api/v1/users/me/privacy":                  true,
+	"PUT /api/v1/users/me/notification-preferences": true,
+	"POST /api/v1/users/{id}/block":                 true,
+	"DELETE /api/v1/users/{id}/block":               true,
+	"POST /api/v1/users/{id}/mute":                  true,
+	"DELETE /api/v1/users/{id}/mute":                true,
+	"POST /api/v1/stream/ticket":                    true,
+	"POST /api/v1/notifications/read-all":           true,
+	"POST /api/v1/notifications/{id}/read":          true,
+	"POST /api/v1/conversations/{id}/read":          true,
+}
+
+// checkSuspension lets the request through unless the user's suspension
+// forbids it, in which case it responds 403 with the suspension notice
+func checkSuspension(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if suspensionService == nil {
+		return true
+	}
+	route := routeKey(r)
+	if suspensionExempt[route] {
+		return true
+	}
+
+	suspension, err := suspensionService.ActiveSuspension(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check account status")
+		return false
+	}
+	if suspension == nil {
+		return true
+	}
+	if suspension.Scope == models.SuspensionScopeReadOnly {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return true
+		}
+		if readOnlyAllowed[route] {
+			return true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      services.SuspensionNotice(suspension),
+		"suspension": services.SuspensionForUser(suspension),
+	})
+	return false
+}
+
+// routeKey is the request's method and route template, e.g.
+// "POST /api/v1/users/{id}/block"
+func routeKey(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = template
+		}
+	}
+	return r.Method + " " + path
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"tech-bant-community/server/constants"
 )
@@ -14,8 +15,10 @@ const (
 // FIXED: Issue #32 - Content-Type validation
 func ContentTypeMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only validate for POST, PUT, PATCH requests
-		if r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" {
+		// Only validate for POST, PUT, PATCH requests. One-click unsubscribe
+		// POSTs come from mail clients as a form (RFC 8058), and the pages
+		// for signed email links post forms back.
+		if (r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH") && !isLinkFormPath(r.URL.Path) {
 			contentType := r.Header.Get("Content-Type")
 			if contentType != "application/json" && contentType != "application/json; charset=utf-8" {
 				// Allow multipart/form-data for file uploads
@@ -29,6 +32,13 @@ func ContentTypeMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// isLinkFormPath reports whether path is a signed email link that accepts
+// form posts
+func isLinkFormPath(path string) bool {
+	return path == "/api/v1/unsubscribe" ||
+		(strings.HasPrefix(path, "/api/v1/email-changes/") && strings.HasSuffix(path, "/revert"))
+}
+
 // BodySizeMiddleware limits request body size
 // FIXED: Issue #33 - Request body size limit
 // FIXED: Issue #85 - JSON size limits
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"tech-bant-community/server/database"
+)
+
+// RequireVerifiedEmail blocks accounts that have not verified their email
+// address from the wrapped handler. Must run after SupabaseAuthMiddleware.
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserID(r.Context())
+		if userID == "" {
+			respondWithError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		var verified bool
+		query := "SELECT email_verified_at IS NOT NULL FROM public.users WHERE id = $1"
+		err := database.QueryRowWithContext(r.Context(), query, userID).Scan(&verified)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusForbidden, "User not found")
+			} else {
+				respondWithError(w, http.StatusForbidden, "Failed to verify account")
+			}
+			return
+		}
+		if !verified {
+			respondWithError(w, http.StatusForbidden, "Please verify your email address first. Check your inbox or request a new link.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
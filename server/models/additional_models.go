@@ -1,18 +1,567 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
-// Report represents a content report
+// Report statuses
+const (
+	ReportStatusPending   = "pending"
+	ReportStatusReviewed  = "reviewed"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Actions a moderator can take when upholding a report
+const (
+	ReportActionRemove  = "remove"  // Delete the reported post or comment
+	ReportActionWarn    = "warn"    // Email the author a warning
+	ReportActionSuspend = "suspend" // Suspend the author's account
+)
+
+// Report represents a content report. Snapshot is the reported content as
+// it was when reported, so it stays reviewable after edits or removal.
 type Report struct {
-	ID         string     `json:"id"`
-	ReporterID string     `json:"reporter_id"`
-	PostID     string     `json:"post_id,omitempty"`
-	CommentID  string     `json:"comment_id,omitempty"`
+	ID                 string          `json:"id"`
+	ReporterID         string          `json:"reporter_id"`
+	TargetType         string          `json:"target_type"` // post, comment
+	TargetID           string          `json:"target_id"`
+	PostID             string          `json:"post_id,omitempty"`
+	CommentID          string          `json:"comment_id,omitempty"`
+	Reason             string          `json:"reason"`
+	Status             string          `json:"status"` // pending, reviewed, resolved, dismissed
+	Snapshot           json.RawMessage `json:"snapshot,omitempty"`
+	Actions            []string        `json:"actions"`
+	AdminNotes         string          `json:"admin_notes,omitempty"`
+	ResolutionSummary  string          `json:"resolution_summary,omitempty"`
+	Spam               bool            `json:"spam"` // Removed as spam
+	CreatedAt          time.Time       `json:"created_at"`
+	ReviewedAt         *time.Time      `json:"reviewed_at,omitempty"`
+	ReviewedBy         string          `json:"reviewed_by,omitempty"`
+	ResolvedAt         *time.Time      `json:"resolved_at,omitempty"`
+	ResolvedBy         string          `json:"resolved_by,omitempty"`
+	ReporterNotifiedAt *time.Time      `json:"reporter_notified_at,omitempty"`
+}
+
+// ResolveReportRequest moves a report to reviewed, resolved or dismissed.
+// Actions only apply to resolved reports; Summary is shown to the reporter
+// and, if actions are taken, to the author. Spam tags a removal as spam,
+// which the spam classifier trains on.
+type ResolveReportRequest struct {
+	Status          string   `json:"status"`
+	Actions         []string `json:"actions,omitempty"`
+	Spam            bool     `json:"spam,omitempty"` // Only with the remove action
+	AdminNotes      string   `json:"admin_notes,omitempty"`
+	Summary         string   `json:"summary,omitempty"`
+	SuspensionScope string   `json:"suspension_scope,omitempty"` // For the suspend action; defaults to full
+	SuspensionHours int      `json:"suspension_hours,omitempty"` // For the suspend action; 0 suspends until lifted
+}
+
+// Suspension scopes
+const (
+	SuspensionScopeReadOnly = "read_only" // Can sign in and read, but not post, comment, like, follow or message
+	SuspensionScopeFull     = "full"      // Locked out of everything but the suspension notice and account data
+)
+
+// Suspension is a timed or indefinite restriction of a user's account
+type Suspension struct {
+	ID         string     `json:"id,omitempty"`
+	UserID     string     `json:"userId"`
+	Scope      string     `json:"scope"`
 	Reason     string     `json:"reason"`
-	Status     string     `json:"status"` // pending, reviewed, resolved, dismissed
-	CreatedAt  time.Time  `json:"created_at"`
-	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
-	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"` // Not set for suspensions that last until lifted
+	ReportID   string     `json:"reportId,omitempty"`
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LiftedAt   *time.Time `json:"liftedAt,omitempty"`
+	LiftedBy   string     `json:"liftedBy,omitempty"`
+	LiftReason string     `json:"liftReason,omitempty"`
+}
+
+// SuspendUserRequest suspends a user. Scope defaults to full; a zero
+// DurationHours suspends until an admin lifts it.
+type SuspendUserRequest struct {
+	Scope         string `json:"scope,omitempty"`
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"durationHours,omitempty"`
+}
+
+// Appealable moderation actions
+const (
+	AppealActionSuspension = "suspension" // A suspension imposed directly by an admin
+	AppealActionReport     = "report"     // An upheld report that removed content, warned or suspended the author
+)
+
+// Appeal statuses
+const (
+	AppealStatusPending  = "pending"
+	AppealStatusAccepted = "accepted"
+	AppealStatusDenied   = "denied"
+)
+
+// AppealableAction is a moderation action taken against a user. For
+// suspensions ID is the suspension; for reports it is the report, and
+// Actions, TargetType and Excerpt describe what happened to the content.
+type AppealableAction struct {
+	Type       string     `json:"type"`
+	ID         string     `json:"id"`
+	Reason     string     `json:"reason,omitempty"`
+	Scope      string     `json:"scope,omitempty"`     // Suspensions only
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"` // Suspensions only; not set for those that last until lifted
+	Actions    []string   `json:"actions,omitempty"`   // Reports only
+	TargetType string     `json:"targetType,omitempty"`
+	Excerpt    string     `json:"excerpt,omitempty"`
+	TakenAt    time.Time  `json:"takenAt"`
+}
+
+// Appeal is a user's request to reverse a moderation action. Action is the
+// action as it was when appealed.
+type Appeal struct {
+	ID           string            `json:"id"`
+	UserID       string            `json:"userId"`
+	User         *User             `json:"user,omitempty"` // Set in the admin queue
+	Action       *AppealableAction `json:"action"`
+	Message      string            `json:"message"`
+	Status       string            `json:"status"`
+	DecisionNote string            `json:"decisionNote,omitempty"`
+	DecidedBy    string            `json:"decidedBy,omitempty"`
+	DecidedAt    *time.Time        `json:"decidedAt,omitempty"`
+	CreatedAt    time.Time         `json:"createdAt"`
+}
+
+// CreateAppealRequest appeals one of the actions listed as appealable
+type CreateAppealRequest struct {
+	ActionType string `json:"actionType"`
+	ActionID   string `json:"actionId"`
+	Message    string `json:"message"`
+}
+
+// DecideAppealRequest accepts or denies an appeal. The note is emailed to
+// the user.
+type DecideAppealRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// Bulk operation kinds
+const (
+	BulkKindSuspendUsers   = "suspend_users"
+	BulkKindRemoveContent  = "remove_content"
+	BulkKindResolveReports = "resolve_reports"
+	BulkKindPurge          = "purge"
+)
+
+// Bulk operation statuses
+const (
+	BulkStatusPending   = "pending"
+	BulkStatusRunning   = "running"
+	BulkStatusCompleted = "completed"
+	BulkStatusFailed    = "failed"
+	BulkStatusCancelled = "cancelled"
+)
+
+// BulkOperationRequest starts a bulk moderation operation. Which fields
+// apply depends on the kind, which is set by the endpoint.
+type BulkOperationRequest struct {
+	Kind          string     `json:"kind"`
+	DryRun        bool       `json:"dryRun"`
+	UserIDs       []string   `json:"userIds,omitempty"`       // suspend_users
+	Scope         string     `json:"scope,omitempty"`         // suspend_users
+	Reason        string     `json:"reason,omitempty"`        // suspend_users and purge
+	DurationHours int        `json:"durationHours,omitempty"` // suspend_users; 0 suspends until lifted
+	UserID        string     `json:"userId,omitempty"`        // remove_content
+	Since         *time.Time `json:"since,omitempty"`         // remove_content
+	Status        string     `json:"status,omitempty"`        // resolve_reports: resolved or dismissed
+	AuthorID      string     `json:"authorId,omitempty"`      // resolve_reports; empty for every author
+	Summary       string     `json:"summary,omitempty"`       // resolve_reports
+	IP            string     `json:"ip,omitempty"`            // purge: an address or CIDR range
+	SignedUpFrom  *time.Time `json:"signedUpFrom,omitempty"`  // purge
+	SignedUpTo    *time.Time `json:"signedUpTo,omitempty"`    // purge
+}
+
+// BulkTarget is one user, post, comment or reported piece of content a
+// bulk operation acts on. Note flags targets that will fail, such as
+// unknown users.
+type BulkTarget struct {
+	Type  string `json:"type"` // user, post or comment
+	ID    string `json:"id"`
+	Label string `json:"label,omitempty"`
+	Note  string `json:"note,omitempty"`
+}
+
+// BulkFailure is a target the operation couldn't act on
+type BulkFailure struct {
+	Target BulkTarget `json:"target"`
+	Error  string     `json:"error"`
+}
+
+// BulkOperation is a bulk moderation operation run by a background job.
+// Targets are resolved when the job starts; only the first ones are
+// returned. Dry runs resolve the targets and change nothing.
+type BulkOperation struct {
+	ID          string               `json:"id"`
+	Kind        string               `json:"kind"`
+	Params      BulkOperationRequest `json:"params"`
+	DryRun      bool                 `json:"dryRun"`
+	Status      string               `json:"status"`
+	Total       int                  `json:"total"`
+	Processed   int                  `json:"processed"`
+	Succeeded   int                  `json:"succeeded"`
+	Failed      int                  `json:"failed"`
+	Targets     []BulkTarget         `json:"targets"`
+	Failures    []BulkFailure        `json:"failures"`
+	Error       string               `json:"error,omitempty"`
+	CreatedBy   string               `json:"createdBy,omitempty"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	StartedAt   *time.Time           `json:"startedAt,omitempty"`
+	CompletedAt *time.Time           `json:"completedAt,omitempty"`
+}
+
+// ShadowBan is a user whose posts, comments and activity are shown to
+// nobody but themselves
+type ShadowBan struct {
+	User           *User     `json:"user"`
+	Reason         string    `json:"reason"`
+	ShadowBannedBy string    `json:"shadowBannedBy,omitempty"`
+	ShadowBannedAt time.Time `json:"shadowBannedAt"`
+}
+
+// ShadowBanRequest shadow-bans a user
+type ShadowBanRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ShadowBannedContent is a post or comment hidden by its author's shadow ban
+type ShadowBannedContent struct {
+	Type      string    `json:"type"` // "post" or "comment"
+	ID        string    `json:"id"`
+	PostID    string    `json:"postId"`
+	Author    *User     `json:"author"`
+	Title     string    `json:"title,omitempty"` // Posts only
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Filter rule kinds
+const (
+	FilterKindWord        = "word"         // Case-insensitive word or phrase
+	FilterKindRegex       = "regex"        // RE2 regular expression; prefix (?i) to ignore case
+	FilterKindDomainBlock = "domain_block" // Links to the domain or its subdomains
+	FilterKindDomainAllow = "domain_allow" // Exempts the domain and its subdomains from domain_block rules
+)
+
+// Filter rule actions, weakest first. A write takes the strongest action
+// of the rules it matches.
+const (
+	FilterActionAllow  = "allow"  // Only in verdicts: nothing matched
+	FilterActionMask   = "mask"   // Publish with the matches replaced by asterisks
+	FilterActionFlag   = "flag"   // Publish and open a report for moderators
+	FilterActionHold   = "hold"   // Queue the write until an admin approves it
+	FilterActionReject = "reject" // Refuse the write
+)
+
+// Filter rule targets
+const (
+	FilterTargetPost    = "post"
+	FilterTargetComment = "comment"
+	FilterTargetProfile = "profile" // Profiles can't be reported, so flag rules hold profile updates
+)
+
+// FilterRule is an admin-managed rule checked against posts, comments and
+// profile updates. Domain allow rules have no action.
+type FilterRule struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Pattern     string    `json:"pattern"`
+	Action      string    `json:"action,omitempty"`
+	AppliesTo   []string  `json:"appliesTo"`
+	Description string    `json:"description,omitempty"`
+	IsActive    bool      `json:"isActive"`
+	CreatedBy   string    `json:"createdBy,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CreateFilterRuleRequest adds a rule. AppliesTo defaults to every target
+// and IsActive to true.
+type CreateFilterRuleRequest struct {
+	Kind        string   `json:"kind"`
+	Pattern     string   `json:"pattern"`
+	Action      string   `json:"action,omitempty"`
+	AppliesTo   []string `json:"appliesTo,omitempty"`
+	Description string   `json:"description,omitempty"`
+	IsActive    *bool    `json:"isActive,omitempty"`
+}
+
+// UpdateFilterRuleRequest changes only the fields that are set. A rule's
+// kind can't be changed.
+type UpdateFilterRuleRequest struct {
+	Pattern     *string  `json:"pattern,omitempty"`
+	Action      *string  `json:"action,omitempty"`
+	AppliesTo   []string `json:"appliesTo,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	IsActive    *bool    `json:"isActive,omitempty"`
+}
+
+// FilterMatch is one rule matching one field
+type FilterMatch struct {
+	RuleID  string `json:"ruleId,omitempty"` // Empty for the draft rule of a dry run
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Field   string `json:"field"`
+	Text    string `json:"text"` // The first matching text
+}
+
+// FilterVerdict is the outcome of checking a write against the filter rules
+type FilterVerdict struct {
+	Action  string        `json:"action"`
+	Matches []FilterMatch `json:"matches"`
+}
+
+// FilterTestRequest dry-runs the active rules, plus an optional unsaved
+// rule, against sample text. Target defaults to post.
+type FilterTestRequest struct {
+	Target string                   `json:"target,omitempty"`
+	Text   string                   `json:"text"`
+	Rule   *CreateFilterRuleRequest `json:"rule,omitempty"`
+}
+
+// FilterTestResponse is a dry run's verdict and the text as it would be
+// published
+type FilterTestResponse struct {
+	Verdict *FilterVerdict `json:"verdict"`
+	Masked  string         `json:"masked"`
+}
+
+// Moderation queue item kinds
+const (
+	QueueKindPost          = "post"
+	QueueKindPostUpdate    = "post_update"
+	QueueKindComment       = "comment"
+	QueueKindCommentUpdate = "comment_update"
+	QueueKindProfile       = "profile"
+	QueueKindMedia         = "media" // An upload, stored but not attachable until approved
+)
+
+// Why a write was held for review
+const (
+	QueueSourceFilter     = "filter"     // It matched a hold filter rule
+	QueueSourceTrust      = "trust"      // Its author is a new account
+	QueueSourceClassifier = "classifier" // The spam classifier scored it above the hold threshold
+)
+
+// Moderation queue statuses
+const (
+	QueueStatusPending  = "pending"
+	QueueStatusApproved = "approved"
+	QueueStatusRejected = "rejected"
+)
+
+// QueueItem is a write held for review. Payload is the request that is
+// replayed when an admin approves it; TargetID is the post commented on,
+// the post or comment being edited, or the uploaded media.
+type QueueItem struct {
+	ID          string          `json:"id"`
+	UserID      string          `json:"userId"`
+	Kind        string          `json:"kind"`
+	Source      string          `json:"source"`
+	TargetID    string          `json:"targetId,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	Reason      string          `json:"reason"`
+	RuleIDs     []string        `json:"ruleIds"`
+	Status      string          `json:"status"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	ReviewedAt  *time.Time      `json:"reviewedAt,omitempty"`
+	ReviewedBy  string          `json:"reviewedBy,omitempty"`
+	ReviewNote  string          `json:"reviewNote,omitempty"`
+	Spam        bool            `json:"spam"`                  // Rejected as spam
+	PublishedID string          `json:"publishedId,omitempty"` // The post or comment created on approval
+}
+
+// ReviewQueueItemRequest approves or rejects a queued write. Spam tags a
+// rejection as spam, which the spam classifier trains on.
+type ReviewQueueItemRequest struct {
+	Note string `json:"note,omitempty"`
+	Spam bool   `json:"spam,omitempty"` // Only when rejecting
+}
+
+// Trust levels, computed on a schedule from account age, email
+// verification, posts, likes received and upheld reports
+const (
+	TrustLevelNew     = 0 // First posts, posts with links and media uploads are held for review
+	TrustLevelBasic   = 1
+	TrustLevelMember  = 2
+	TrustLevelTrusted = 3 // Admins are always trusted
+)
+
+// TrustRequirement is what a user needs to reach a trust level, on top of
+// the requirements of the levels below it
+type TrustRequirement struct {
+	Level             int    `json:"level"`
+	Name              string `json:"name"`
+	MinAccountAgeDays int    `json:"minAccountAgeDays"`
+	EmailVerified     bool   `json:"emailVerified"`
+	MinPosts          int    `json:"minPosts"`
+	MinLikesReceived  int    `json:"minLikesReceived"`
+	MaxUpheldReports  int    `json:"maxUpheldReports"` // Within the upheld report window
+}
+
+// TrustStats is what a user's trust level is computed from
+type TrustStats struct {
+	AccountAgeDays int  `json:"accountAgeDays"`
+	EmailVerified  bool `json:"emailVerified"`
+	Posts          int  `json:"posts"`
+	LikesReceived  int  `json:"likesReceived"`
+	UpheldReports  int  `json:"upheldReports"`
+}
+
+// TrustStatus is a user's trust level and how far they are from the next.
+// Level is the one in force, which can trail Stats until the next scheduled
+// recompute.
+type TrustStatus struct {
+	UserID    string            `json:"userId"`
+	Level     int               `json:"level"`
+	Name      string            `json:"name"`
+	UpdatedAt *time.Time        `json:"updatedAt,omitempty"`
+	Stats     TrustStats        `json:"stats"`
+	Next      *TrustRequirement `json:"next,omitempty"` // Not set at the top level
+}
+
+// SpamModel is a trained spam classifier. It only scores content once it
+// has seen enough spam and ham examples to be Active.
+type SpamModel struct {
+	ID            string         `json:"id"`
+	TrainedAt     time.Time      `json:"trainedAt"`
+	TrainedBy     string         `json:"trainedBy,omitempty"` // Empty for scheduled retraining
+	SpamExamples  int            `json:"spamExamples"`
+	HamExamples   int            `json:"hamExamples"`
+	Vocabulary    int            `json:"vocabulary"`
+	Active        bool           `json:"active"`
+	HoldThreshold float64        `json:"holdThreshold"`
+	FlagThreshold float64        `json:"flagThreshold"`
+	Evaluation    SpamEvaluation `json:"evaluation"`
+}
+
+// SpamEvaluation is how a model scored its training examples when each was
+// left out of training in turn, at the flag threshold
+type SpamEvaluation struct {
+	TruePositives  int     `json:"truePositives"`
+	FalsePositives int     `json:"falsePositives"`
+	TrueNegatives  int     `json:"trueNegatives"`
+	FalseNegatives int     `json:"falseNegatives"`
+	Accuracy       float64 `json:"accuracy"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+}
+
+// SpamFeature is a token the classifier scores. Weight is the log ratio of
+// how likely it is in spam to how likely it is in ham, so positive weights
+// point to spam.
+type SpamFeature struct {
+	Token        string  `json:"token"`
+	Weight       float64 `json:"weight"`
+	SpamExamples int     `json:"spamExamples"`
+	HamExamples  int     `json:"hamExamples"`
+}
+
+// SpamFeatureWeights lists a model's strongest features both ways
+type SpamFeatureWeights struct {
+	Model *SpamModel    `json:"model"`
+	Spam  []SpamFeature `json:"spam"`
+	Ham   []SpamFeature `json:"ham"`
+}
+
+// SpamMisclassification is a training example the model got wrong when it
+// was left out of training. Source is the queue item or report it came from.
+type SpamMisclassification struct {
+	Source     string  `json:"source"` // queue or report
+	SourceID   string  `json:"sourceId"`
+	TargetType string  `json:"targetType"` // post or comment
+	Label      string  `json:"label"`      // spam or ham, as moderators decided
+	Score      float64 `json:"score"`
+	Excerpt    string  `json:"excerpt"`
+}
+
+// SpamTestRequest scores sample text with the current model
+type SpamTestRequest struct {
+	Text string `json:"text"`
+}
+
+// SpamTestResponse is a dry run's score, what the classifier would do, and
+// the features in the text that moved the score most
+type SpamTestResponse struct {
+	Score    float64       `json:"score"`
+	Action   string        `json:"action"` // allow, flag or hold
+	Features []SpamFeature `json:"features"`
+}
+
+// Audited admin actions
+const (
+	AuditActionUserSuspend         = "user.suspend"
+	AuditActionUserUnsuspend       = "user.unsuspend" // Also written, without an actor, when a suspension expires
+	AuditActionUserShadowBan       = "user.shadow_ban"
+	AuditActionUserUnshadowBan     = "user.unshadow_ban"
+	AuditActionUserVerify          = "user.verify"
+	AuditActionUserPromote         = "user.promote"
+	AuditActionAdminCreate         = "admin.create"
+	AuditActionAdminUpdateRole     = "admin.update_role"
+	AuditActionAdminDelete         = "admin.delete"
+	AuditActionReportReview        = "report.review"
+	AuditActionReportResolve       = "report.resolve"
+	AuditActionReportDismiss       = "report.dismiss"
+	AuditActionWebhookCreate       = "webhook.create"
+	AuditActionWebhookUpdate       = "webhook.update"
+	AuditActionWebhookDelete       = "webhook.delete"
+	AuditActionWebhookRedeliver    = "webhook.redeliver"
+	AuditActionReputationRecompute = "reputation.recompute"
+	AuditActionFilterRuleCreate    = "filter_rule.create"
+	AuditActionFilterRuleUpdate    = "filter_rule.update"
+	AuditActionFilterRuleDelete    = "filter_rule.delete"
+	AuditActionQueueApprove        = "queue.approve"
+	AuditActionQueueReject         = "queue.reject"
+	AuditActionQueueReopen         = "queue.reopen" // Written when an approved write can't be published
+	AuditActionSpamModelRetrain    = "spam_model.retrain"
+	AuditActionAppealAccept        = "appeal.accept"
+	AuditActionAppealDeny          = "appeal.deny"
+	AuditActionBulkStart           = "bulk.start"
+	AuditActionBulkCancel          = "bulk.cancel"
+	AuditActionContentRemove       = "content.remove"
+	AuditActionUserPurge           = "user.purge"
+)
+
+// AuditEntry is one admin action in the append-only audit log. Before and
+// After hold the target's state around the action.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    string          `json:"actorId,omitempty"` // Empty for system actions
+	ActorRole  string          `json:"actorRole,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   string          `json:"targetId"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	IPAddress  string          `json:"ipAddress,omitempty"`
+	RequestID  string          `json:"requestId,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	PrevHash   string          `json:"prevHash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditListResponse is a cursor-paginated page of audit entries, newest first
+type AuditListResponse struct {
+	Entries    []*AuditEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// AuditVerification is the result of checking the audit log's hash chain.
+// BrokenAt is the first entry whose hash does not match.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt *int64 `json:"brokenAt,omitempty"`
+	Head     string `json:"head,omitempty"` // Hash of the newest entry
 }
 
 // Session represents a user session
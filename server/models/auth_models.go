@@ -6,7 +6,8 @@ import "time"
 type AuthRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
-	Name     string `json:"name,omitempty"` // Only for signup
+	Name     string `json:"name,omitempty"`   // Only for signup
+	Handle   string `json:"handle,omitempty"` // Only for signup (generated from name if empty)
 }
 
 // AuthResponse represents an authentication response
@@ -17,6 +18,8 @@ type AuthResponse struct {
 	User         *User    `json:"user"`
 	Roles        []string `json:"roles"`
 	Permissions  []string `json:"permissions"`
+	// DeletionCancelled is set when this login cancelled a pending account deletion
+	DeletionCancelled bool `json:"deletionCancelled,omitempty"`
 }
 
 // RefreshTokenRequest represents a token refresh request
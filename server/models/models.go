@@ -1,28 +1,50 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // User represents a user in the system
 type User struct {
-	ID             string    `firestore:"id" json:"id"`
-	Name           string    `firestore:"name" json:"name"`
-	Email          string    `firestore:"email" json:"email,omitempty"`
-	Avatar         string    `firestore:"avatar" json:"avatar"`
-	Bio            string    `firestore:"bio" json:"bio,omitempty"`
-	Location       string    `firestore:"location" json:"location,omitempty"`
-	Website        string    `firestore:"website" json:"website,omitempty"`
-	IsAdmin        bool      `firestore:"is_admin" json:"isAdmin"`
-	IsVerified     bool      `firestore:"is_verified" json:"isVerified"`
-	IsActive       bool      `firestore:"is_active" json:"isActive"`
-	Role           string    `firestore:"role" json:"role,omitempty"`
-	Provider       string    `firestore:"provider" json:"provider,omitempty"`
-	CreatedAt      time.Time `firestore:"created_at" json:"createdAt"`
-	UpdatedAt      time.Time `firestore:"updated_at" json:"updatedAt"`
-	PostsCount     int       `firestore:"posts_count" json:"posts_count,omitempty"`
-	FollowersCount int       `firestore:"followers_count" json:"followers_count,omitempty"`
-	FollowingCount int       `firestore:"following_count" json:"following_count,omitempty"`
+	ID             string       `firestore:"id" json:"id"`
+	Name           string       `firestore:"name" json:"name"`
+	Handle         string       `firestore:"handle" json:"handle,omitempty"`
+	Email          string       `firestore:"email" json:"email,omitempty"`
+	Avatar         string       `firestore:"avatar" json:"avatar"`
+	Bio            string       `firestore:"bio" json:"bio,omitempty"`
+	Location       string       `firestore:"location" json:"location,omitempty"`
+	Website        string       `firestore:"website" json:"website,omitempty"`
+	CoverPhoto     string       `firestore:"cover_photo" json:"coverPhoto,omitempty"`
+	SocialLinks    *SocialLinks `firestore:"social_links" json:"socialLinks,omitempty"`
+	Skills         []string     `firestore:"skills" json:"skills,omitempty"`
+	Pronouns       string       `firestore:"pronouns" json:"pronouns,omitempty"`
+	Employer       string       `firestore:"employer" json:"employer,omitempty"`
+	IsAdmin        bool         `firestore:"is_admin" json:"isAdmin"`
+	IsVerified     bool         `firestore:"is_verified" json:"isVerified"`
+	IsActive       bool         `firestore:"is_active" json:"isActive"`
+	EmailVerified  bool         `firestore:"-" json:"emailVerified"`
+	Role           string       `firestore:"role" json:"role,omitempty"`
+	Provider       string       `firestore:"provider" json:"provider,omitempty"`
+	CreatedAt      time.Time    `firestore:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time    `firestore:"updated_at" json:"updatedAt"`
+	PostsCount     int          `firestore:"posts_count" json:"posts_count,omitempty"`
+	FollowersCount int          `firestore:"followers_count" json:"followers_count,omitempty"`
+	FollowingCount int          `firestore:"following_count" json:"following_count,omitempty"`
+	Reputation     int          `firestore:"reputation" json:"reputation"`
+	Badges         []Badge      `firestore:"-" json:"badges,omitempty"`
+	IsPrivate      bool         `firestore:"-" json:"isPrivate,omitempty"` // set when only a limited profile is returned
+	// Relationship to the requesting user (only set for authenticated viewers)
+	IsFollowing *bool `firestore:"-" json:"isFollowing,omitempty"`
+	FollowsYou  *bool `firestore:"-" json:"followsYou,omitempty"`
+}
+
+// SocialLinks holds a user's profile links, stored as canonical URLs
+type SocialLinks struct {
+	GitHub   string `json:"github,omitempty"`
+	LinkedIn string `json:"linkedin,omitempty"`
+	X        string `json:"x,omitempty"`
+	Mastodon string `json:"mastodon,omitempty"`
 }
 
 // Post represents a post in the system
@@ -54,18 +76,22 @@ type MediaAttachment struct {
 	URL  string `firestore:"url" json:"url"`
 	Name string `firestore:"name" json:"name"`
 	Size int64  `firestore:"size" json:"size"`
+	// "pending" while a new account's upload awaits review
+	Status string `firestore:"-" json:"status,omitempty"`
 }
 
 // Comment represents a comment on a post
 type Comment struct {
-	ID        string    `firestore:"id" json:"id"`
-	PostID    string    `firestore:"post_id" json:"post_id"`
-	AuthorID  string    `firestore:"author_id" json:"author_id"`
-	Author    *User     `firestore:"-" json:"author,omitempty"`
-	Content   string    `firestore:"content" json:"content"`
-	Likes     int       `firestore:"likes" json:"likes"`
-	CreatedAt time.Time `firestore:"created_at" json:"createdAt"`
-	UpdatedAt time.Time `firestore:"updated_at" json:"updatedAt"`
+	ID         string    `firestore:"id" json:"id"`
+	PostID     string    `firestore:"post_id" json:"post_id"`
+	AuthorID   string    `firestore:"author_id" json:"author_id"`
+	ParentID   string    `firestore:"parent_id" json:"parentId,omitempty"` // Set on replies
+	Author     *User     `firestore:"-" json:"author,omitempty"`
+	Content    string    `firestore:"content" json:"content"`
+	Likes      int       `firestore:"likes" json:"likes"`
+	IsAccepted bool      `firestore:"-" json:"isAccepted,omitempty"` // Accepted as the answer by the post's author
+	CreatedAt  time.Time `firestore:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time `firestore:"updated_at" json:"updatedAt"`
 }
 
 // Like represents a like on a post or comment
@@ -85,6 +111,277 @@ type Bookmark struct {
 	CreatedAt time.Time `firestore:"created_at" json:"createdAt"`
 }
 
+// FollowListResponse is a cursor-paginated page of users in the follow graph
+type FollowListResponse struct {
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// FollowSuggestion is a ranked who-to-follow candidate
+type FollowSuggestion struct {
+	User        *User   `json:"user"`
+	Score       float64 `json:"score"`
+	Reason      string  `json:"reason"` // mutual_follows, shared_interests, verified, active
+	MutualCount int     `json:"mutualCount,omitempty"`
+}
+
+// DataExport represents a data export (takeout archive) job
+type DataExport struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"` // pending, processing, ready, failed, expired
+	SizeBytes   int64      `json:"sizeBytes,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	RequestedAt time.Time  `json:"requestedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Badge is an achievement awarded by a server-side rule
+type Badge struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	AwardedAt   *time.Time `json:"awardedAt,omitempty"`
+}
+
+// Notification types
+const (
+	NotificationLike    = "like"
+	NotificationComment = "comment"
+	NotificationReply   = "reply"
+	NotificationMention = "mention"
+	NotificationFollow  = "follow"
+)
+
+// Notification is one entry in a user's notification list. Similar
+// notifications (e.g. every like on the same post) are grouped into one.
+type Notification struct {
+	ID         string    `json:"id"` // Latest notification in the group
+	Type       string    `json:"type"`
+	Message    string    `json:"message"` // e.g. "Alice and 4 others liked your post"
+	Actors     []*User   `json:"actors"`  // Up to 3, most recent first
+	ActorCount int       `json:"actorCount"`
+	PostID     string    `json:"postId,omitempty"`
+	CommentID  string    `json:"commentId,omitempty"`
+	Read       bool      `json:"read"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// NotificationTopPosts is the preference for the digest section listing top
+// posts from people you follow. It only takes a digest channel or off.
+const NotificationTopPosts = "top_posts"
+
+// Notification delivery channels
+const (
+	ChannelInApp  = "in_app" // Listed in the app only
+	ChannelEmail  = "email"  // In the app and emailed right away
+	ChannelDaily  = "daily"  // In the app and summarised in a daily digest
+	ChannelWeekly = "weekly" // In the app and summarised in a weekly digest
+	ChannelOff    = "off"    // Not recorded at all
+)
+
+// DigestPost is a post featured in a digest email
+type DigestPost struct {
+	Title      string
+	AuthorName string
+	URL        string
+	Likes      int
+	Comments   int
+}
+
+// NotificationListResponse is a cursor-paginated page of notifications
+type NotificationListResponse struct {
+	Notifications []*Notification `json:"notifications"`
+	NextCursor    string          `json:"nextCursor,omitempty"`
+}
+
+// StreamEvent is pushed to clients connected to /api/v1/stream
+type StreamEvent struct {
+	ID      string          `json:"id"`   // Increasing; send back as Last-Event-ID to resume
+	Type    string          `json:"type"` // notification, comment, likes
+	ActorID string          `json:"actorId,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Conversation is a 1:1 or small group direct message thread
+type Conversation struct {
+	ID            string    `json:"id"`
+	IsGroup       bool      `json:"isGroup"`
+	Title         string    `json:"title,omitempty"`
+	Participants  []*User   `json:"participants"`
+	LastMessage   *Message  `json:"lastMessage,omitempty"`
+	UnreadCount   int       `json:"unreadCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastMessageAt time.Time `json:"lastMessageAt"`
+}
+
+// Message is a direct message. Deleted messages stay in the history
+// without their content.
+type Message struct {
+	ID             string            `json:"id"`
+	ConversationID string            `json:"conversationId"`
+	SenderID       string            `json:"senderId,omitempty"`
+	Content        string            `json:"content"`
+	Media          []MediaAttachment `json:"media,omitempty"`
+	ReadBy         []string          `json:"readBy"` // Other participants who have read it
+	CreatedAt      time.Time         `json:"createdAt"`
+	EditedAt       *time.Time        `json:"editedAt,omitempty"`
+	Deleted        bool              `json:"deleted,omitempty"`
+}
+
+// ConversationListResponse is a cursor-paginated page of conversations
+type ConversationListResponse struct {
+	Conversations []*Conversation `json:"conversations"`
+	NextCursor    string          `json:"nextCursor,omitempty"`
+}
+
+// MessageListResponse is a cursor-paginated page of messages, newest first
+type MessageListResponse struct {
+	Messages   []*Message `json:"messages"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// Webhook event types
+const (
+	WebhookEventPostCreated    = "post.created"
+	WebhookEventCommentCreated = "comment.created"
+	WebhookEventReportCreated  = "report.created"
+	WebhookEventUserBanned     = "user.banned"
+)
+
+// Webhook is an endpoint that receives signed POSTs for the events it
+// subscribes to
+type Webhook struct {
+	ID                  string     `json:"id"`
+	OwnerID             string     `json:"ownerId"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"` // Only returned when the webhook is created
+	Events              []string   `json:"events"`
+	Description         string     `json:"description,omitempty"`
+	IsActive            bool       `json:"isActive"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+	DisabledReason      string     `json:"disabledReason,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+// WebhookDelivery is one event sent (or queued) to one webhook
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	EventID        string          `json:"eventId"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // pending, succeeded, failed
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	ResponseBody   string          `json:"responseBody,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMs     int             `json:"durationMs,omitempty"`
+	RedeliveryOf   string          `json:"redeliveryOf,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	CompletedAt    *time.Time      `json:"completedAt,omitempty"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks
+type WebhookPayload struct {
+	ID        string      `json:"id"` // Same for every webhook and redelivery of an event
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Privacy setting values
+const (
+	ProfileVisibilityPublic    = "public"
+	ProfileVisibilityMembers   = "members"
+	ProfileVisibilityFollowers = "followers"
+
+	AudienceEveryone  = "everyone"
+	AudienceFollowers = "followers"
+	AudienceNobody    = "nobody"
+)
+
+// PrivacySettings controls who can see and interact with a user
+type PrivacySettings struct {
+	ProfileVisibility string    `json:"profileVisibility"` // public, members, followers
+	Searchable        bool      `json:"searchable"`
+	CommentPermission string    `json:"commentPermission"` // everyone, followers, nobody
+	MentionPermission string    `json:"mentionPermission"` // everyone, followers, nobody
+	MessagePermission string    `json:"messagePermission"` // everyone, followers, nobody
+	ShowLikes         bool      `json:"showLikes"`
+	ShowBookmarks     bool      `json:"showBookmarks"`
+	UpdatedAt         time.Time `json:"updatedAt,omitempty"`
+}
+
+// UpdatePrivacySettingsRequest changes only the fields that are set
+type UpdatePrivacySettingsRequest struct {
+	ProfileVisibility *string `json:"profileVisibility,omitempty"`
+	Searchable        *bool   `json:"searchable,omitempty"`
+	CommentPermission *string `json:"commentPermission,omitempty"`
+	MentionPermission *string `json:"mentionPermission,omitempty"`
+	MessagePermission *string `json:"messagePermission,omitempty"`
+	ShowLikes         *bool   `json:"showLikes,omitempty"`
+	ShowBookmarks     *bool   `json:"showBookmarks,omitempty"`
+}
+
+// LeaderboardEntry is one ranked user on the reputation leaderboard
+type LeaderboardEntry struct {
+	Rank   int   `json:"rank"`
+	User   *User `json:"user"`
+	Points int   `json:"points"` // Reputation earned in the requested period
+}
+
+// Account deletion modes
+const (
+	DeletionModeDelete    = "delete"    // Remove the account and everything it posted
+	DeletionModeAnonymize = "anonymize" // Keep posts and comments under "[deleted user]"
+)
+
+// DeleteAccountRequest confirms an account deletion. Email/password accounts
+// re-authenticate with their password; OAuth accounts with an emailed code.
+type DeleteAccountRequest struct {
+	Password string `json:"password,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Mode     string `json:"mode"` // delete or anonymize
+}
+
+// AccountDeletion represents a scheduled account deletion
+type AccountDeletion struct {
+	Mode         string    `json:"mode"`
+	Status       string    `json:"status"` // scheduled, processing, completed, cancelled
+	RequestedAt  time.Time `json:"requestedAt"`
+	ScheduledFor time.Time `json:"scheduledFor"`
+}
+
+// ChangeEmailRequest starts an email change. Only email/password accounts
+// can change their email here, so the password is always required.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"newEmail"`
+	Password string `json:"password"`
+}
+
+// ConfirmEmailChangeRequest carries the codes sent to the old and new
+// addresses. RefreshToken identifies the session to keep signed in.
+type ConfirmEmailChangeRequest struct {
+	OldCode      string `json:"oldCode"`
+	NewCode      string `json:"newCode"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// EmailChange is a requested or completed change of a user's email
+type EmailChange struct {
+	ID              string     `json:"id"`
+	NewEmail        string     `json:"newEmail"`
+	Status          string     `json:"status"` // pending, completed, cancelled, reverted
+	RequestedAt     time.Time  `json:"requestedAt"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	RevertExpiresAt *time.Time `json:"revertExpiresAt,omitempty"`
+}
+
 // AdminStats represents dashboard statistics
 type AdminStats struct {
 	TotalUsers       int `json:"total_users"`
@@ -122,16 +419,27 @@ type UpdatePostRequest struct {
 
 // UpdateProfileRequest represents a request to update user profile
 type UpdateProfileRequest struct {
-	Name     string `json:"name,omitempty"`
-	Bio      string `json:"bio,omitempty"`
-	Location string `json:"location,omitempty"`
-	Website  string `json:"website,omitempty"`
-	Avatar   string `json:"avatar,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Bio         string       `json:"bio,omitempty"`
+	Location    string       `json:"location,omitempty"`
+	Website     string       `json:"website,omitempty"`
+	Avatar      string       `json:"avatar,omitempty"`
+	CoverPhoto  string       `json:"coverPhoto,omitempty"`
+	SocialLinks *SocialLinks `json:"socialLinks,omitempty"` // Replaces all links when present
+	Skills      []string     `json:"skills,omitempty"`      // Replaces the list when present
+	Pronouns    *string      `json:"pronouns,omitempty"`    // Empty string clears
+	Employer    *string      `json:"employer,omitempty"`    // Empty string clears
+}
+
+// UpdateHandleRequest represents a request to change the user's handle
+type UpdateHandleRequest struct {
+	Handle string `json:"handle"`
 }
 
 // CreateCommentRequest represents a request to create a comment
 type CreateCommentRequest struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	ParentID string `json:"parentId,omitempty"` // Comment being replied to, on the same post
 }
 
 type UpdateCommentRequest struct {
@@ -151,6 +459,41 @@ type UpdateAdminRoleRequest struct {
 	Role string `json:"role"`
 }
 
+// CreateConversationRequest starts a conversation, or returns the existing
+// one for a single participant
+type CreateConversationRequest struct {
+	ParticipantIDs []string `json:"participantIds"`  // Everyone but the caller
+	Title          string   `json:"title,omitempty"` // Group conversations only
+}
+
+// SendMessageRequest sends a message with optional attachments uploaded
+// through /media/upload
+type SendMessageRequest struct {
+	Content  string   `json:"content"`
+	MediaIDs []string `json:"mediaIds,omitempty"`
+}
+
+// EditMessageRequest replaces a message's text
+type EditMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// CreateWebhookRequest registers a webhook
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+}
+
+// UpdateWebhookRequest changes only the fields that are set. Setting
+// isActive re-enables a webhook that was disabled after failures.
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	IsActive    *bool    `json:"isActive,omitempty"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
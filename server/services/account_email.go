@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrEmailManagedByProvider = errors.New("your email is managed by your sign-in provider")
+	ErrInvalidEmail           = errors.New("invalid email address")
+	ErrEmailUnchanged         = errors.New("new email is the same as the current one")
+	ErrEmailTaken             = errors.New("email is already in use")
+	ErrNoEmailChangePending   = errors.New("no email change is pending")
+	ErrEmailChangeCode        = errors.New("invalid or expired verification code")
+	ErrRevertUnavailable      = errors.New("this link is invalid or has expired")
+)
+
+// otpPurposeEmailChange is the otps.type used for both email change codes
+const otpPurposeEmailChange = "email_change"
+
+// RequestEmailChange starts changing user's email to newEmail by sending a
+// code to each address. Any earlier pending change is cancelled. The caller
+// must have re-authenticated.
+func (s *AccountService) RequestEmailChange(ctx context.Context, user *models.User, newEmail string) (*models.EmailChange, error) {
+	if user.Provider != "" && user.Provider != "email" {
+		return nil, ErrEmailManagedByProvider
+	}
+	newEmail = strings.ToLower(strings.TrimSpace(newEmail))
+	if !utils.ValidateEmail(newEmail) {
+		return nil, ErrInvalidEmail
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return nil, ErrEmailUnchanged
+	}
+	if taken, err := s.emailTaken(ctx, newEmail, user.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, ErrEmailTaken
+	}
+	if s.twoFAService == nil || s.emailService == nil {
+		return nil, errors.New("email change is not configured")
+	}
+
+	now := time.Now().UTC()
+	change := &models.EmailChange{NewEmail: newEmail, Status: "pending", RequestedAt: now}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE public.email_changes SET status = 'cancelled' WHERE user_id = $1 AND status = 'pending'", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.email_changes (user_id, old_email, new_email, status, requested_at)
+		VALUES ($1, $2, $3, 'pending', $4)
+		RETURNING id
+	`, user.ID, user.Email, newEmail, now).Scan(&change.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, email := range []string{user.Email, newEmail} {
+		otp, err := s.twoFAService.CreateOTP(ctx, user.ID, email, otpPurposeEmailChange)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.emailService.SendOTPEmail(ctx, email, otp.Code, otpPurposeEmailChange); err != nil {
+			return nil, fmt.Errorf("failed to send verification code: %w", err)
+		}
+	}
+
+	s.logEvent(ctx, user.ID, "email_change_requested", newEmail)
+	return change, nil
+}
+
+// ConfirmEmailChange completes the pending change once both codes check out.
+// The new email is written to Supabase Auth and the profile, every other
+// session is signed out, and the old address is sent a revert link.
+// keepSessionID is the refresh token of the caller's session (may be empty)
+// and accessToken is the caller's Supabase JWT.
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, user *models.User, oldCode, newCode, keepSessionID, accessToken string) (*models.EmailChange, error) {
+	if s.twoFAService == nil {
+		return nil, errors.New("email change is not configured")
+	}
+
+	var change models.EmailChange
+	var oldEmail string
+	err := database.QueryRowWithContext(ctx, `
+		SELECT id, old_email, new_email, requested_at
+		FROM public.email_changes
+		WHERE user_id = $1 AND status = 'pending' AND requested_at > $2
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`, user.ID, time.Now().UTC().Add(-constants.EmailChangeCodeExpiry)).Scan(
+		&change.ID, &oldEmail, &change.NewEmail, &change.RequestedAt,
+	)
+	if err == sql.ErrNoRows || (err == nil && !strings.EqualFold(oldEmail, user.Email)) {
+		return nil, ErrNoEmailChangePending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if oldCode == "" || newCode == "" {
+		return nil, ErrEmailChangeCode
+	}
+	if valid, err := s.twoFAService.VerifyOTP(ctx, oldEmail, oldCode, otpPurposeEmailChange); err != nil || !valid {
+		return nil, ErrEmailChangeCode
+	}
+	if valid, err := s.twoFAService.VerifyOTP(ctx, change.NewEmail, newCode, otpPurposeEmailChange); err != nil || !valid {
+		return nil, ErrEmailChangeCode
+	}
+
+	now := time.Now().UTC()
+	revertBy := now.Add(constants.EmailChangeRevertWindow)
+
+	// Update our copy first and only commit once Supabase Auth has accepted
+	// the new address, so the two never disagree
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE public.users SET email = $1, email_verified_at = $2, updated_at = $2 WHERE id = $3", change.NewEmail, now, user.ID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.email_changes
+		SET status = 'completed', completed_at = $1, revert_expires_at = $2
+		WHERE id = $3
+	`, now, revertBy, change.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.supabaseAdmin(ctx, "PUT", user.ID, map[string]interface{}{
+		"email":         change.NewEmail,
+		"email_confirm": true,
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	change.Status = "completed"
+	change.CompletedAt = &now
+	change.RevertExpiresAt = &revertBy
+
+	_, _ = database.ExecWithContext(ctx,
+		"UPDATE public.sessions SET is_active = FALSE WHERE user_id = $1 AND id <> $2", user.ID, keepSessionID)
+	if err := s.supabaseSignOutOthers(ctx, accessToken); err != nil {
+		log.Printf("Failed to sign out other sessions for user %s: %v", user.ID, err)
+	}
+	s.logEvent(ctx, user.ID, "email_changed", oldEmail+" -> "+change.NewEmail)
+
+	if s.emailService != nil {
+		if err := s.emailService.SendEmailChangedEmail(ctx, oldEmail, change.NewEmail, s.revertLink(change.ID, revertBy), revertBy); err != nil {
+			log.Printf("Failed to send email change notice to user %s: %v", user.ID, err)
+		}
+	}
+
+	return &change, nil
+}
+
+// CheckEmailChangeRevert reports whether a signed revert link can still be
+// used, without changing anything
+func (s *AccountService) CheckEmailChangeRevert(ctx context.Context, changeID, expires, sig string) error {
+	if !utils.VerifyExpiring(s.cfg.SigningSecret, sig, expires, "email-change-revert", changeID) {
+		return ErrRevertUnavailable
+	}
+	var revertible bool
+	err := database.QueryRowWithContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM public.email_changes
+			WHERE id = $1 AND status = 'completed' AND revert_expires_at > $2
+		)
+	`, changeID, time.Now().UTC()).Scan(&revertible)
+	if err != nil {
+		return err
+	}
+	if !revertible {
+		return ErrRevertUnavailable
+	}
+	return nil
+}
+
+// RevertEmailChange restores the old address from a signed revert link and
+// signs the account out everywhere. Later changes by the same user are
+// reverted too, so a hijacker can't dodge the revert by changing it again.
+// The password is scrambled, which also revokes every Supabase Auth
+// session, so the owner has to reset it before signing in again.
+func (s *AccountService) RevertEmailChange(ctx context.Context, changeID, expires, sig string) error {
+	if !utils.VerifyExpiring(s.cfg.SigningSecret, sig, expires, "email-change-revert", changeID) {
+		return ErrRevertUnavailable
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID, oldEmail string
+	var completedAt time.Time
+	now := time.Now().UTC()
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, old_email, completed_at
+		FROM public.email_changes
+		WHERE id = $1 AND status = 'completed' AND revert_expires_at > $2
+		FOR UPDATE
+	`, changeID, now).Scan(&userID, &oldEmail, &completedAt)
+	if err == sql.ErrNoRows {
+		return ErrRevertUnavailable
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE public.users SET email = $1, updated_at = $2 WHERE id = $3", oldEmail, now, userID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.email_changes
+		SET status = CASE WHEN status = 'pending' THEN 'cancelled' ELSE 'reverted' END, reverted_at = $1
+		WHERE user_id = $2 AND (id = $3 OR (status = 'completed' AND completed_at >= $4) OR status = 'pending')
+	`, now, userID, changeID, completedAt)
+	if err != nil {
+		return err
+	}
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return err
+	}
+	// Setting the password through the Admin API signs the user out of
+	// every Supabase session; doing it with the email update means the
+	// revert never commits while a hijacker's refresh tokens still work
+	if err := s.supabaseAdmin(ctx, "PUT", userID, map[string]interface{}{
+		"email":         oldEmail,
+		"password":      hex.EncodeToString(password),
+		"email_confirm": true,
+	}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	_, _ = database.ExecWithContext(ctx, "UPDATE public.sessions SET is_active = FALSE WHERE user_id = $1", userID)
+	s.logEvent(ctx, userID, "email_change_reverted", oldEmail)
+	return nil
+}
+
+// revertLink returns the signed link emailed to the old address
+func (s *AccountService) revertLink(changeID string, expiresAt time.Time) string {
+	sig := utils.SignExpiring(s.cfg.SigningSecret, expiresAt, "email-change-revert", changeID)
+	return fmt.Sprintf("%s/api/v1/email-changes/%s/revert?expires=%d&sig=%s",
+		s.cfg.APIBaseURL, url.PathEscape(changeID), expiresAt.Unix(), sig)
+}
+
+// emailTaken reports whether another account already uses email
+func (s *AccountService) emailTaken(ctx context.Context, email, userID string) (bool, error) {
+	var taken bool
+	err := database.QueryRowWithContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM public.users WHERE LOWER(email) = LOWER($1) AND id <> $2)", email, userID,
+	).Scan(&taken)
+	return taken, err
+}
+
+// supabaseSignOutOthers revokes every Supabase session of the token's owner
+// except the one the token belongs to
+func (s *AccountService) supabaseSignOutOthers(ctx context.Context, accessToken string) error {
+	if accessToken == "" {
+		return nil
+	}
+	reqURL := fmt.Sprintf("%s/auth/v1/logout?scope=others", s.cfg.SupabaseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", reqURL, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("apikey", s.cfg.SupabaseAnonKey)
+	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("supabase logout failed: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReauthRequired      = errors.New("password or verification code required")
+	ErrReauthFailed        = errors.New("re-authentication failed")
+	ErrInvalidDeletionMode = errors.New("mode must be 'delete' or 'anonymize'")
+	ErrLastSuperAdmin      = errors.New("cannot delete the last super admin")
+	ErrNoDeletionScheduled = errors.New("no account deletion is scheduled")
+)
+
+// maxAccountDeletionTries is how many times a failed deletion is retried
+const maxAccountDeletionTries = 5
+
+// AccountService handles account lifecycle operations: re-authentication
+// for sensitive changes and self-service deletion
+type AccountService struct {
+	db           *sql.DB
+	cfg          *config.Config
+	twoFAService *TwoFAService
+	emailService *EmailService
+}
+
+// NewAccountService creates a new AccountService instance
+func NewAccountService(db *sql.DB, cfg *config.Config, twoFAService *TwoFAService, emailService *EmailService) *AccountService {
+	return &AccountService{db: db, cfg: cfg, twoFAService: twoFAService, emailService: emailService}
+}
+
+// Reauthenticate confirms the caller still controls the account. Email
+// accounts give their password; OAuth accounts have no password here, so
+// they confirm with a code from POST /auth/2fa/send-otp instead.
+func (s *AccountService) Reauthenticate(ctx context.Context, user *models.User, password, code string) error {
+	if user.Provider == "" || user.Provider == "email" {
+		if password == "" {
+			return ErrReauthRequired
+		}
+		if _, err := utils.VerifyPasswordWithSupabase(ctx, s.cfg.SupabaseURL, s.cfg.SupabaseAnonKey, user.Email, password); err != nil {
+			return ErrReauthFailed
+		}
+		return nil
+	}
+
+	if code == "" {
+		return ErrReauthRequired
+	}
+	if s.twoFAService == nil {
+		return ErrReauthFailed
+	}
+	valid, err := s.twoFAService.VerifyOTP(ctx, user.Email, code, "2fa")
+	if err != nil || !valid {
+		return ErrReauthFailed
+	}
+	return nil
+}
+
+// RequestDeletion schedules the account for deletion after the grace period
+// and signs it out everywhere. Requesting again restarts the grace period.
+func (s *AccountService) RequestDeletion(ctx context.Context, user *models.User, mode string) (*models.AccountDeletion, error) {
+	if mode == "" {
+		mode = models.DeletionModeAnonymize
+	}
+	if mode != models.DeletionModeDelete && mode != models.DeletionModeAnonymize {
+		return nil, ErrInvalidDeletionMode
+	}
+
+	if user.Role == models.RoleSuperAdmin {
+		var count int
+		err := database.QueryRowWithContext(ctx,
+			"SELECT COUNT(*) FROM public.users WHERE role = 'super_admin' AND is_active = true").Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count <= 1 {
+			return nil, ErrLastSuperAdmin
+		}
+	}
+
+	now := time.Now().UTC()
+	deletion := &models.AccountDeletion{
+		Mode:         mode,
+		Status:       "scheduled",
+		RequestedAt:  now,
+		ScheduledFor: now.Add(constants.AccountDeletionGracePeriod),
+	}
+
+	_, err := database.ExecWithContext(ctx, `
+		INSERT INTO public.account_deletions (user_id, mode, status, requested_at, scheduled_for, attempts)
+		VALUES ($1, $2, 'scheduled', $3, $4, 0)
+		ON CONFLICT (user_id) DO UPDATE SET
+			mode = EXCLUDED.mode, status = 'scheduled', requested_at = EXCLUDED.requested_at,
+			scheduled_for = EXCLUDED.scheduled_for, started_at = NULL, cancelled_at = NULL,
+			completed_at = NULL, attempts = 0, error = NULL
+	`, user.ID, mode, deletion.RequestedAt, deletion.ScheduledFor)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = database.ExecWithContext(ctx, "UPDATE public.sessions SET is_active = FALSE WHERE user_id = $1", user.ID)
+	s.logEvent(ctx, user.ID, "account_deletion_requested", mode)
+
+	if s.emailService != nil {
+		if err := s.emailService.SendAccountDeletionEmail(ctx, user.Email, deletion.ScheduledFor); err != nil {
+			log.Printf("Failed to send account deletion email to user %s: %v", user.ID, err)
+		}
+	}
+
+	return deletion, nil
+}
+
+// GetDeletion returns the user's pending or failed deletion, or
+// ErrNoDeletionScheduled
+func (s *AccountService) GetDeletion(ctx context.Context, userID string) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	err := database.QueryRowWithContext(ctx, `
+		SELECT mode, status, requested_at, scheduled_for
+		FROM public.account_deletions
+		WHERE user_id = $1 AND status IN ('scheduled', 'processing', 'failed')
+	`, userID).Scan(&deletion.Mode, &deletion.Status, &deletion.RequestedAt, &deletion.ScheduledFor)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoDeletionScheduled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// CancelDeletion cancels a deletion that is still in its grace period.
+// Returns false if there was nothing to cancel.
+func (s *AccountService) CancelDeletion(ctx context.Context, userID string) (bool, error) {
+	result, err := database.ExecWithContext(ctx, `
+		UPDATE public.account_deletions
+		SET status = 'cancelled', cancelled_at = $1
+		WHERE user_id = $2 AND status = 'scheduled'
+	`, time.Now().UTC(), userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	s.logEvent(ctx, userID, "account_deletion_cancelled", "")
+	return true, nil
+}
+
+// ProcessDueDeletion carries out the oldest deletion whose grace period has
+// ended. A deletion still failing after maxAccountDeletionTries attempts is
+// marked failed and logged for an operator to finish by hand. Returns false
+// when there was nothing to do.
+func (s *AccountService) ProcessDueDeletion(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	rows, err := database.QueryWithContext(ctx, `
+		UPDATE public.account_deletions SET status = 'failed', completed_at = $1
+		WHERE status = 'processing' AND started_at < $2 AND attempts >= $3
+		RETURNING user_id, COALESCE(error, '')
+	`, now, now.Add(-time.Hour), maxAccountDeletionTries)
+	if err != nil {
+		return false, err
+	}
+	for rows.Next() {
+		var failedID, lastErr string
+		if err := rows.Scan(&failedID, &lastErr); err != nil {
+			rows.Close()
+			return false, err
+		}
+		log.Printf("ALERT: account deletion for user %s failed after %d attempts and needs manual cleanup: %s",
+			failedID, maxAccountDeletionTries, lastErr)
+		s.logEvent(ctx, failedID, "account_deletion_failed", lastErr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	var userID, mode string
+	err = database.QueryRowWithContext(ctx, `
+		UPDATE public.account_deletions
+		SET status = 'processing', started_at = $1, attempts = attempts + 1
+		WHERE user_id = (
+			SELECT user_id FROM public.account_deletions
+			WHERE (status = 'scheduled' AND scheduled_for <= $1)
+				OR (status = 'processing' AND started_at < $2 AND attempts < $3)
+			ORDER BY scheduled_for
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING user_id, mode
+	`, now, now.Add(-time.Hour), maxAccountDeletionTries).Scan(&userID, &mode)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.deleteAccount(ctx, userID, mode); err != nil {
+		// Left in processing; it is picked up again once started_at is stale
+		log.Printf("Account deletion for user %s failed: %v", userID, err)
+		_, _ = database.ExecWithContext(ctx,
+			"UPDATE public.account_deletions SET error = $1 WHERE user_id = $2", err.Error(), userID)
+		return true, nil
+	}
+
+	_, err = database.ExecWithContext(ctx, `
+		UPDATE public.account_deletions SET status = 'completed', completed_at = $1, error = NULL WHERE user_id = $2
+	`, time.Now().UTC(), userID)
+	return true, err
+}
+
+// deleteAccount removes or anonymizes the account. Every step is safe to
+// repeat, so a failure part-way through can simply be retried.
+func (s *AccountService) deleteAccount(ctx context.Context, userID, mode string) error {
+	objectPaths, err := s.storageObjects(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM public.users WHERE id = $1)", userID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		if err := removePersonalData(ctx, tx, userID); err != nil {
+			return err
+		}
+		if mode == models.DeletionModeDelete {
+			err = removeContent(ctx, tx, userID)
+		} else {
+			err = anonymizeUser(ctx, tx, userID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(objectPaths) > 0 {
+		if err := storageDelete(ctx, s.cfg, objectPaths...); err != nil {
+			log.Printf("Failed to remove storage objects for deleted user %s: %v", userID, err)
+		}
+	}
+
+	// Deleting the auth user cascades to whatever is left of public.users.
+	// Anonymized accounts keep their row, so the auth user is scrambled and
+	// banned instead.
+	if mode == models.DeletionModeDelete {
+		return s.supabaseAdmin(ctx, "DELETE", userID, nil)
+	}
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return err
+	}
+	return s.supabaseAdmin(ctx, "PUT", userID, map[string]interface{}{
+		"email":         deletedUserEmail(userID),
+		"password":      hex.EncodeToString(password),
+		"email_confirm": true,
+		"user_metadata": map[string]interface{}{},
+		"ban_duration":  "876000h",
+	})
+}
+
+// storageObjects lists the user's media uploads and export archives in storage
+func (s *AccountService) storageObjects(ctx context.Context, userID string) ([]string, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT url FROM public.media WHERE user_id = $1
+		UNION ALL
+		SELECT avatar FROM public.users WHERE id = $1 AND avatar IS NOT NULL
+		UNION ALL
+		SELECT cover_photo FROM public.users WHERE id = $1 AND cover_photo IS NOT NULL
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if p := storagePathFromURL(s.cfg, url); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = database.QueryWithContext(ctx,
+		"SELECT object_path FROM public.data_exports WHERE user_id = $1 AND object_path IS NOT NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// removePersonalData drops everything about the user that isn't public
+// content, fixing the counters on the other side of each relationship
+func removePersonalData(ctx context.Context, tx *sql.Tx, userID string) error {
+	// Reputation the user gave others through the likes and follows removed below
+	err := revokeReputation(ctx, tx, "actor_id = $1 AND user_id <> $1 AND event_type = ANY($2)",
+		userID, pq.Array([]string{ReputationPostLikeReceived, ReputationCommentLikeReceived, ReputationFollowerGained}))
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		// Follow counters on the people they followed and who followed them
+		`UPDATE public.users u SET followers_count = GREATEST(u.followers_count - 1, 0)
+			FROM public.follows f WHERE f.follower_id = $1 AND f.following_id = u.id`,
+		`UPDATE public.users u SET following_count = GREATEST(u.following_count - 1, 0)
+			FROM public.follows f WHERE f.following_id = $1 AND f.follower_id = u.id`,
+		"DELETE FROM public.follows WHERE follower_id = $1 OR following_id = $1",
+		// Like counts on posts they liked
+		`UPDATE public.posts p SET likes = GREATEST(p.likes - 1, 0)
+			FROM public.likes l WHERE l.user_id = $1 AND l.post_id = p.id`,
+		"DELETE FROM public.likes WHERE user_id = $1",
+		"DELETE FROM public.bookmarks WHERE user_id = $1",
+		"DELETE FROM public.user_blocks WHERE blocker_id = $1 OR blocked_id = $1",
+		"DELETE FROM public.user_mutes WHERE muter_id = $1 OR muted_id = $1",
+		"DELETE FROM public.follow_suggestions WHERE user_id = $1 OR candidate_id = $1",
+		"DELETE FROM public.handle_history WHERE user_id = $1",
+		"DELETE FROM public.user_privacy_settings WHERE user_id = $1",
+		"DELETE FROM public.notifications WHERE user_id = $1 OR actor_id = $1",
+		"DELETE FROM public.notification_preferences WHERE user_id = $1",
+		"DELETE FROM public.notification_digests WHERE user_id = $1",
+		"DELETE FROM public.webhooks WHERE owner_id = $1",
+		"UPDATE public.messages SET content = '', deleted_at = COALESCE(deleted_at, NOW()) WHERE sender_id = $1",
+		"DELETE FROM public.conversation_participants WHERE user_id = $1",
+		"UPDATE public.reports SET snapshot = NULL WHERE snapshot->>'authorId' = $1",
+		"DELETE FROM public.email_changes WHERE user_id = $1",
+		"DELETE FROM public.sessions WHERE user_id = $1",
+		"DELETE FROM public.otps WHERE user_id = $1",
+		"DELETE FROM public.two_factor_auth WHERE user_id = $1",
+		"DELETE FROM public.account_lockouts WHERE user_id = $1",
+		"DELETE FROM public.data_exports WHERE user_id = $1",
+		"DELETE FROM public.media WHERE user_id = $1",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeContent deletes the user's posts and comments along with replies
+// hanging off them, keeping post comment counts and global counters right
+func removeContent(ctx context.Context, tx *sql.Tx, userID string) error {
+	rows, err := tx.QueryContext(ctx, `
+		WITH RECURSIVE doomed AS (
+			SELECT c.id FROM public.comments c
+			WHERE c.author_id = $1
+				OR c.post_id IN (SELECT id FROM public.posts WHERE author_id = $1)
+			UNION
+			SELECT c.id FROM public.comments c JOIN doomed d ON c.parent_id = d.id
+		)
+		SELECT id FROM doomed
+	`, userID)
+	if err != nil {
+		return err
+	}
+	var commentIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		commentIDs = append(commentIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Reputation others earned from the removed comments (e.g. accepted
+	// answers on the user's posts); the user's own events go with their row
+	err = revokeReputation(ctx, tx, "user_id <> $1 AND source_id = ANY($2::uuid[])", userID, pq.Array(commentIDs))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if len(commentIDs) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.posts p SET comments = GREATEST(p.comments - d.n, 0)
+			FROM (
+				SELECT post_id, COUNT(*) AS n FROM public.comments
+				WHERE id = ANY($1::uuid[]) GROUP BY post_id
+			) d
+			WHERE p.id = d.post_id AND p.author_id <> $2
+		`, pq.Array(commentIDs), userID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM public.comments WHERE id = ANY($1::uuid[])", pq.Array(commentIDs)); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.counters SET count = GREATEST(count - $1, 0), updated_at = $2 WHERE collection_name = 'comments'
+		`, len(commentIDs), now)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM public.posts WHERE author_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.counters SET count = GREATEST(count - $1, 0), updated_at = $2 WHERE collection_name = 'posts'
+		`, n, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM public.users WHERE id = $1", userID)
+	return err
+}
+
+// anonymizeUser strips the profile down to a "[deleted user]" tombstone
+// that their posts and comments stay attached to
+func anonymizeUser(ctx context.Context, tx *sql.Tx, userID string) error {
+	now := time.Now().UTC()
+	_, err := tx.ExecContext(ctx, `
+		UPDATE public.users SET
+			name = $1, handle = NULL, handle_skeleton = NULL, handle_changed_at = NULL,
+			email = $2, avatar = NULL, cover_photo = NULL, bio = NULL, location = NULL, website = NULL,
+			social_links = '{}'::jsonb, skills = '{}', pronouns = NULL, employer = NULL,
+			is_admin = false, is_verified = false, is_active = false, role = 'user',
+			followers_count = 0, following_count = 0, deleted_at = $3, updated_at = $3
+		WHERE id = $4
+	`, constants.DeletedUserName, deletedUserEmail(userID), now, userID)
+	return err
+}
+
+// deletedUserEmail is a unique, undeliverable placeholder for anonymized accounts
+func deletedUserEmail(userID string) string {
+	return fmt.Sprintf("deleted-%s@deleted.invalid", userID)
+}
+
+// supabaseAdmin calls the Supabase Auth admin API for a user. A 404 on
+// delete means an earlier attempt already removed them.
+func (s *AccountService) supabaseAdmin(ctx context.Context, method, userID string, payload interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	reqURL := fmt.Sprintf("%s/auth/v1/admin/users/%s", s.cfg.SupabaseURL, userID)
+	httpReq, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("apikey", s.cfg.SupabaseServiceKey)
+	httpReq.Header.Set("Authorization", "Bearer "+s.cfg.SupabaseServiceKey)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to call Supabase Auth Admin API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if method == "DELETE" && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	msg, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("supabase auth admin %s failed: status %d, %s", method, resp.StatusCode, string(msg))
+}
+
+func (s *AccountService) logEvent(ctx context.Context, userID, eventType, details string) {
+	_, _ = database.ExecWithContext(ctx, `
+		INSERT INTO public.security_events (user_id, event_type, success, details, created_at)
+		VALUES ($1, $2, true, $3, $4)
+	`, userID, eventType, details, time.Now().UTC())
+}
+
+// StartAccountDeletionJob carries out deletions whose grace period has ended
+func (s *AccountService) StartAccountDeletionJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.AccountDeletionPollInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+				for {
+					processed, err := s.ProcessDueDeletion(jobCtx)
+					if err != nil {
+						log.Printf("Account deletion job error: %v", err)
+					}
+					if !processed || err != nil {
+						break
+					}
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
@@ -130,7 +130,7 @@ func (s *AdminService) GetAdmins(ctx context.Context, limit, offset int) ([]*mod
 	}
 
 	query := `
-		SELECT id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM public.users
 		WHERE role IN ('admin', 'super_admin')
 		ORDER BY created_at DESC
@@ -145,33 +145,11 @@ func (s *AdminService) GetAdmins(ctx context.Context, limit, offset int) ([]*mod
 
 	var admins []*models.User
 	for rows.Next() {
-		var admin models.User
-		var avatar, bio, location, website sql.NullString
-
-		err := rows.Scan(
-			&admin.ID, &admin.Name, &admin.Email, &avatar, &bio, &location, &website,
-			&admin.IsAdmin, &admin.IsVerified, &admin.IsActive, &admin.Role, &admin.Provider,
-			&admin.PostsCount, &admin.FollowersCount, &admin.FollowingCount,
-			&admin.CreatedAt, &admin.UpdatedAt,
-		)
+		admin, err := scanUserRow(rows)
 		if err != nil {
 			continue
 		}
-
-		if avatar.Valid {
-			admin.Avatar = avatar.String
-		}
-		if bio.Valid {
-			admin.Bio = bio.String
-		}
-		if location.Valid {
-			admin.Location = location.String
-		}
-		if website.Valid {
-			admin.Website = website.String
-		}
-
-		admins = append(admins, &admin)
+		admins = append(admins, admin)
 	}
 
 	return admins, nil
@@ -248,27 +226,19 @@ func (s *AdminService) CreateAdmin(ctx context.Context, req *models.CreateAdminR
 
 	// Create admin profile in PostgreSQL
 	query := `
-		INSERT INTO public.users (id, name, email, avatar, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
-		RETURNING id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at
-	`
+		INSERT INTO public.users (id, name, email, avatar, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at, handle, handle_skeleton, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $13)
+		RETURNING ` + userColumns
 
 	avatar := "https://images.pexels.com/photos/774909/pexels-photo-774909.jpeg?auto=compress&cs=tinysrgb&w=40&h=40&fit=crop"
-	row := database.QueryRowWithContext(ctx, query,
+	handle := NewUserService(s.db).GenerateHandle(ctx, req.Name)
+	user, err := s.insertAdmin(ctx, query,
 		userID, req.Name, req.Email, avatar,
 		true, true, true, // is_admin, is_verified, is_active
 		req.Role, "email",
 		0, 0, 0, // posts_count, followers_count, following_count
 		now, now,
-	)
-
-	var user models.User
-	var bio, location, website sql.NullString
-	err = row.Scan(
-		&user.ID, &user.Name, &user.Email, &user.Avatar, &bio, &location, &website,
-		&user.IsAdmin, &user.IsVerified, &user.IsActive, &user.Role, &user.Provider,
-		&user.PostsCount, &user.FollowersCount, &user.FollowingCount,
-		&user.CreatedAt, &user.UpdatedAt,
+		handle, utils.HandleSkeleton(handle),
 	)
 	if err != nil {
 		// Rollback: delete auth user via Admin API
@@ -281,40 +251,85 @@ func (s *AdminService) CreateAdmin(ctx context.Context, req *models.CreateAdminR
 		return nil, fmt.Errorf("failed to create admin profile: %w", err)
 	}
 
-	if bio.Valid {
-		user.Bio = bio.String
+	return user, nil
+}
+
+// insertAdmin inserts the admin's profile and records admin.create in the
+// same transaction
+func (s *AdminService) insertAdmin(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if location.Valid {
-		user.Location = location.String
+	defer tx.Rollback()
+
+	user, err := scanUserRow(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, err
 	}
-	if website.Valid {
-		user.Website = website.String
+	after := map[string]interface{}{
+		"email": user.Email,
+		"name":  user.Name,
+		"role":  user.Role,
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionAdminCreate, "user", user.ID, nil, after); err != nil {
+		return nil, err
 	}
 
-	return &user, nil
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-// UpdateAdminRole updates an admin's role
+// UpdateAdminRole updates an admin's role. Users who are not admins are
+// left alone.
 func (s *AdminService) UpdateAdminRole(ctx context.Context, adminID, role string) error {
 	if role != models.RoleAdmin && role != models.RoleSuperAdmin {
 		return errors.New("invalid role")
 	}
 
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockUserAdminState(ctx, tx, adminID)
+	if err == ErrUserNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if before.Role != models.RoleAdmin && before.Role != models.RoleSuperAdmin {
+		return nil
+	}
+
 	query := `
 		UPDATE public.users
 		SET role = $1, is_admin = true, updated_at = $2
-		WHERE id = $3 AND role IN ('admin', 'super_admin')
+		WHERE id = $3
 	`
-	_, err := database.ExecWithContext(ctx, query, role, time.Now().UTC(), adminID)
-	return err
+	if _, err := tx.ExecContext(ctx, query, role, time.Now().UTC(), adminID); err != nil {
+		return err
+	}
+	after := before
+	after.Role = role
+	after.IsAdmin = true
+	if err := recordAudit(ctx, tx, models.AuditActionAdminUpdateRole, "user", adminID, before, after); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // DeleteAdmin deletes an admin
 func (s *AdminService) DeleteAdmin(ctx context.Context, adminID string, cfg *config.Config) error {
 	// Get admin role first to check if it's super admin
-	query := "SELECT role FROM public.users WHERE id = $1"
-	var role string
-	err := database.QueryRowWithContext(ctx, query, adminID).Scan(&role)
+	query := "SELECT role, email, name FROM public.users WHERE id = $1"
+	var role, email, name string
+	err := database.QueryRowWithContext(ctx, query, adminID).Scan(&role, &email, &name)
 	if err != nil {
 		return err
 	}
@@ -352,6 +367,23 @@ func (s *AdminService) DeleteAdmin(ctx context.Context, adminID string, cfg *con
 	}
 
 	// Delete from PostgreSQL (CASCADE will handle related records)
-	_, err = database.ExecWithContext(ctx, "DELETE FROM public.users WHERE id = $1", adminID)
-	return err
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM public.users WHERE id = $1", adminID); err != nil {
+		return err
+	}
+	before := map[string]interface{}{
+		"email": email,
+		"name":  name,
+		"role":  role,
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionAdminDelete, "user", adminID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,464 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAppealNotFound       = errors.New("appeal not found")
+	ErrAppealActionNotFound = errors.New("there is no appealable action with that type and ID")
+	ErrAlreadyAppealed      = errors.New("this action has already been appealed")
+	ErrAppealClosed         = errors.New("this appeal has already been decided")
+	ErrInvalidAppealAction  = errors.New("actionType must be 'suspension' or 'report'")
+	ErrInvalidAppealStatus  = errors.New("status must be 'pending', 'accepted' or 'denied'")
+	ErrInvalidAppealMessage = fmt.Errorf("message is required and must be at most %d characters", constants.MaxAppealMessageLength)
+	ErrInvalidAppealNote    = fmt.Errorf("notes are at most %d characters", constants.MaxAppealNoteLength)
+)
+
+const appealExcerptLength = 280
+
+const appealColumns = `a.id, a.user_id, a.action, a.message, a.status, COALESCE(a.decision_note, ''),
+	COALESCE(a.decided_by::text, ''), a.decided_at, a.created_at, u.name, u.handle, u.avatar`
+
+// AppealService lets users appeal suspensions and upheld reports, and
+// admins accept or deny the appeals
+type AppealService struct {
+	db           *sql.DB
+	emailService *EmailService
+}
+
+// NewAppealService creates a new AppealService instance
+func NewAppealService(db *sql.DB, emailService *EmailService) *AppealService {
+	return &AppealService{db: db, emailService: emailService}
+}
+
+func scanAppeal(row rowScanner) (*models.Appeal, error) {
+	var appeal models.Appeal
+	var action []byte
+	var decidedAt sql.NullTime
+	var name, handle, avatar sql.NullString
+	err := row.Scan(&appeal.ID, &appeal.UserID, &action, &appeal.Message, &appeal.Status, &appeal.DecisionNote,
+		&appeal.DecidedBy, &decidedAt, &appeal.CreatedAt, &name, &handle, &avatar)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(action, &appeal.Action); err != nil {
+		return nil, err
+	}
+	if decidedAt.Valid {
+		appeal.DecidedAt = &decidedAt.Time
+	}
+	appeal.User = &models.User{ID: appeal.UserID, Name: name.String, Handle: handle.String, Avatar: avatar.String}
+	return &appeal, nil
+}
+
+// appealForUser is the appeal as shown to the user who made it, without
+// the moderator who decided it
+func appealForUser(appeal *models.Appeal) *models.Appeal {
+	shown := *appeal
+	shown.User = nil
+	shown.DecidedBy = ""
+	return &shown
+}
+
+// ListAppealable returns the actions the user can still appeal: active
+// suspensions imposed directly, and reports upheld against their content
+// with actions taken in the last AppealWindow, one per post or comment.
+// Suspensions imposed through a report are appealed through the report.
+func (s *AppealService) ListAppealable(ctx context.Context, userID string) ([]*models.AppealableAction, error) {
+	now := time.Now().UTC()
+	actions := []*models.AppealableAction{}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT s.id, s.scope, s.reason, s.expires_at, s.created_at
+		FROM public.user_suspensions s
+		WHERE s.user_id::text = $1 AND s.lifted_at IS NULL AND s.report_id IS NULL
+			AND (s.expires_at IS NULL OR s.expires_at > $2)
+			AND NOT EXISTS (SELECT 1 FROM public.appeals a WHERE a.action_type = 'suspension' AND a.action_id = s.id)
+		ORDER BY s.created_at DESC
+	`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		action := &models.AppealableAction{Type: models.AppealActionSuspension}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&action.ID, &action.Scope, &action.Reason, &expiresAt, &action.TakenAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if expiresAt.Valid {
+			action.ExpiresAt = &expiresAt.Time
+		}
+		actions = append(actions, action)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = database.QueryWithContext(ctx, `
+		SELECT id, target_type, actions, summary, title, content, resolved_at FROM (
+			SELECT DISTINCT ON (r.target_type, r.target_id) r.id, r.target_type, r.actions,
+				COALESCE(r.resolution_summary, '') AS summary, COALESCE(r.snapshot->>'title', '') AS title,
+				COALESCE(r.snapshot->>'content', '') AS content, r.resolved_at
+			FROM public.reports r
+			WHERE r.snapshot->>'authorId' = $1 AND r.status = 'resolved' AND cardinality(r.actions) > 0
+				AND r.resolved_at > $2
+			ORDER BY r.target_type, r.target_id, r.created_at, r.id
+		) upheld
+		WHERE NOT EXISTS (SELECT 1 FROM public.appeals a WHERE a.action_type = 'report' AND a.action_id = upheld.id)
+		ORDER BY resolved_at DESC
+	`, userID, now.Add(-constants.AppealWindow))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		action := &models.AppealableAction{Type: models.AppealActionReport}
+		var title, content string
+		err := rows.Scan(&action.ID, &action.TargetType, pq.Array(&action.Actions), &action.Reason, &title, &content, &action.TakenAt)
+		if err != nil {
+			return nil, err
+		}
+		action.Excerpt = truncateRunes(strings.TrimSpace(title+"\n"+content), appealExcerptLength)
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+// CreateAppeal appeals one of the user's appealable actions. Each action
+// can be appealed once.
+func (s *AppealService) CreateAppeal(ctx context.Context, userID string, req *models.CreateAppealRequest) (*models.Appeal, error) {
+	if req.ActionType != models.AppealActionSuspension && req.ActionType != models.AppealActionReport {
+		return nil, ErrInvalidAppealAction
+	}
+	message := strings.TrimSpace(utils.SanitizeHTML(req.Message))
+	if message == "" || !utils.ValidateLength(message, 1, constants.MaxAppealMessageLength) {
+		return nil, ErrInvalidAppealMessage
+	}
+
+	var appealed bool
+	err := database.QueryRowWithContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM public.appeals WHERE action_type = $1 AND action_id::text = $2 AND user_id::text = $3)
+	`, req.ActionType, req.ActionID, userID).Scan(&appealed)
+	if err != nil {
+		return nil, err
+	}
+	if appealed {
+		return nil, ErrAlreadyAppealed
+	}
+
+	appealable, err := s.ListAppealable(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var action *models.AppealableAction
+	for _, a := range appealable {
+		if a.Type == req.ActionType && a.ID == req.ActionID {
+			action = a
+		}
+	}
+	if action == nil {
+		return nil, ErrAppealActionNotFound
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return nil, err
+	}
+
+	var appealID string
+	err = database.QueryRowWithContext(ctx, `
+		INSERT INTO public.appeals (user_id, action_type, action_id, action, message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (action_type, action_id) DO NOTHING
+		RETURNING id
+	`, userID, action.Type, action.ID, string(data), message, time.Now().UTC()).Scan(&appealID)
+	if err == sql.ErrNoRows {
+		return nil, ErrAlreadyAppealed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	appeal, err := s.GetAppeal(ctx, appealID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyAppealAsync(appeal)
+	return appealForUser(appeal), nil
+}
+
+// ListMyAppeals returns the user's appeals, newest first
+func (s *AppealService) ListMyAppeals(ctx context.Context, userID string) ([]*models.Appeal, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+appealColumns+`
+		FROM public.appeals a
+		LEFT JOIN public.users u ON u.id = a.user_id
+		WHERE a.user_id::text = $1
+		ORDER BY a.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appeals := []*models.Appeal{}
+	for rows.Next() {
+		appeal, err := scanAppeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		appeals = append(appeals, appealForUser(appeal))
+	}
+	return appeals, rows.Err()
+}
+
+// ListAppeals returns appeals with the given status (pending by default),
+// oldest first so the longest-waiting are decided first
+func (s *AppealService) ListAppeals(ctx context.Context, status string, limit, offset int) ([]*models.Appeal, error) {
+	switch status {
+	case "":
+		status = models.AppealStatusPending
+	case models.AppealStatusPending, models.AppealStatusAccepted, models.AppealStatusDenied:
+	default:
+		return nil, ErrInvalidAppealStatus
+	}
+	limit, offset = clampPage(limit, offset)
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+appealColumns+`
+		FROM public.appeals a
+		LEFT JOIN public.users u ON u.id = a.user_id
+		WHERE a.status = $1
+		ORDER BY a.created_at
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appeals := []*models.Appeal{}
+	for rows.Next() {
+		appeal, err := scanAppeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		appeals = append(appeals, appeal)
+	}
+	return appeals, rows.Err()
+}
+
+// GetAppeal returns one appeal
+func (s *AppealService) GetAppeal(ctx context.Context, appealID string) (*models.Appeal, error) {
+	appeal, err := scanAppeal(database.QueryRowWithContext(ctx, `
+		SELECT `+appealColumns+`
+		FROM public.appeals a
+		LEFT JOIN public.users u ON u.id = a.user_id
+		WHERE a.id::text = $1
+	`, appealID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAppealNotFound
+	}
+	return appeal, err
+}
+
+// Accept reverses the appealed action and emails the user
+func (s *AppealService) Accept(ctx context.Context, appealID, adminID, note string) (*models.Appeal, error) {
+	return s.decide(ctx, appealID, adminID, note, models.AppealStatusAccepted)
+}
+
+// Deny closes the appeal without changing anything and emails the user
+func (s *AppealService) Deny(ctx context.Context, appealID, adminID, note string) (*models.Appeal, error) {
+	return s.decide(ctx, appealID, adminID, note, models.AppealStatusDenied)
+}
+
+func (s *AppealService) decide(ctx context.Context, appealID, adminID, note, status string) (*models.Appeal, error) {
+	note = strings.TrimSpace(utils.SanitizeHTML(note))
+	if !utils.ValidateLength(note, 0, constants.MaxAppealNoteLength) {
+		return nil, ErrInvalidAppealNote
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	appeal, err := scanAppeal(tx.QueryRowContext(ctx, `
+		SELECT `+appealColumns+`
+		FROM public.appeals a
+		LEFT JOIN public.users u ON u.id = a.user_id
+		WHERE a.id::text = $1
+		FOR UPDATE OF a
+	`, appealID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAppealNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if appeal.Status != models.AppealStatusPending {
+		return nil, ErrAppealClosed
+	}
+
+	now := time.Now().UTC()
+	var reversed map[string]interface{}
+	if status == models.AppealStatusAccepted {
+		if reversed, err = reverseAppealedActionTx(ctx, tx, appeal, adminID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.appeals
+		SET status = $2, decision_note = NULLIF($3, ''), decided_by = NULLIF($4, '')::uuid, decided_at = $5
+		WHERE id = $1
+	`, appeal.ID, status, note, adminID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	action := models.AuditActionAppealDeny
+	if status == models.AppealStatusAccepted {
+		action = models.AuditActionAppealAccept
+	}
+	before := map[string]interface{}{"status": appeal.Status, "action": appeal.Action}
+	after := map[string]interface{}{"status": status, "note": note, "reversed": reversed}
+	if err := recordAudit(ctx, tx, action, "appeal", appeal.ID, before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if status == models.AppealStatusAccepted {
+		invalidateSuspension(appeal.UserID)
+		if appeal.Action.Type == models.AppealActionReport {
+			refreshBadgesAsync(appeal.UserID)
+		}
+	}
+	appeal.Status = status
+	appeal.DecisionNote = note
+	appeal.DecidedBy = adminID
+	appeal.DecidedAt = &now
+	s.notifyAppealAsync(appeal)
+	return appeal, nil
+}
+
+// reverseAppealedActionTx undoes an appealed action. A suspension is lifted
+// if it is still in force. An upheld report is marked dismissed along with
+// the other reports closed with it, their reputation penalty is taken back
+// and the suspension they imposed is lifted if still in force. Removed
+// content was deleted and stays deleted. It returns what was reversed, for
+// the audit log.
+func reverseAppealedActionTx(ctx context.Context, tx *sql.Tx, appeal *models.Appeal, adminID string, now time.Time) (map[string]interface{}, error) {
+	if _, err := lockUserAdminState(ctx, tx, appeal.UserID); err != nil {
+		return nil, err
+	}
+	reversed := map[string]interface{}{}
+
+	var reportIDs []string
+	if appeal.Action.Type == models.AppealActionReport {
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE public.reports SET status = 'dismissed', updated_at = $2
+			WHERE status = 'resolved'
+				AND (target_type, target_id) = (SELECT target_type, target_id FROM public.reports WHERE id::text = $1)
+			RETURNING id::text
+		`, appeal.Action.ID, now)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			reportIDs = append(reportIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		err = revokeReputation(ctx, tx, "event_type = $1 AND source_id::text = ANY($2)", ReputationReportUpheld, pq.Array(reportIDs))
+		if err != nil {
+			return nil, err
+		}
+		reversed["dismissedReports"] = reportIDs
+	}
+
+	lifted, err := liftAppealedSuspensionTx(ctx, tx, appeal, reportIDs, adminID, now)
+	if err != nil || lifted == nil {
+		return reversed, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionUserUnsuspend, "user", appeal.UserID, lifted, nil); err != nil {
+		return nil, err
+	}
+	reversed["liftedSuspension"] = lifted.ID
+	return reversed, nil
+}
+
+// liftAppealedSuspensionTx lifts the user's active suspension if it is the
+// appealed one or was imposed by one of reportIDs, and returns it. It
+// returns nil if another suspension, or none, is in force.
+func liftAppealedSuspensionTx(ctx context.Context, tx *sql.Tx, appeal *models.Appeal, reportIDs []string, adminID string, now time.Time) (*models.Suspension, error) {
+	var activeID, reportID string
+	err := tx.QueryRowContext(ctx, `
+		SELECT id::text, COALESCE(report_id::text, '') FROM public.user_suspensions
+		WHERE user_id::text = $1 AND lifted_at IS NULL
+	`, appeal.UserID).Scan(&activeID, &reportID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	appealed := appeal.Action.Type == models.AppealActionSuspension && activeID == appeal.Action.ID
+	for _, id := range reportIDs {
+		appealed = appealed || (reportID != "" && id == reportID)
+	}
+	if !appealed {
+		return nil, nil
+	}
+	return liftSuspensionTx(ctx, tx, appeal.UserID, adminID, "appeal", now)
+}
+
+// notifyAppealAsync emails the user that their appeal was received or, once
+// decided, the decision
+func (s *AppealService) notifyAppealAsync(appeal *models.Appeal) {
+	if s.emailService == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var email string
+		err := database.QueryRowWithContext(ctx,
+			"SELECT email FROM public.users WHERE id::text = $1", appeal.UserID).Scan(&email)
+		if err != nil {
+			log.Printf("Failed to look up appealing user %s: %v", appeal.UserID, err)
+			return
+		}
+		if err := s.emailService.SendAppealEmail(ctx, email, appeal); err != nil {
+			log.Printf("Failed to send appeal email for appeal %s: %v", appeal.ID, err)
+		}
+	}()
+}
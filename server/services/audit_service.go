@@ -0,0 +1,334 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/google/uuid"
+)
+
+// auditChainLock is the advisory lock key that serializes audit log
+// appends, so each entry links to the one committed just before it
+const auditChainLock = 7254103
+
+// auditGenesisHash is the prev_hash of the first entry
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// auditVerifyBatch is how many entries Verify reads per query
+const auditVerifyBatch = 1000
+
+const auditColumns = `id, COALESCE(actor_id::text, ''), COALESCE(actor_role, ''), action, target_type, target_id,
+	before, after, COALESCE(reason, ''), COALESCE(ip_address, ''), COALESCE(request_id, ''),
+	created_at, prev_hash, hash`
+
+// AuditActor is who made an admin request, from where and why. The audit
+// middleware stores it in the request context for recordAudit.
+type AuditActor struct {
+	ID        string
+	Role      string
+	IP        string
+	RequestID string
+	Reason    string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a context carrying the actor recorded with every
+// audit entry written under it
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+func auditActorFrom(ctx context.Context) AuditActor {
+	actor, _ := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor
+}
+
+// AuditFilter narrows the audit log listing. Empty fields match everything.
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+// AuditService reads the admin audit log
+type AuditService struct {
+	db *sql.DB
+}
+
+// NewAuditService creates a new AuditService instance
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record appends an entry for an action that is not made inside a
+// transaction of its own
+func (s *AuditService) Record(ctx context.Context, action, targetType, targetID string, before, after interface{}) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordAudit(ctx, tx, action, targetType, targetID, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordAudit appends an entry to the audit log within tx, chained to the
+// newest entry. The actor comes from ctx; before and after are the target's
+// state around the action and may be nil.
+func recordAudit(ctx context.Context, tx *sql.Tx, action, targetType, targetID string, before, after interface{}) error {
+	actor := auditActorFrom(ctx)
+	entry := &models.AuditEntry{
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     strings.TrimSpace(actor.Reason),
+		IPAddress:  actor.IP,
+		RequestID:  actor.RequestID,
+		// Postgres keeps microseconds; the hash must match what is read back
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if id, err := uuid.Parse(actor.ID); err == nil {
+		entry.ActorID = id.String()
+	}
+	if reason := []rune(entry.Reason); len(reason) > constants.MaxAuditReasonLength {
+		entry.Reason = string(reason[:constants.MaxAuditReasonLength])
+	}
+
+	var err error
+	if entry.Before, err = auditState(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditState(after); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx,
+		"SELECT hash FROM public.admin_audit_log ORDER BY id DESC LIMIT 1").Scan(&entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.PrevHash = auditGenesisHash
+	} else if err != nil {
+		return err
+	}
+	if entry.Hash, err = auditHash(entry); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.admin_audit_log (actor_id, actor_role, action, target_type, target_id, before, after,
+			reason, ip_address, request_id, created_at, prev_hash, hash)
+		VALUES (NULLIF($1, '')::uuid, NULLIF($2, ''), $3, $4, $5, $6, $7,
+			NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13)
+	`, entry.ActorID, entry.ActorRole, entry.Action, entry.TargetType, entry.TargetID,
+		nullJSON(entry.Before), nullJSON(entry.After),
+		entry.Reason, entry.IPAddress, entry.RequestID, entry.CreatedAt, entry.PrevHash, entry.Hash)
+	return err
+}
+
+// List returns audit entries matching filter, newest first. The cursor is
+// the ID of the last entry of the previous page.
+func (s *AuditService) List(ctx context.Context, filter AuditFilter, cursor string, limit int) (*models.AuditListResponse, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var beforeID int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, ErrInvalidCursor
+		}
+		beforeID = id
+	}
+	from := sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()}
+	to := sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+auditColumns+`
+		FROM public.admin_audit_log
+		WHERE ($1 = '' OR actor_id::text = $1)
+			AND ($2 = '' OR action = $2)
+			AND ($3 = '' OR target_type = $3)
+			AND ($4 = '' OR target_id = $4)
+			AND ($5::timestamptz IS NULL OR created_at >= $5)
+			AND ($6::timestamptz IS NULL OR created_at < $6)
+			AND ($7 = 0 OR id < $7)
+		ORDER BY id DESC
+		LIMIT $8
+	`, filter.ActorID, filter.Action, filter.TargetType, filter.TargetID, from, to, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.AuditListResponse{Entries: []*models.AuditEntry{}}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.NextCursor = strconv.FormatInt(page.Entries[limit-1].ID, 10)
+	}
+	return page, nil
+}
+
+// Verify walks the whole log oldest first and recomputes every hash. An
+// entry that was edited, or follows a removed one, breaks the chain.
+// Removing the newest entries leaves a valid but shorter chain, so callers
+// should keep the returned head hash somewhere the database can't reach.
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true}
+	prevHash := auditGenesisHash
+	var lastID int64
+
+	for {
+		rows, err := database.QueryWithContext(ctx, `
+			SELECT `+auditColumns+`
+			FROM public.admin_audit_log
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2
+		`, lastID, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		count := 0
+		for rows.Next() {
+			entry, err := scanAuditEntry(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			count++
+			lastID = entry.ID
+
+			hash, err := auditHash(entry)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if entry.PrevHash != prevHash || entry.Hash != hash {
+				rows.Close()
+				result.Valid = false
+				result.BrokenAt = &entry.ID
+				return result, nil
+			}
+			result.Checked++
+			result.Head = entry.Hash
+			prevHash = entry.Hash
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if count < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// auditHash is the sha256 of the entry's contents and the previous hash
+func auditHash(entry *models.AuditEntry) (string, error) {
+	payload, err := json.Marshal([]interface{}{
+		entry.PrevHash,
+		entry.ActorID,
+		entry.ActorRole,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.Before,
+		entry.After,
+		entry.Reason,
+		entry.IPAddress,
+		entry.RequestID,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// auditState marshals a before/after state to canonical JSON
+func auditState(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalJSON(raw)
+}
+
+// canonicalJSON re-encodes raw with sorted keys and no whitespace. JSONB
+// reorders keys and adds spaces, so hashes are always computed over this
+// form.
+func canonicalJSON(raw []byte) (json.RawMessage, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func nullJSON(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var before, after []byte
+	err := row.Scan(&entry.ID, &entry.ActorID, &entry.ActorRole, &entry.Action, &entry.TargetType, &entry.TargetID,
+		&before, &after, &entry.Reason, &entry.IPAddress, &entry.RequestID,
+		&entry.CreatedAt, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Before, err = canonicalJSON(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = canonicalJSON(after); err != nil {
+		return nil, err
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC()
+	return &entry, nil
+}
@@ -69,6 +69,25 @@ func (s *AuthService) SignupSupabase(ctx context.Context, req *models.AuthReques
 		return nil, errors.New("name is required")
 	}
 
+	// Resolve the handle before creating the auth user so a taken or
+	// invalid handle doesn't leave an orphaned Supabase account
+	userService := NewUserService(s.db)
+	handle := utils.NormalizeHandle(req.Handle)
+	if handle != "" {
+		if err := utils.ValidateHandle(handle); err != nil {
+			return nil, err
+		}
+		available, err := userService.IsHandleAvailable(ctx, handle, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to check handle: %w", err)
+		}
+		if !available {
+			return nil, ErrHandleTaken
+		}
+	} else {
+		handle = userService.GenerateHandle(ctx, req.Name)
+	}
+
 	// Check if email already exists
 	existingUser, err := s.getUserByEmail(ctx, req.Email)
 	if err == nil && existingUser != nil {
@@ -137,14 +156,15 @@ func (s *AuthService) SignupSupabase(ctx context.Context, req *models.AuthReques
 	defer tx.Rollback()
 
 	query := `
-		INSERT INTO public.users (id, name, email, avatar, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		INSERT INTO public.users (id, name, email, avatar, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at, handle, handle_skeleton)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
 	avatar := "https://images.pexels.com/photos/774909/pexels-photo-774909.jpeg?auto=compress&cs=tinysrgb&w=40&h=40&fit=crop"
 	_, err = tx.ExecContext(ctx, query,
 		userID, req.Name, req.Email, avatar,
 		false, false, true, models.RoleUser, "email",
 		0, 0, 0, now, now,
+		handle, utils.HandleSkeleton(handle),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user profile: %w", err)
@@ -231,8 +251,13 @@ func (s *AuthService) LoginSupabase(ctx context.Context, req *models.AuthRequest
 		}
 	}
 
-	// Check if account is active
-	if !user.IsActive {
+	// Check if account is active. Suspended accounts can still sign in;
+	// the auth middleware limits them to what their suspension allows.
+	allowed, err := signInAllowed(ctx, user)
+	if err != nil {
+		return nil, s.sanitizeError(err)
+	}
+	if !allowed {
 		s.logSecurityEvent(ctx, userID, "login_attempt", ipAddress, userAgent, false, "account_inactive")
 		return nil, errors.New("account is inactive")
 	}
@@ -240,6 +265,12 @@ func (s *AuthService) LoginSupabase(ctx context.Context, req *models.AuthRequest
 	// Reset failed attempts on successful login
 	s.resetFailedAttempts(ctx, user.ID)
 
+	// Logging in during the grace period cancels a pending account deletion
+	deletionCancelled, err := NewAccountService(s.db, s.cfg, nil, nil).CancelDeletion(ctx, user.ID)
+	if err != nil {
+		return nil, s.sanitizeError(err)
+	}
+
 	// Log successful login
 	s.logSecurityEvent(ctx, user.ID, "login", ipAddress, userAgent, true, "")
 
@@ -252,64 +283,51 @@ func (s *AuthService) LoginSupabase(ctx context.Context, req *models.AuthRequest
 	permissions := models.GetRolePermissions(user.Role)
 
 	return &models.AuthResponse{
-		Token:        accessToken,
-		RefreshToken: session.ID,
-		ExpiresIn:    int64(s.sessionExpiry.Seconds()),
-		User:         user,
-		Roles:        []string{user.Role},
-		Permissions:  permissions,
+		Token:             accessToken,
+		RefreshToken:      session.ID,
+		ExpiresIn:         int64(s.sessionExpiry.Seconds()),
+		User:              user,
+		Roles:             []string{user.Role},
+		Permissions:       permissions,
+		DeletionCancelled: deletionCancelled,
 	}, nil
 }
 
 // Helper methods migrated to PostgreSQL
 
+// signInAllowed reports whether an account may sign in. Inactive accounts
+// may only if an active suspension is why they are inactive, so suspended
+// users can still read the notice and appeal; deleted accounts and those
+// deactivated otherwise may not.
+func signInAllowed(ctx context.Context, user *models.User) (bool, error) {
+	if user.IsActive {
+		return true, nil
+	}
+	var suspended bool
+	err := database.QueryRowWithContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM public.user_suspensions s
+			JOIN public.users u ON u.id = s.user_id
+			WHERE s.user_id::text = $1 AND s.lifted_at IS NULL AND u.deleted_at IS NULL
+		)
+	`, user.ID).Scan(&suspended)
+	return suspended, err
+}
+
 func (s *AuthService) getUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `
-		SELECT id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at
-		FROM public.users
-		WHERE email = $1
-	`
+	query := "SELECT " + userColumns + " FROM public.users WHERE email = $1"
 	row := database.QueryRowWithContext(ctx, query, email)
 	return s.scanUser(row)
 }
 
 func (s *AuthService) getUserByID(ctx context.Context, userID string) (*models.User, error) {
-	query := `
-		SELECT id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at
-		FROM public.users
-		WHERE id = $1
-	`
+	query := "SELECT " + userColumns + " FROM public.users WHERE id = $1"
 	row := database.QueryRowWithContext(ctx, query, userID)
 	return s.scanUser(row)
 }
 
 func (s *AuthService) scanUser(row *sql.Row) (*models.User, error) {
-	var user models.User
-	var avatar, bio, location, website sql.NullString
-	err := row.Scan(
-		&user.ID, &user.Name, &user.Email, &avatar, &bio, &location, &website,
-		&user.IsAdmin, &user.IsVerified, &user.IsActive, &user.Role, &user.Provider,
-		&user.PostsCount, &user.FollowersCount, &user.FollowingCount,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	if avatar.Valid {
-		user.Avatar = avatar.String
-	}
-	if bio.Valid {
-		user.Bio = bio.String
-	}
-	if location.Valid {
-		user.Location = location.String
-	}
-	if website.Valid {
-		user.Website = website.String
-	}
-
-	return &user, nil
+	return scanUserRow(row)
 }
 
 func (s *AuthService) createSession(ctx context.Context, userID, token, ipAddress, userAgent string) (*models.Session, error) {
@@ -483,6 +501,13 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken, ipAddress,
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
+	allowed, err := signInAllowed(ctx, user)
+	if err != nil {
+		return nil, s.sanitizeError(err)
+	}
+	if !allowed {
+		return nil, errors.New("account is inactive")
+	}
 
 	// Create new session
 	session, err := s.createSession(ctx, userID, tokenID, ipAddress, userAgent)
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCannotBlockSelf = errors.New("cannot block or mute yourself")
+	ErrUserBlocked     = errors.New("action not allowed: user is blocked")
+)
+
+// blockedEitherQuery reports whether $1 and $2 have blocked each other in either direction
+const blockedEitherQuery = `
+	SELECT EXISTS(
+		SELECT 1 FROM public.user_blocks
+		WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+	)
+`
+
+// BlockService handles blocking (mutual invisibility) and muting (one-way hide)
+type BlockService struct {
+	db *sql.DB
+}
+
+// NewBlockService creates a new BlockService instance
+func NewBlockService(db *sql.DB) *BlockService {
+	return &BlockService{db: db}
+}
+
+// BlockUser blocks a user and removes any follow relationship between the two
+func (s *BlockService) BlockUser(ctx context.Context, blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return ErrCannotBlockSelf
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM public.users WHERE id = $1)", blockedID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.user_blocks (blocker_id, blocked_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, blockerID, blockedID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	// Break follows in both directions, keeping counters in step
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM public.follows
+		WHERE (follower_id = $1 AND following_id = $2) OR (follower_id = $2 AND following_id = $1)
+		RETURNING follower_id, following_id
+	`, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	var removed [][2]string
+	for rows.Next() {
+		var follower, following string
+		if err := rows.Scan(&follower, &following); err != nil {
+			rows.Close()
+			return err
+		}
+		removed = append(removed, [2]string{follower, following})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range removed {
+		_, err = tx.ExecContext(ctx, "UPDATE public.users SET following_count = GREATEST(following_count - 1, 0) WHERE id = $1", f[0])
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE public.users SET followers_count = GREATEST(followers_count - 1, 0) WHERE id = $1", f[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Followers lost to the block no longer count towards reputation
+	err = revokeReputation(ctx, tx, `event_type = $1 AND (
+		(user_id = $2 AND actor_id = $3) OR (user_id = $3 AND actor_id = $2))`,
+		ReputationFollowerGained, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	// Drop precomputed suggestions pointing either way
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM public.follow_suggestions
+		WHERE (user_id = $1 AND candidate_id = $2) OR (user_id = $2 AND candidate_id = $1)
+	`, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnblockUser removes a block. Follows removed by the block are not restored.
+func (s *BlockService) UnblockUser(ctx context.Context, blockerID, blockedID string) error {
+	_, err := database.ExecWithContext(ctx,
+		"DELETE FROM public.user_blocks WHERE blocker_id = $1 AND blocked_id = $2", blockerID, blockedID)
+	return err
+}
+
+// MuteUser hides a user's content from the muter without them knowing
+func (s *BlockService) MuteUser(ctx context.Context, muterID, mutedID string) error {
+	if muterID == mutedID {
+		return ErrCannotBlockSelf
+	}
+
+	var exists bool
+	err := database.QueryRowWithContext(ctx, "SELECT EXISTS(SELECT 1 FROM public.users WHERE id = $1)", mutedID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	_, err = database.ExecWithContext(ctx, `
+		INSERT INTO public.user_mutes (muter_id, muted_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (muter_id, muted_id) DO NOTHING
+	`, muterID, mutedID, time.Now().UTC())
+	return err
+}
+
+// UnmuteUser removes a mute
+func (s *BlockService) UnmuteUser(ctx context.Context, muterID, mutedID string) error {
+	_, err := database.ExecWithContext(ctx,
+		"DELETE FROM public.user_mutes WHERE muter_id = $1 AND muted_id = $2", muterID, mutedID)
+	return err
+}
+
+// GetBlockedUsers lists users blocked by userID, most recent first
+func (s *BlockService) GetBlockedUsers(ctx context.Context, userID string, limit, offset int) ([]*models.User, error) {
+	return s.listUsers(ctx, `
+		SELECT u.id, u.name, u.handle, u.avatar
+		FROM public.user_blocks b
+		JOIN public.users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+}
+
+// GetMutedUsers lists users muted by userID, most recent first
+func (s *BlockService) GetMutedUsers(ctx context.Context, userID string, limit, offset int) ([]*models.User, error) {
+	return s.listUsers(ctx, `
+		SELECT u.id, u.name, u.handle, u.avatar
+		FROM public.user_mutes m
+		JOIN public.users u ON u.id = m.muted_id
+		WHERE m.muter_id = $1
+		ORDER BY m.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+}
+
+func (s *BlockService) listUsers(ctx context.Context, query, userID string, limit, offset int) ([]*models.User, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := database.QueryWithContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		var user models.User
+		var handle, avatar sql.NullString
+		if err := rows.Scan(&user.ID, &user.Name, &handle, &avatar); err != nil {
+			return nil, err
+		}
+		if handle.Valid {
+			user.Handle = handle.String
+		}
+		if avatar.Valid {
+			user.Avatar = avatar.String
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// IsBlockedEither reports whether either user has blocked the other
+func (s *BlockService) IsBlockedEither(ctx context.Context, userA, userB string) (bool, error) {
+	if userA == "" || userB == "" || userA == userB {
+		return false, nil
+	}
+
+	var blocked bool
+	err := database.QueryRowWithContext(ctx, blockedEitherQuery, userA, userB).Scan(&blocked)
+	return blocked, err
+}
+
+// BlockedUserIDs returns users hidden from viewerID by a block in either direction
+func (s *BlockService) BlockedUserIDs(ctx context.Context, viewerID string) ([]string, error) {
+	return s.userIDs(ctx, `
+		SELECT blocked_id FROM public.user_blocks WHERE blocker_id = $1
+		UNION
+		SELECT blocker_id FROM public.user_blocks WHERE blocked_id = $1
+	`, viewerID)
+}
+
+// HiddenUserIDs returns users whose content should not be shown to viewerID:
+// blocks in either direction plus the viewer's mutes
+func (s *BlockService) HiddenUserIDs(ctx context.Context, viewerID string) ([]string, error) {
+	return s.userIDs(ctx, `
+		SELECT blocked_id FROM public.user_blocks WHERE blocker_id = $1
+		UNION
+		SELECT blocker_id FROM public.user_blocks WHERE blocked_id = $1
+		UNION
+		SELECT muted_id FROM public.user_mutes WHERE muter_id = $1
+	`, viewerID)
+}
+
+// FilterBlocked drops IDs of users that have a block with userID in either direction
+func (s *BlockService) FilterBlocked(ctx context.Context, userID string, candidateIDs []string) ([]string, error) {
+	if userID == "" || len(candidateIDs) == 0 {
+		return candidateIDs, nil
+	}
+
+	return s.userIDs(ctx, `
+		SELECT c.id::text FROM unnest($2::uuid[]) AS c(id)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM public.user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = c.id) OR (blocker_id = c.id AND blocked_id = $1)
+		)
+	`, userID, pq.Array(candidateIDs))
+}
+
+// userIDs never returns a nil slice: callers pass the result to
+// NOT (x = ANY($n)), and pq sends a nil slice as NULL, which matches no rows
+func (s *BlockService) userIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	if len(args) > 0 {
+		if viewerID, ok := args[0].(string); ok && viewerID == "" {
+			return []string{}, nil
+		}
+	}
+
+	rows, err := database.QueryWithContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,1002 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrBulkOperationNotFound  = errors.New("bulk operation not found")
+	ErrBulkOperationFinished  = errors.New("bulk operation has already finished")
+	ErrInvalidBulkKind        = errors.New("unknown bulk operation")
+	ErrInvalidBulkUsers       = fmt.Errorf("userIds must list 1 to %d users", constants.BulkMaxUsers)
+	ErrInvalidBulkSince       = errors.New("userId and since are required")
+	ErrInvalidBulkStatus      = errors.New("status must be 'resolved' or 'dismissed'")
+	ErrInvalidBulkSummary     = fmt.Errorf("summary must be at most %d characters", constants.MaxBulkSummaryLength)
+	ErrInvalidBulkIP          = errors.New("ip must be an IP address or CIDR range")
+	ErrInvalidBulkCriteria    = errors.New("purge needs an ip, a signup window or both")
+	ErrInvalidBulkWindow      = errors.New("signedUpFrom must be before signedUpTo")
+	ErrTooManyBulkTargets     = fmt.Errorf("the operation matches more than %d targets; narrow the criteria", constants.BulkMaxTargets)
+	ErrCannotBulkSuspendAdmin = errors.New("admins can't be suspended or purged in bulk")
+)
+
+// maxBulkAttempts is how many times an interrupted operation is resumed
+const maxBulkAttempts = 3
+
+// bulkLabelLength caps the excerpts shown for posts and comments
+const bulkLabelLength = 120
+
+// bulkColumns returns only the first BulkPreviewTargets targets
+var bulkColumns = fmt.Sprintf(`b.id, b.kind, b.params, b.dry_run, b.status, b.total, b.processed, b.succeeded, b.failed,
+	COALESCE((
+		SELECT jsonb_agg(t.value ORDER BY t.ordinality)
+		FROM jsonb_array_elements(b.targets) WITH ORDINALITY t
+		WHERE t.ordinality <= %d
+	), '[]'), b.failures, COALESCE(b.error, ''), COALESCE(b.created_by::text, ''), b.created_at,
+	b.started_at, b.completed_at`, constants.BulkPreviewTargets)
+
+// BulkService runs super admins' bulk moderation operations (suspending a
+// list of users, removing a user's recent content, closing reports on
+// removed content and purging accounts by IP or signup time) in the
+// background
+type BulkService struct {
+	db          *sql.DB
+	cache       *CacheService
+	suspensions *SuspensionService
+	reports     *ReportService
+}
+
+// NewBulkService creates a new BulkService instance
+func NewBulkService(db *sql.DB, cache *CacheService, suspensions *SuspensionService, reports *ReportService) *BulkService {
+	return &BulkService{db: db, cache: cache, suspensions: suspensions, reports: reports}
+}
+
+// Start validates a bulk operation and queues it for the background job
+// started with StartBulkJob. The actor in ctx is stored with it, so every
+// change the job makes is audited as theirs and under the request that
+// started it. Dry runs only resolve the targets.
+func (s *BulkService) Start(ctx context.Context, adminID string, req *models.BulkOperationRequest) (*models.BulkOperation, error) {
+	params, err := validateBulkRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	actor := auditActorFrom(ctx)
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.bulk_operations (kind, params, dry_run, created_by, actor_role, ip_address, request_id,
+			audit_reason, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9)
+		RETURNING id
+	`, params.Kind, data, params.DryRun, adminID, actor.Role, actor.IP, actor.RequestID,
+		truncateRunes(strings.TrimSpace(actor.Reason), constants.MaxAuditReasonLength), time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	if !params.DryRun {
+		if err := recordAudit(ctx, tx, models.AuditActionBulkStart, "bulk_operation", id, nil, params); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetOperation(ctx, id)
+}
+
+// validateBulkRequest checks a request and returns its parameters with
+// only the fields its kind uses
+func validateBulkRequest(ctx context.Context, req *models.BulkOperationRequest) (*models.BulkOperationRequest, error) {
+	params := &models.BulkOperationRequest{Kind: req.Kind, DryRun: req.DryRun}
+	switch req.Kind {
+	case models.BulkKindSuspendUsers:
+		params.UserIDs = uniqueStrings(req.UserIDs)
+		if len(params.UserIDs) == 0 || len(params.UserIDs) > constants.BulkMaxUsers {
+			return nil, ErrInvalidBulkUsers
+		}
+		scope, _, err := validateSuspension(req.Scope, req.DurationHours)
+		if err != nil {
+			return nil, err
+		}
+		params.Scope = scope
+		params.DurationHours = req.DurationHours
+		if params.Reason, err = validateBulkReason(req.Reason); err != nil {
+			return nil, err
+		}
+
+	case models.BulkKindRemoveContent:
+		params.UserID = strings.TrimSpace(req.UserID)
+		if params.UserID == "" || req.Since == nil || req.Since.IsZero() {
+			return nil, ErrInvalidBulkSince
+		}
+		var exists bool
+		err := database.QueryRowWithContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM public.users WHERE id::text = $1)", params.UserID).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrUserNotFound
+		}
+		since := req.Since.UTC()
+		params.Since = &since
+
+	case models.BulkKindResolveReports:
+		switch req.Status {
+		case "":
+			params.Status = models.ReportStatusResolved
+		case models.ReportStatusResolved, models.ReportStatusDismissed:
+			params.Status = req.Status
+		default:
+			return nil, ErrInvalidBulkStatus
+		}
+		params.AuthorID = strings.TrimSpace(req.AuthorID)
+		params.Summary = strings.TrimSpace(utils.SanitizeHTML(req.Summary))
+		if !utils.ValidateLength(params.Summary, 0, constants.MaxBulkSummaryLength) {
+			return nil, ErrInvalidBulkSummary
+		}
+
+	case models.BulkKindPurge:
+		params.IP = strings.TrimSpace(req.IP)
+		if params.IP != "" {
+			if _, err := parseBulkIP(params.IP); err != nil {
+				return nil, err
+			}
+		}
+		if req.SignedUpFrom != nil && !req.SignedUpFrom.IsZero() {
+			from := req.SignedUpFrom.UTC()
+			params.SignedUpFrom = &from
+		}
+		if req.SignedUpTo != nil && !req.SignedUpTo.IsZero() {
+			to := req.SignedUpTo.UTC()
+			params.SignedUpTo = &to
+		}
+		if params.IP == "" && params.SignedUpFrom == nil && params.SignedUpTo == nil {
+			return nil, ErrInvalidBulkCriteria
+		}
+		if params.SignedUpFrom != nil && params.SignedUpTo != nil && !params.SignedUpFrom.Before(*params.SignedUpTo) {
+			return nil, ErrInvalidBulkWindow
+		}
+		var err error
+		if params.Reason, err = validateBulkReason(req.Reason); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, ErrInvalidBulkKind
+	}
+	return params, nil
+}
+
+func validateBulkReason(reason string) (string, error) {
+	reason = strings.TrimSpace(utils.SanitizeHTML(reason))
+	if reason == "" || !utils.ValidateLength(reason, 1, constants.MaxSuspensionReasonLength) {
+		return "", ErrInvalidSuspensionReason
+	}
+	return reason, nil
+}
+
+// parseBulkIP parses an address, as a single-address range, or a CIDR range
+func parseBulkIP(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, ErrInvalidBulkIP
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, ErrInvalidBulkIP
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func scanBulkOperation(row rowScanner) (*models.BulkOperation, error) {
+	var op models.BulkOperation
+	var params, targets, failures []byte
+	var startedAt, completedAt sql.NullTime
+	err := row.Scan(&op.ID, &op.Kind, &params, &op.DryRun, &op.Status, &op.Total, &op.Processed, &op.Succeeded,
+		&op.Failed, &targets, &failures, &op.Error, &op.CreatedBy, &op.CreatedAt, &startedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &op.Params); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(targets, &op.Targets); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(failures, &op.Failures); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		op.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		op.CompletedAt = &completedAt.Time
+	}
+	return &op, nil
+}
+
+// GetOperation returns a bulk operation and its progress
+func (s *BulkService) GetOperation(ctx context.Context, id string) (*models.BulkOperation, error) {
+	op, err := scanBulkOperation(database.QueryRowWithContext(ctx,
+		"SELECT "+bulkColumns+" FROM public.bulk_operations b WHERE b.id::text = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrBulkOperationNotFound
+	}
+	return op, err
+}
+
+// ListOperations returns bulk operations, newest first
+func (s *BulkService) ListOperations(ctx context.Context, limit, offset int) ([]*models.BulkOperation, error) {
+	limit, offset = clampPage(limit, offset)
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+bulkColumns+` FROM public.bulk_operations b
+		ORDER BY b.created_at DESC, b.id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []*models.BulkOperation{}
+	for rows.Next() {
+		op, err := scanBulkOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+// Cancel stops a queued or running operation. Targets already processed
+// stay processed.
+func (s *BulkService) Cancel(ctx context.Context, id string) (*models.BulkOperation, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var dryRun bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT status, dry_run FROM public.bulk_operations WHERE id::text = $1 FOR UPDATE", id).Scan(&status, &dryRun)
+	if err == sql.ErrNoRows {
+		return nil, ErrBulkOperationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != models.BulkStatusPending && status != models.BulkStatusRunning {
+		return nil, ErrBulkOperationFinished
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.bulk_operations SET status = 'cancelled', completed_at = $2 WHERE id::text = $1
+	`, id, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		before := map[string]interface{}{"status": status}
+		after := map[string]interface{}{"status": models.BulkStatusCancelled}
+		if err := recordAudit(ctx, tx, models.AuditActionBulkCancel, "bulk_operation", id, before, after); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetOperation(ctx, id)
+}
+
+// StartBulkJob runs queued bulk operations in the background, one at a
+// time per instance
+func (s *BulkService) StartBulkJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.BulkPollInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				for {
+					processed, err := s.ProcessPending(ctx)
+					if err != nil {
+						log.Printf("Bulk operation job error: %v", err)
+					}
+					if !processed || err != nil {
+						break
+					}
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// bulkJob is a claimed operation with what the job needs to run it
+type bulkJob struct {
+	id          string
+	params      models.BulkOperationRequest
+	dryRun      bool
+	targets     []models.BulkTarget
+	resolved    bool
+	processed   int
+	adminID     string
+	actor       AuditActor
+	scope       string
+	expiresAt   *time.Time
+	removedPost bool
+}
+
+// ProcessPending runs the oldest queued operation, if any, to the end.
+// Operations left running by a crashed instance are resumed from the
+// first unprocessed target a limited number of times. Returns false when
+// there was nothing to do.
+func (s *BulkService) ProcessPending(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	stale := now.Add(-constants.BulkStaleAfter)
+	_, err := database.ExecWithContext(ctx, `
+		UPDATE public.bulk_operations
+		SET status = 'failed', error = 'interrupted too many times', completed_at = $1
+		WHERE status = 'running' AND updated_at < $2 AND attempts >= $3
+	`, now, stale, maxBulkAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	job := &bulkJob{}
+	var params, targets []byte
+	err = database.QueryRowWithContext(ctx, `
+		UPDATE public.bulk_operations
+		SET status = 'running', started_at = COALESCE(started_at, $1), updated_at = $1, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM public.bulk_operations
+			WHERE status = 'pending' OR (status = 'running' AND updated_at < $2)
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, params, dry_run, targets, processed, COALESCE(created_by::text, ''), COALESCE(actor_role, ''),
+			COALESCE(ip_address, ''), COALESCE(request_id, ''), COALESCE(audit_reason, '')
+	`, now, stale).Scan(&job.id, &params, &job.dryRun, &targets, &job.processed, &job.adminID, &job.actor.Role,
+		&job.actor.IP, &job.actor.RequestID, &job.actor.Reason)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	job.actor.ID = job.adminID
+
+	err = json.Unmarshal(params, &job.params)
+	if err == nil && targets != nil {
+		job.resolved = true
+		err = json.Unmarshal(targets, &job.targets)
+	}
+	if err == nil {
+		err = s.run(ctx, job)
+	}
+	if err != nil {
+		log.Printf("Bulk operation %s failed: %v", job.id, err)
+		_, _ = database.ExecWithContext(ctx, `
+			UPDATE public.bulk_operations SET status = 'failed', error = $2, completed_at = $3
+			WHERE id = $1 AND status = 'running'
+		`, job.id, err.Error(), time.Now().UTC())
+	}
+	return true, nil
+}
+
+// run resolves the operation's targets, unless a previous attempt did,
+// and acts on each unprocessed one
+func (s *BulkService) run(ctx context.Context, job *bulkJob) error {
+	if !job.resolved {
+		targets, err := s.resolveTargets(ctx, &job.params)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(targets)
+		if err != nil {
+			return err
+		}
+		status := models.BulkStatusRunning
+		var completedAt *time.Time
+		now := time.Now().UTC()
+		if job.dryRun {
+			status = models.BulkStatusCompleted
+			completedAt = &now
+		}
+		result, err := database.ExecWithContext(ctx, `
+			UPDATE public.bulk_operations SET targets = $2, total = $3, status = $4, completed_at = $5, updated_at = $6
+			WHERE id = $1 AND status = 'running'
+		`, job.id, data, len(targets), status, completedAt, now)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil
+		}
+		job.targets = targets
+	}
+	if job.dryRun {
+		return nil
+	}
+
+	switch job.params.Kind {
+	case models.BulkKindSuspendUsers:
+		scope, expiresAt, err := validateSuspension(job.params.Scope, job.params.DurationHours)
+		if err != nil {
+			return err
+		}
+		job.scope, job.expiresAt = scope, expiresAt
+	case models.BulkKindPurge:
+		job.scope = models.SuspensionScopeFull
+	}
+
+	ctx = WithAuditActor(ctx, job.actor)
+	defer func() {
+		if job.removedPost && s.cache != nil {
+			s.cache.InvalidatePosts(context.Background())
+		}
+	}()
+	for _, target := range job.targets[job.processed:] {
+		running, err := s.processTarget(ctx, job, target)
+		if err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+	}
+
+	_, err := database.ExecWithContext(ctx, `
+		UPDATE public.bulk_operations SET status = 'completed', completed_at = $2, updated_at = $2
+		WHERE id = $1 AND status = 'running'
+	`, job.id, time.Now().UTC())
+	return err
+}
+
+// processTarget acts on one target in its own transaction, which also
+// counts it as processed so a resumed job never repeats it. It returns
+// false once the operation has been cancelled.
+func (s *BulkService) processTarget(ctx context.Context, job *bulkJob, target models.BulkTarget) (bool, error) {
+	itemCtx, cancel := context.WithTimeout(ctx, constants.BulkItemTimeout)
+	defer cancel()
+	tx, err := database.BeginTx(itemCtx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var done func()
+	switch job.params.Kind {
+	case models.BulkKindSuspendUsers:
+		done, err = s.suspendTarget(itemCtx, tx, job, target, now)
+	case models.BulkKindRemoveContent:
+		done, err = s.removeTarget(itemCtx, tx, job, target)
+	case models.BulkKindResolveReports:
+		done, err = s.resolveReportsTarget(itemCtx, tx, job, target, now)
+	case models.BulkKindPurge:
+		done, err = s.purgeTarget(itemCtx, tx, job, target, now)
+	default:
+		return false, ErrInvalidBulkKind
+	}
+	if err != nil {
+		tx.Rollback()
+		return s.recordFailure(ctx, job, target, err)
+	}
+
+	result, err := tx.ExecContext(itemCtx, `
+		UPDATE public.bulk_operations SET processed = processed + 1, succeeded = succeeded + 1, updated_at = $2
+		WHERE id = $1 AND status = 'running'
+	`, job.id, now)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	if done != nil {
+		done()
+	}
+	return true, nil
+}
+
+// recordFailure counts a target the operation couldn't act on, keeping the
+// first BulkMaxFailuresKept errors
+func (s *BulkService) recordFailure(ctx context.Context, job *bulkJob, target models.BulkTarget, cause error) (bool, error) {
+	data, err := json.Marshal([]models.BulkFailure{{Target: target, Error: cause.Error()}})
+	if err != nil {
+		return false, err
+	}
+	result, err := database.ExecWithContext(ctx, `
+		UPDATE public.bulk_operations
+		SET processed = processed + 1, failed = failed + 1, updated_at = $3,
+			failures = CASE WHEN jsonb_array_length(failures) < $4 THEN failures || $2::jsonb ELSE failures END
+		WHERE id = $1 AND status = 'running'
+	`, job.id, data, time.Now().UTC(), constants.BulkMaxFailuresKept)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// suspendTarget suspends one user of a suspend_users operation and emails
+// them the notice, as a single suspension would
+func (s *BulkService) suspendTarget(ctx context.Context, tx *sql.Tx, job *bulkJob, target models.BulkTarget, now time.Time) (func(), error) {
+	state, err := lockUserAdminState(ctx, tx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if state.IsAdmin {
+		return nil, ErrCannotBulkSuspendAdmin
+	}
+
+	previous, suspension, err := suspendUserTx(ctx, tx, target.ID, job.scope, job.params.Reason, job.expiresAt, job.adminID, "", now)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionUserSuspend, "user", target.ID, previous, suspension); err != nil {
+		return nil, err
+	}
+	return func() {
+		invalidateSuspension(target.ID)
+		if s.suspensions != nil {
+			s.suspensions.notifySuspendedAsync(suspension)
+		}
+	}, nil
+}
+
+// removeTarget deletes one post or comment of a remove_content operation.
+// Content already gone, such as replies deleted with their parent, counts
+// as removed.
+func (s *BulkService) removeTarget(ctx context.Context, tx *sql.Tx, job *bulkJob, target models.BulkTarget) (func(), error) {
+	removed, err := removeBulkContentTx(ctx, tx, target.Type, target.ID)
+	if err != nil || removed == nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionContentRemove, target.Type, target.ID, removed, nil); err != nil {
+		return nil, err
+	}
+	return func() {
+		if target.Type == "post" {
+			job.removedPost = true
+		}
+	}, nil
+}
+
+// removeBulkContentTx deletes a post or comment if it still exists and
+// returns what was removed, for the audit log
+func removeBulkContentTx(ctx context.Context, tx *sql.Tx, targetType, id string) (map[string]interface{}, error) {
+	var authorID, content string
+	removed := map[string]interface{}{}
+	var err error
+	if targetType == "post" {
+		var title string
+		err = tx.QueryRowContext(ctx,
+			"SELECT author_id, title, content FROM public.posts WHERE id::text = $1 FOR UPDATE", id,
+		).Scan(&authorID, &title, &content)
+		if err == nil {
+			removed["title"] = title
+			err = deletePostTx(ctx, tx, id, authorID)
+		}
+	} else {
+		var postID string
+		err = tx.QueryRowContext(ctx,
+			"SELECT author_id, post_id, content FROM public.comments WHERE id::text = $1 FOR UPDATE", id,
+		).Scan(&authorID, &postID, &content)
+		if err == nil {
+			removed["postId"] = postID
+			err = deleteCommentTx(ctx, tx, id, postID)
+		}
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	removed["authorId"] = authorID
+	removed["content"] = truncateRunes(content, bulkLabelLength)
+	return removed, nil
+}
+
+// resolveReportsTarget closes the open reports on one piece of removed
+// content. Upheld reports cost the author reputation, and reporters are
+// emailed the outcome, as when a moderator resolves them one by one.
+func (s *BulkService) resolveReportsTarget(ctx context.Context, tx *sql.Tx, job *bulkJob, target models.BulkTarget, now time.Time) (func(), error) {
+	status, summary := job.params.Status, job.params.Summary
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE public.reports
+		SET status = $3, reviewed_at = COALESCE(reviewed_at, $4), reviewed_by = COALESCE(reviewed_by, NULLIF($5, '')::uuid),
+			resolved_at = $4, resolved_by = NULLIF($5, '')::uuid, resolution_summary = NULLIF($6, ''), updated_at = $4
+		WHERE target_type = $1 AND target_id::text = $2 AND status IN ('pending', 'reviewed')
+			AND post_id IS NULL AND comment_id IS NULL
+		RETURNING id, COALESCE((SELECT u.id::text FROM public.users u WHERE u.id::text = snapshot->>'authorId'), '')
+	`, target.Type, target.ID, status, now, job.adminID, summary)
+	if err != nil {
+		return nil, err
+	}
+	var closedIDs []string
+	var authorID string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id, &authorID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		closedIDs = append(closedIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(closedIDs) == 0 {
+		return nil, nil
+	}
+	sort.Strings(closedIDs)
+
+	upheld := status == models.ReportStatusResolved && authorID != "" && job.adminID != ""
+	if upheld {
+		if err := recordReputation(ctx, tx, authorID, ReputationReportUpheld, closedIDs[0], job.adminID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	action := models.AuditActionReportDismiss
+	if status == models.ReportStatusResolved {
+		action = models.AuditActionReportResolve
+	}
+	after := map[string]interface{}{
+		"status":        status,
+		"summary":       summary,
+		"closedReports": closedIDs,
+		"targetType":    target.Type,
+		"targetId":      target.ID,
+	}
+	if err := recordAudit(ctx, tx, action, "report", closedIDs[0], nil, after); err != nil {
+		return nil, err
+	}
+	return func() {
+		if upheld {
+			refreshBadgesAsync(authorID)
+		}
+		if s.reports != nil {
+			s.reports.notifyOutcomeAsync(closedIDs, authorID, target.Type, status, nil, summary)
+		}
+	}, nil
+}
+
+// purgeTarget fully suspends one account of a purge operation until
+// lifted and removes all its posts and comments. Purged users aren't
+// emailed.
+func (s *BulkService) purgeTarget(ctx context.Context, tx *sql.Tx, job *bulkJob, target models.BulkTarget, now time.Time) (func(), error) {
+	state, err := lockUserAdminState(ctx, tx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if state.IsAdmin {
+		return nil, ErrCannotBulkSuspendAdmin
+	}
+
+	previous, suspension, err := suspendUserTx(ctx, tx, target.ID, job.scope, job.params.Reason, nil, job.adminID, "", now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments on the user's own posts go with the posts
+	rows, err := tx.QueryContext(ctx, `
+		SELECT 'post', id::text FROM public.posts WHERE author_id::text = $1
+		UNION ALL
+		SELECT 'comment', id::text FROM (
+			SELECT c.id, c.created_at FROM public.comments c
+			WHERE c.author_id::text = $1
+				AND c.post_id NOT IN (SELECT id FROM public.posts WHERE author_id::text = $1)
+			ORDER BY c.created_at
+		) c
+	`, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	var content []models.BulkTarget
+	for rows.Next() {
+		var item models.BulkTarget
+		if err := rows.Scan(&item.Type, &item.ID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		content = append(content, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	removedPosts, removedComments := []string{}, []string{}
+	for _, item := range content {
+		removed, err := removeBulkContentTx(ctx, tx, item.Type, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		if removed == nil {
+			continue
+		}
+		if item.Type == "post" {
+			removedPosts = append(removedPosts, item.ID)
+		} else {
+			removedComments = append(removedComments, item.ID)
+		}
+	}
+
+	after := map[string]interface{}{
+		"suspension":      suspension,
+		"removedPosts":    removedPosts,
+		"removedComments": removedComments,
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionUserPurge, "user", target.ID, previous, after); err != nil {
+		return nil, err
+	}
+	return func() {
+		invalidateSuspension(target.ID)
+		if len(removedPosts) > 0 {
+			job.removedPost = true
+		}
+	}, nil
+}
+
+// resolveTargets lists what an operation acts on, in the order it acts.
+// Operations matching more than BulkMaxTargets fail.
+func (s *BulkService) resolveTargets(ctx context.Context, params *models.BulkOperationRequest) ([]models.BulkTarget, error) {
+	var targets []models.BulkTarget
+	var err error
+	switch params.Kind {
+	case models.BulkKindSuspendUsers:
+		targets, err = resolveBulkUsers(ctx, params.UserIDs)
+	case models.BulkKindRemoveContent:
+		targets, err = resolveBulkContent(ctx, params.UserID, *params.Since)
+	case models.BulkKindResolveReports:
+		targets, err = resolveBulkReports(ctx, params.AuthorID)
+	case models.BulkKindPurge:
+		targets, err = resolveBulkPurge(ctx, params)
+	default:
+		err = ErrInvalidBulkKind
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) > constants.BulkMaxTargets {
+		return nil, ErrTooManyBulkTargets
+	}
+	if targets == nil {
+		targets = []models.BulkTarget{}
+	}
+	return targets, nil
+}
+
+func bulkUserLabel(name, handle string) string {
+	if handle == "" {
+		return name
+	}
+	return name + " (@" + handle + ")"
+}
+
+// resolveBulkUsers returns every requested user, in the order given, with
+// a note on those that will be skipped
+func resolveBulkUsers(ctx context.Context, userIDs []string) ([]models.BulkTarget, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT id::text, name, COALESCE(handle, ''), COALESCE(is_admin, false)
+		FROM public.users
+		WHERE id::text = ANY($1)
+	`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]models.BulkTarget{}
+	for rows.Next() {
+		var id, name, handle string
+		var isAdmin bool
+		if err := rows.Scan(&id, &name, &handle, &isAdmin); err != nil {
+			return nil, err
+		}
+		target := models.BulkTarget{Type: "user", ID: id, Label: bulkUserLabel(name, handle)}
+		if isAdmin {
+			target.Note = "admin, will be skipped"
+		}
+		found[id] = target
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	targets := make([]models.BulkTarget, 0, len(userIDs))
+	for _, id := range userIDs {
+		target, ok := found[id]
+		if !ok {
+			target = models.BulkTarget{Type: "user", ID: id, Note: "user not found"}
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// resolveBulkContent returns a user's posts since a time, then their
+// comments since then on other posts, oldest first
+func resolveBulkContent(ctx context.Context, userID string, since time.Time) ([]models.BulkTarget, error) {
+	return queryBulkTargets(ctx, `
+		SELECT type, id, label FROM (
+			SELECT 'post' AS type, p.id::text AS id, p.title AS label, p.created_at
+			FROM public.posts p
+			WHERE p.author_id::text = $1 AND p.created_at >= $2
+			UNION ALL
+			SELECT 'comment', c.id::text, c.content, c.created_at
+			FROM public.comments c
+			WHERE c.author_id::text = $1 AND c.created_at >= $2
+				AND c.post_id NOT IN (SELECT id FROM public.posts WHERE author_id::text = $1 AND created_at >= $2)
+		) t
+		ORDER BY CASE type WHEN 'post' THEN 0 ELSE 1 END, created_at
+		LIMIT $3
+	`, userID, since, constants.BulkMaxTargets+1)
+}
+
+// resolveBulkReports returns the removed posts and comments with open
+// reports, optionally only one author's, oldest report first
+func resolveBulkReports(ctx context.Context, authorID string) ([]models.BulkTarget, error) {
+	return queryBulkTargets(ctx, `
+		SELECT r.target_type, r.target_id::text,
+			COUNT(*) || ' open report(s): ' || MAX(COALESCE(NULLIF(r.snapshot->>'title', ''), r.snapshot->>'content', ''))
+		FROM public.reports r
+		WHERE r.status IN ('pending', 'reviewed') AND r.post_id IS NULL AND r.comment_id IS NULL
+			AND r.target_type IS NOT NULL AND r.target_id IS NOT NULL
+			AND ($1 = '' OR r.snapshot->>'authorId' = $1)
+		GROUP BY r.target_type, r.target_id
+		ORDER BY MIN(r.created_at)
+		LIMIT $2
+	`, authorID, constants.BulkMaxTargets+1)
+}
+
+// resolveBulkPurge returns the accounts, admins aside, that signed up in
+// the window and signed up or signed in from the IP range, oldest first
+func resolveBulkPurge(ctx context.Context, params *models.BulkOperationRequest) ([]models.BulkTarget, error) {
+	var addresses []string
+	if params.IP != "" {
+		network, err := parseBulkIP(params.IP)
+		if err != nil {
+			return nil, err
+		}
+		if addresses, err = matchingBulkAddresses(ctx, network); err != nil {
+			return nil, err
+		}
+		if len(addresses) == 0 {
+			return nil, nil
+		}
+	}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT u.id::text, u.name, COALESCE(u.handle, '')
+		FROM public.users u
+		WHERE u.deleted_at IS NULL AND COALESCE(u.is_admin, false) = false
+			AND ($1::timestamptz IS NULL OR u.created_at >= $1)
+			AND ($2::timestamptz IS NULL OR u.created_at < $2)
+			AND (NOT $3 OR u.id IN (
+				SELECT user_id FROM public.security_events
+				WHERE event_type IN ('signup', 'login') AND success = true AND ip_address = ANY($4)
+				UNION
+				SELECT user_id FROM public.sessions WHERE ip_address = ANY($4)
+			))
+		ORDER BY u.created_at, u.id
+		LIMIT $5
+	`, params.SignedUpFrom, params.SignedUpTo, params.IP != "", pq.Array(addresses), constants.BulkMaxTargets+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []models.BulkTarget
+	for rows.Next() {
+		var id, name, handle string
+		if err := rows.Scan(&id, &name, &handle); err != nil {
+			return nil, err
+		}
+		targets = append(targets, models.BulkTarget{Type: "user", ID: id, Label: bulkUserLabel(name, handle)})
+	}
+	return targets, rows.Err()
+}
+
+// matchingBulkAddresses returns the recorded sign-up and sign-in addresses
+// in network, as stored. Addresses are recorded as the single client IP
+// resolved by middleware.ClientIP; values that aren't one address, like raw
+// forwarded-for lists recorded before that, can't be trusted and never match.
+func matchingBulkAddresses(ctx context.Context, network *net.IPNet) ([]string, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT DISTINCT ip_address FROM (
+			SELECT ip_address FROM public.security_events
+			WHERE event_type IN ('signup', 'login') AND success = true AND user_id IS NOT NULL
+			UNION
+			SELECT ip_address FROM public.sessions
+		) ips
+		WHERE ip_address IS NOT NULL AND ip_address <> ''
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var stored string
+		if err := rows.Scan(&stored); err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(stored); ip != nil && network.Contains(ip) {
+			addresses = append(addresses, stored)
+		}
+	}
+	return addresses, rows.Err()
+}
+
+// queryBulkTargets runs a query returning type, ID and label rows
+func queryBulkTargets(ctx context.Context, query string, args ...interface{}) ([]models.BulkTarget, error) {
+	rows, err := database.QueryWithContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []models.BulkTarget
+	for rows.Next() {
+		var target models.BulkTarget
+		if err := rows.Scan(&target.Type, &target.ID, &target.Label); err != nil {
+			return nil, err
+		}
+		target.Label = truncateRunes(target.Label, bulkLabelLength)
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
@@ -3,19 +3,24 @@ package services
 import (
 	"context"
 	"database/sql"
+	"log"
 	"time"
 
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
 	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
 )
 
 // CleanupService handles cleanup operations
 type CleanupService struct {
-	db *sql.DB
+	db  *sql.DB
+	cfg *config.Config
 }
 
 // NewCleanupService creates a new CleanupService instance
-func NewCleanupService(db *sql.DB) *CleanupService {
-	return &CleanupService{db: db}
+func NewCleanupService(db *sql.DB, cfg *config.Config) *CleanupService {
+	return &CleanupService{db: db, cfg: cfg}
 }
 
 // CleanupExpiredOTPs removes expired OTP codes from PostgreSQL
@@ -32,6 +37,43 @@ func (s *CleanupService) CleanupExpiredSessions(ctx context.Context) error {
 	return err
 }
 
+// CleanupUnverifiedAccounts deletes email signups that were never verified
+// within constants.UnverifiedAccountRetention, Supabase auth user included
+func (s *CleanupService) CleanupUnverifiedAccounts(ctx context.Context) error {
+	query := `
+		SELECT id FROM public.users
+		WHERE email_verified_at IS NULL AND provider = 'email' AND role = 'user'
+			AND deleted_at IS NULL AND created_at < $1
+		ORDER BY created_at
+		LIMIT 100
+	`
+	rows, err := database.QueryWithContext(ctx, query, time.Now().UTC().Add(-constants.UnverifiedAccountRetention))
+	if err != nil {
+		return err
+	}
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	accounts := NewAccountService(s.db, s.cfg, nil, nil)
+	for _, id := range userIDs {
+		if err := accounts.deleteAccount(ctx, id, models.DeletionModeDelete); err != nil {
+			log.Printf("Failed to remove unverified account %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
 // StartCleanupJob starts background cleanup job (unchanged, just uses new methods)
 func (s *CleanupService) StartCleanupJob(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Hour) // Run every hour
@@ -42,6 +84,7 @@ func (s *CleanupService) StartCleanupJob(ctx context.Context) {
 				cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 				s.CleanupExpiredOTPs(cleanupCtx)
 				s.CleanupExpiredSessions(cleanupCtx)
+				s.CleanupUnverifiedAccounts(cleanupCtx)
 				cancel()
 			case <-ctx.Done():
 				ticker.Stop()
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,13 @@ import (
 	"tech-bant-community/server/utils"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrNotPostAuthor is returned when someone other than the post's author tries to accept an answer
+var (
+	ErrNotPostAuthor         = errors.New("only the post's author can accept an answer")
+	ErrParentCommentNotFound = errors.New("the comment you are replying to does not exist on this post")
 )
 
 // CommentService handles comment operations
@@ -23,7 +31,9 @@ func NewCommentService(db *sql.DB) *CommentService {
 	return &CommentService{db: db}
 }
 
-// CreateComment creates a comment on a post using PostgreSQL transaction
+// CreateComment creates a comment on a post using PostgreSQL transaction.
+// Comments matching a hold filter rule, or that the spam classifier scores
+// high enough, are queued for review and return ErrContentHeld.
 func (s *CommentService) CreateComment(ctx context.Context, userID, postID string, req *models.CreateCommentRequest) (*models.Comment, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
@@ -41,6 +51,56 @@ func (s *CommentService) CreateComment(ctx context.Context, userID, postID strin
 		return nil, utils.WrapError(err, "failed to get user")
 	}
 
+	// Blocked users cannot reply to each other's posts
+	var postAuthorID string
+	err = database.QueryRowWithContext(ctx, "SELECT author_id FROM public.posts WHERE id = $1", postID).Scan(&postAuthorID)
+	if err != nil {
+		return nil, utils.WrapError(err, "failed to get post")
+	}
+	blocked, err := NewBlockService(s.db).IsBlockedEither(ctx, userID, postAuthorID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrUserBlocked
+	}
+	allowed, err := NewPrivacyService(s.db).CanComment(ctx, userID, postAuthorID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrCommentsRestricted
+	}
+
+	// Replies must point at a comment on the same post
+	var parentID sql.NullString
+	var parentAuthorID string
+	if req.ParentID != "" {
+		err = database.QueryRowWithContext(ctx,
+			"SELECT author_id FROM public.comments WHERE id::text = $1 AND post_id = $2", req.ParentID, postID,
+		).Scan(&parentAuthorID)
+		if err == sql.ErrNoRows {
+			return nil, ErrParentCommentNotFound
+		}
+		if err != nil {
+			return nil, utils.WrapError(err, "failed to get parent comment")
+		}
+		parentID = sql.NullString{String: req.ParentID, Valid: true}
+	}
+
+	verdict, err := filterContent(ctx, models.FilterTargetComment, filterField{name: "content", value: &content})
+	if err != nil {
+		return nil, err
+	}
+	held := &models.CreateCommentRequest{Content: content, ParentID: req.ParentID}
+	if verdict.Action == models.FilterActionHold {
+		return nil, holdContent(ctx, userID, models.QueueKindComment, postID, held, verdict)
+	}
+	spamScore, err := holdSpam(ctx, userID, models.QueueKindComment, postID, held, content)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UTC()
 	commentID := uuid.New()
 
@@ -53,14 +113,14 @@ func (s *CommentService) CreateComment(ctx context.Context, userID, postID strin
 
 	// Insert comment
 	commentQuery := `
-		INSERT INTO public.comments (id, post_id, author_id, content, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO public.comments (id, post_id, author_id, parent_id, content, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, post_id, author_id, content, created_at, updated_at
 	`
 
 	var comment models.Comment
 	err = tx.QueryRowContext(ctx, commentQuery,
-		commentID, postID, userID, content, now, now,
+		commentID, postID, userID, parentID, content, now, now,
 	).Scan(
 		&comment.ID, &comment.PostID, &comment.AuthorID, &comment.Content,
 		&comment.CreatedAt, &comment.UpdatedAt,
@@ -68,6 +128,7 @@ func (s *CommentService) CreateComment(ctx context.Context, userID, postID strin
 	if err != nil {
 		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
+	comment.ParentID = parentID.String
 
 	// Increment post comments count
 	_, err = tx.ExecContext(ctx, "UPDATE public.posts SET comments = comments + 1 WHERE id = $1", postID)
@@ -85,19 +146,57 @@ func (s *CommentService) CreateComment(ctx context.Context, userID, postID strin
 		return nil, fmt.Errorf("failed to increment counter: %w", err)
 	}
 
+	if err := recordReputation(ctx, tx, userID, ReputationCommentCreated, comment.ID, userID, now); err != nil {
+		return nil, fmt.Errorf("failed to record reputation: %w", err)
+	}
+
+	if err := flagContentTx(ctx, tx, "comment", comment.ID, verdict); err != nil {
+		return nil, fmt.Errorf("failed to flag comment: %w", err)
+	}
+	if err := flagSpamTx(ctx, tx, "comment", comment.ID, spamScore); err != nil {
+		return nil, fmt.Errorf("failed to flag comment: %w", err)
+	}
+
+	// A shadow-banned user's comments reach nobody else: no webhooks and
+	// no live stream event. Notifications are dropped when recorded.
+	shadowBanned, err := isShadowBanned(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check shadow ban: %w", err)
+	}
+	if !shadowBanned {
+		err = enqueueWebhookEvent(ctx, tx, models.WebhookEventCommentCreated, map[string]interface{}{
+			"id":        comment.ID,
+			"postId":    comment.PostID,
+			"parentId":  comment.ParentID,
+			"content":   comment.Content,
+			"author":    webhookUser(user),
+			"createdAt": comment.CreatedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to queue webhooks: %w", err)
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	refreshBadgesAsync(userID)
+	notifyCommentAsync(&comment, postAuthorID, parentAuthorID)
 
 	// Populate author
 	comment.Author = user
 	comment.Likes = 0 // Will be calculated from likes table if needed
+	if !shadowBanned {
+		publishCommentAsync(&comment)
+	}
 
 	return &comment, nil
 }
 
-// GetComments gets comments for a post with author information
-func (s *CommentService) GetComments(ctx context.Context, postID string, limit, offset int) ([]*models.Comment, error) {
+// GetComments gets comments for a post with author information, leaving
+// out authors the viewer has blocked, muted or been blocked by and
+// shadow-banned authors other than the viewer
+func (s *CommentService) GetComments(ctx context.Context, viewerID, postID string, limit, offset int) ([]*models.Comment, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -109,19 +208,25 @@ func (s *CommentService) GetComments(ctx context.Context, postID string, limit,
 	}
 
 	query := `
-		SELECT c.id, c.post_id, c.author_id, c.content, c.created_at, c.updated_at,
-		       u.id, u.name, u.email, u.avatar, u.is_admin, u.is_verified,
-		       COUNT(l.id) as likes_count
+		SELECT c.id, c.post_id, c.author_id, c.parent_id, c.content, c.created_at, c.updated_at,
+		       u.id, u.name, u.handle, u.email, u.avatar, u.is_admin, u.is_verified,
+		       COUNT(l.id) as likes_count, p.accepted_comment_id IS NOT DISTINCT FROM c.id as is_accepted
 		FROM public.comments c
 		JOIN public.users u ON c.author_id = u.id
+		JOIN public.posts p ON p.id = c.post_id
 		LEFT JOIN public.likes l ON l.comment_id = c.id
-		WHERE c.post_id = $1
-		GROUP BY c.id, u.id
+		WHERE c.post_id = $1 AND NOT (c.author_id::text = ANY($4)) AND NOT ` + hiddenByShadowBan("c.author_id", "$5") + `
+		GROUP BY c.id, u.id, p.id
 		ORDER BY c.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := database.QueryWithContext(ctx, query, postID, limit, offset)
+	hidden, err := NewBlockService(s.db).HiddenUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	rows, err := database.QueryWithContext(ctx, query, postID, limit, offset, pq.Array(hidden), viewerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get comments: %w", err)
 	}
@@ -131,23 +236,27 @@ func (s *CommentService) GetComments(ctx context.Context, postID string, limit,
 	for rows.Next() {
 		var comment models.Comment
 		var author models.User
-		var avatar sql.NullString
+		var avatar, handle, parentID sql.NullString
 		var likesCount int
 
 		err := rows.Scan(
-			&comment.ID, &comment.PostID, &comment.AuthorID, &comment.Content,
+			&comment.ID, &comment.PostID, &comment.AuthorID, &parentID, &comment.Content,
 			&comment.CreatedAt, &comment.UpdatedAt,
-			&author.ID, &author.Name, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
-			&likesCount,
+			&author.ID, &author.Name, &handle, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
+			&likesCount, &comment.IsAccepted,
 		)
 		if err != nil {
 			continue
 		}
 
 		comment.Likes = likesCount
+		comment.ParentID = parentID.String
 		if avatar.Valid {
 			author.Avatar = avatar.String
 		}
+		if handle.Valid {
+			author.Handle = handle.String
+		}
 		comment.Author = &author
 		comments = append(comments, &comment)
 	}
@@ -172,6 +281,18 @@ func (s *CommentService) UpdateComment(ctx context.Context, userID, commentID st
 		return nil, utils.WrapError(err, "invalid comment content")
 	}
 
+	verdict, err := filterContent(ctx, models.FilterTargetComment, filterField{name: "content", value: &content})
+	if err != nil {
+		return nil, err
+	}
+	if verdict.Action == models.FilterActionHold {
+		return nil, holdContent(ctx, userID, models.QueueKindCommentUpdate, commentID, &models.UpdateCommentRequest{Content: content}, verdict)
+	}
+	spamScore, err := holdSpam(ctx, userID, models.QueueKindCommentUpdate, commentID, &models.UpdateCommentRequest{Content: content}, content)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE public.comments
 		SET content = $1, updated_at = $2
@@ -188,6 +309,12 @@ func (s *CommentService) UpdateComment(ctx context.Context, userID, commentID st
 	if err != nil {
 		return nil, err
 	}
+	if err := flagContent(ctx, "comment", updatedComment.ID, verdict); err != nil {
+		return nil, err
+	}
+	if err := flagSpam(ctx, "comment", updatedComment.ID, spamScore); err != nil {
+		return nil, err
+	}
 
 	// Get author
 	userService := NewUserService(s.db)
@@ -215,25 +342,57 @@ func (s *CommentService) DeleteComment(ctx context.Context, userID, commentID st
 	}
 	defer tx.Rollback()
 
-	// Delete comment (CASCADE will handle related records)
-	_, err = tx.ExecContext(ctx, "DELETE FROM public.comments WHERE id = $1", commentID)
+	if err := deleteCommentTx(ctx, tx, commentID, comment.PostID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshBadgesAsync(userID)
+	return nil
+}
+
+// commentThreadQuery selects the IDs of comment $1 and every reply below it
+const commentThreadQuery = `
+	WITH RECURSIVE thread AS (
+		SELECT id FROM public.comments WHERE id = $1
+		UNION
+		SELECT c.id FROM public.comments c JOIN thread t ON c.parent_id = t.id
+	)
+	SELECT id FROM thread`
+
+// deleteCommentTx deletes a comment and, through the parent_id cascade,
+// its replies, takes back the reputation they earned, and updates the
+// counters by the number of comments removed
+func deleteCommentTx(ctx context.Context, tx *sql.Tx, commentID, postID string) error {
+	var removed int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+commentThreadQuery+") t", commentID).Scan(&removed)
 	if err != nil {
 		return err
 	}
 
-	// Decrement post comments count
-	_, err = tx.ExecContext(ctx, "UPDATE public.posts SET comments = comments - 1 WHERE id = $1", comment.PostID)
+	// Take back reputation earned from the comment and replies to it
+	err = revokeReputation(ctx, tx, "source_id IN ("+commentThreadQuery+")", commentID)
 	if err != nil {
 		return err
 	}
 
-	// Decrement comments counter
-	_, err = tx.ExecContext(ctx, "UPDATE public.counters SET count = count - 1 WHERE collection_name = 'comments'")
+	// Delete comment (CASCADE will handle replies and related records)
+	_, err = tx.ExecContext(ctx, "DELETE FROM public.comments WHERE id = $1", commentID)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	// Decrement post comments count
+	_, err = tx.ExecContext(ctx, "UPDATE public.posts SET comments = comments - $2 WHERE id = $1", postID, removed)
+	if err != nil {
+		return err
+	}
+
+	// Decrement comments counter
+	_, err = tx.ExecContext(ctx, "UPDATE public.counters SET count = count - $1 WHERE collection_name = 'comments'", removed)
+	return err
 }
 
 // LikeComment toggles like on a comment
@@ -242,6 +401,12 @@ func (s *CommentService) LikeComment(ctx context.Context, userID, commentID stri
 	checkQuery := "SELECT id FROM public.likes WHERE comment_id = $1 AND user_id = $2"
 	var likeID string
 	err := database.QueryRowWithContext(ctx, checkQuery, commentID, userID).Scan(&likeID)
+	liked := err == nil
+
+	comment, err := s.getCommentByID(ctx, commentID)
+	if err != nil {
+		return err
+	}
 
 	tx, err := database.BeginTx(ctx)
 	if err != nil {
@@ -249,20 +414,141 @@ func (s *CommentService) LikeComment(ctx context.Context, userID, commentID stri
 	}
 	defer tx.Rollback()
 
-	if err == nil {
+	if liked {
 		// Unlike: delete like
 		_, err = tx.ExecContext(ctx, "DELETE FROM public.likes WHERE id = $1", likeID)
+		if err == nil {
+			err = revokeReputation(ctx, tx, "event_type = $1 AND source_id = $2 AND actor_id = $3",
+				ReputationCommentLikeReceived, commentID, userID)
+		}
 	} else {
 		// Like: insert like
 		likeID := uuid.New()
-		_, err = tx.ExecContext(ctx, "INSERT INTO public.likes (id, comment_id, user_id, created_at) VALUES ($1, $2, $3, $4)", likeID, commentID, userID, time.Now().UTC())
+		now := time.Now().UTC()
+		_, err = tx.ExecContext(ctx, "INSERT INTO public.likes (id, comment_id, user_id, created_at) VALUES ($1, $2, $3, $4)", likeID, commentID, userID, now)
+		if err == nil {
+			err = recordReputation(ctx, tx, comment.AuthorID, ReputationCommentLikeReceived, commentID, userID, now)
+		}
 	}
 
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshBadgesAsync(comment.AuthorID)
+	if liked {
+		retractNotificationAsync(comment.AuthorID, userID, likeCommentGroup(commentID))
+	} else {
+		notifyAsync(notificationEvent{
+			recipientID: comment.AuthorID, actorID: userID, kind: models.NotificationLike,
+			postID: comment.PostID, commentID: commentID, groupKey: likeCommentGroup(commentID),
+		})
+	}
+	publishLikesAsync(comment.PostID, commentID)
+	return nil
+}
+
+// AcceptAnswer marks a comment as the accepted answer to its post. Only the
+// post's author can accept, and accepting another comment replaces the
+// previous answer.
+func (s *CommentService) AcceptAnswer(ctx context.Context, userID, commentID string) error {
+	comment, err := s.getCommentByID(ctx, commentID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var postAuthorID string
+	var previous sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT author_id, accepted_comment_id FROM public.posts WHERE id = $1 FOR UPDATE", comment.PostID,
+	).Scan(&postAuthorID, &previous)
+	if err != nil {
+		return err
+	}
+	if postAuthorID != userID {
+		return ErrNotPostAuthor
+	}
+	if previous.Valid && previous.String == commentID {
+		return tx.Commit()
+	}
+
+	var previousAuthorID string
+	if previous.Valid {
+		if err := tx.QueryRowContext(ctx, "SELECT author_id FROM public.comments WHERE id = $1", previous.String).Scan(&previousAuthorID); err != nil {
+			return err
+		}
+		err = revokeReputation(ctx, tx, "event_type = $1 AND source_id = $2", ReputationAnswerAccepted, previous.String)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx,
+		"UPDATE public.posts SET accepted_comment_id = $1, accepted_at = $2 WHERE id = $3", commentID, now, comment.PostID)
+	if err != nil {
+		return err
+	}
+	if err := recordReputation(ctx, tx, comment.AuthorID, ReputationAnswerAccepted, commentID, userID, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshBadgesAsync(comment.AuthorID, previousAuthorID)
+	return nil
+}
+
+// UnacceptAnswer clears the accepted answer if it is this comment
+func (s *CommentService) UnacceptAnswer(ctx context.Context, userID, commentID string) error {
+	comment, err := s.getCommentByID(ctx, commentID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var postAuthorID string
+	err = tx.QueryRowContext(ctx, "SELECT author_id FROM public.posts WHERE id = $1 FOR UPDATE", comment.PostID).Scan(&postAuthorID)
+	if err != nil {
+		return err
+	}
+	if postAuthorID != userID {
+		return ErrNotPostAuthor
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE public.posts SET accepted_comment_id = NULL, accepted_at = NULL
+		WHERE id = $1 AND accepted_comment_id = $2
+	`, comment.PostID, commentID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		if err := revokeReputation(ctx, tx, "event_type = $1 AND source_id = $2", ReputationAnswerAccepted, commentID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshBadgesAsync(comment.AuthorID)
+	return nil
 }
 
 // getCommentByID gets a comment by ID
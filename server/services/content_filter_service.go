@@ -0,0 +1,649 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrFilterRuleNotFound        = errors.New("filter rule not found")
+	ErrInvalidFilterKind         = errors.New("kind must be 'word', 'regex', 'domain_block' or 'domain_allow'")
+	ErrInvalidFilterPattern      = fmt.Errorf("pattern is required and must be at most %d characters; domains look like example.com", constants.MaxFilterPatternLength)
+	ErrInvalidFilterAction       = errors.New("action must be 'reject', 'hold', 'flag' or 'mask', and domain_allow rules take none")
+	ErrInvalidFilterTarget       = errors.New("targets must be 'post', 'comment' or 'profile'")
+	ErrInvalidFilterDescription  = fmt.Errorf("description must be at most %d characters", constants.MaxFilterDescriptionLength)
+	ErrInvalidFilterTestText     = fmt.Errorf("text is required and must be at most %d characters", constants.MaxFilterTestTextLength)
+	ErrContentRejected           = errors.New("this contains words or links that aren't allowed here")
+	ErrContentHeld               = errors.New("this has been sent to a moderator for review and will appear once approved")
+	errInvalidFilterRegexPattern = errors.New("pattern is not a valid regular expression")
+)
+
+// filterActionRank orders actions so a write takes the strongest one it matches
+var filterActionRank = map[string]int{
+	models.FilterActionAllow:  0,
+	models.FilterActionMask:   1,
+	models.FilterActionFlag:   2,
+	models.FilterActionHold:   3,
+	models.FilterActionReject: 4,
+}
+
+var filterTargets = []string{models.FilterTargetPost, models.FilterTargetComment, models.FilterTargetProfile}
+
+// linkPattern finds URLs and bare domains such as scam.example in text
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://[^\s<>"'()]+|(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}\b(?:/[^\s<>"'()]*)?)`)
+
+// compiledRule is a filter rule ready to match. Word and regex rules have a
+// regular expression; domain rules a normalized domain.
+type compiledRule struct {
+	rule   *models.FilterRule
+	re     *regexp.Regexp
+	domain string
+}
+
+func (c *compiledRule) appliesTo(target string) bool {
+	for _, t := range c.rule.AppliesTo {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRuleSet is the active rules, compiled
+type filterRuleSet struct {
+	rules   []*compiledRule // Word, regex and domain_block rules
+	allowed []*compiledRule // domain_allow rules
+}
+
+// contentFilter holds the rule set every service checks writes against. It
+// is swapped whole on reload, so a check always sees one consistent set.
+var contentFilter = struct {
+	sync.RWMutex
+	set     *filterRuleSet
+	version string
+}{set: &filterRuleSet{}}
+
+func activeFilterRules() *filterRuleSet {
+	contentFilter.RLock()
+	defer contentFilter.RUnlock()
+	return contentFilter.set
+}
+
+// filterField is a piece of a write checked against the rules. Matches of
+// mask rules are masked in place; in fields that can't be masked, such as
+// URLs, they reject the write instead.
+type filterField struct {
+	name   string
+	value  *string
+	noMask bool
+}
+
+type approvedContentKey struct{}
+
+// asApprovedContent marks ctx so writes made with it skip the filter rules
+// and new-account premoderation. Used to publish writes an admin approved
+// from the queue.
+func asApprovedContent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvedContentKey{}, true)
+}
+
+func isApprovedContent(ctx context.Context) bool {
+	approved, _ := ctx.Value(approvedContentKey{}).(bool)
+	return approved
+}
+
+// filterContent checks a write's fields against the active rules for
+// target, masking matches in place. It returns ErrContentRejected if a
+// reject rule matched; callers hold, flag or publish based on the verdict.
+func filterContent(ctx context.Context, target string, fields ...filterField) (*models.FilterVerdict, error) {
+	if isApprovedContent(ctx) {
+		return &models.FilterVerdict{Action: models.FilterActionAllow, Matches: []models.FilterMatch{}}, nil
+	}
+	verdict := activeFilterRules().evaluate(target, fields)
+	if verdict.Action == models.FilterActionReject {
+		return verdict, ErrContentRejected
+	}
+	return verdict, nil
+}
+
+// evaluate matches every field against the rules for target
+func (set *filterRuleSet) evaluate(target string, fields []filterField) *models.FilterVerdict {
+	verdict := &models.FilterVerdict{Action: models.FilterActionAllow, Matches: []models.FilterMatch{}}
+	for _, field := range fields {
+		if field.value == nil || *field.value == "" {
+			continue
+		}
+		text := *field.value
+		links := set.blockableLinks(text)
+
+		var masks [][]int
+		for _, rule := range set.rules {
+			if !rule.appliesTo(target) {
+				continue
+			}
+			var spans [][]int
+			if rule.re != nil {
+				spans = rule.re.FindAllStringIndex(text, -1)
+			} else {
+				for _, link := range links {
+					if domainMatches(link.host, rule.domain) {
+						spans = append(spans, link.span)
+					}
+				}
+			}
+			if len(spans) == 0 {
+				continue
+			}
+
+			action := rule.rule.Action
+			if action == models.FilterActionMask && field.noMask {
+				action = models.FilterActionReject
+			}
+			verdict.Matches = append(verdict.Matches, models.FilterMatch{
+				RuleID:  rule.rule.ID,
+				Kind:    rule.rule.Kind,
+				Pattern: rule.rule.Pattern,
+				Action:  action,
+				Field:   field.name,
+				Text:    text[spans[0][0]:spans[0][1]],
+			})
+			if filterActionRank[action] > filterActionRank[verdict.Action] {
+				verdict.Action = action
+			}
+			if action == models.FilterActionMask {
+				masks = append(masks, spans...)
+			}
+		}
+		if len(masks) > 0 {
+			*field.value = maskSpans(text, masks)
+		}
+	}
+	return verdict
+}
+
+type filterLink struct {
+	span []int
+	host string
+}
+
+// blockableLinks returns the links in text that no domain_allow rule exempts
+func (set *filterRuleSet) blockableLinks(text string) []filterLink {
+	var links []filterLink
+	for _, span := range linkPattern.FindAllStringIndex(text, -1) {
+		host := linkHost(text[span[0]:span[1]])
+		allowed := false
+		for _, allow := range set.allowed {
+			if domainMatches(host, allow.domain) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			links = append(links, filterLink{span: span, host: host})
+		}
+	}
+	return links
+}
+
+// linkHost returns the lowercased host of a URL or bare domain
+func linkHost(link string) string {
+	host := strings.ToLower(link)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// domainMatches reports whether host is domain or one of its subdomains
+func domainMatches(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// maskSpans replaces every character inside spans with an asterisk
+func maskSpans(text string, spans [][]int) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var b strings.Builder
+	pos := 0
+	for _, span := range spans {
+		start, end := span[0], span[1]
+		if end <= pos {
+			continue
+		}
+		if start < pos {
+			start = pos
+		}
+		b.WriteString(text[pos:start])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[start:end])))
+		pos = end
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// compileFilterRule prepares a rule for matching
+func compileFilterRule(rule *models.FilterRule) (*compiledRule, error) {
+	compiled := &compiledRule{rule: rule}
+	switch rule.Kind {
+	case models.FilterKindWord:
+		compiled.re = wordPattern(rule.Pattern)
+	case models.FilterKindRegex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, errInvalidFilterRegexPattern
+		}
+		compiled.re = re
+	case models.FilterKindDomainBlock, models.FilterKindDomainAllow:
+		compiled.domain = rule.Pattern
+	default:
+		return nil, ErrInvalidFilterKind
+	}
+	return compiled, nil
+}
+
+// wordPattern matches a word or phrase case-insensitively, as a whole word
+// and with any run of whitespace between its words
+func wordPattern(phrase string) *regexp.Regexp {
+	words := strings.Fields(phrase)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	expr := strings.Join(words, `\s+`)
+	first, _ := utf8.DecodeRuneInString(phrase)
+	last, _ := utf8.DecodeLastRuneInString(phrase)
+	if isWordRune(first) {
+		expr = `\b` + expr
+	}
+	if isWordRune(last) {
+		expr += `\b`
+	}
+	return regexp.MustCompile(`(?i)` + expr)
+}
+
+// isWordRune reports whether \b treats r as a word character, which it
+// only does for ASCII
+func isWordRune(r rune) bool {
+	return r == '_' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// normalizeFilterDomain turns a domain or URL into a bare lowercase domain
+func normalizeFilterDomain(pattern string) (string, error) {
+	domain := strings.TrimPrefix(linkHost(pattern), "www.")
+	if !strings.Contains(domain, ".") || linkPattern.FindString(domain) != domain {
+		return "", ErrInvalidFilterPattern
+	}
+	return domain, nil
+}
+
+// validateFilterRule checks and normalizes a rule's fields in place
+func validateFilterRule(rule *models.FilterRule) (*compiledRule, error) {
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Pattern == "" || len(rule.Pattern) > constants.MaxFilterPatternLength {
+		return nil, ErrInvalidFilterPattern
+	}
+	switch rule.Kind {
+	case models.FilterKindWord, models.FilterKindRegex, models.FilterKindDomainBlock:
+		if filterActionRank[rule.Action] == 0 {
+			return nil, ErrInvalidFilterAction
+		}
+	case models.FilterKindDomainAllow:
+		if rule.Action != "" {
+			return nil, ErrInvalidFilterAction
+		}
+	default:
+		return nil, ErrInvalidFilterKind
+	}
+	if rule.Kind == models.FilterKindDomainBlock || rule.Kind == models.FilterKindDomainAllow {
+		domain, err := normalizeFilterDomain(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rule.Pattern = domain
+	}
+
+	rule.AppliesTo = uniqueStrings(rule.AppliesTo)
+	if len(rule.AppliesTo) == 0 {
+		rule.AppliesTo = append([]string(nil), filterTargets...)
+	}
+	for _, target := range rule.AppliesTo {
+		if target != models.FilterTargetPost && target != models.FilterTargetComment && target != models.FilterTargetProfile {
+			return nil, ErrInvalidFilterTarget
+		}
+	}
+	rule.Description = strings.TrimSpace(rule.Description)
+	if len(rule.Description) > constants.MaxFilterDescriptionLength {
+		return nil, ErrInvalidFilterDescription
+	}
+
+	compiled, err := compileFilterRule(rule)
+	if err == errInvalidFilterRegexPattern {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilterPattern, err)
+	}
+	return compiled, err
+}
+
+// ContentFilterService manages the filter rules and keeps this instance's
+// compiled copy in step with the database
+type ContentFilterService struct {
+	db *sql.DB
+}
+
+// NewContentFilterService creates a new ContentFilterService instance
+func NewContentFilterService(db *sql.DB) *ContentFilterService {
+	return &ContentFilterService{db: db}
+}
+
+const filterRuleColumns = `id, kind, pattern, COALESCE(action, ''), applies_to, COALESCE(description, ''),
+	is_active, created_by, created_at, updated_at`
+
+func scanFilterRule(row rowScanner) (*models.FilterRule, error) {
+	var rule models.FilterRule
+	var createdBy sql.NullString
+	err := row.Scan(&rule.ID, &rule.Kind, &rule.Pattern, &rule.Action, pq.Array(&rule.AppliesTo),
+		&rule.Description, &rule.IsActive, &createdBy, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	rule.CreatedBy = createdBy.String
+	return &rule, nil
+}
+
+// ListRules returns every filter rule, active or not, newest first
+func (s *ContentFilterService) ListRules(ctx context.Context) ([]*models.FilterRule, error) {
+	rows, err := database.QueryWithContext(ctx,
+		"SELECT "+filterRuleColumns+" FROM public.content_filter_rules ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*models.FilterRule{}
+	for rows.Next() {
+		rule, err := scanFilterRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// CreateRule adds a filter rule, which applies at once on this instance
+func (s *ContentFilterService) CreateRule(ctx context.Context, adminID string, req *models.CreateFilterRuleRequest) (*models.FilterRule, error) {
+	rule := &models.FilterRule{
+		Kind:        req.Kind,
+		Pattern:     req.Pattern,
+		Action:      req.Action,
+		AppliesTo:   req.AppliesTo,
+		Description: req.Description,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+	if _, err := validateFilterRule(rule); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanFilterRule(tx.QueryRowContext(ctx, `
+		INSERT INTO public.content_filter_rules (kind, pattern, action, applies_to, description, is_active, created_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), $6, $7)
+		RETURNING `+filterRuleColumns,
+		rule.Kind, rule.Pattern, rule.Action, pq.Array(rule.AppliesTo), rule.Description, rule.IsActive, adminID))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionFilterRuleCreate, "filter_rule", created.ID, nil, created); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.reloadAfterChange(ctx)
+	return created, nil
+}
+
+// UpdateRule changes the fields set in req
+func (s *ContentFilterService) UpdateRule(ctx context.Context, ruleID string, req *models.UpdateFilterRuleRequest) (*models.FilterRule, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanFilterRule(tx.QueryRowContext(ctx,
+		"SELECT "+filterRuleColumns+" FROM public.content_filter_rules WHERE id::text = $1 FOR UPDATE", ruleID))
+	if err == sql.ErrNoRows {
+		return nil, ErrFilterRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rule := *before
+	if req.Pattern != nil {
+		rule.Pattern = *req.Pattern
+	}
+	if req.Action != nil {
+		rule.Action = *req.Action
+	}
+	if req.AppliesTo != nil {
+		rule.AppliesTo = req.AppliesTo
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if _, err := validateFilterRule(&rule); err != nil {
+		return nil, err
+	}
+
+	updated, err := scanFilterRule(tx.QueryRowContext(ctx, `
+		UPDATE public.content_filter_rules
+		SET pattern = $2, action = NULLIF($3, ''), applies_to = $4, description = NULLIF($5, ''),
+			is_active = $6, updated_at = $7
+		WHERE id = $1
+		RETURNING `+filterRuleColumns,
+		rule.ID, rule.Pattern, rule.Action, pq.Array(rule.AppliesTo), rule.Description, rule.IsActive, time.Now().UTC()))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionFilterRuleUpdate, "filter_rule", updated.ID, before, updated); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.reloadAfterChange(ctx)
+	return updated, nil
+}
+
+// DeleteRule removes a filter rule
+func (s *ContentFilterService) DeleteRule(ctx context.Context, ruleID string) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanFilterRule(tx.QueryRowContext(ctx,
+		"DELETE FROM public.content_filter_rules WHERE id::text = $1 RETURNING "+filterRuleColumns, ruleID))
+	if err == sql.ErrNoRows {
+		return ErrFilterRuleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionFilterRuleDelete, "filter_rule", before.ID, before, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.reloadAfterChange(ctx)
+	return nil
+}
+
+// TestRules dry-runs the active rules, and the draft rule if given, against
+// sample text without saving or publishing anything
+func (s *ContentFilterService) TestRules(req *models.FilterTestRequest) (*models.FilterTestResponse, error) {
+	target := req.Target
+	if target == "" {
+		target = models.FilterTargetPost
+	}
+	if target != models.FilterTargetPost && target != models.FilterTargetComment && target != models.FilterTargetProfile {
+		return nil, ErrInvalidFilterTarget
+	}
+	if req.Text == "" || len(req.Text) > constants.MaxFilterTestTextLength {
+		return nil, ErrInvalidFilterTestText
+	}
+
+	active := activeFilterRules()
+	set := &filterRuleSet{
+		rules:   append([]*compiledRule(nil), active.rules...),
+		allowed: append([]*compiledRule(nil), active.allowed...),
+	}
+	if req.Rule != nil {
+		draft := &models.FilterRule{
+			Kind:        req.Rule.Kind,
+			Pattern:     req.Rule.Pattern,
+			Action:      req.Rule.Action,
+			AppliesTo:   req.Rule.AppliesTo,
+			Description: req.Rule.Description,
+			IsActive:    true,
+		}
+		compiled, err := validateFilterRule(draft)
+		if err != nil {
+			return nil, err
+		}
+		if draft.Kind == models.FilterKindDomainAllow {
+			set.allowed = append(set.allowed, compiled)
+		} else {
+			set.rules = append(set.rules, compiled)
+		}
+	}
+
+	masked := req.Text
+	verdict := set.evaluate(target, []filterField{{name: "text", value: &masked}})
+	return &models.FilterTestResponse{Verdict: verdict, Masked: masked}, nil
+}
+
+// StartContentFilterJob loads the rules now and then reloads them whenever
+// the rules table changes, so edits made on other instances apply within
+// ContentFilterReloadInterval
+func (s *ContentFilterService) StartContentFilterJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.ContentFilterReloadInterval)
+	go func() {
+		if err := s.Reload(ctx, false); err != nil {
+			log.Printf("Content filter reload error: %v", err)
+		}
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+				if err := s.Reload(jobCtx, false); err != nil {
+					log.Printf("Content filter reload error: %v", err)
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// reloadAfterChange applies a rule change on this instance right away
+func (s *ContentFilterService) reloadAfterChange(ctx context.Context) {
+	if err := s.Reload(ctx, true); err != nil {
+		log.Printf("Content filter reload error: %v", err)
+	}
+}
+
+// Reload recompiles the active rules if the rules table changed since the
+// last load, or always if force is set. Rules that fail to compile are
+// skipped and logged.
+func (s *ContentFilterService) Reload(ctx context.Context, force bool) error {
+	var count int
+	var lastUpdate time.Time
+	err := database.QueryRowWithContext(ctx, `
+		SELECT COUNT(*), COALESCE(MAX(updated_at), 'epoch'::timestamptz) FROM public.content_filter_rules
+	`).Scan(&count, &lastUpdate)
+	if err != nil {
+		return err
+	}
+	version := fmt.Sprintf("%d/%s", count, lastUpdate.UTC().Format(time.RFC3339Nano))
+
+	contentFilter.RLock()
+	unchanged := contentFilter.version == version
+	contentFilter.RUnlock()
+	if unchanged && !force {
+		return nil
+	}
+
+	rows, err := database.QueryWithContext(ctx,
+		"SELECT "+filterRuleColumns+" FROM public.content_filter_rules WHERE is_active = true ORDER BY created_at")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	set := &filterRuleSet{}
+	for rows.Next() {
+		rule, err := scanFilterRule(rows)
+		if err != nil {
+			return err
+		}
+		compiled, err := compileFilterRule(rule)
+		if err != nil {
+			log.Printf("Skipping filter rule %s: %v", rule.ID, err)
+			continue
+		}
+		if rule.Kind == models.FilterKindDomainAllow {
+			set.allowed = append(set.allowed, compiled)
+		} else {
+			set.rules = append(set.rules, compiled)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	contentFilter.Lock()
+	contentFilter.set = set
+	contentFilter.version = version
+	contentFilter.Unlock()
+	return nil
+}
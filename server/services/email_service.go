@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
+	"time"
 
 	"tech-bant-community/server/config"
+	"tech-bant-community/server/models"
 
 	"github.com/resend/resend-go/v2"
 )
@@ -61,3 +65,424 @@ Tech Bant Community
 
 	return nil
 }
+
+// SendDataExportEmail sends the download link for a finished data export
+func (s *EmailService) SendDataExportEmail(ctx context.Context, email, link string, expiresAt time.Time) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+Your Tech Bant Community data export is ready.
+
+<a href="%s">Download your archive</a>
+
+This link expires on %s. After that you can request a new export from your account settings.
+
+If you didn't request this export, please change your password and contact support.
+
+Best regards,
+Tech Bant Community
+`, link, expiresAt.UTC().Format("January 2, 2006 at 15:04 UTC"))
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: "Your data export is ready",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendAccountDeletionEmail confirms a scheduled account deletion
+func (s *EmailService) SendAccountDeletionEmail(ctx context.Context, email string, scheduledFor time.Time) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+Your Tech Bant Community account is scheduled for deletion on %s.
+
+Changed your mind? Just log in before then and the deletion will be cancelled.
+
+If you didn't request this, log in now and change your password.
+
+Best regards,
+Tech Bant Community
+`, scheduledFor.UTC().Format("January 2, 2006 at 15:04 UTC"))
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: "Your account is scheduled for deletion",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendEmailChangedEmail tells the previous address that the account's email
+// was changed, with a link to undo it
+func (s *EmailService) SendEmailChangedEmail(ctx context.Context, oldEmail, newEmail, revertLink string, expiresAt time.Time) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+The email address on your Tech Bant Community account was changed to %s.
+
+If you didn't make this change, <a href="%s">restore your old email address</a> and then reset your password. This link works until %s.
+
+Best regards,
+Tech Bant Community
+`, html.EscapeString(newEmail), revertLink, expiresAt.UTC().Format("January 2, 2006 at 15:04 UTC"))
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{oldEmail},
+		Subject: "Your email address was changed",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendVerificationEmail sends the link that confirms a new account's address
+func (s *EmailService) SendVerificationEmail(ctx context.Context, email, link string, expiresAt time.Time) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+Welcome to Tech Bant Community! Please confirm your email address to start posting and commenting.
+
+<a href="%s">Verify your email address</a>
+
+This link expires on %s. Accounts that are never verified are removed after a week.
+
+If you didn't sign up, you can ignore this email.
+
+Best regards,
+Tech Bant Community
+`, link, expiresAt.UTC().Format("January 2, 2006 at 15:04 UTC"))
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: "Verify your email address",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendReportOutcomeEmail tells a reporter how their report was handled
+func (s *EmailService) SendReportOutcomeEmail(ctx context.Context, email, targetType string, upheld bool, summary string) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	outcome := fmt.Sprintf("Our moderators reviewed the %s you reported and found that it doesn't break the community guidelines.", targetType)
+	if upheld {
+		outcome = fmt.Sprintf("Our moderators reviewed the %s you reported and took action. Thanks for helping keep the community safe.", targetType)
+	}
+	if summary != "" {
+		outcome += "<br><br>" + html.EscapeString(summary)
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+%s
+
+Best regards,
+Tech Bant Community
+`, outcome)
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: "Update on your report",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendModerationNoticeEmail tells an author what moderators did about their
+// reported post or comment
+func (s *EmailService) SendModerationNoticeEmail(ctx context.Context, email, targetType string, actions []string, summary string) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	var items strings.Builder
+	for _, action := range actions {
+		switch action {
+		case models.ReportActionRemove:
+			fmt.Fprintf(&items, "<li>Your %s was removed.</li>\n", targetType)
+		case models.ReportActionWarn:
+			items.WriteString("<li>This is a formal warning. Further violations may lead to a suspension.</li>\n")
+		case models.ReportActionSuspend:
+			items.WriteString("<li>Your account has been suspended.</li>\n")
+		}
+	}
+	reason := ""
+	if summary != "" {
+		reason = "<p>" + html.EscapeString(summary) + "</p>\n"
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+Our moderators reviewed a %s of yours that was reported and found that it breaks the community guidelines.
+
+%s<ul>
+%s</ul>
+
+Best regards,
+Tech Bant Community
+`, targetType, reason, items.String())
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: "A moderator reviewed your content",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendSuspensionEmail tells a user their account was suspended, why, and
+// until when
+func (s *EmailService) SendSuspensionEmail(ctx context.Context, email string, suspension *models.Suspension) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	scope := "You can't sign in to use the community while it lasts, but you can still download or delete your data."
+	if suspension.Scope == models.SuspensionScopeReadOnly {
+		scope = "You can still sign in and read, but you can't post, comment, like, follow or send messages while it lasts."
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+%s
+
+<p>Reason: %s</p>
+
+%s
+
+Best regards,
+Tech Bant Community
+`, SuspensionNotice(suspension), html.EscapeString(suspension.Reason), scope)
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: "Your account has been suspended",
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendAppealEmail tells a user their appeal was received or, once decided,
+// whether it was accepted
+func (s *EmailService) SendAppealEmail(ctx context.Context, email string, appeal *models.Appeal) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	action := "the suspension of your account"
+	if appeal.Action.Type == models.AppealActionReport {
+		action = "the moderation of your " + appeal.Action.TargetType
+	}
+
+	var subject, outcome string
+	switch appeal.Status {
+	case models.AppealStatusAccepted:
+		subject = "Your appeal was accepted"
+		outcome = fmt.Sprintf("A moderator reviewed your appeal of %s and accepted it. The action has been reversed.", action)
+		if appeal.Action.Type == models.AppealActionReport {
+			outcome += " Content that was removed can't be restored, but you're welcome to post it again."
+		}
+	case models.AppealStatusDenied:
+		subject = "Your appeal was denied"
+		outcome = fmt.Sprintf("A moderator reviewed your appeal of %s and decided to keep the action in place.", action)
+	default:
+		subject = "We received your appeal"
+		outcome = fmt.Sprintf("We received your appeal of %s. A moderator will review it and email you the decision.", action)
+	}
+	note := ""
+	if appeal.DecisionNote != "" {
+		note = "<p>" + html.EscapeString(appeal.DecisionNote) + "</p>\n"
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+%s
+
+%s
+Best regards,
+Tech Bant Community
+`, outcome, note)
+
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: subject,
+		Html:    body,
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// SendNotificationEmail sends notifications the user asked to get by email
+// right away. unsubscribeLink stops these emails in one click.
+func (s *EmailService) SendNotificationEmail(ctx context.Context, email string, lines []string, unsubscribeLink string) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	var items strings.Builder
+	for _, line := range lines {
+		fmt.Fprintf(&items, "<li>%s</li>\n", html.EscapeString(line))
+	}
+
+	subject := lines[0]
+	if len(lines) > 1 {
+		subject = fmt.Sprintf("You have %d new notifications", len(lines))
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+<ul>
+%s</ul>
+
+Best regards,
+Tech Bant Community
+
+<small><a href="%s">Unsubscribe from these emails</a></small>
+`, items.String(), unsubscribeLink)
+
+	return s.sendWithUnsubscribe(ctx, email, subject, body, unsubscribeLink)
+}
+
+// SendDigestEmail sends a daily or weekly digest of unread notifications and
+// top posts from people the user follows
+func (s *EmailService) SendDigestEmail(ctx context.Context, email, frequency string, lines []string, posts []*models.DigestPost, unsubscribeLink string) error {
+	if s.client == nil || s.cfg.ResendAPIKey == "" {
+		return errors.New("email service not configured")
+	}
+
+	var content strings.Builder
+	if len(lines) > 0 {
+		content.WriteString("<h3>What you missed</h3>\n<ul>\n")
+		for _, line := range lines {
+			fmt.Fprintf(&content, "<li>%s</li>\n", html.EscapeString(line))
+		}
+		content.WriteString("</ul>\n")
+	}
+	if len(posts) > 0 {
+		content.WriteString("<h3>Top posts from people you follow</h3>\n<ul>\n")
+		for _, post := range posts {
+			fmt.Fprintf(&content, "<li><a href=\"%s\">%s</a> by %s (%d likes, %d comments)</li>\n",
+				post.URL, html.EscapeString(post.Title), html.EscapeString(post.AuthorName), post.Likes, post.Comments)
+		}
+		content.WriteString("</ul>\n")
+	}
+
+	period := "day"
+	if frequency == models.ChannelWeekly {
+		period = "week"
+	}
+
+	body := fmt.Sprintf(`
+Hello,
+
+Here's your Tech Bant Community digest for the past %s.
+
+%s
+Best regards,
+Tech Bant Community
+
+<small><a href="%s">Unsubscribe from this digest</a></small>
+`, period, content.String(), unsubscribeLink)
+
+	return s.sendWithUnsubscribe(ctx, email, fmt.Sprintf("Your %s digest", frequency), body, unsubscribeLink)
+}
+
+// sendWithUnsubscribe sends a bulk email with RFC 8058 one-click unsubscribe headers
+func (s *EmailService) sendWithUnsubscribe(ctx context.Context, email, subject, body, unsubscribeLink string) error {
+	params := &resend.SendEmailRequest{
+		From:    s.cfg.ResendFrom,
+		To:      []string{email},
+		Subject: subject,
+		Html:    body,
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeLink + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+
+	_, err := s.client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,610 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrExportInProgress  = errors.New("an export is already in progress")
+	ErrExportTooSoon     = errors.New("an export was already created in the last 24 hours")
+	ErrExportUnavailable = errors.New("export link is invalid or has expired")
+)
+
+// maxExportAttempts is how many times a crashed/stuck export is retried
+const maxExportAttempts = 3
+
+// ExportService builds self-service data export (takeout) archives
+type ExportService struct {
+	db           *sql.DB
+	cfg          *config.Config
+	emailService *EmailService
+}
+
+// NewExportService creates a new ExportService instance
+func NewExportService(db *sql.DB, cfg *config.Config, emailService *EmailService) *ExportService {
+	return &ExportService{db: db, cfg: cfg, emailService: emailService}
+}
+
+// RequestExport queues a new export for the user. The archive is built by
+// the background job started with StartExportJob. A unique index allows one
+// pending or processing export per user, so concurrent requests can't both
+// queue one.
+func (s *ExportService) RequestExport(ctx context.Context, userID string) (*models.DataExport, error) {
+	var inProgress, recent bool
+	err := database.QueryRowWithContext(ctx, `
+		SELECT
+			EXISTS(SELECT 1 FROM public.data_exports WHERE user_id = $1 AND status IN ('pending', 'processing')),
+			EXISTS(SELECT 1 FROM public.data_exports WHERE user_id = $1 AND status = 'ready' AND requested_at > $2)
+	`, userID, time.Now().UTC().Add(-constants.DataExportCooldown)).Scan(&inProgress, &recent)
+	if err != nil {
+		return nil, err
+	}
+	if inProgress {
+		return nil, ErrExportInProgress
+	}
+	if recent {
+		return nil, ErrExportTooSoon
+	}
+
+	export := &models.DataExport{
+		ID:          uuid.New().String(),
+		Status:      "pending",
+		RequestedAt: time.Now().UTC(),
+	}
+	_, err = database.ExecWithContext(ctx, `
+		INSERT INTO public.data_exports (id, user_id, status, requested_at)
+		VALUES ($1, $2, $3, $4)
+	`, export.ID, userID, export.Status, export.RequestedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrExportInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// GetExports lists the user's recent exports, newest first
+func (s *ExportService) GetExports(ctx context.Context, userID string) ([]*models.DataExport, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT id, status, size_bytes, error, requested_at, completed_at, expires_at
+		FROM public.data_exports
+		WHERE user_id = $1
+		ORDER BY requested_at DESC
+		LIMIT 10
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*models.DataExport{}
+	for rows.Next() {
+		var export models.DataExport
+		var size sql.NullInt64
+		var exportErr sql.NullString
+		var completedAt, expiresAt sql.NullTime
+		if err := rows.Scan(&export.ID, &export.Status, &size, &exportErr, &export.RequestedAt, &completedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		if size.Valid {
+			export.SizeBytes = size.Int64
+		}
+		if export.Status == "failed" && exportErr.Valid {
+			export.Error = "Export failed, please try again"
+		}
+		if completedAt.Valid {
+			export.CompletedAt = &completedAt.Time
+		}
+		if expiresAt.Valid {
+			export.ExpiresAt = &expiresAt.Time
+		}
+		exports = append(exports, &export)
+	}
+
+	return exports, rows.Err()
+}
+
+// DownloadLink returns the signed link emailed to the user for a finished export
+func (s *ExportService) DownloadLink(exportID string, expiresAt time.Time) string {
+	sig := utils.SignExpiring(s.cfg.SigningSecret, expiresAt, "export", exportID)
+	return fmt.Sprintf("%s/api/v1/exports/%s/download?expires=%d&sig=%s",
+		s.cfg.APIBaseURL, url.PathEscape(exportID), expiresAt.Unix(), sig)
+}
+
+// ResolveDownload verifies a signed download link and returns a short-lived
+// storage URL for the archive
+func (s *ExportService) ResolveDownload(ctx context.Context, exportID, expires, sig string) (string, error) {
+	if !utils.VerifyExpiring(s.cfg.SigningSecret, sig, expires, "export", exportID) {
+		return "", ErrExportUnavailable
+	}
+
+	var status string
+	var objectPath sql.NullString
+	var expiresAt sql.NullTime
+	err := database.QueryRowWithContext(ctx,
+		"SELECT status, object_path, expires_at FROM public.data_exports WHERE id = $1", exportID,
+	).Scan(&status, &objectPath, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", ErrExportUnavailable
+	}
+	if err != nil {
+		return "", err
+	}
+	if status != "ready" || !objectPath.Valid || (expiresAt.Valid && time.Now().After(expiresAt.Time)) {
+		return "", ErrExportUnavailable
+	}
+
+	return storageSignedURL(ctx, s.cfg, objectPath.String, time.Minute)
+}
+
+// ProcessPending builds the oldest queued export, if any. Exports stuck in
+// processing (e.g. after a crash) are retried a limited number of times and
+// then failed. Returns false when there was nothing to do.
+func (s *ExportService) ProcessPending(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	stale := now.Add(-time.Hour)
+	_, err := database.ExecWithContext(ctx, `
+		UPDATE public.data_exports
+		SET status = 'failed', error = 'interrupted too many times', completed_at = $1
+		WHERE status = 'processing' AND started_at < $2 AND attempts >= $3
+	`, now, stale, maxExportAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	var exportID, userID string
+	err = database.QueryRowWithContext(ctx, `
+		UPDATE public.data_exports
+		SET status = 'processing', started_at = $1, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM public.data_exports
+			WHERE status = 'pending'
+				OR (status = 'processing' AND started_at < $2 AND attempts < $3)
+			ORDER BY requested_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, user_id
+	`, now, stale, maxExportAttempts).Scan(&exportID, &userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.buildExport(ctx, exportID, userID); err != nil {
+		log.Printf("Data export %s failed: %v", exportID, err)
+		_, _ = database.ExecWithContext(ctx,
+			"UPDATE public.data_exports SET status = 'failed', error = $1, completed_at = $2 WHERE id = $3",
+			err.Error(), time.Now().UTC(), exportID)
+	}
+	return true, nil
+}
+
+// buildExport writes the archive to a temp file, uploads it and emails the link
+func (s *ExportService) buildExport(ctx context.Context, exportID, userID string) error {
+	user, err := NewUserService(s.db).GetUser(ctx, userID)
+	if err != nil {
+		return utils.WrapError(err, "failed to get user")
+	}
+
+	tmp, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	if err := s.writeArchive(ctx, zw, user); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	objectPath := fmt.Sprintf("exports/%s/%s.zip", userID, exportID)
+	if err := storageUpload(ctx, s.cfg, objectPath, "application/zip", tmp); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(constants.DataExportLinkExpiry)
+	_, err = database.ExecWithContext(ctx, `
+		UPDATE public.data_exports
+		SET status = 'ready', object_path = $1, size_bytes = $2, completed_at = $3, expires_at = $4, error = NULL
+		WHERE id = $5
+	`, objectPath, size, now, expiresAt, exportID)
+	if err != nil {
+		return err
+	}
+
+	if s.emailService != nil {
+		if err := s.emailService.SendDataExportEmail(ctx, user.Email, s.DownloadLink(exportID, expiresAt), expiresAt); err != nil {
+			log.Printf("Failed to send data export email for %s: %v", exportID, err)
+		}
+	}
+	return nil
+}
+
+// writeArchive writes every section of the export into the ZIP
+func (s *ExportService) writeArchive(ctx context.Context, zw *zip.Writer, user *models.User) error {
+	if err := writeZipJSON(zw, "profile.json", user); err != nil {
+		return err
+	}
+	if err := s.writePosts(ctx, zw, user.ID); err != nil {
+		return err
+	}
+
+	sections := []struct {
+		name  string
+		query string
+	}{
+		{"comments.json", `
+			SELECT id, post_id, parent_id, content, created_at, updated_at
+			FROM public.comments WHERE author_id = $1 ORDER BY created_at`},
+		{"likes.json", `
+			SELECT post_id, comment_id, created_at
+			FROM public.likes WHERE user_id = $1 ORDER BY created_at`},
+		{"bookmarks.json", `
+			SELECT b.post_id, p.title, b.created_at
+			FROM public.bookmarks b LEFT JOIN public.posts p ON p.id = b.post_id
+			WHERE b.user_id = $1 ORDER BY b.created_at`},
+		{"following.json", `
+			SELECT u.id, u.name, u.handle, f.created_at AS followed_at
+			FROM public.follows f JOIN public.users u ON u.id = f.following_id
+			WHERE f.follower_id = $1 ORDER BY f.created_at`},
+		{"followers.json", `
+			SELECT u.id, u.name, u.handle, f.created_at AS followed_at
+			FROM public.follows f JOIN public.users u ON u.id = f.follower_id
+			WHERE f.following_id = $1 ORDER BY f.created_at`},
+		{"messages.json", `
+			SELECT id, conversation_id, content, created_at, edited_at
+			FROM public.messages WHERE sender_id = $1 AND deleted_at IS NULL ORDER BY created_at`},
+		{"suspensions.json", `
+			SELECT scope, reason, expires_at, created_at, lifted_at, lift_reason
+			FROM public.user_suspensions WHERE user_id = $1 ORDER BY created_at`},
+		{"held_for_review.json", `
+			SELECT kind, target_id, payload, status, created_at, reviewed_at
+			FROM public.moderation_queue WHERE user_id = $1 ORDER BY created_at`},
+		{"security_events.json", `
+			SELECT event_type, ip_address, user_agent, success, details, created_at
+			FROM public.security_events WHERE user_id = $1 ORDER BY created_at`},
+	}
+	for _, section := range sections {
+		records, err := queryRecords(ctx, section.query, user.ID)
+		if err != nil {
+			return utils.WrapError(err, "failed to export "+section.name)
+		}
+		if err := writeZipJSON(zw, section.name, records); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeMedia(ctx, zw, user.ID); err != nil {
+		return err
+	}
+
+	readme, err := zw.Create("README.txt")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(readme, `Tech Bant Community data export
+Generated: %s
+
+profile.json          Your profile
+posts/                Your posts as Markdown (.md) and HTML (.html); posts.json indexes them
+comments.json         Comments you wrote
+likes.json            Posts and comments you liked
+bookmarks.json        Posts you bookmarked
+following.json        People you follow
+followers.json        People who follow you
+messages.json         Direct messages you sent
+suspensions.json      Suspensions of your account
+held_for_review.json  Posts, comments and profile changes held for moderator review
+security_events.json  Sign-in and account security events
+media/                Files you uploaded; media.json lists them
+`, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// writePosts writes each post as Markdown and HTML plus a posts.json index
+func (s *ExportService) writePosts(ctx context.Context, zw *zip.Writer, userID string) error {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT id, title, content, html_content, category, tags, likes, comments, views, created_at, updated_at
+		FROM public.posts WHERE author_id = $1 ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return utils.WrapError(err, "failed to export posts")
+	}
+	defer rows.Close()
+
+	type exportedPost struct {
+		ID        string    `json:"id"`
+		Title     string    `json:"title"`
+		Category  string    `json:"category"`
+		Tags      []string  `json:"tags"`
+		Likes     int       `json:"likes"`
+		Comments  int       `json:"comments"`
+		Views     int       `json:"views"`
+		Markdown  string    `json:"markdown"`
+		HTML      string    `json:"html"`
+		CreatedAt time.Time `json:"createdAt"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+
+	index := []exportedPost{}
+	for rows.Next() {
+		var p exportedPost
+		var content string
+		var htmlContent sql.NullString
+		var tags pq.StringArray
+		if err := rows.Scan(&p.ID, &p.Title, &content, &htmlContent, &p.Category, &tags,
+			&p.Likes, &p.Comments, &p.Views, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return err
+		}
+		p.Tags = tags
+		base := fmt.Sprintf("posts/%s-%s", p.CreatedAt.Format("2006-01-02"), p.ID)
+		p.Markdown = base + ".md"
+		p.HTML = base + ".html"
+
+		md, err := zw.Create(p.Markdown)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(md, "---\ntitle: %q\ncategory: %s\ntags: [%s]\ncreated: %s\n---\n\n%s\n",
+			p.Title, p.Category, strings.Join(p.Tags, ", "), p.CreatedAt.Format(time.RFC3339), content)
+
+		body := content
+		if htmlContent.Valid && htmlContent.String != "" {
+			body = htmlContent.String
+		}
+		page, err := zw.Create(p.HTML)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(page, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n<h1>%s</h1>\n%s\n</body></html>\n",
+			html.EscapeString(p.Title), html.EscapeString(p.Title), utils.SanitizeHTML(body))
+
+		index = append(index, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return writeZipJSON(zw, "posts/posts.json", index)
+}
+
+// writeMedia copies the user's uploads from storage into media/, up to
+// DataExportMaxMediaSize in total; the rest are listed but not included
+func (s *ExportService) writeMedia(ctx context.Context, zw *zip.Writer, userID string) error {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT id, post_id, url, type, size, created_at, moderation_status = 'pending'
+		FROM public.media WHERE user_id = $1 ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return utils.WrapError(err, "failed to export media")
+	}
+
+	type exportedMedia struct {
+		ID        string    `json:"id"`
+		PostID    string    `json:"postId,omitempty"`
+		Type      string    `json:"type"`
+		URL       string    `json:"url"`
+		Size      int64     `json:"size"`
+		File      string    `json:"file,omitempty"`
+		Note      string    `json:"note,omitempty"`
+		CreatedAt time.Time `json:"createdAt"`
+		pending   bool
+	}
+
+	var manifest []exportedMedia
+	for rows.Next() {
+		var m exportedMedia
+		var postID sql.NullString
+		var size sql.NullInt64
+		if err := rows.Scan(&m.ID, &postID, &m.URL, &m.Type, &size, &m.CreatedAt, &m.pending); err != nil {
+			rows.Close()
+			return err
+		}
+		m.PostID = postID.String
+		m.Size = size.Int64
+		manifest = append(manifest, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var total int64
+	for i := range manifest {
+		m := &manifest[i]
+		objectPath := storagePathFromURL(s.cfg, m.URL)
+		if objectPath == "" {
+			m.Note = "not stored in this bucket"
+			continue
+		}
+		if total+m.Size > constants.DataExportMaxMediaSize {
+			m.Note = "skipped: archive media size limit reached, use the URL to download"
+			continue
+		}
+
+		body, err := storageDownload(ctx, s.cfg, objectPath)
+		if err != nil {
+			m.Note = "could not be fetched from storage"
+			continue
+		}
+		m.File = "media/" + m.ID + "-" + path.Base(objectPath)
+		w, err := zw.Create(m.File)
+		if err != nil {
+			body.Close()
+			return err
+		}
+		n, err := io.Copy(w, io.LimitReader(body, constants.DataExportMaxMediaSize-total))
+		body.Close()
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+
+	// Uploads awaiting review keep their URL private until approved
+	for i := range manifest {
+		if manifest[i].pending {
+			manifest[i].URL = ""
+			manifest[i].Note = "awaiting review"
+		}
+	}
+
+	if manifest == nil {
+		manifest = []exportedMedia{}
+	}
+	return writeZipJSON(zw, "media/media.json", manifest)
+}
+
+// ExpireExports deletes archives whose download window has passed
+func (s *ExportService) ExpireExports(ctx context.Context) error {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT id, object_path FROM public.data_exports
+		WHERE status = 'ready' AND expires_at < $1
+	`, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	var ids, paths []string
+	for rows.Next() {
+		var id string
+		var objectPath sql.NullString
+		if err := rows.Scan(&id, &objectPath); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		if objectPath.Valid {
+			paths = append(paths, objectPath.String)
+		}
+	}
+	rows.Close()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := storageDelete(ctx, s.cfg, paths...); err != nil {
+		return err
+	}
+
+	_, err = database.ExecWithContext(ctx,
+		"UPDATE public.data_exports SET status = 'expired', object_path = NULL WHERE id = ANY($1)", pq.Array(ids))
+	return err
+}
+
+// StartExportJob processes queued exports and expires old archives in the background
+func (s *ExportService) StartExportJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.DataExportPollInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+				for {
+					processed, err := s.ProcessPending(jobCtx)
+					if err != nil {
+						log.Printf("Data export job error: %v", err)
+					}
+					if !processed || err != nil {
+						break
+					}
+				}
+				if err := s.ExpireExports(jobCtx); err != nil {
+					log.Printf("Failed to expire data exports: %v", err)
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// queryRecords runs a query and returns rows as column-name maps for JSON export
+func queryRecords(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := database.QueryWithContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				record[col] = string(b)
+			} else {
+				record[col] = values[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
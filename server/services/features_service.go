@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"strings"
 	"time"
 
 	"tech-bant-community/server/database"
@@ -15,14 +17,21 @@ import (
 // FollowService handles user follow/unfollow operations
 type FollowService struct{}
 
+var (
+	ErrCannotFollowSelf = errors.New("cannot follow yourself")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+)
+
 func NewFollowService() *FollowService {
 	return &FollowService{}
 }
 
-// FollowUser creates a follow relationship
+// FollowUser creates a follow relationship.
+// Following an already-followed user is a no-op and does not touch the counters.
 func (s *FollowService) FollowUser(ctx context.Context, followerID, followingID string) error {
 	if followerID == followingID {
-		return errors.New("cannot follow yourself")
+		return ErrCannotFollowSelf
 	}
 
 	tx, err := database.BeginTx(ctx)
@@ -31,9 +40,26 @@ func (s *FollowService) FollowUser(ctx context.Context, followerID, followingID
 	}
 	defer tx.Rollback()
 
+	var isActive bool
+	err = tx.QueryRowContext(ctx, "SELECT is_active FROM public.users WHERE id = $1", followingID).Scan(&isActive)
+	if err == sql.ErrNoRows || (err == nil && !isActive) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var blocked bool
+	if err := tx.QueryRowContext(ctx, blockedEitherQuery, followerID, followingID).Scan(&blocked); err != nil {
+		return err
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+
 	// Insert follow
 	followID := uuid.New()
-	_, err = tx.ExecContext(ctx, `
+	result, err := tx.ExecContext(ctx, `
 		INSERT INTO public.follows (id, follower_id, following_id, created_at)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (follower_id, following_id) DO NOTHING
@@ -42,7 +68,13 @@ func (s *FollowService) FollowUser(ctx context.Context, followerID, followingID
 		return err
 	}
 
-	// Increment counts
+	// Only count rows we actually inserted
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return tx.Commit()
+	}
+
 	_, err = tx.ExecContext(ctx, "UPDATE public.users SET following_count = following_count + 1 WHERE id = $1", followerID)
 	if err != nil {
 		return err
@@ -51,11 +83,22 @@ func (s *FollowService) FollowUser(ctx context.Context, followerID, followingID
 	if err != nil {
 		return err
 	}
+	if err := recordReputation(ctx, tx, followingID, ReputationFollowerGained, followerID, followerID, time.Now().UTC()); err != nil {
+		return err
+	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshBadgesAsync(followingID)
+	notifyAsync(notificationEvent{
+		recipientID: followingID, actorID: followerID, kind: models.NotificationFollow, groupKey: followGroup,
+	})
+	return nil
 }
 
-// UnfollowUser removes a follow relationship
+// UnfollowUser removes a follow relationship.
+// Unfollowing a user that is not followed is a no-op.
 func (s *FollowService) UnfollowUser(ctx context.Context, followerID, followingID string) error {
 	tx, err := database.BeginTx(ctx)
 	if err != nil {
@@ -64,16 +107,38 @@ func (s *FollowService) UnfollowUser(ctx context.Context, followerID, followingI
 	defer tx.Rollback()
 
 	// Delete follow
-	_, err = tx.ExecContext(ctx, "DELETE FROM public.follows WHERE follower_id = $1 AND following_id = $2", followerID, followingID)
+	result, err := tx.ExecContext(ctx, "DELETE FROM public.follows WHERE follower_id = $1 AND following_id = $2", followerID, followingID)
 	if err != nil {
 		return err
 	}
 
-	// Decrement counts
-	_, _ = tx.ExecContext(ctx, "UPDATE public.users SET following_count = following_count - 1 WHERE id = $1", followerID)
-	_, _ = tx.ExecContext(ctx, "UPDATE public.users SET followers_count = followers_count - 1 WHERE id = $1", followingID)
+	// Only decrement when a row was actually removed
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return tx.Commit()
+	}
 
-	return tx.Commit()
+	_, err = tx.ExecContext(ctx, "UPDATE public.users SET following_count = GREATEST(following_count - 1, 0) WHERE id = $1", followerID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE public.users SET followers_count = GREATEST(followers_count - 1, 0) WHERE id = $1", followingID)
+	if err != nil {
+		return err
+	}
+	err = revokeReputation(ctx, tx, "user_id = $1 AND event_type = $2 AND actor_id = $3",
+		followingID, ReputationFollowerGained, followerID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	refreshBadgesAsync(followingID)
+	retractNotificationAsync(followingID, followerID, followGroup)
+	return nil
 }
 
 // IsFollowing checks if user is following another user
@@ -81,138 +146,192 @@ func (s *FollowService) IsFollowing(ctx context.Context, followerID, followingID
 	query := "SELECT id FROM public.follows WHERE follower_id = $1 AND following_id = $2"
 	var id string
 	err := database.QueryRowWithContext(ctx, query, followerID, followingID).Scan(&id)
-	return err == nil, nil
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// ReportService handles content reporting
-type ReportService struct{}
+// GetRelationship returns whether viewerID follows targetID and whether targetID follows viewerID
+func (s *FollowService) GetRelationship(ctx context.Context, viewerID, targetID string) (isFollowing, followsYou bool, err error) {
+	query := `
+		SELECT
+			EXISTS(SELECT 1 FROM public.follows WHERE follower_id = $1 AND following_id = $2),
+			EXISTS(SELECT 1 FROM public.follows WHERE follower_id = $2 AND following_id = $1)
+	`
+	err = database.QueryRowWithContext(ctx, query, viewerID, targetID).Scan(&isFollowing, &followsYou)
+	return isFollowing, followsYou, err
+}
 
-func NewReportService() *ReportService {
-	return &ReportService{}
+// GetFollowers lists users following userID, newest first
+func (s *FollowService) GetFollowers(ctx context.Context, viewerID, userID, cursor string, limit int) (*models.FollowListResponse, error) {
+	return s.listFollows(ctx, `
+		SELECT f.id, f.created_at, `+followUserColumns+`
+		FROM public.follows f
+		JOIN public.users u ON u.id = f.follower_id
+		WHERE f.following_id = $1 AND u.is_active = true AND NOT `+hiddenByShadowBan("u.id", "$5")+`
+			AND ($2::timestamptz IS NULL OR (f.created_at, f.id) < ($2, $3::uuid))
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $4
+	`, viewerID, userID, cursor, limit)
 }
 
-// ReportPost creates a report for a post
-func (s *ReportService) ReportPost(ctx context.Context, reporterID, postID, reason string) error {
-	reportID := uuid.New()
-	query := `
-		INSERT INTO public.reports (id, reporter_id, post_id, reason, status, created_at)
-		VALUES ($1, $2, $3, $4, 'pending', $5)
-	`
-	_, err := database.ExecWithContext(ctx, query, reportID, reporterID, postID, reason, time.Now().UTC())
-	return err
+// GetFollowing lists users that userID follows, newest first
+func (s *FollowService) GetFollowing(ctx context.Context, viewerID, userID, cursor string, limit int) (*models.FollowListResponse, error) {
+	return s.listFollows(ctx, `
+		SELECT f.id, f.created_at, `+followUserColumns+`
+		FROM public.follows f
+		JOIN public.users u ON u.id = f.following_id
+		WHERE f.follower_id = $1 AND u.is_active = true AND NOT `+hiddenByShadowBan("u.id", "$5")+`
+			AND ($2::timestamptz IS NULL OR (f.created_at, f.id) < ($2, $3::uuid))
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $4
+	`, viewerID, userID, cursor, limit)
 }
 
-// ReportComment creates a report for a comment
-func (s *ReportService) ReportComment(ctx context.Context, reporterID, commentID, reason string) error {
-	reportID := uuid.New()
-	query := `
-		INSERT INTO public.reports (id, reporter_id, comment_id, reason, status, created_at)
-		VALUES ($1, $2, $3, $4, 'pending', $5)
-	`
-	_, err := database.ExecWithContext(ctx, query, reportID, reporterID, commentID, reason, time.Now().UTC())
-	return err
+// GetMutuals lists users that userID follows and who follow userID back
+func (s *FollowService) GetMutuals(ctx context.Context, viewerID, userID, cursor string, limit int) (*models.FollowListResponse, error) {
+	return s.listFollows(ctx, `
+		SELECT f.id, f.created_at, `+followUserColumns+`
+		FROM public.follows f
+		JOIN public.follows back ON back.follower_id = f.following_id AND back.following_id = f.follower_id
+		JOIN public.users u ON u.id = f.following_id
+		WHERE f.follower_id = $1 AND u.is_active = true AND NOT `+hiddenByShadowBan("u.id", "$5")+`
+			AND ($2::timestamptz IS NULL OR (f.created_at, f.id) < ($2, $3::uuid))
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $4
+	`, viewerID, userID, cursor, limit)
 }
 
-// GetReports gets all reports (admin only)
-func (s *ReportService) GetReports(ctx context.Context, limit, offset int, status string) ([]*models.Report, error) {
+const followUserColumns = "u.id, u.name, u.handle, u.avatar, u.bio, u.is_verified, u.followers_count, u.following_count"
+
+// listFollows runs a keyset-paginated follow query. The cursor encodes the
+// (created_at, id) of the last follow row returned on the previous page.
+// Shadow-banned users are only listed for themselves.
+func (s *FollowService) listFollows(ctx context.Context, query, viewerID, userID, cursor string, limit int) (*models.FollowListResponse, error) {
 	if limit <= 0 {
 		limit = 20
 	}
 	if limit > 100 {
 		limit = 100
 	}
-	if offset < 0 {
-		offset = 0
-	}
 
-	query := `
-		SELECT id, reporter_id, post_id, comment_id, reason, status, created_at, reviewed_at, reviewed_by
-		FROM public.reports
-		WHERE ($1 = '' OR status = $1)
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+	var afterTime sql.NullTime
+	var afterID sql.NullString
+	if cursor != "" {
+		t, id, err := decodeFollowCursor(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		afterTime = sql.NullTime{Time: t, Valid: true}
+		afterID = sql.NullString{String: id, Valid: true}
+	}
 
-	rows, err := database.QueryWithContext(ctx, query, status, limit, offset)
+	// Fetch one extra row to know whether there is a next page
+	rows, err := database.QueryWithContext(ctx, query, userID, afterTime, afterID, limit+1, viewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var reports []*models.Report
+	resp := &models.FollowListResponse{Users: []*models.User{}}
+	var lastTime time.Time
+	var lastID string
 	for rows.Next() {
-		var report models.Report
-		var postID, commentID, reviewedBy sql.NullString
-		var reviewedAt sql.NullTime
-
-		err := rows.Scan(
-			&report.ID, &report.ReporterID, &postID, &commentID, &report.Reason, &report.Status,
-			&report.CreatedAt, &reviewedAt, &reviewedBy,
-		)
-		if err != nil {
-			continue
+		var followID string
+		var followedAt time.Time
+		var user models.User
+		var handle, avatar, bio sql.NullString
+		if err := rows.Scan(
+			&followID, &followedAt,
+			&user.ID, &user.Name, &handle, &avatar, &bio, &user.IsVerified, &user.FollowersCount, &user.FollowingCount,
+		); err != nil {
+			return nil, err
 		}
-
-		if postID.Valid {
-			report.PostID = postID.String
+		if len(resp.Users) == limit {
+			resp.NextCursor = encodeFollowCursor(lastTime, lastID)
+			break
 		}
-		if commentID.Valid {
-			report.CommentID = commentID.String
+		if handle.Valid {
+			user.Handle = handle.String
 		}
-		if reviewedAt.Valid {
-			report.ReviewedAt = &reviewedAt.Time
+		if avatar.Valid {
+			user.Avatar = avatar.String
 		}
-		if reviewedBy.Valid {
-			report.ReviewedBy = reviewedBy.String
+		if bio.Valid {
+			user.Bio = bio.String
 		}
-
-		reports = append(reports, &report)
+		user.IsActive = true
+		resp.Users = append(resp.Users, &user)
+		lastTime, lastID = followedAt, followID
 	}
 
-	return reports, nil
+	return resp, rows.Err()
 }
 
-// ResolveReport resolves a report (admin only)
-func (s *ReportService) ResolveReport(ctx context.Context, reportID string, status string, reviewedBy string) error {
-	if status != "resolved" && status != "rejected" {
-		return errors.New("invalid status, must be 'resolved' or 'rejected'")
-	}
+func encodeFollowCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
 
-	query := `
-		UPDATE public.reports
-		SET status = $1, reviewed_at = $2, reviewed_by = $3
-		WHERE id = $4
-	`
-	_, err := database.ExecWithContext(ctx, query, status, time.Now().UTC(), reviewedBy, reportID)
-	return err
+func decodeFollowCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		return time.Time{}, "", err
+	}
+	return t, parts[1], nil
 }
 
-// BanService handles user banning (admin)
+// BanService handles user verification and promotion (admin). Bans are
+// suspensions, see SuspensionService.
 type BanService struct{}
 
 func NewBanService() *BanService {
 	return &BanService{}
 }
 
-// BanUser bans a user
-func (s *BanService) BanUser(ctx context.Context, userID string) error {
-	query := "UPDATE public.users SET is_active = false, updated_at = $1 WHERE id = $2"
-	_, err := database.ExecWithContext(ctx, query, time.Now().UTC(), userID)
-	return err
-}
+// banUserTx deactivates an active user and queues the user.banned webhook
+func banUserTx(ctx context.Context, tx *sql.Tx, userID string, now time.Time) error {
+	var user models.User
+	var handle, avatar sql.NullString
+	err := tx.QueryRowContext(ctx, `
+		UPDATE public.users SET is_active = false, updated_at = $1
+		WHERE id = $2 AND is_active = true
+		RETURNING id, name, handle, avatar
+	`, now, userID).Scan(&user.ID, &user.Name, &handle, &avatar)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	user.Handle = handle.String
+	user.Avatar = avatar.String
 
-// UnbanUser unbans a user
-func (s *BanService) UnbanUser(ctx context.Context, userID string) error {
-	query := "UPDATE public.users SET is_active = true, updated_at = $1 WHERE id = $2"
-	_, err := database.ExecWithContext(ctx, query, time.Now().UTC(), userID)
-	return err
+	return enqueueWebhookEvent(ctx, tx, models.WebhookEventUserBanned, map[string]interface{}{
+		"user":     webhookUser(&user),
+		"bannedAt": now,
+	})
 }
 
 // VerifyUser verifies a user (admin)
 func (s *BanService) VerifyUser(ctx context.Context, userID string) error {
-	query := "UPDATE public.users SET is_verified = true, updated_at = $1 WHERE id = $2"
-	_, err := database.ExecWithContext(ctx, query, time.Now().UTC(), userID)
-	return err
+	return updateUserAdminState(ctx, userID, models.AuditActionUserVerify, func(state *userAdminState) {
+		state.IsVerified = true
+	})
 }
 
 // PromoteToAdmin promotes a user to admin (super admin only)
@@ -220,7 +339,64 @@ func (s *BanService) PromoteToAdmin(ctx context.Context, userID, role string) er
 	if role != models.RoleAdmin && role != models.RoleSuperAdmin {
 		return errors.New("invalid role")
 	}
-	query := "UPDATE public.users SET role = $1, is_admin = true, updated_at = $2 WHERE id = $3"
-	_, err := database.ExecWithContext(ctx, query, role, time.Now().UTC(), userID)
-	return err
+	return updateUserAdminState(ctx, userID, models.AuditActionUserPromote, func(state *userAdminState) {
+		state.Role = role
+		state.IsAdmin = true
+	})
+}
+
+// userAdminState is the part of a user that admins change, as recorded in
+// the audit log
+type userAdminState struct {
+	IsActive   bool   `json:"isActive"`
+	IsVerified bool   `json:"isVerified"`
+	IsAdmin    bool   `json:"isAdmin"`
+	Role       string `json:"role"`
+}
+
+// lockUserAdminState reads a user's admin-controlled state and locks the
+// row for the rest of tx
+func lockUserAdminState(ctx context.Context, tx *sql.Tx, userID string) (userAdminState, error) {
+	var state userAdminState
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(is_active, true), COALESCE(is_verified, false), COALESCE(is_admin, false), COALESCE(role, 'user')
+		FROM public.users
+		WHERE id::text = $1
+		FOR UPDATE
+	`, userID).Scan(&state.IsActive, &state.IsVerified, &state.IsAdmin, &state.Role)
+	if err == sql.ErrNoRows {
+		return state, ErrUserNotFound
+	}
+	return state, err
+}
+
+// updateUserAdminState applies change to a user's admin-controlled state
+// and records action in the audit log
+func updateUserAdminState(ctx context.Context, userID, action string, change func(*userAdminState)) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockUserAdminState(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	after := before
+	change(&after)
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.users
+		SET is_active = $2, is_verified = $3, is_admin = $4, role = $5, updated_at = $6
+		WHERE id::text = $1
+	`, userID, after.IsActive, after.IsVerified, after.IsAdmin, after.Role, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, action, "user", userID, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
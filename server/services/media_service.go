@@ -26,7 +26,10 @@ func NewMediaService(db *sql.DB) *MediaService {
 	return &MediaService{db: db}
 }
 
-// UploadMedia uploads a file to Supabase Storage and creates a media record
+// UploadMedia uploads a file to Supabase Storage and creates a media record.
+// New accounts' uploads are queued for review and can't be attached until
+// approved; their Status is "pending" and their URL is withheld until then.
+// The object path holds a random UUID, so the file can't be reached without it.
 func (s *MediaService) UploadMedia(ctx context.Context, userID string, file io.Reader, filename string, size int64, detectedMIME string, cfg *config.Config) (*models.MediaAttachment, error) {
 	// Determine media type
 	var mediaType string
@@ -42,6 +45,15 @@ func (s *MediaService) UploadMedia(ctx context.Context, userID string, file io.R
 		mediaType = "image" // default
 	}
 
+	level, _, err := userTrustLevel(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	moderationStatus := "approved"
+	if level == models.TrustLevelNew {
+		moderationStatus = "pending"
+	}
+
 	// Generate unique filename
 	mediaID := uuid.New()
 	objectPath := fmt.Sprintf("%s/%s", mediaID.String(), filename)
@@ -81,13 +93,13 @@ func (s *MediaService) UploadMedia(ctx context.Context, userID string, file io.R
 
 	// Create media record in PostgreSQL
 	query := `
-		INSERT INTO public.media (id, user_id, url, type, size, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO public.media (id, user_id, url, type, size, created_at, moderation_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, user_id, post_id, url, type, size, created_at
 	`
 
 	row := database.QueryRowWithContext(ctx, query,
-		mediaID, userID, publicURL, mediaType, size, time.Now().UTC(),
+		mediaID, userID, publicURL, mediaType, size, time.Now().UTC(), moderationStatus,
 	)
 
 	var media models.MediaAttachment
@@ -108,9 +120,32 @@ func (s *MediaService) UploadMedia(ctx context.Context, userID string, file io.R
 
 	media.Name = filename
 
+	if moderationStatus == "pending" {
+		media.Status = moderationStatus
+		err = queueForReview(ctx, userID, models.QueueKindMedia, models.QueueSourceTrust, media.ID, &media,
+			"New account: uploads are reviewed", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to queue media for review: %w", err)
+		}
+		media.URL = ""
+	}
+
 	return &media, nil
 }
 
+// approveMedia makes an upload held for review attachable
+func approveMedia(ctx context.Context, mediaID string) error {
+	result, err := database.ExecWithContext(ctx,
+		"UPDATE public.media SET moderation_status = 'approved' WHERE id::text = $1", mediaID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // GetMedia gets media by ID
 func (s *MediaService) GetMedia(ctx context.Context, mediaID string) (*models.MediaAttachment, string, error) {
 	query := "SELECT id, user_id, post_id, url, type, size, created_at FROM public.media WHERE id = $1"
@@ -184,7 +219,7 @@ func (s *MediaService) GetUserMedia(ctx context.Context, userID string) ([]*mode
 	}
 
 	query := `
-		SELECT id, user_id, post_id, url, type, size, created_at
+		SELECT id, user_id, post_id, url, type, size, created_at, moderation_status
 		FROM public.media
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -203,11 +238,16 @@ func (s *MediaService) GetUserMedia(ctx context.Context, userID string) ([]*mode
 		var scannedUserID string
 		var postID *string
 		var createdAt time.Time
+		var moderationStatus string
 
-		err := rows.Scan(&media.ID, &scannedUserID, &postID, &media.URL, &media.Type, &media.Size, &createdAt)
+		err := rows.Scan(&media.ID, &scannedUserID, &postID, &media.URL, &media.Type, &media.Size, &createdAt, &moderationStatus)
 		if err != nil {
 			continue
 		}
+		if moderationStatus == "pending" {
+			media.Status = moderationStatus
+			media.URL = ""
+		}
 
 		mediaList = append(mediaList, &media)
 	}
@@ -0,0 +1,781 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrConversationNotFound     = errors.New("conversation not found")
+	ErrMessageNotFound          = errors.New("message not found")
+	ErrCannotMessage            = errors.New("you can't message this user")
+	ErrNotMessageSender         = errors.New("only the sender can change this message")
+	ErrInvalidConversation      = fmt.Errorf("a conversation needs 1 to %d other participants", constants.MaxGroupParticipants-1)
+	ErrInvalidConversationTitle = fmt.Errorf("conversation titles are at most %d characters", constants.MaxConversationTitle)
+	ErrInvalidMessage           = fmt.Errorf("a message needs text or an attachment, and at most %d characters", constants.MaxMessageLength)
+	ErrInvalidMessageMedia      = fmt.Errorf("attachments must be your own unused uploads, at most %d per message", constants.MaxMediaPerMessage)
+)
+
+// MessageService handles direct messages. A pair of users shares a single
+// 1:1 conversation; groups are created anew each time.
+type MessageService struct {
+	db *sql.DB
+}
+
+// NewMessageService creates a new MessageService instance
+func NewMessageService(db *sql.DB) *MessageService {
+	return &MessageService{db: db}
+}
+
+// directKey identifies the 1:1 conversation between two users
+func directKey(userA, userB string) string {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return userA + ":" + userB
+}
+
+// conversationQuery selects the conversations of the caller ($1) with a
+// per-conversation unread count. 1:1 conversations with someone the caller
+// has a block with ($2) are left out, as are messages from such users in
+// groups. Both participants of a 1:1 conversation are listed even if one
+// has left it.
+var conversationQuery = `
+	SELECT c.id, c.is_group, COALESCE(c.title, ''), c.created_at, c.last_message_at,
+		(SELECT COUNT(*) FROM public.messages m
+			WHERE m.conversation_id = c.id AND m.deleted_at IS NULL
+				AND m.sender_id IS DISTINCT FROM $1
+				AND m.created_at > COALESCE(p.last_read_at, '-infinity')
+				AND NOT (COALESCE(m.sender_id::text, '') = ANY($2))
+				AND NOT ` + hiddenByShadowBan("m.sender_id", "$1::text") + `) AS unread_count,
+		ARRAY(
+			SELECT cp.user_id::text FROM public.conversation_participants cp
+			WHERE cp.conversation_id = c.id AND (cp.left_at IS NULL OR NOT c.is_group)
+			ORDER BY cp.joined_at, cp.user_id
+		) AS participant_ids
+	FROM public.conversations c
+	JOIN public.conversation_participants p ON p.conversation_id = c.id AND p.user_id = $1 AND p.left_at IS NULL
+	WHERE (c.is_group OR NOT EXISTS (
+		SELECT 1 FROM public.conversation_participants o
+		WHERE o.conversation_id = c.id AND o.user_id::text = ANY($2)
+	))
+`
+
+// listedConversation keeps conversations nobody has written in yet out of
+// everyone's list but their creator's. Messages from shadow-banned senders
+// don't count for anyone but the sender.
+var listedConversation = `
+	AND (c.created_by = $1 OR EXISTS (
+		SELECT 1 FROM public.messages m
+		WHERE m.conversation_id = c.id AND NOT ` + hiddenByShadowBan("m.sender_id", "$1::text") + `
+	))
+`
+
+// messageColumns selects a message (alias m) with the other participants
+// whose last read time is past it
+const messageColumns = `
+	m.id, m.conversation_id, m.sender_id, m.content, m.created_at, m.edited_at, m.deleted_at,
+	ARRAY(
+		SELECT cp.user_id::text FROM public.conversation_participants cp
+		WHERE cp.conversation_id = m.conversation_id AND cp.user_id IS DISTINCT FROM m.sender_id
+			AND cp.last_read_at >= m.created_at
+		ORDER BY cp.user_id
+	)
+`
+
+func scanMessage(row rowScanner) (*models.Message, error) {
+	var message models.Message
+	var senderID sql.NullString
+	var editedAt, deletedAt sql.NullTime
+	readBy := []string{}
+	err := row.Scan(
+		&message.ID, &message.ConversationID, &senderID, &message.Content,
+		&message.CreatedAt, &editedAt, &deletedAt, pq.Array(&readBy),
+	)
+	if err != nil {
+		return nil, err
+	}
+	message.SenderID = senderID.String
+	message.ReadBy = readBy
+	if editedAt.Valid {
+		message.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		message.Content = ""
+		message.Deleted = true
+	}
+	return &message, nil
+}
+
+// CreateConversation starts a conversation between the caller and the given
+// users. With a single other user it returns their existing 1:1
+// conversation if there is one. Everyone added must accept messages from
+// the caller and have no block with them.
+func (s *MessageService) CreateConversation(ctx context.Context, userID string, req *models.CreateConversationRequest) (*models.Conversation, error) {
+	seen := map[string]bool{userID: true}
+	var others []string
+	for _, id := range req.ParticipantIDs {
+		parsed, err := uuid.Parse(strings.TrimSpace(id))
+		if err != nil {
+			return nil, ErrInvalidConversation
+		}
+		id = parsed.String()
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 || len(others) > constants.MaxGroupParticipants-1 {
+		return nil, ErrInvalidConversation
+	}
+
+	title := ""
+	if len(others) > 1 {
+		title = strings.TrimSpace(utils.SanitizeHTML(req.Title))
+		if !utils.ValidateLength(title, 0, constants.MaxConversationTitle) {
+			return nil, ErrInvalidConversationTitle
+		}
+	}
+
+	var active int
+	err := database.QueryRowWithContext(ctx,
+		"SELECT COUNT(*) FROM public.users WHERE id::text = ANY($1) AND is_active = true", pq.Array(others),
+	).Scan(&active)
+	if err != nil {
+		return nil, err
+	}
+	if active != len(others) {
+		return nil, ErrUserNotFound
+	}
+	for _, id := range others {
+		if err := s.checkRecipient(ctx, userID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var conversationID string
+	if len(others) == 1 {
+		key := directKey(userID, others[0])
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO public.conversations (is_group, direct_key, created_by, created_at, last_message_at)
+			VALUES (false, $1, $2, $3, $3)
+			ON CONFLICT (direct_key) DO NOTHING
+			RETURNING id
+		`, key, userID, now).Scan(&conversationID)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRowContext(ctx,
+				"SELECT id FROM public.conversations WHERE direct_key = $1", key).Scan(&conversationID)
+		}
+	} else {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO public.conversations (is_group, title, created_by, created_at, last_message_at)
+			VALUES (true, NULLIF($1, ''), $2, $3, $3)
+			RETURNING id
+		`, title, userID, now).Scan(&conversationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.conversation_participants (conversation_id, user_id, joined_at)
+		SELECT $1::uuid, u::uuid, $3::timestamptz FROM unnest($2::text[]) AS u
+		ON CONFLICT (conversation_id, user_id) DO NOTHING
+	`, conversationID, pq.Array(append([]string{userID}, others...)), now)
+	if err != nil {
+		return nil, err
+	}
+	// Reopening a 1:1 conversation the caller left
+	_, err = tx.ExecContext(ctx,
+		"UPDATE public.conversation_participants SET left_at = NULL WHERE conversation_id = $1 AND user_id = $2",
+		conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetConversation(ctx, userID, conversationID)
+}
+
+// checkRecipient returns ErrCannotMessage unless senderID may start a
+// conversation with recipientID
+func (s *MessageService) checkRecipient(ctx context.Context, senderID, recipientID string) error {
+	blocked, err := NewBlockService(s.db).IsBlockedEither(ctx, senderID, recipientID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrCannotMessage
+	}
+	allowed, err := NewPrivacyService(s.db).CanMessage(ctx, senderID, recipientID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrCannotMessage
+	}
+	return nil
+}
+
+// ListConversations returns the user's conversations, most recently active
+// first. The cursor encodes the (last_message_at, id) of the last
+// conversation returned on the previous page.
+func (s *MessageService) ListConversations(ctx context.Context, userID, cursor string, limit int) (*models.ConversationListResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var afterTime sql.NullTime
+	var afterID sql.NullString
+	if cursor != "" {
+		t, id, err := decodeFollowCursor(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		afterTime = sql.NullTime{Time: t, Valid: true}
+		afterID = sql.NullString{String: id, Valid: true}
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	conversations, err := s.queryConversations(ctx, userID, listedConversation+`
+		AND ($3::timestamptz IS NULL OR (c.last_message_at, c.id) < ($3, $4::uuid))
+		ORDER BY c.last_message_at DESC, c.id DESC
+		LIMIT $5
+	`, afterTime, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.ConversationListResponse{Conversations: conversations}
+	if len(conversations) > limit {
+		resp.Conversations = conversations[:limit]
+		last := resp.Conversations[limit-1]
+		resp.NextCursor = encodeFollowCursor(last.LastMessageAt, last.ID)
+	}
+	return resp, nil
+}
+
+// GetConversation returns one of the user's conversations
+func (s *MessageService) GetConversation(ctx context.Context, userID, conversationID string) (*models.Conversation, error) {
+	conversations, err := s.queryConversations(ctx, userID, "AND c.id::text = $3", conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, ErrConversationNotFound
+	}
+	return conversations[0], nil
+}
+
+// queryConversations runs conversationQuery with extra conditions, whose
+// arguments start at $3, and fills in participants and last messages
+func (s *MessageService) queryConversations(ctx context.Context, userID, conditions string, args ...interface{}) ([]*models.Conversation, error) {
+	blocked, err := NewBlockService(s.db).BlockedUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.QueryWithContext(ctx, conversationQuery+conditions,
+		append([]interface{}{userID, pq.Array(blocked)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	conversations := []*models.Conversation{}
+	var participantIDs [][]string
+	var allParticipants []string
+	for rows.Next() {
+		var c models.Conversation
+		var ids []string
+		if err := rows.Scan(&c.ID, &c.IsGroup, &c.Title, &c.CreatedAt, &c.LastMessageAt, &c.UnreadCount, pq.Array(&ids)); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		conversations = append(conversations, &c)
+		participantIDs = append(participantIDs, ids)
+		allParticipants = append(allParticipants, ids...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return conversations, nil
+	}
+
+	users, err := NewNotificationService(s.db).actorsByID(ctx, allParticipants)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(conversations))
+	for i, c := range conversations {
+		ids[i] = c.ID
+		c.Participants = []*models.User{}
+		for _, id := range participantIDs[i] {
+			if u, ok := users[id]; ok {
+				c.Participants = append(c.Participants, u)
+			}
+		}
+	}
+
+	rows, err = database.QueryWithContext(ctx, `
+		SELECT DISTINCT ON (m.conversation_id) `+messageColumns+`
+		FROM public.messages m
+		WHERE m.conversation_id::text = ANY($1) AND NOT (COALESCE(m.sender_id::text, '') = ANY($2))
+			AND NOT `+hiddenByShadowBan("m.sender_id", "$3")+`
+		ORDER BY m.conversation_id, m.created_at DESC, m.id DESC
+	`, pq.Array(ids), pq.Array(blocked), userID)
+	if err != nil {
+		return nil, err
+	}
+	lastMessages := make(map[string]*models.Message)
+	var messages []*models.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lastMessages[message.ConversationID] = message
+		messages = append(messages, message)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.attachMedia(ctx, messages); err != nil {
+		return nil, err
+	}
+	for _, c := range conversations {
+		c.LastMessage = lastMessages[c.ID]
+	}
+
+	return conversations, nil
+}
+
+// ListMessages returns a conversation's messages, newest first, leaving out
+// those from users the caller has a block with or who are shadow-banned. The cursor encodes the
+// (created_at, id) of the last message returned on the previous page.
+func (s *MessageService) ListMessages(ctx context.Context, userID, conversationID, cursor string, limit int) (*models.MessageListResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var afterTime sql.NullTime
+	var afterID sql.NullString
+	if cursor != "" {
+		t, id, err := decodeFollowCursor(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		afterTime = sql.NullTime{Time: t, Valid: true}
+		afterID = sql.NullString{String: id, Valid: true}
+	}
+
+	if _, err := s.participant(ctx, userID, conversationID); err != nil {
+		return nil, err
+	}
+	blocked, err := NewBlockService(s.db).BlockedUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM public.messages m
+		WHERE m.conversation_id::text = $1 AND NOT (COALESCE(m.sender_id::text, '') = ANY($2))
+			AND ($3::timestamptz IS NULL OR (m.created_at, m.id) < ($3, $4::uuid))
+			AND NOT `+hiddenByShadowBan("m.sender_id", "$6")+`
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $5
+	`, conversationID, pq.Array(blocked), afterTime, afterID, limit+1, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.MessageListResponse{Messages: []*models.Message{}}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Messages) == limit {
+			last := resp.Messages[limit-1]
+			resp.NextCursor = encodeFollowCursor(last.CreatedAt, last.ID)
+			break
+		}
+		resp.Messages = append(resp.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := s.attachMedia(ctx, resp.Messages); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// participant checks that the user is in the conversation and reports
+// whether it is a group
+func (s *MessageService) participant(ctx context.Context, userID, conversationID string) (bool, error) {
+	var isGroup bool
+	err := database.QueryRowWithContext(ctx, `
+		SELECT c.is_group FROM public.conversations c
+		JOIN public.conversation_participants p ON p.conversation_id = c.id AND p.user_id = $2 AND p.left_at IS NULL
+		WHERE c.id::text = $1
+	`, conversationID, userID).Scan(&isGroup)
+	if err == sql.ErrNoRows {
+		return false, ErrConversationNotFound
+	}
+	return isGroup, err
+}
+
+// attachMedia loads the attachments of messages that aren't deleted
+func (s *MessageService) attachMedia(ctx context.Context, messages []*models.Message) error {
+	byID := make(map[string]*models.Message, len(messages))
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		if !m.Deleted {
+			byID[m.ID] = m
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT message_id, id, type, url, COALESCE(name, ''), COALESCE(size, 0)
+		FROM public.media
+		WHERE message_id::text = ANY($1)
+		ORDER BY created_at, id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var media models.MediaAttachment
+		if err := rows.Scan(&messageID, &media.ID, &media.Type, &media.URL, &media.Name, &media.Size); err != nil {
+			return err
+		}
+		byID[messageID].Media = append(byID[messageID].Media, media)
+	}
+	return rows.Err()
+}
+
+// SendMessage posts a message to a conversation the sender is in. In a 1:1
+// conversation the recipient's message setting applies until they reply.
+func (s *MessageService) SendMessage(ctx context.Context, userID, conversationID string, req *models.SendMessageRequest) (*models.Message, error) {
+	content := strings.TrimSpace(utils.SanitizeHTML(req.Content))
+	if !utils.ValidateLength(content, 0, constants.MaxMessageLength) {
+		return nil, ErrInvalidMessage
+	}
+	mediaIDs := uniqueStrings(req.MediaIDs)
+	if content == "" && len(mediaIDs) == 0 {
+		return nil, ErrInvalidMessage
+	}
+	if len(mediaIDs) > constants.MaxMediaPerMessage {
+		return nil, ErrInvalidMessageMedia
+	}
+
+	isGroup, err := s.participant(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !isGroup {
+		if err := s.checkDirectRecipient(ctx, userID, conversationID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	message := &models.Message{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Content:        content,
+		ReadBy:         []string{},
+		CreatedAt:      now,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.messages (conversation_id, sender_id, content, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, conversation_id
+	`, conversationID, userID, content, now).Scan(&message.ID, &message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mediaIDs) > 0 {
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE public.media SET message_id = $1
+			WHERE id::text = ANY($2) AND user_id = $3 AND post_id IS NULL AND message_id IS NULL
+				AND moderation_status = 'approved'
+			RETURNING id, type, url, COALESCE(name, ''), COALESCE(size, 0)
+		`, message.ID, pq.Array(mediaIDs), userID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var media models.MediaAttachment
+			if err := rows.Scan(&media.ID, &media.Type, &media.URL, &media.Name, &media.Size); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			message.Media = append(message.Media, media)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(message.Media) != len(mediaIDs) {
+			return nil, ErrInvalidMessageMedia
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE public.conversations SET last_message_at = $2 WHERE id = $1", message.ConversationID, now)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE public.conversation_participants SET last_read_at = $3 WHERE conversation_id = $1 AND user_id = $2",
+		message.ConversationID, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !isGroup {
+		// A new message brings a 1:1 conversation back for whoever left it
+		_, err = tx.ExecContext(ctx,
+			"UPDATE public.conversation_participants SET left_at = NULL WHERE conversation_id = $1 AND left_at IS NOT NULL",
+			message.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	publishMessageAsync(message.ConversationID, userID, "created", message)
+	return message, nil
+}
+
+// checkDirectRecipient checks that the other user of a 1:1 conversation can
+// still be messaged: they must be active with no block either way, and
+// their message setting must allow it unless they have written themselves
+func (s *MessageService) checkDirectRecipient(ctx context.Context, userID, conversationID string) error {
+	var recipientID string
+	var active, replied bool
+	err := database.QueryRowWithContext(ctx, `
+		SELECT u.id, u.is_active,
+			EXISTS(SELECT 1 FROM public.messages m WHERE m.conversation_id = p.conversation_id AND m.sender_id = u.id)
+		FROM public.conversation_participants p
+		JOIN public.users u ON u.id = p.user_id
+		WHERE p.conversation_id::text = $1 AND p.user_id <> $2
+	`, conversationID, userID).Scan(&recipientID, &active, &replied)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return ErrCannotMessage
+	}
+	if err != nil {
+		return err
+	}
+
+	if replied {
+		blocked, err := NewBlockService(s.db).IsBlockedEither(ctx, userID, recipientID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrCannotMessage
+		}
+		return nil
+	}
+	return s.checkRecipient(ctx, userID, recipientID)
+}
+
+// EditMessage replaces the text of one of the user's messages
+func (s *MessageService) EditMessage(ctx context.Context, userID, messageID string, req *models.EditMessageRequest) (*models.Message, error) {
+	content := strings.TrimSpace(utils.SanitizeHTML(req.Content))
+	if !utils.ValidateLength(content, 1, constants.MaxMessageLength) {
+		return nil, ErrInvalidMessage
+	}
+
+	conversationID, err := s.ownMessage(ctx, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := scanMessage(database.QueryRowWithContext(ctx, `
+		UPDATE public.messages m SET content = $2, edited_at = $3
+		WHERE m.id::text = $1 AND m.deleted_at IS NULL
+		RETURNING `+messageColumns,
+		messageID, content, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachMedia(ctx, []*models.Message{message}); err != nil {
+		return nil, err
+	}
+
+	publishMessageAsync(conversationID, userID, "edited", message)
+	return message, nil
+}
+
+// DeleteMessage removes the content and attachments of one of the user's
+// messages, leaving a placeholder in the history
+func (s *MessageService) DeleteMessage(ctx context.Context, userID, messageID string) error {
+	conversationID, err := s.ownMessage(ctx, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	message, err := scanMessage(database.QueryRowWithContext(ctx, `
+		UPDATE public.messages m SET content = '', deleted_at = $2
+		WHERE m.id::text = $1 AND m.deleted_at IS NULL
+		RETURNING `+messageColumns,
+		messageID, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	publishMessageAsync(conversationID, userID, "deleted", message)
+	return nil
+}
+
+// ownMessage returns the conversation of a message the user sent and can
+// still see
+func (s *MessageService) ownMessage(ctx context.Context, userID, messageID string) (string, error) {
+	var conversationID string
+	var senderID sql.NullString
+	err := database.QueryRowWithContext(ctx, `
+		SELECT m.conversation_id, m.sender_id FROM public.messages m
+		JOIN public.conversation_participants p
+			ON p.conversation_id = m.conversation_id AND p.user_id = $2 AND p.left_at IS NULL
+		WHERE m.id::text = $1 AND m.deleted_at IS NULL
+	`, messageID, userID).Scan(&conversationID, &senderID)
+	if err == sql.ErrNoRows {
+		return "", ErrMessageNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if senderID.String != userID {
+		return "", ErrNotMessageSender
+	}
+	return conversationID, nil
+}
+
+// MarkRead marks everything in the conversation as read by the user and
+// tells the other participants
+func (s *MessageService) MarkRead(ctx context.Context, userID, conversationID string) error {
+	now := time.Now().UTC()
+	var id string
+	err := database.QueryRowWithContext(ctx, `
+		UPDATE public.conversation_participants SET last_read_at = $3
+		WHERE conversation_id::text = $1 AND user_id = $2 AND left_at IS NULL
+		RETURNING conversation_id
+	`, conversationID, userID, now).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrConversationNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	publishMessageReadAsync(id, userID, now)
+	return nil
+}
+
+// UnreadCount returns how many conversations have unread messages for the
+// user and how many unread messages they hold in total
+func (s *MessageService) UnreadCount(ctx context.Context, userID string) (int, int, error) {
+	blocked, err := NewBlockService(s.db).BlockedUserIDs(ctx, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var conversations, messages int
+	err = database.QueryRowWithContext(ctx, `
+		WITH unread AS (`+conversationQuery+listedConversation+`)
+		SELECT COUNT(*) FILTER (WHERE unread_count > 0), COALESCE(SUM(unread_count), 0)
+		FROM unread
+	`, userID, pq.Array(blocked)).Scan(&conversations, &messages)
+	return conversations, messages, err
+}
+
+// LeaveConversation removes the user from a conversation. A 1:1
+// conversation comes back with the next message; a group is left for good.
+func (s *MessageService) LeaveConversation(ctx context.Context, userID, conversationID string) error {
+	result, err := database.ExecWithContext(ctx, `
+		UPDATE public.conversation_participants SET left_at = $3
+		WHERE conversation_id::text = $1 AND user_id = $2 AND left_at IS NULL
+	`, conversationID, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// uniqueStrings returns the non-empty values in order, without repeats
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrQueueItemNotFound  = errors.New("queue item not found")
+	ErrQueueItemClosed    = errors.New("this item has already been approved or rejected")
+	ErrQueuePublishFailed = errors.New("the held content could not be published")
+	ErrInvalidQueueStatus = errors.New("status must be 'pending', 'approved' or 'rejected'")
+	ErrInvalidQueueSource = errors.New("source must be 'filter', 'trust' or 'classifier'")
+	ErrInvalidQueueNote   = fmt.Errorf("notes are at most %d characters", constants.MaxQueueNoteLength)
+)
+
+// filterMatchSummary lists the rules behind the matches that took action,
+// by description or, failing that, pattern
+func filterMatchSummary(verdict *models.FilterVerdict, action string) string {
+	var rules []string
+	for _, match := range verdict.Matches {
+		if match.Action != action {
+			continue
+		}
+		name := match.Pattern
+		for _, rule := range activeFilterRules().rules {
+			if rule.rule.ID == match.RuleID && rule.rule.Description != "" {
+				name = rule.rule.Description
+			}
+		}
+		rules = append(rules, name)
+	}
+	return strings.Join(uniqueStrings(rules), ", ")
+}
+
+func filterMatchRuleIDs(verdict *models.FilterVerdict) []string {
+	ids := make([]string, 0, len(verdict.Matches))
+	for _, match := range verdict.Matches {
+		ids = append(ids, match.RuleID)
+	}
+	return uniqueStrings(ids)
+}
+
+// holdContent queues a write that matched a hold filter rule instead of
+// publishing it. payload is the request replayed on approval. It returns
+// ErrContentHeld once the write is queued.
+func holdContent(ctx context.Context, userID, kind, targetID string, payload interface{}, verdict *models.FilterVerdict) error {
+	reason := "Matched filter rules: " + filterMatchSummary(verdict, verdict.Action)
+	if err := queueForReview(ctx, userID, kind, models.QueueSourceFilter, targetID, payload, reason, filterMatchRuleIDs(verdict)); err != nil {
+		return err
+	}
+	return ErrContentHeld
+}
+
+// queueForReview adds a write to the moderation queue
+func queueForReview(ctx context.Context, userID, kind, source, targetID string, payload interface{}, reason string, ruleIDs []string) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if ruleIDs == nil {
+		ruleIDs = []string{}
+	}
+
+	_, err = database.ExecWithContext(ctx, `
+		INSERT INTO public.moderation_queue (user_id, kind, source, target_id, payload, reason, rule_ids)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7)
+	`, userID, kind, source, targetID, string(data), truncateRunes(reason, constants.MaxReportReasonLength), pq.Array(ruleIDs))
+	return err
+}
+
+// flagContentTx opens a report, without a reporter, on a post or comment
+// that matched a flag rule. It does nothing for other verdicts.
+func flagContentTx(ctx context.Context, tx *sql.Tx, targetType, targetID string, verdict *models.FilterVerdict) error {
+	if verdict == nil || verdict.Action != models.FilterActionFlag {
+		return nil
+	}
+	return openSystemReportTx(ctx, tx, targetType, targetID, "Flagged by filter rules: "+filterMatchSummary(verdict, models.FilterActionFlag))
+}
+
+// openSystemReportTx opens a report without a reporter on a post or
+// comment, unless it already has an open one from the filter or the spam
+// classifier
+func openSystemReportTx(ctx context.Context, tx *sql.Tx, targetType, targetID, reason string) error {
+	var open bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM public.reports
+			WHERE reporter_id IS NULL AND target_type = $1 AND target_id::text = $2 AND status IN ('pending', 'reviewed')
+		)
+	`, targetType, targetID).Scan(&open)
+	if err != nil || open {
+		return err
+	}
+
+	_, snapshot, err := snapshotReportTarget(ctx, tx, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	targetColumn := "post_id"
+	if targetType == "comment" {
+		targetColumn = "comment_id"
+	}
+	reason = truncateRunes(reason, constants.MaxReportReasonLength)
+	now := time.Now().UTC()
+	var reportID string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.reports (`+targetColumn+`, target_type, target_id, reason, status, snapshot, created_at, updated_at)
+		VALUES ($1, $2, $1, $3, 'pending', $4, $5, $5)
+		RETURNING id
+	`, targetID, targetType, reason, string(snapshot), now).Scan(&reportID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"id":        reportID,
+		"reason":    reason,
+		"createdAt": now,
+	}
+	if targetType == "post" {
+		data["postId"] = targetID
+	} else {
+		data["commentId"] = targetID
+	}
+	return enqueueWebhookEvent(ctx, tx, models.WebhookEventReportCreated, data)
+}
+
+// flagContent is flagContentTx for writes made outside a transaction
+func flagContent(ctx context.Context, targetType, targetID string, verdict *models.FilterVerdict) error {
+	if verdict == nil || verdict.Action != models.FilterActionFlag {
+		return nil
+	}
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := flagContentTx(ctx, tx, targetType, targetID, verdict); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// ModerationQueueService lets admins approve or reject writes held for review
+type ModerationQueueService struct {
+	db    *sql.DB
+	cache *CacheService
+	cfg   *config.Config
+}
+
+// NewModerationQueueService creates a new ModerationQueueService instance
+func NewModerationQueueService(db *sql.DB, cache *CacheService, cfg *config.Config) *ModerationQueueService {
+	return &ModerationQueueService{db: db, cache: cache, cfg: cfg}
+}
+
+const queueColumns = `id, user_id, kind, source, target_id, payload, reason, rule_ids, status, created_at,
+	reviewed_at, reviewed_by, COALESCE(review_note, ''), spam, published_id`
+
+func scanQueueItem(row rowScanner) (*models.QueueItem, error) {
+	var item models.QueueItem
+	var targetID, reviewedBy, publishedID sql.NullString
+	var reviewedAt sql.NullTime
+	var payload []byte
+	ruleIDs := []string{}
+	err := row.Scan(&item.ID, &item.UserID, &item.Kind, &item.Source, &targetID, &payload, &item.Reason, pq.Array(&ruleIDs),
+		&item.Status, &item.CreatedAt, &reviewedAt, &reviewedBy, &item.ReviewNote, &item.Spam, &publishedID)
+	if err != nil {
+		return nil, err
+	}
+	item.TargetID = targetID.String
+	item.Payload = payload
+	item.RuleIDs = ruleIDs
+	item.ReviewedBy = reviewedBy.String
+	item.PublishedID = publishedID.String
+	if reviewedAt.Valid {
+		item.ReviewedAt = &reviewedAt.Time
+	}
+	return &item, nil
+}
+
+// ListQueue returns queued writes with the given status (pending by
+// default), optionally only those held for one source, oldest first so the
+// longest-waiting are reviewed first
+func (s *ModerationQueueService) ListQueue(ctx context.Context, status, source string, limit, offset int) ([]*models.QueueItem, error) {
+	switch status {
+	case "":
+		status = models.QueueStatusPending
+	case models.QueueStatusPending, models.QueueStatusApproved, models.QueueStatusRejected:
+	default:
+		return nil, ErrInvalidQueueStatus
+	}
+	switch source {
+	case "", models.QueueSourceFilter, models.QueueSourceTrust, models.QueueSourceClassifier:
+	default:
+		return nil, ErrInvalidQueueSource
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+queueColumns+`
+		FROM public.moderation_queue
+		WHERE status = $1 AND ($2 = '' OR source = $2)
+		ORDER BY created_at
+		LIMIT $3 OFFSET $4
+	`, status, source, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.QueueItem{}
+	for rows.Next() {
+		item, err := scanQueueItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetQueueItem returns one queued write
+func (s *ModerationQueueService) GetQueueItem(ctx context.Context, itemID string) (*models.QueueItem, error) {
+	item, err := scanQueueItem(database.QueryRowWithContext(ctx,
+		"SELECT "+queueColumns+" FROM public.moderation_queue WHERE id::text = $1", itemID))
+	if err == sql.ErrNoRows {
+		return nil, ErrQueueItemNotFound
+	}
+	return item, err
+}
+
+// Approve publishes a held write as its author made it, skipping the filter
+// rules. The item is marked approved before the write is replayed, so a
+// second approval can't publish it twice. The write goes through the usual
+// checks again, so it fails with ErrQueuePublishFailed if, say, the post was
+// deleted in the meantime, and the item goes back to pending.
+func (s *ModerationQueueService) Approve(ctx context.Context, itemID, adminID, note string) (*models.QueueItem, error) {
+	item, err := s.review(ctx, itemID, adminID, note, models.QueueStatusApproved, false)
+	if err != nil {
+		return nil, err
+	}
+
+	publishedID, err := s.publish(asApprovedContent(ctx), item)
+	if err != nil {
+		if reopenErr := s.reopen(ctx, item); reopenErr != nil {
+			log.Printf("Failed to reopen queue item %s after its publish failed: %v", item.ID, reopenErr)
+		}
+		return nil, err
+	}
+	if publishedID == "" {
+		return item, nil
+	}
+	return scanQueueItem(database.QueryRowWithContext(ctx, `
+		UPDATE public.moderation_queue SET published_id = $2::uuid
+		WHERE id = $1
+		RETURNING `+queueColumns,
+		item.ID, publishedID))
+}
+
+// Reject discards a held write. Rejected uploads are deleted. Rejections
+// tagged as spam are the spam classifier's spam examples.
+func (s *ModerationQueueService) Reject(ctx context.Context, itemID, adminID, note string, spam bool) (*models.QueueItem, error) {
+	return s.review(ctx, itemID, adminID, note, models.QueueStatusRejected, spam)
+}
+
+func (s *ModerationQueueService) review(ctx context.Context, itemID, adminID, note, status string, spam bool) (*models.QueueItem, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > constants.MaxQueueNoteLength {
+		return nil, ErrInvalidQueueNote
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The row stays locked until it's closed, so two admins can't review it
+	// at once
+	item, err := scanQueueItem(tx.QueryRowContext(ctx,
+		"SELECT "+queueColumns+" FROM public.moderation_queue WHERE id::text = $1 FOR UPDATE", itemID))
+	if err == sql.ErrNoRows {
+		return nil, ErrQueueItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != models.QueueStatusPending {
+		return nil, ErrQueueItemClosed
+	}
+
+	action := models.AuditActionQueueApprove
+	if status == models.QueueStatusRejected {
+		action = models.AuditActionQueueReject
+		if item.Kind == models.QueueKindMedia {
+			if err := NewMediaService(s.db).DeleteMedia(ctx, item.UserID, item.TargetID, s.cfg); err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+		}
+	}
+
+	reviewed, err := scanQueueItem(tx.QueryRowContext(ctx, `
+		UPDATE public.moderation_queue
+		SET status = $2, reviewed_at = $3, reviewed_by = $4, review_note = NULLIF($5, ''), spam = $6
+		WHERE id = $1
+		RETURNING `+queueColumns,
+		item.ID, status, time.Now().UTC(), adminID, note, spam))
+	if err != nil {
+		return nil, err
+	}
+	before := map[string]interface{}{"status": item.Status, "kind": item.Kind, "userId": item.UserID, "targetId": item.TargetID}
+	after := map[string]interface{}{"status": reviewed.Status, "note": note, "spam": reviewed.Spam}
+	if err := recordAudit(ctx, tx, action, "queue_item", item.ID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reviewed, nil
+}
+
+// reopen puts an approved item whose write couldn't be published back in
+// the queue
+func (s *ModerationQueueService) reopen(ctx context.Context, item *models.QueueItem) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE public.moderation_queue
+		SET status = 'pending', reviewed_at = NULL, reviewed_by = NULL, review_note = NULL
+		WHERE id = $1 AND status = 'approved' AND published_id IS NULL
+	`, item.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+	before := map[string]interface{}{"status": item.Status, "note": item.ReviewNote}
+	after := map[string]interface{}{"status": models.QueueStatusPending}
+	if err := recordAudit(ctx, tx, models.AuditActionQueueReopen, "queue_item", item.ID, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// publish replays a held write and returns the ID of the post or comment
+// it created, if any
+func (s *ModerationQueueService) publish(ctx context.Context, item *models.QueueItem) (string, error) {
+	var err error
+	var publishedID string
+	switch item.Kind {
+	case models.QueueKindPost:
+		var req models.CreatePostRequest
+		if err = json.Unmarshal(item.Payload, &req); err == nil {
+			var post *models.Post
+			if post, err = NewPostServiceWithCache(s.db, s.cache).CreatePost(ctx, item.UserID, &req); err == nil {
+				publishedID = post.ID
+			}
+		}
+	case models.QueueKindPostUpdate:
+		var req models.UpdatePostRequest
+		if err = json.Unmarshal(item.Payload, &req); err == nil {
+			_, err = NewPostServiceWithCache(s.db, s.cache).UpdatePost(ctx, item.UserID, item.TargetID, &req)
+		}
+	case models.QueueKindComment:
+		var req models.CreateCommentRequest
+		if err = json.Unmarshal(item.Payload, &req); err == nil {
+			var comment *models.Comment
+			if comment, err = NewCommentService(s.db).CreateComment(ctx, item.UserID, item.TargetID, &req); err == nil {
+				publishedID = comment.ID
+			}
+		}
+	case models.QueueKindCommentUpdate:
+		var req models.UpdateCommentRequest
+		if err = json.Unmarshal(item.Payload, &req); err == nil {
+			_, err = NewCommentService(s.db).UpdateComment(ctx, item.UserID, item.TargetID, &req)
+		}
+	case models.QueueKindProfile:
+		var req models.UpdateProfileRequest
+		if err = json.Unmarshal(item.Payload, &req); err == nil {
+			_, err = NewUserService(s.db).UpdateUser(ctx, item.UserID, &req)
+		}
+	case models.QueueKindMedia:
+		err = approveMedia(ctx, item.TargetID)
+		publishedID = item.TargetID
+	default:
+		err = fmt.Errorf("unknown queue item kind %q", item.Kind)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrQueuePublishFailed, err)
+	}
+	return publishedID, nil
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var ErrUnsubscribeLinkInvalid = errors.New("this unsubscribe link is invalid")
+
+// NotificationEmailService emails notifications as they happen or as daily
+// and weekly digests, following each user's notification preferences
+type NotificationEmailService struct {
+	db           *sql.DB
+	cfg          *config.Config
+	emailService *EmailService
+}
+
+// NewNotificationEmailService creates a new NotificationEmailService instance
+func NewNotificationEmailService(db *sql.DB, cfg *config.Config, emailService *EmailService) *NotificationEmailService {
+	return &NotificationEmailService{db: db, cfg: cfg, emailService: emailService}
+}
+
+// StartNotificationEmailJob sends immediate notification emails every
+// minute and checks hourly for due digests
+func (s *NotificationEmailService) StartNotificationEmailJob(ctx context.Context) {
+	emailTicker := time.NewTicker(constants.NotificationEmailPollInterval)
+	digestTicker := time.NewTicker(constants.DigestPollInterval)
+	go func() {
+		for {
+			select {
+			case <-emailTicker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+				if err := s.SendPendingEmails(jobCtx); err != nil {
+					log.Printf("Notification email job error: %v", err)
+				}
+				cancel()
+			case <-digestTicker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+				for _, frequency := range []string{models.ChannelDaily, models.ChannelWeekly} {
+					for {
+						handled, err := s.SendDueDigests(jobCtx, frequency)
+						if err != nil {
+							log.Printf("Failed to send %s digests: %v", frequency, err)
+						}
+						if handled < constants.DigestBatchSize || err != nil {
+							break
+						}
+					}
+				}
+				cancel()
+			case <-ctx.Done():
+				emailTicker.Stop()
+				digestTicker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// SendPendingEmails emails unread notifications whose recipient chose
+// immediate email for their type, one email per recipient
+func (s *NotificationEmailService) SendPendingEmails(ctx context.Context) error {
+	if s.emailService == nil {
+		return nil
+	}
+
+	query := `
+		SELECT n.id, n.user_id, u.email, n.type, n.group_key, a.name
+		FROM public.notifications n
+		JOIN public.users u ON u.id = n.user_id AND u.is_active = true AND u.email_verified_at IS NOT NULL
+		JOIN public.users a ON a.id = n.actor_id AND a.is_active = true AND a.shadow_banned_at IS NULL
+		WHERE n.emailed_at IS NULL AND n.read_at IS NULL AND n.created_at > $1
+			AND ` + notificationChannelSQL("n.user_id", "n.type") + ` = 'email'
+		ORDER BY n.user_id, n.created_at
+		LIMIT 500
+	`
+	rows, err := database.QueryWithContext(ctx, query, time.Now().UTC().Add(-constants.NotificationEmailMaxAge))
+	if err != nil {
+		return err
+	}
+
+	type pendingEmail struct {
+		email string
+		ids   []string
+		lines []string
+		kinds map[string]bool
+	}
+	pending := make(map[string]*pendingEmail)
+	var order []string
+	for rows.Next() {
+		var id, userID, email, kind, groupKey, actorName string
+		if err := rows.Scan(&id, &userID, &email, &kind, &groupKey, &actorName); err != nil {
+			rows.Close()
+			return err
+		}
+		p, ok := pending[userID]
+		if !ok {
+			p = &pendingEmail{email: email, kinds: make(map[string]bool)}
+			pending[userID] = p
+			order = append(order, userID)
+		}
+		p.ids = append(p.ids, id)
+		p.lines = append(p.lines, notificationMessage([]*models.User{{Name: actorName}}, 1, notificationVerb(kind, groupKey)))
+		p.kinds[kind] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range order {
+		p := pending[userID]
+		kinds := make([]string, 0, len(p.kinds))
+		for kind := range p.kinds {
+			kinds = append(kinds, kind)
+		}
+		if err := s.emailService.SendNotificationEmail(ctx, p.email, p.lines, s.unsubscribeLink(userID, kinds)); err != nil {
+			log.Printf("Failed to email notifications to user %s: %v", userID, err)
+			continue
+		}
+		_, err := database.ExecWithContext(ctx,
+			"UPDATE public.notifications SET emailed_at = $1 WHERE id::text = ANY($2)", time.Now().UTC(), pq.Array(p.ids))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendDueDigests sends the daily or weekly digest to up to DigestBatchSize
+// users whose last one is a full period old, and returns how many users it
+// handled. Users with nothing to report are skipped until the next period.
+func (s *NotificationEmailService) SendDueDigests(ctx context.Context, frequency string) (int, error) {
+	if s.emailService == nil {
+		return 0, nil
+	}
+	period := 24 * time.Hour
+	if frequency == models.ChannelWeekly {
+		period = 7 * 24 * time.Hour
+	}
+	now := time.Now().UTC()
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT u.id, u.email, d.last_sent_at
+		FROM public.users u
+		LEFT JOIN public.notification_digests d ON d.user_id = u.id AND d.frequency = $1
+		WHERE u.is_active = true AND u.email_verified_at IS NOT NULL
+			AND (d.last_sent_at IS NULL OR d.last_sent_at <= $2)
+		ORDER BY d.last_sent_at NULLS FIRST, u.id
+		LIMIT $3
+	`, frequency, now.Add(-period), constants.DigestBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type recipient struct {
+		id, email string
+		since     time.Time
+	}
+	var recipients []recipient
+	for rows.Next() {
+		var r recipient
+		var lastSent sql.NullTime
+		if err := rows.Scan(&r.id, &r.email, &lastSent); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		r.since = now.Add(-period)
+		if lastSent.Valid {
+			r.since = lastSent.Time
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range recipients {
+		if err := s.sendDigest(ctx, r.id, r.email, frequency, r.since); err != nil {
+			log.Printf("Failed to send %s digest to user %s: %v", frequency, r.id, err)
+		}
+		_, err := database.ExecWithContext(ctx, `
+			INSERT INTO public.notification_digests (user_id, frequency, last_sent_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, frequency) DO UPDATE SET last_sent_at = EXCLUDED.last_sent_at
+		`, r.id, frequency, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(recipients), nil
+}
+
+// sendDigest composes one user's digest from unread notifications of the
+// types they get at this frequency and, if they opted in, top posts from
+// people they follow
+func (s *NotificationEmailService) sendDigest(ctx context.Context, userID, email, frequency string, since time.Time) error {
+	prefs, err := NewNotificationService(s.db).GetPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var kinds []string
+	for kind, channel := range prefs {
+		if channel == frequency && kind != models.NotificationTopPosts {
+			kinds = append(kinds, kind)
+		}
+	}
+	withPosts := prefs[models.NotificationTopPosts] == frequency
+	if len(kinds) == 0 && !withPosts {
+		return nil
+	}
+
+	var lines []string
+	if len(kinds) > 0 {
+		if lines, err = s.digestLines(ctx, userID, kinds, since); err != nil {
+			return err
+		}
+	}
+	var posts []*models.DigestPost
+	if withPosts {
+		if posts, err = s.topPostsFromFollowing(ctx, userID, since); err != nil {
+			return err
+		}
+		kinds = append(kinds, models.NotificationTopPosts)
+	}
+	if len(lines) == 0 && len(posts) == 0 {
+		return nil
+	}
+
+	return s.emailService.SendDigestEmail(ctx, email, frequency, lines, posts, s.unsubscribeLink(userID, kinds))
+}
+
+// digestLines summarises the user's unread notifications of the given types
+// since a time, one line per group
+func (s *NotificationEmailService) digestLines(ctx context.Context, userID string, kinds []string, since time.Time) ([]string, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		WITH visible AS (`+visibleNotifications+`)
+		SELECT v.type, v.group_key, COUNT(*), (ARRAY_AGG(a.name ORDER BY v.created_at DESC))[1]
+		FROM visible v
+		JOIN public.users a ON a.id = v.actor_id
+		WHERE v.read_at IS NULL AND v.created_at > $2 AND v.type = ANY($3)
+		GROUP BY v.type, v.group_key
+		ORDER BY MAX(v.created_at) DESC
+		LIMIT 20
+	`, userID, since, pq.Array(kinds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var kind, groupKey, latestActor string
+		var count int
+		if err := rows.Scan(&kind, &groupKey, &count, &latestActor); err != nil {
+			return nil, err
+		}
+		lines = append(lines, notificationMessage([]*models.User{{Name: latestActor}}, count, notificationVerb(kind, groupKey)))
+	}
+	return lines, rows.Err()
+}
+
+// topPostsFromFollowing returns the most liked and discussed posts published
+// since a time by people the user follows
+func (s *NotificationEmailService) topPostsFromFollowing(ctx context.Context, userID string, since time.Time) ([]*models.DigestPost, error) {
+	hidden, err := NewBlockService(s.db).HiddenUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT p.id, p.title, u.name, p.likes, p.comments
+		FROM public.posts p
+		JOIN public.follows f ON f.following_id = p.author_id AND f.follower_id = $1
+		JOIN public.users u ON u.id = p.author_id AND u.is_active = true AND u.shadow_banned_at IS NULL
+		WHERE p.created_at > $2 AND NOT (p.author_id::text = ANY($3))
+		ORDER BY p.likes + 2 * p.comments DESC, p.created_at DESC
+		LIMIT $4
+	`, userID, since, pq.Array(hidden), constants.DigestTopPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.DigestPost
+	for rows.Next() {
+		var id string
+		var post models.DigestPost
+		if err := rows.Scan(&id, &post.Title, &post.AuthorName, &post.Likes, &post.Comments); err != nil {
+			return nil, err
+		}
+		post.URL = fmt.Sprintf("%s/posts/%s", s.cfg.AppBaseURL, url.PathEscape(id))
+		posts = append(posts, &post)
+	}
+	return posts, rows.Err()
+}
+
+// Unsubscribe stops the emails a signed link from unsubscribeLink was sent
+// for: those notification types stay in the app only, and the top posts
+// digest is turned off
+func (s *NotificationEmailService) Unsubscribe(ctx context.Context, userID, types, sig string) error {
+	changes, err := s.unsubscribeChanges(userID, types, sig)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewNotificationService(s.db).UpdatePreferences(ctx, userID, changes)
+	return err
+}
+
+// CheckUnsubscribeLink reports whether a signed unsubscribe link is valid,
+// without changing anything
+func (s *NotificationEmailService) CheckUnsubscribeLink(userID, types, sig string) error {
+	_, err := s.unsubscribeChanges(userID, types, sig)
+	return err
+}
+
+// unsubscribeChanges verifies an unsubscribe link and returns the
+// preference changes it makes
+func (s *NotificationEmailService) unsubscribeChanges(userID, types, sig string) (map[string]string, error) {
+	if !utils.Verify(s.cfg.SigningSecret, sig, "unsubscribe", userID, types) {
+		return nil, ErrUnsubscribeLinkInvalid
+	}
+
+	changes := make(map[string]string)
+	for _, kind := range strings.Split(types, ",") {
+		if _, ok := defaultNotificationChannels[kind]; !ok {
+			return nil, ErrUnsubscribeLinkInvalid
+		}
+		changes[kind] = models.ChannelInApp
+		if kind == models.NotificationTopPosts {
+			changes[kind] = models.ChannelOff
+		}
+	}
+	return changes, nil
+}
+
+// unsubscribeLink returns a link that stops emails for the given types. It
+// never expires, as required for List-Unsubscribe.
+func (s *NotificationEmailService) unsubscribeLink(userID string, kinds []string) string {
+	sort.Strings(kinds)
+	types := strings.Join(kinds, ",")
+	sig := utils.Sign(s.cfg.SigningSecret, "unsubscribe", userID, types)
+	return fmt.Sprintf("%s/api/v1/unsubscribe?uid=%s&types=%s&sig=%s",
+		s.cfg.APIBaseURL, url.QueryEscape(userID), url.QueryEscape(types), sig)
+}
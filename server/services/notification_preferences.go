@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+)
+
+var ErrInvalidNotificationPreference = errors.New("invalid notification preference")
+
+// defaultNotificationChannels apply to types a user never set. Direct
+// interactions are emailed; likes and follows stay in the app.
+var defaultNotificationChannels = map[string]string{
+	models.NotificationLike:     models.ChannelInApp,
+	models.NotificationComment:  models.ChannelDaily,
+	models.NotificationReply:    models.ChannelEmail,
+	models.NotificationMention:  models.ChannelEmail,
+	models.NotificationFollow:   models.ChannelInApp,
+	models.NotificationTopPosts: models.ChannelWeekly,
+}
+
+// validNotificationChannel reports whether channel can be chosen for kind
+func validNotificationChannel(kind, channel string) bool {
+	if _, ok := defaultNotificationChannels[kind]; !ok {
+		return false
+	}
+	switch channel {
+	case models.ChannelDaily, models.ChannelWeekly, models.ChannelOff:
+		return true
+	case models.ChannelInApp, models.ChannelEmail:
+		return kind != models.NotificationTopPosts
+	}
+	return false
+}
+
+// notificationChannelSQL is an SQL expression for the channel the user in
+// userExpr chose for the notification type in typeExpr
+func notificationChannelSQL(userExpr, typeExpr string) string {
+	kinds := make([]string, 0, len(defaultNotificationChannels))
+	for kind := range defaultNotificationChannels {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var defaults strings.Builder
+	for _, kind := range kinds {
+		fmt.Fprintf(&defaults, " WHEN '%s' THEN '%s'", kind, defaultNotificationChannels[kind])
+	}
+	return fmt.Sprintf(`COALESCE(
+		(SELECT np.channel FROM public.notification_preferences np WHERE np.user_id = %s AND np.type = %s),
+		CASE %s%s ELSE 'in_app' END
+	)`, userExpr, typeExpr, typeExpr, defaults.String())
+}
+
+// GetPreferences returns the delivery channel of every notification type for the user
+func (s *NotificationService) GetPreferences(ctx context.Context, userID string) (map[string]string, error) {
+	prefs := make(map[string]string, len(defaultNotificationChannels))
+	for kind, channel := range defaultNotificationChannels {
+		prefs[kind] = channel
+	}
+
+	rows, err := database.QueryWithContext(ctx,
+		"SELECT type, channel FROM public.notification_preferences WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind, channel string
+		if err := rows.Scan(&kind, &channel); err != nil {
+			return nil, err
+		}
+		prefs[kind] = channel
+	}
+	return prefs, rows.Err()
+}
+
+// UpdatePreferences changes the channels of the types present in changes
+func (s *NotificationService) UpdatePreferences(ctx context.Context, userID string, changes map[string]string) (map[string]string, error) {
+	for kind, channel := range changes {
+		if !validNotificationChannel(kind, channel) {
+			return nil, ErrInvalidNotificationPreference
+		}
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for kind, channel := range changes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO public.notification_preferences (user_id, type, channel, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, type) DO UPDATE SET channel = EXCLUDED.channel, updated_at = EXCLUDED.updated_at
+		`, userID, kind, channel, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetPreferences(ctx, userID)
+}
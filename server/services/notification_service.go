@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// notificationEvent is one actor doing something the recipient should hear
+// about. Events with the same groupKey are listed together.
+type notificationEvent struct {
+	recipientID string
+	actorID     string
+	kind        string
+	postID      string
+	commentID   string
+	groupKey    string
+}
+
+// Group keys. One actor appears at most once per group, so liking the same
+// post twice bumps the existing notification instead of adding another.
+func likePostGroup(postID string) string       { return "like:post:" + postID }
+func likeCommentGroup(commentID string) string { return "like:comment:" + commentID }
+func commentGroup(postID string) string        { return "comment:post:" + postID }
+func replyGroup(parentID string) string        { return "reply:comment:" + parentID }
+func mentionGroup(commentID string) string     { return "mention:comment:" + commentID }
+
+const followGroup = "follow"
+
+// notifyAsync records notifications without holding up the request that
+// caused them. Self-notifications are dropped, as are events between users
+// who have blocked each other, whose actor the recipient has muted, or whose
+// type the recipient turned off.
+func notifyAsync(events ...notificationEvent) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		recordNotifications(ctx, events)
+	}()
+}
+
+// retractNotificationAsync removes an unread notification whose cause was
+// undone (an unlike or unfollow)
+func retractNotificationAsync(recipientID, actorID, groupKey string) {
+	if recipientID == "" || recipientID == actorID {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, err := database.ExecWithContext(ctx, `
+			DELETE FROM public.notifications
+			WHERE user_id = $1 AND group_key = $2 AND actor_id = $3 AND read_at IS NULL
+		`, recipientID, groupKey, actorID)
+		if err != nil {
+			log.Printf("Failed to retract notification for user %s: %v", recipientID, err)
+		}
+	}()
+}
+
+// notifyCommentAsync tells the post's author about a new comment, the parent
+// comment's author about a reply, and anyone the comment mentions who allows
+// the commenter to mention them. Each user gets at most one of these.
+func notifyCommentAsync(comment *models.Comment, postAuthorID, parentAuthorID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		notified := map[string]bool{comment.AuthorID: true}
+		var events []notificationEvent
+		if parentAuthorID != "" {
+			events = append(events, notificationEvent{
+				recipientID: parentAuthorID, actorID: comment.AuthorID, kind: models.NotificationReply,
+				postID: comment.PostID, commentID: comment.ID, groupKey: replyGroup(comment.ParentID),
+			})
+			notified[parentAuthorID] = true
+		}
+		if !notified[postAuthorID] {
+			events = append(events, notificationEvent{
+				recipientID: postAuthorID, actorID: comment.AuthorID, kind: models.NotificationComment,
+				postID: comment.PostID, commentID: comment.ID, groupKey: commentGroup(comment.PostID),
+			})
+			notified[postAuthorID] = true
+		}
+
+		mentioned, err := mentionedUserIDs(ctx, comment.AuthorID, comment.Content)
+		if err != nil {
+			log.Printf("Failed to resolve mentions in comment %s: %v", comment.ID, err)
+		}
+		for _, id := range mentioned {
+			if notified[id] {
+				continue
+			}
+			events = append(events, notificationEvent{
+				recipientID: id, actorID: comment.AuthorID, kind: models.NotificationMention,
+				postID: comment.PostID, commentID: comment.ID, groupKey: mentionGroup(comment.ID),
+			})
+			notified[id] = true
+		}
+
+		recordNotifications(ctx, events)
+	}()
+}
+
+// mentionedUserIDs resolves the @handles in content to the users authorID may mention
+func mentionedUserIDs(ctx context.Context, authorID, content string) ([]string, error) {
+	handles := utils.ExtractMentions(content)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+	resolved, err := NewUserService(database.GetDB()).ResolveHandles(ctx, handles)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resolved))
+	for _, id := range resolved {
+		ids = append(ids, id)
+	}
+	return NewPrivacyService(database.GetDB()).FilterMentionable(ctx, authorID, ids)
+}
+
+// recordNotifications stores events and streams them to connected recipients
+func recordNotifications(ctx context.Context, events []notificationEvent) {
+	now := time.Now().UTC()
+	for _, e := range events {
+		if e.recipientID == "" || e.recipientID == e.actorID {
+			continue
+		}
+		result, err := database.ExecWithContext(ctx, `
+			INSERT INTO public.notifications (user_id, type, actor_id, post_id, comment_id, group_key, created_at)
+			SELECT $1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, $6, $7
+			WHERE NOT EXISTS(
+				SELECT 1 FROM public.user_blocks
+				WHERE (blocker_id = $1 AND blocked_id = $3) OR (blocker_id = $3 AND blocked_id = $1)
+			) AND NOT EXISTS(
+				SELECT 1 FROM public.user_mutes WHERE muter_id = $1 AND muted_id = $3
+			) AND NOT `+hiddenByShadowBan("$3::uuid", "$1::text")+`
+			AND `+notificationChannelSQL("$1::uuid", "$2::text")+` <> 'off'
+			ON CONFLICT (user_id, group_key, actor_id) DO UPDATE SET
+				comment_id = EXCLUDED.comment_id,
+				created_at = EXCLUDED.created_at,
+				read_at = NULL,
+				emailed_at = NULL
+		`, e.recipientID, e.kind, e.actorID, e.postID, e.commentID, e.groupKey, now)
+		if err != nil {
+			log.Printf("Failed to record %s notification for user %s: %v", e.kind, e.recipientID, err)
+			continue
+		}
+		if n, _ := result.RowsAffected(); n > 0 && defaultStream != nil {
+			unread, err := NewNotificationService(database.GetDB()).UnreadCount(ctx, e.recipientID)
+			if err != nil {
+				log.Printf("Failed to count unread notifications for user %s: %v", e.recipientID, err)
+				continue
+			}
+			publishStreamEvent(ctx, userStreamChannel(e.recipientID), StreamEventNotification, e.actorID, map[string]interface{}{
+				"type":        e.kind,
+				"actorId":     e.actorID,
+				"postId":      e.postID,
+				"commentId":   e.commentID,
+				"unreadCount": unread,
+			})
+		}
+	}
+}
+
+// NotificationService lists notifications and tracks what has been read
+type NotificationService struct {
+	db *sql.DB
+}
+
+// NewNotificationService creates a new NotificationService instance
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// visibleNotifications selects the recipient's ($1) notifications, leaving
+// out actors that are gone or shadow-banned, or that the recipient has
+// blocked, muted or been blocked by since
+const visibleNotifications = `
+	SELECT n.* FROM public.notifications n
+	JOIN public.users a ON a.id = n.actor_id AND a.is_active = true AND a.shadow_banned_at IS NULL
+	WHERE n.user_id = $1
+		AND NOT EXISTS(
+			SELECT 1 FROM public.user_blocks b
+			WHERE (b.blocker_id = $1 AND b.blocked_id = n.actor_id) OR (b.blocker_id = n.actor_id AND b.blocked_id = $1)
+		)
+		AND NOT EXISTS(
+			SELECT 1 FROM public.user_mutes m WHERE m.muter_id = $1 AND m.muted_id = n.actor_id
+		)
+`
+
+// List returns the user's notifications grouped by target, newest first.
+// Unread and already-read notifications of a group are listed separately, so
+// new activity on an old post shows up on its own. The cursor encodes the
+// (created_at, id) of the last group returned on the previous page.
+func (s *NotificationService) List(ctx context.Context, userID, cursor string, limit int) (*models.NotificationListResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var afterTime sql.NullTime
+	var afterID sql.NullString
+	if cursor != "" {
+		t, id, err := decodeFollowCursor(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		afterTime = sql.NullTime{Time: t, Valid: true}
+		afterID = sql.NullString{String: id, Valid: true}
+	}
+
+	query := `
+		WITH visible AS (` + visibleNotifications + `),
+		groups AS (
+			SELECT DISTINCT ON (group_key, read_at IS NULL)
+				id, type, post_id, comment_id, group_key, read_at IS NULL AS unread, created_at
+			FROM visible
+			ORDER BY group_key, read_at IS NULL, created_at DESC, id DESC
+		)
+		SELECT g.id, g.type, g.post_id, g.comment_id, g.group_key, g.unread, g.created_at,
+			(SELECT COUNT(*) FROM visible v WHERE v.group_key = g.group_key AND (v.read_at IS NULL) = g.unread),
+			ARRAY(
+				SELECT v.actor_id::text FROM visible v
+				WHERE v.group_key = g.group_key AND (v.read_at IS NULL) = g.unread
+				ORDER BY v.created_at DESC
+				LIMIT 3
+			)
+		FROM groups g
+		WHERE $2::timestamptz IS NULL OR (g.created_at, g.id) < ($2, $3::uuid)
+		ORDER BY g.created_at DESC, g.id DESC
+		LIMIT $4
+	`
+	// Fetch one extra row to know whether there is a next page
+	rows, err := database.QueryWithContext(ctx, query, userID, afterTime, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &models.NotificationListResponse{Notifications: []*models.Notification{}}
+	type pending struct {
+		verb     string
+		actorIDs []string
+	}
+	var groups []pending
+	var allActors []string
+	for rows.Next() {
+		var n models.Notification
+		var postID, commentID sql.NullString
+		var groupKey string
+		var unread bool
+		var actors []string
+		if err := rows.Scan(
+			&n.ID, &n.Type, &postID, &commentID, &groupKey, &unread, &n.CreatedAt,
+			&n.ActorCount, pq.Array(&actors),
+		); err != nil {
+			return nil, err
+		}
+		if len(resp.Notifications) == limit {
+			last := resp.Notifications[limit-1]
+			resp.NextCursor = encodeFollowCursor(last.CreatedAt, last.ID)
+			break
+		}
+		n.PostID = postID.String
+		n.CommentID = commentID.String
+		n.Read = !unread
+		resp.Notifications = append(resp.Notifications, &n)
+		groups = append(groups, pending{verb: notificationVerb(n.Type, groupKey), actorIDs: actors})
+		allActors = append(allActors, actors...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	users, err := s.actorsByID(ctx, allActors)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range resp.Notifications {
+		n.Actors = []*models.User{}
+		for _, id := range groups[i].actorIDs {
+			if u, ok := users[id]; ok {
+				n.Actors = append(n.Actors, u)
+			}
+		}
+		n.Message = notificationMessage(n.Actors, n.ActorCount, groups[i].verb)
+	}
+
+	return resp, nil
+}
+
+// UnreadCount returns how many notification groups the user hasn't read
+func (s *NotificationService) UnreadCount(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := database.QueryRowWithContext(ctx, `
+		WITH visible AS (`+visibleNotifications+`)
+		SELECT COUNT(DISTINCT group_key) FROM visible WHERE read_at IS NULL
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks the group that notificationID belongs to as read
+func (s *NotificationService) MarkRead(ctx context.Context, userID, notificationID string) error {
+	var groupKey string
+	err := database.QueryRowWithContext(ctx,
+		"SELECT group_key FROM public.notifications WHERE id::text = $1 AND user_id = $2", notificationID, userID,
+	).Scan(&groupKey)
+	if err == sql.ErrNoRows {
+		return ErrNotificationNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = database.ExecWithContext(ctx, `
+		UPDATE public.notifications SET read_at = $1
+		WHERE user_id = $2 AND group_key = $3 AND read_at IS NULL
+	`, time.Now().UTC(), userID, groupKey)
+	return err
+}
+
+// MarkAllRead marks every notification of the user as read
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID string) error {
+	_, err := database.ExecWithContext(ctx,
+		"UPDATE public.notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL",
+		time.Now().UTC(), userID)
+	return err
+}
+
+// actorsByID loads the public profile fields shown next to notifications
+func (s *NotificationService) actorsByID(ctx context.Context, ids []string) (map[string]*models.User, error) {
+	users := make(map[string]*models.User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	rows, err := database.QueryWithContext(ctx,
+		"SELECT id, name, handle, avatar, is_verified FROM public.users WHERE id::text = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		var handle, avatar sql.NullString
+		if err := rows.Scan(&user.ID, &user.Name, &handle, &avatar, &user.IsVerified); err != nil {
+			return nil, err
+		}
+		user.Handle = handle.String
+		user.Avatar = avatar.String
+		user.IsActive = true
+		users[user.ID] = &user
+	}
+	return users, rows.Err()
+}
+
+// notificationVerb describes what the actors did
+func notificationVerb(kind, groupKey string) string {
+	switch kind {
+	case models.NotificationLike:
+		if strings.HasPrefix(groupKey, "like:comment:") {
+			return "liked your comment"
+		}
+		return "liked your post"
+	case models.NotificationComment:
+		return "commented on your post"
+	case models.NotificationReply:
+		return "replied to your comment"
+	case models.NotificationMention:
+		return "mentioned you in a comment"
+	case models.NotificationFollow:
+		return "started following you"
+	}
+	return kind
+}
+
+// notificationMessage builds e.g. "Alice and 4 others liked your post"
+func notificationMessage(actors []*models.User, count int, verb string) string {
+	if len(actors) == 0 {
+		return "Someone " + verb
+	}
+	first := actors[0].Name
+	switch {
+	case count <= 1:
+		return fmt.Sprintf("%s %s", first, verb)
+	case count == 2 && len(actors) > 1:
+		return fmt.Sprintf("%s and %s %s", first, actors[1].Name, verb)
+	case count == 2:
+		return fmt.Sprintf("%s and 1 other %s", first, verb)
+	default:
+		return fmt.Sprintf("%s and %d others %s", first, count-1, verb)
+	}
+}
@@ -191,10 +191,16 @@ func (s *OAuthService) CreateOrUpdateUserFromOAuth(ctx context.Context, provider
 		user.Avatar = avatar.String
 	}
 
+	// Signing in during the grace period cancels a pending account deletion
+	if _, err := NewAccountService(s.db, s.cfg, nil, nil).CancelDeletion(ctx, user.ID); err != nil {
+		return nil, false, fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+
 	// Update user info
 	updateQuery := `
 		UPDATE public.users
-		SET name = $1, avatar = $2, updated_at = $3, is_verified = COALESCE(is_verified, $4)
+		SET name = $1, avatar = $2, updated_at = $3, is_verified = COALESCE(is_verified, $4),
+			email_verified_at = CASE WHEN $4 THEN COALESCE(email_verified_at, $3) ELSE email_verified_at END
 		WHERE id = $5
 	`
 	_, err = database.ExecWithContext(ctx, updateQuery,
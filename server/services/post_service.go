@@ -14,6 +14,7 @@ import (
 	"tech-bant-community/server/utils"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // PostService handles post operations
@@ -32,8 +33,26 @@ func NewPostServiceWithCache(db *sql.DB, cache *CacheService) *PostService {
 	return &PostService{db: db, cache: cache}
 }
 
-// CreatePost creates a new post in PostgreSQL
+// CreatePost creates a new post in PostgreSQL. Posts matching a hold
+// filter rule, new accounts' first posts and posts with links, and posts
+// the spam classifier scores high enough are queued for review and return
+// ErrContentHeld.
 func (s *PostService) CreatePost(ctx context.Context, userID string, req *models.CreatePostRequest) (*models.Post, error) {
+	verdict, err := filterContent(ctx, models.FilterTargetPost, postFilterFields(&req.Title, &req.Content, req.Tags)...)
+	if err != nil {
+		return nil, err
+	}
+	if verdict.Action == models.FilterActionHold {
+		return nil, holdContent(ctx, userID, models.QueueKindPost, "", req, verdict)
+	}
+	if err := premoderatePost(ctx, userID, models.QueueKindPost, "", req, req.Title, req.Content); err != nil {
+		return nil, err
+	}
+	spamScore, err := holdSpam(ctx, userID, models.QueueKindPost, "", req, req.Title, req.Content, strings.Join(req.Tags, " "))
+	if err != nil {
+		return nil, err
+	}
+
 	// Check for duplicate posts using content hash
 	contentHash := s.hashPostContent(userID, req.Title, req.Content)
 
@@ -43,7 +62,7 @@ func (s *PostService) CreatePost(ctx context.Context, userID string, req *models
 		LIMIT 1
 	`
 	var existingID string
-	err := database.QueryRowWithContext(ctx, duplicateQuery, userID, contentHash).Scan(&existingID)
+	err = database.QueryRowWithContext(ctx, duplicateQuery, userID, contentHash).Scan(&existingID)
 	if err == nil {
 		return nil, fmt.Errorf("duplicate post detected")
 	}
@@ -111,6 +130,17 @@ func (s *PostService) CreatePost(ctx context.Context, userID string, req *models
 		return nil, fmt.Errorf("failed to increment counter: %w", err)
 	}
 
+	if err := recordReputation(ctx, tx, userID, ReputationPostCreated, postID.String(), userID, now); err != nil {
+		return nil, fmt.Errorf("failed to record reputation: %w", err)
+	}
+
+	if err := flagContentTx(ctx, tx, "post", postID.String(), verdict); err != nil {
+		return nil, fmt.Errorf("failed to flag post: %w", err)
+	}
+	if err := flagSpamTx(ctx, tx, "post", postID.String(), spamScore); err != nil {
+		return nil, fmt.Errorf("failed to flag post: %w", err)
+	}
+
 	// Get media attachments if provided
 	if len(req.MediaIDs) > 0 {
 		post.Media = make([]models.MediaAttachment, 0, len(req.MediaIDs))
@@ -118,9 +148,11 @@ func (s *PostService) CreatePost(ctx context.Context, userID string, req *models
 			if !utils.ValidatePostID(mediaID) {
 				continue
 			}
-			mediaQuery := "SELECT id, user_id, post_id, url, type, size FROM public.media WHERE id = $1"
+			// Uploads still awaiting review can't be attached
+			mediaQuery := `SELECT id, type, url, COALESCE(name, ''), COALESCE(size, 0) FROM public.media
+				WHERE id = $1 AND user_id = $2 AND moderation_status = 'approved'`
 			var media models.MediaAttachment
-			err := tx.QueryRowContext(ctx, mediaQuery, mediaID).Scan(
+			err := tx.QueryRowContext(ctx, mediaQuery, mediaID, userID).Scan(
 				&media.ID, &media.Type, &media.URL, &media.Name, &media.Size,
 			)
 			if err == nil {
@@ -129,9 +161,30 @@ func (s *PostService) CreatePost(ctx context.Context, userID string, req *models
 		}
 	}
 
+	// Integrations never hear of a shadow-banned user's posts
+	shadowBanned, err := isShadowBanned(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check shadow ban: %w", err)
+	}
+	if !shadowBanned {
+		err = enqueueWebhookEvent(ctx, tx, models.WebhookEventPostCreated, map[string]interface{}{
+			"id":        post.ID,
+			"title":     post.Title,
+			"content":   post.Content,
+			"category":  post.Category,
+			"tags":      post.Tags,
+			"author":    webhookUser(user),
+			"createdAt": post.CreatedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to queue webhooks: %w", err)
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	refreshBadgesAsync(userID)
 
 	// Invalidate post list cache
 	if s.cache != nil {
@@ -148,7 +201,7 @@ func (s *PostService) CreatePost(ctx context.Context, userID string, req *models
 func (s *PostService) GetPost(ctx context.Context, postID string) (*models.Post, error) {
 	query := `
 		SELECT p.id, p.title, p.content, p.author_id, p.category, p.tags, p.likes, p.comments, p.views, p.shares, p.is_pinned, p.is_hot, p.location, p.published_at, p.created_at, p.updated_at,
-		       u.id, u.name, u.email, u.avatar, u.is_admin, u.is_verified
+		       u.id, u.name, u.handle, u.email, u.avatar, u.is_admin, u.is_verified
 		FROM public.posts p
 		JOIN public.users u ON p.author_id = u.id
 		WHERE p.id = $1
@@ -158,14 +211,14 @@ func (s *PostService) GetPost(ctx context.Context, postID string) (*models.Post,
 	var post models.Post
 	var author models.User
 	var location sql.NullString
-	var avatar sql.NullString
+	var avatar, handle sql.NullString
 
 	err := row.Scan(
 		&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.Category, &post.Tags,
 		&post.Likes, &post.Comments, &post.Views, &post.Shares,
 		&post.IsPinned, &post.IsHot, &location,
 		&post.PublishedAt, &post.CreatedAt, &post.UpdatedAt,
-		&author.ID, &author.Name, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
+		&author.ID, &author.Name, &handle, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
 	)
 	if err != nil {
 		return nil, err
@@ -177,6 +230,9 @@ func (s *PostService) GetPost(ctx context.Context, postID string) (*models.Post,
 	if avatar.Valid {
 		author.Avatar = avatar.String
 	}
+	if handle.Valid {
+		author.Handle = handle.String
+	}
 
 	post.Author = &author
 
@@ -188,8 +244,12 @@ func (s *PostService) GetPost(ctx context.Context, postID string) (*models.Post,
 	return &post, nil
 }
 
-// GetPosts gets posts with pagination (cached)
-func (s *PostService) GetPosts(ctx context.Context, limit, offset int) ([]*models.Post, error) {
+// GetPosts gets posts with pagination (cached).
+// Posts by users the viewer has blocked, muted or been blocked by, and by
+// shadow-banned users other than the viewer, are left out. The shared cache,
+// which never has shadow-banned posts, is only used when there is nothing
+// else to hide and the viewer isn't shadow-banned.
+func (s *PostService) GetPosts(ctx context.Context, viewerID string, limit, offset int) ([]*models.Post, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -200,8 +260,18 @@ func (s *PostService) GetPosts(ctx context.Context, limit, offset int) ([]*model
 		offset = 0
 	}
 
+	hidden, err := NewBlockService(s.db).HiddenUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	shadowBanned, err := isShadowBanned(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	useCache := s.cache != nil && len(hidden) == 0 && !shadowBanned
+
 	// Try cache first (first page only, no offset)
-	if s.cache != nil && offset == 0 {
+	if useCache && offset == 0 {
 		cacheKey := fmt.Sprintf("posts:list:%d", limit)
 		cached, err := s.cache.GetPosts(ctx, cacheKey)
 		if err == nil && cached != nil {
@@ -211,14 +281,15 @@ func (s *PostService) GetPosts(ctx context.Context, limit, offset int) ([]*model
 
 	query := `
 		SELECT p.id, p.title, p.content, p.author_id, p.category, p.tags, p.likes, p.comments, p.views, p.shares, p.is_pinned, p.is_hot, p.location, p.published_at, p.created_at, p.updated_at,
-		       u.id, u.name, u.email, u.avatar, u.is_admin, u.is_verified
+		       u.id, u.name, u.handle, u.email, u.avatar, u.is_admin, u.is_verified
 		FROM public.posts p
 		JOIN public.users u ON p.author_id = u.id
+		WHERE NOT (p.author_id::text = ANY($3)) AND NOT ` + hiddenByShadowBan("p.author_id", "$4") + `
 		ORDER BY p.created_at DESC
 		LIMIT $1 OFFSET $2
 	`
 
-	rows, err := database.QueryWithContext(ctx, query, limit, offset)
+	rows, err := database.QueryWithContext(ctx, query, limit, offset, pq.Array(hidden), viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -229,14 +300,14 @@ func (s *PostService) GetPosts(ctx context.Context, limit, offset int) ([]*model
 		var post models.Post
 		var author models.User
 		var location sql.NullString
-		var avatar sql.NullString
+		var avatar, handle sql.NullString
 
 		err := rows.Scan(
 			&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.Category, &post.Tags,
 			&post.Likes, &post.Comments, &post.Views, &post.Shares,
 			&post.IsPinned, &post.IsHot, &location,
 			&post.PublishedAt, &post.CreatedAt, &post.UpdatedAt,
-			&author.ID, &author.Name, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
+			&author.ID, &author.Name, &handle, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
 		)
 		if err != nil {
 			continue
@@ -248,13 +319,16 @@ func (s *PostService) GetPosts(ctx context.Context, limit, offset int) ([]*model
 		if avatar.Valid {
 			author.Avatar = avatar.String
 		}
+		if handle.Valid {
+			author.Handle = handle.String
+		}
 
 		post.Author = &author
 		posts = append(posts, &post)
 	}
 
 	// Cache first page for 30s
-	if s.cache != nil && offset == 0 && len(posts) > 0 {
+	if useCache && offset == 0 && len(posts) > 0 {
 		cacheKey := fmt.Sprintf("posts:list:%d", limit)
 		_ = s.cache.SetPosts(ctx, cacheKey, posts, 30*time.Second)
 	}
@@ -262,8 +336,9 @@ func (s *PostService) GetPosts(ctx context.Context, limit, offset int) ([]*model
 	return posts, nil
 }
 
-// GetPostsByCategory gets posts by category (cached)
-func (s *PostService) GetPostsByCategory(ctx context.Context, category string, limit, offset int) ([]*models.Post, error) {
+// GetPostsByCategory gets posts by category (cached), hiding blocked, muted
+// and shadow-banned authors as GetPosts does
+func (s *PostService) GetPostsByCategory(ctx context.Context, viewerID, category string, limit, offset int) ([]*models.Post, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -274,8 +349,18 @@ func (s *PostService) GetPostsByCategory(ctx context.Context, category string, l
 		offset = 0
 	}
 
+	hidden, err := NewBlockService(s.db).HiddenUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	shadowBanned, err := isShadowBanned(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	useCache := s.cache != nil && len(hidden) == 0 && !shadowBanned
+
 	// Try cache first (first page only, no offset)
-	if s.cache != nil && offset == 0 {
+	if useCache && offset == 0 {
 		cacheKey := fmt.Sprintf("posts:category:%s:%d", category, limit)
 		cached, err := s.cache.GetPosts(ctx, cacheKey)
 		if err == nil && cached != nil {
@@ -285,15 +370,15 @@ func (s *PostService) GetPostsByCategory(ctx context.Context, category string, l
 
 	query := `
 		SELECT p.id, p.title, p.content, p.author_id, p.category, p.tags, p.likes, p.comments, p.views, p.shares, p.is_pinned, p.is_hot, p.location, p.published_at, p.created_at, p.updated_at,
-		       u.id, u.name, u.email, u.avatar, u.is_admin, u.is_verified
+		       u.id, u.name, u.handle, u.email, u.avatar, u.is_admin, u.is_verified
 		FROM public.posts p
 		JOIN public.users u ON p.author_id = u.id
-		WHERE p.category = $1
+		WHERE p.category = $1 AND NOT (p.author_id::text = ANY($4)) AND NOT ` + hiddenByShadowBan("p.author_id", "$5") + `
 		ORDER BY p.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := database.QueryWithContext(ctx, query, category, limit, offset)
+	rows, err := database.QueryWithContext(ctx, query, category, limit, offset, pq.Array(hidden), viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -304,14 +389,14 @@ func (s *PostService) GetPostsByCategory(ctx context.Context, category string, l
 		var post models.Post
 		var author models.User
 		var location sql.NullString
-		var avatar sql.NullString
+		var avatar, handle sql.NullString
 
 		err := rows.Scan(
 			&post.ID, &post.Title, &post.Content, &post.AuthorID, &post.Category, &post.Tags,
 			&post.Likes, &post.Comments, &post.Views, &post.Shares,
 			&post.IsPinned, &post.IsHot, &location,
 			&post.PublishedAt, &post.CreatedAt, &post.UpdatedAt,
-			&author.ID, &author.Name, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
+			&author.ID, &author.Name, &handle, &author.Email, &avatar, &author.IsAdmin, &author.IsVerified,
 		)
 		if err != nil {
 			continue
@@ -323,13 +408,16 @@ func (s *PostService) GetPostsByCategory(ctx context.Context, category string, l
 		if avatar.Valid {
 			author.Avatar = avatar.String
 		}
+		if handle.Valid {
+			author.Handle = handle.String
+		}
 
 		post.Author = &author
 		posts = append(posts, &post)
 	}
 
 	// Cache first page for 30s
-	if s.cache != nil && offset == 0 && len(posts) > 0 {
+	if useCache && offset == 0 && len(posts) > 0 {
 		cacheKey := fmt.Sprintf("posts:category:%s:%d", category, limit)
 		_ = s.cache.SetPosts(ctx, cacheKey, posts, 30*time.Second)
 	}
@@ -337,7 +425,7 @@ func (s *PostService) GetPostsByCategory(ctx context.Context, category string, l
 	return posts, nil
 }
 
-// UpdatePost updates a post
+// UpdatePost updates a post. Like CreatePost, edits can be held for review.
 func (s *PostService) UpdatePost(ctx context.Context, userID, postID string, req *models.UpdatePostRequest) (*models.Post, error) {
 	// Verify ownership (lightweight check, no full post fetch)
 	ownerID, err := s.getPostOwnerID(ctx, postID)
@@ -348,6 +436,21 @@ func (s *PostService) UpdatePost(ctx context.Context, userID, postID string, req
 		return nil, fmt.Errorf("unauthorized")
 	}
 
+	verdict, err := filterContent(ctx, models.FilterTargetPost, postFilterFields(&req.Title, &req.Content, req.Tags)...)
+	if err != nil {
+		return nil, err
+	}
+	if verdict.Action == models.FilterActionHold {
+		return nil, holdContent(ctx, userID, models.QueueKindPostUpdate, postID, req, verdict)
+	}
+	if err := premoderatePost(ctx, userID, models.QueueKindPostUpdate, postID, req, req.Title, req.Content); err != nil {
+		return nil, err
+	}
+	spamScore, err := holdSpam(ctx, userID, models.QueueKindPostUpdate, postID, req, req.Title, req.Content, strings.Join(req.Tags, " "))
+	if err != nil {
+		return nil, err
+	}
+
 	updates := []string{"updated_at = $1"}
 	args := []interface{}{time.Now().UTC()}
 	argIndex := 2
@@ -388,6 +491,12 @@ func (s *PostService) UpdatePost(ctx context.Context, userID, postID string, req
 	if err != nil {
 		return nil, err
 	}
+	if err := flagContent(ctx, "post", postID, verdict); err != nil {
+		return nil, err
+	}
+	if err := flagSpam(ctx, "post", postID, spamScore); err != nil {
+		return nil, err
+	}
 
 	// Invalidate post list cache
 	if s.cache != nil {
@@ -397,6 +506,16 @@ func (s *PostService) UpdatePost(ctx context.Context, userID, postID string, req
 	return s.GetPost(ctx, postID)
 }
 
+// postFilterFields is what the filter rules check in a post. Tags are
+// masked in place in the slice.
+func postFilterFields(title, content *string, tags []string) []filterField {
+	fields := []filterField{{name: "title", value: title}, {name: "content", value: content}}
+	for i := range tags {
+		fields = append(fields, filterField{name: "tags", value: &tags[i]})
+	}
+	return fields
+}
+
 // DeletePost deletes a post
 func (s *PostService) DeletePost(ctx context.Context, userID, postID string) error {
 	// Verify ownership (lightweight check, no full post fetch)
@@ -415,34 +534,48 @@ func (s *PostService) DeletePost(ctx context.Context, userID, postID string) err
 	}
 	defer tx.Rollback()
 
-	// Delete post (CASCADE will handle related records)
-	_, err = tx.ExecContext(ctx, "DELETE FROM public.posts WHERE id = $1", postID)
-	if err != nil {
+	if err := deletePostTx(ctx, tx, postID, userID); err != nil {
 		return err
 	}
 
-	// Decrement user's posts count
-	_, err = tx.ExecContext(ctx, "UPDATE public.users SET posts_count = posts_count - 1 WHERE id = $1", userID)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
+	refreshBadgesAsync(userID)
 
-	// Decrement posts counter
-	_, err = tx.ExecContext(ctx, "UPDATE public.counters SET count = count - 1 WHERE collection_name = 'posts'")
+	// Invalidate post list cache
+	if s.cache != nil {
+		s.cache.InvalidatePosts(ctx)
+	}
+
+	return nil
+}
+
+// deletePostTx deletes a post, takes back the reputation it and its
+// comments earned, and updates the counters
+func deletePostTx(ctx context.Context, tx *sql.Tx, postID, authorID string) error {
+	// Take back reputation earned from the post and its comments
+	err := revokeReputation(ctx, tx,
+		"source_id = $1 OR source_id IN (SELECT id FROM public.comments WHERE post_id = $1)", postID)
 	if err != nil {
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
+	// Delete post (CASCADE will handle related records)
+	_, err = tx.ExecContext(ctx, "DELETE FROM public.posts WHERE id = $1", postID)
+	if err != nil {
 		return err
 	}
 
-	// Invalidate post list cache
-	if s.cache != nil {
-		s.cache.InvalidatePosts(ctx)
+	// Decrement user's posts count
+	_, err = tx.ExecContext(ctx, "UPDATE public.users SET posts_count = posts_count - 1 WHERE id = $1", authorID)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// Decrement posts counter
+	_, err = tx.ExecContext(ctx, "UPDATE public.counters SET count = count - 1 WHERE collection_name = 'posts'")
+	return err
 }
 
 // LikePost toggles like on a post
@@ -452,27 +585,44 @@ func (s *PostService) LikePost(ctx context.Context, userID, postID string) error
 	var likeID string
 	err := database.QueryRowWithContext(ctx, checkQuery, postID, userID).Scan(&likeID)
 
+	liked := err == nil
+
+	authorID, err := s.getPostOwnerID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
 	tx, err := database.BeginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if err == nil {
+	if liked {
 		// Unlike: delete like and decrement count
 		_, err = tx.ExecContext(ctx, "DELETE FROM public.likes WHERE id = $1", likeID)
 		if err != nil {
 			return err
 		}
 		_, err = tx.ExecContext(ctx, "UPDATE public.posts SET likes = likes - 1 WHERE id = $1", postID)
+		if err != nil {
+			return err
+		}
+		err = revokeReputation(ctx, tx, "event_type = $1 AND source_id = $2 AND actor_id = $3",
+			ReputationPostLikeReceived, postID, userID)
 	} else {
 		// Like: insert like and increment count
 		likeID := uuid.New()
-		_, err = tx.ExecContext(ctx, "INSERT INTO public.likes (id, post_id, user_id, created_at) VALUES ($1, $2, $3, $4)", likeID, postID, userID, time.Now().UTC())
+		now := time.Now().UTC()
+		_, err = tx.ExecContext(ctx, "INSERT INTO public.likes (id, post_id, user_id, created_at) VALUES ($1, $2, $3, $4)", likeID, postID, userID, now)
 		if err != nil {
 			return err
 		}
 		_, err = tx.ExecContext(ctx, "UPDATE public.posts SET likes = likes + 1 WHERE id = $1", postID)
+		if err != nil {
+			return err
+		}
+		err = recordReputation(ctx, tx, authorID, ReputationPostLikeReceived, postID, userID, now)
 	}
 
 	if err != nil {
@@ -482,6 +632,16 @@ func (s *PostService) LikePost(ctx context.Context, userID, postID string) error
 	if err := tx.Commit(); err != nil {
 		return err
 	}
+	refreshBadgesAsync(authorID)
+	if liked {
+		retractNotificationAsync(authorID, userID, likePostGroup(postID))
+	} else {
+		notifyAsync(notificationEvent{
+			recipientID: authorID, actorID: userID, kind: models.NotificationLike,
+			postID: postID, groupKey: likePostGroup(postID),
+		})
+	}
+	publishLikesAsync(postID, "")
 
 	// Invalidate post list cache
 	if s.cache != nil {
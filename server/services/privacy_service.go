@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidPrivacySetting = errors.New("invalid privacy setting")
+	ErrCommentsRestricted    = errors.New("the author has limited who can comment on this post")
+)
+
+// hiddenFromSearch returns a subquery of users the viewer may not find in
+// search: those who opted out, and those whose profile the viewer may not
+// see. viewerParam is the placeholder holding the viewer's ID (empty if anonymous).
+func hiddenFromSearch(viewerParam string) string {
+	return strings.ReplaceAll(`
+		SELECT p.user_id FROM public.user_privacy_settings p
+		WHERE p.user_id::text <> $viewer AND (
+			p.searchable = false
+			OR (p.profile_visibility = 'members' AND $viewer = '')
+			OR (p.profile_visibility = 'followers' AND NOT EXISTS(
+				SELECT 1 FROM public.follows f WHERE f.follower_id::text = $viewer AND f.following_id = p.user_id
+			))
+		)
+	`, "$viewer", viewerParam)
+}
+
+// PrivacyService reads and enforces per-user privacy settings
+type PrivacyService struct {
+	db *sql.DB
+}
+
+// NewPrivacyService creates a new PrivacyService instance
+func NewPrivacyService(db *sql.DB) *PrivacyService {
+	return &PrivacyService{db: db}
+}
+
+// defaultPrivacySettings applies to users who never saved their settings
+func defaultPrivacySettings() *models.PrivacySettings {
+	return &models.PrivacySettings{
+		ProfileVisibility: models.ProfileVisibilityPublic,
+		Searchable:        true,
+		CommentPermission: models.AudienceEveryone,
+		MentionPermission: models.AudienceEveryone,
+		MessagePermission: models.AudienceEveryone,
+		ShowLikes:         true,
+		ShowBookmarks:     false,
+	}
+}
+
+// GetSettings returns the user's privacy settings, or the defaults if none are saved
+func (s *PrivacyService) GetSettings(ctx context.Context, userID string) (*models.PrivacySettings, error) {
+	query := `
+		SELECT profile_visibility, searchable, comment_permission, mention_permission, message_permission, show_likes, show_bookmarks, updated_at
+		FROM public.user_privacy_settings
+		WHERE user_id = $1
+	`
+	var settings models.PrivacySettings
+	var updatedAt sql.NullTime
+	err := database.QueryRowWithContext(ctx, query, userID).Scan(
+		&settings.ProfileVisibility, &settings.Searchable, &settings.CommentPermission,
+		&settings.MentionPermission, &settings.MessagePermission, &settings.ShowLikes, &settings.ShowBookmarks, &updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return defaultPrivacySettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if updatedAt.Valid {
+		settings.UpdatedAt = updatedAt.Time
+	}
+	return &settings, nil
+}
+
+// UpdateSettings applies the set fields of req on top of the current settings
+func (s *PrivacyService) UpdateSettings(ctx context.Context, userID string, req *models.UpdatePrivacySettingsRequest) (*models.PrivacySettings, error) {
+	settings, err := s.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ProfileVisibility != nil {
+		switch *req.ProfileVisibility {
+		case models.ProfileVisibilityPublic, models.ProfileVisibilityMembers, models.ProfileVisibilityFollowers:
+			settings.ProfileVisibility = *req.ProfileVisibility
+		default:
+			return nil, ErrInvalidPrivacySetting
+		}
+	}
+	if req.CommentPermission != nil {
+		if !validAudience(*req.CommentPermission) {
+			return nil, ErrInvalidPrivacySetting
+		}
+		settings.CommentPermission = *req.CommentPermission
+	}
+	if req.MentionPermission != nil {
+		if !validAudience(*req.MentionPermission) {
+			return nil, ErrInvalidPrivacySetting
+		}
+		settings.MentionPermission = *req.MentionPermission
+	}
+	if req.MessagePermission != nil {
+		if !validAudience(*req.MessagePermission) {
+			return nil, ErrInvalidPrivacySetting
+		}
+		settings.MessagePermission = *req.MessagePermission
+	}
+	if req.Searchable != nil {
+		settings.Searchable = *req.Searchable
+	}
+	if req.ShowLikes != nil {
+		settings.ShowLikes = *req.ShowLikes
+	}
+	if req.ShowBookmarks != nil {
+		settings.ShowBookmarks = *req.ShowBookmarks
+	}
+	settings.UpdatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO public.user_privacy_settings
+			(user_id, profile_visibility, searchable, comment_permission, mention_permission, message_permission, show_likes, show_bookmarks, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id) DO UPDATE SET
+			profile_visibility = EXCLUDED.profile_visibility,
+			searchable = EXCLUDED.searchable,
+			comment_permission = EXCLUDED.comment_permission,
+			mention_permission = EXCLUDED.mention_permission,
+			message_permission = EXCLUDED.message_permission,
+			show_likes = EXCLUDED.show_likes,
+			show_bookmarks = EXCLUDED.show_bookmarks,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err = database.ExecWithContext(ctx, query,
+		userID, settings.ProfileVisibility, settings.Searchable, settings.CommentPermission,
+		settings.MentionPermission, settings.MessagePermission, settings.ShowLikes, settings.ShowBookmarks, settings.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// CanViewProfile reports whether viewerID (empty for anonymous) may see
+// ownerID's full profile and activity
+func (s *PrivacyService) CanViewProfile(ctx context.Context, viewerID, ownerID string) (bool, error) {
+	if viewerID == ownerID {
+		return true, nil
+	}
+	settings, err := s.GetSettings(ctx, ownerID)
+	if err != nil {
+		return false, err
+	}
+	return s.inAudience(ctx, settings.ProfileVisibility, viewerID, ownerID)
+}
+
+// CanComment reports whether userID may comment on a post by authorID
+func (s *PrivacyService) CanComment(ctx context.Context, userID, authorID string) (bool, error) {
+	if userID == authorID {
+		return true, nil
+	}
+	settings, err := s.GetSettings(ctx, authorID)
+	if err != nil {
+		return false, err
+	}
+	return s.inAudience(ctx, settings.CommentPermission, userID, authorID)
+}
+
+// CanMessage reports whether senderID may start a conversation with recipientID
+func (s *PrivacyService) CanMessage(ctx context.Context, senderID, recipientID string) (bool, error) {
+	settings, err := s.GetSettings(ctx, recipientID)
+	if err != nil {
+		return false, err
+	}
+	return s.inAudience(ctx, settings.MessagePermission, senderID, recipientID)
+}
+
+// FilterMentionable returns the subset of userIDs that authorID is allowed to mention
+func (s *PrivacyService) FilterMentionable(ctx context.Context, authorID string, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return userIDs, nil
+	}
+
+	query := `
+		SELECT p.user_id FROM public.user_privacy_settings p
+		WHERE p.user_id::text = ANY($2) AND p.user_id::text <> $1 AND (
+			p.mention_permission = 'nobody'
+			OR (p.mention_permission = 'followers' AND NOT EXISTS(
+				SELECT 1 FROM public.follows f WHERE f.follower_id::text = $1 AND f.following_id = p.user_id
+			))
+		)
+	`
+	rows, err := database.QueryWithContext(ctx, query, authorID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	denied := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		denied[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	allowed := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !denied[id] {
+			allowed = append(allowed, id)
+		}
+	}
+	return allowed, nil
+}
+
+// CanViewLikes reports whether viewerID may list the posts ownerID has liked
+func (s *PrivacyService) CanViewLikes(ctx context.Context, viewerID, ownerID string) (bool, error) {
+	return s.canViewActivity(ctx, viewerID, ownerID, func(p *models.PrivacySettings) bool { return p.ShowLikes })
+}
+
+// CanViewBookmarks reports whether viewerID may list the posts ownerID has bookmarked
+func (s *PrivacyService) CanViewBookmarks(ctx context.Context, viewerID, ownerID string) (bool, error) {
+	return s.canViewActivity(ctx, viewerID, ownerID, func(p *models.PrivacySettings) bool { return p.ShowBookmarks })
+}
+
+// canViewActivity requires both profile access and the owner's opt-in via shown
+func (s *PrivacyService) canViewActivity(ctx context.Context, viewerID, ownerID string, shown func(*models.PrivacySettings) bool) (bool, error) {
+	if viewerID == ownerID {
+		return true, nil
+	}
+	settings, err := s.GetSettings(ctx, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if !shown(settings) {
+		return false, nil
+	}
+	return s.inAudience(ctx, settings.ProfileVisibility, viewerID, ownerID)
+}
+
+// inAudience reports whether viewerID falls within a visibility or
+// permission value set by ownerID
+func (s *PrivacyService) inAudience(ctx context.Context, audience, viewerID, ownerID string) (bool, error) {
+	switch audience {
+	case models.ProfileVisibilityPublic, models.AudienceEveryone:
+		return true, nil
+	case models.ProfileVisibilityMembers:
+		return viewerID != "", nil
+	case models.AudienceFollowers:
+		if viewerID == "" {
+			return false, nil
+		}
+		return NewFollowService().IsFollowing(ctx, viewerID, ownerID)
+	default:
+		return false, nil
+	}
+}
+
+func validAudience(audience string) bool {
+	switch audience {
+	case models.AudienceEveryone, models.AudienceFollowers, models.AudienceNobody:
+		return true
+	}
+	return false
+}
@@ -45,14 +45,21 @@ type EndpointLimit struct {
 
 // Default endpoint limits
 var DefaultLimits = map[string]EndpointLimit{
-	"/api/v1/auth/login":           {Requests: 5, Window: 15 * time.Minute, Burst: 3},
-	"/api/v1/auth/signup":          {Requests: 3, Window: 1 * time.Hour, Burst: 2},
-	"/api/v1/auth/refresh":         {Requests: 10, Window: 1 * time.Minute, Burst: 5},
-	"/api/v1/auth/change-password": {Requests: 5, Window: 1 * time.Hour, Burst: 2}, // FIXED: Issue #21
-	"/api/v1/posts":                {Requests: 100, Window: 1 * time.Minute, Burst: 20},
-	"/api/v1/posts/{id}/like":      {Requests: 30, Window: 1 * time.Minute, Burst: 10},
-	"/api/v1/media/upload":         {Requests: 10, Window: 1 * time.Minute, Burst: 3},
-	"/api/v1/admin":                {Requests: 200, Window: 1 * time.Minute, Burst: 50},
+	"/api/v1/auth/login":                  {Requests: 5, Window: 15 * time.Minute, Burst: 3},
+	"/api/v1/auth/signup":                 {Requests: 3, Window: 1 * time.Hour, Burst: 2},
+	"/api/v1/auth/refresh":                {Requests: 10, Window: 1 * time.Minute, Burst: 5},
+	"/api/v1/auth/change-password":        {Requests: 5, Window: 1 * time.Hour, Burst: 2}, // FIXED: Issue #21
+	"/api/v1/auth/verify-email/resend":    {Requests: 3, Window: 1 * time.Hour, Burst: 1},
+	"/api/v1/posts":                       {Requests: 100, Window: 1 * time.Minute, Burst: 20},
+	"/api/v1/posts/{id}/like":             {Requests: 30, Window: 1 * time.Minute, Burst: 10},
+	"/api/v1/stream":                      {Requests: 20, Window: 1 * time.Minute, Burst: 5},
+	"/api/v1/media/upload":                {Requests: 10, Window: 1 * time.Minute, Burst: 3},
+	"/api/v1/users/{id}/follow":           {Requests: 30, Window: 1 * time.Minute, Burst: 10},
+	"/api/v1/conversations/{id}/messages": {Requests: 30, Window: 1 * time.Minute, Burst: 10}, // Also applied per user in main.go
+	"/api/v1/users/me/export":             {Requests: 3, Window: 1 * time.Hour, Burst: 1},
+	"/api/v1/users/me/email":              {Requests: 3, Window: 1 * time.Hour, Burst: 1},
+	"/api/v1/users/me/email/confirm":      {Requests: 5, Window: 15 * time.Minute, Burst: 2},
+	"/api/v1/admin":                       {Requests: 200, Window: 1 * time.Minute, Burst: 50},
 }
 
 // CheckRateLimit checks if request is within rate limit
@@ -0,0 +1,506 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReportNotFound         = errors.New("report not found")
+	ErrReportTargetNotFound   = errors.New("the reported content doesn't exist")
+	ErrAlreadyReported        = errors.New("you have already reported this")
+	ErrCannotReportOwnContent = errors.New("you can't report your own content")
+	ErrReportClosed           = errors.New("this report has already been resolved or dismissed")
+	ErrInvalidReportReason    = fmt.Errorf("a reason of at most %d characters is required", constants.MaxReportReasonLength)
+	ErrInvalidReportStatus    = errors.New("status must be 'reviewed', 'resolved' or 'dismissed'")
+	ErrInvalidReportAction    = errors.New("actions must be 'remove', 'warn' or 'suspend', and only apply to resolved reports")
+	ErrInvalidReportSpam      = errors.New("only reports resolved with the remove action can be marked as spam")
+	ErrInvalidReportNote      = fmt.Errorf("notes and summaries are at most %d characters", constants.MaxReportNoteLength)
+)
+
+// ReportService handles content reporting and moderation of reports
+type ReportService struct {
+	emailService *EmailService
+	cache        *CacheService
+}
+
+func NewReportService(emailService *EmailService, cache *CacheService) *ReportService {
+	return &ReportService{emailService: emailService, cache: cache}
+}
+
+// ReportPost creates a report for a post
+func (s *ReportService) ReportPost(ctx context.Context, reporterID, postID, reason string) error {
+	return s.createReport(ctx, reporterID, "post", postID, reason)
+}
+
+// ReportComment creates a report for a comment
+func (s *ReportService) ReportComment(ctx context.Context, reporterID, commentID, reason string) error {
+	return s.createReport(ctx, reporterID, "comment", commentID, reason)
+}
+
+// createReport stores a pending report with a snapshot of the post or
+// comment and queues the report.created webhook. Each user can report a
+// post or comment once.
+func (s *ReportService) createReport(ctx context.Context, reporterID, targetType, targetID, reason string) error {
+	reason = strings.TrimSpace(utils.SanitizeHTML(reason))
+	if !utils.ValidateLength(reason, 1, constants.MaxReportReasonLength) {
+		return ErrInvalidReportReason
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	authorID, snapshot, err := snapshotReportTarget(ctx, tx, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	if authorID == reporterID {
+		return ErrCannotReportOwnContent
+	}
+
+	targetColumn := "post_id"
+	if targetType == "comment" {
+		targetColumn = "comment_id"
+	}
+	now := time.Now().UTC()
+	var reportID string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.reports (reporter_id, `+targetColumn+`, target_type, target_id, reason, status, snapshot, created_at, updated_at)
+		VALUES ($1, $2, $3, $2, $4, 'pending', $5, $6, $6)
+		ON CONFLICT (reporter_id, target_type, target_id) DO NOTHING
+		RETURNING id
+	`, reporterID, targetID, targetType, reason, string(snapshot), now).Scan(&reportID)
+	if err == sql.ErrNoRows {
+		return ErrAlreadyReported
+	}
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"id":         reportID,
+		"reporterId": reporterID,
+		"reason":     reason,
+		"createdAt":  now,
+	}
+	if targetType == "post" {
+		data["postId"] = targetID
+	} else {
+		data["commentId"] = targetID
+	}
+	if err := enqueueWebhookEvent(ctx, tx, models.WebhookEventReportCreated, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// snapshotReportTarget returns the author and a JSON copy of the reported
+// post or comment
+func snapshotReportTarget(ctx context.Context, tx *sql.Tx, targetType, targetID string) (string, []byte, error) {
+	snapshot := map[string]interface{}{"type": targetType}
+	var authorID, authorName, content string
+	var authorHandle sql.NullString
+	var createdAt, updatedAt time.Time
+	var err error
+	if targetType == "post" {
+		var title, category string
+		var tags, media []string
+		err = tx.QueryRowContext(ctx, `
+			SELECT p.author_id, u.name, u.handle, p.title, p.content, p.category, COALESCE(p.tags, '{}'),
+				ARRAY(SELECT m.url FROM public.media m WHERE m.post_id = p.id ORDER BY m.created_at),
+				p.created_at, p.updated_at
+			FROM public.posts p
+			JOIN public.users u ON u.id = p.author_id
+			WHERE p.id::text = $1
+		`, targetID).Scan(&authorID, &authorName, &authorHandle, &title, &content, &category,
+			pq.Array(&tags), pq.Array(&media), &createdAt, &updatedAt)
+		snapshot["title"] = title
+		snapshot["category"] = category
+		snapshot["tags"] = tags
+		snapshot["media"] = media
+	} else {
+		var postID string
+		err = tx.QueryRowContext(ctx, `
+			SELECT c.author_id, u.name, u.handle, c.post_id, c.content, c.created_at, c.updated_at
+			FROM public.comments c
+			JOIN public.users u ON u.id = c.author_id
+			WHERE c.id::text = $1
+		`, targetID).Scan(&authorID, &authorName, &authorHandle, &postID, &content, &createdAt, &updatedAt)
+		snapshot["postId"] = postID
+	}
+	if err == sql.ErrNoRows {
+		return "", nil, ErrReportTargetNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	snapshot["authorId"] = authorID
+	snapshot["authorName"] = authorName
+	snapshot["authorHandle"] = authorHandle.String
+	snapshot["content"] = content
+	snapshot["createdAt"] = createdAt
+	snapshot["updatedAt"] = updatedAt
+	data, err := json.Marshal(snapshot)
+	return authorID, data, err
+}
+
+const reportColumns = `
+	id, reporter_id, target_type, target_id, post_id, comment_id, reason, status, snapshot, actions,
+	COALESCE(admin_notes, ''), COALESCE(resolution_summary, ''), spam, created_at,
+	reviewed_at, reviewed_by, resolved_at, resolved_by, reporter_notified_at
+`
+
+func scanReport(row rowScanner) (*models.Report, error) {
+	var report models.Report
+	var reporterID, postID, commentID, reviewedBy, resolvedBy sql.NullString
+	var snapshot []byte
+	var reviewedAt, resolvedAt, notifiedAt sql.NullTime
+	actions := []string{}
+	err := row.Scan(
+		&report.ID, &reporterID, &report.TargetType, &report.TargetID, &postID, &commentID,
+		&report.Reason, &report.Status, &snapshot, pq.Array(&actions),
+		&report.AdminNotes, &report.ResolutionSummary, &report.Spam, &report.CreatedAt,
+		&reviewedAt, &reviewedBy, &resolvedAt, &resolvedBy, &notifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ReporterID = reporterID.String
+	report.PostID = postID.String
+	report.CommentID = commentID.String
+	report.Snapshot = snapshot
+	report.Actions = actions
+	report.ReviewedBy = reviewedBy.String
+	report.ResolvedBy = resolvedBy.String
+	if reviewedAt.Valid {
+		report.ReviewedAt = &reviewedAt.Time
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	if notifiedAt.Valid {
+		report.ReporterNotifiedAt = &notifiedAt.Time
+	}
+	return &report, nil
+}
+
+// GetReports gets all reports (admin only)
+func (s *ReportService) GetReports(ctx context.Context, limit, offset int, status string) ([]*models.Report, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := `
+		SELECT ` + reportColumns + `
+		FROM public.reports
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := database.QueryWithContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*models.Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// GetReport gets a single report (admin only)
+func (s *ReportService) GetReport(ctx context.Context, reportID string) (*models.Report, error) {
+	report, err := scanReport(database.QueryRowWithContext(ctx,
+		"SELECT "+reportColumns+" FROM public.reports WHERE id::text = $1", reportID))
+	if err == sql.ErrNoRows {
+		return nil, ErrReportNotFound
+	}
+	return report, err
+}
+
+// ResolveReport moves a report along its workflow (admin only). Marking it
+// reviewed only records that a moderator picked it up. Resolving or
+// dismissing it closes every open report on the same content, takes the
+// requested actions against the author, and emails the reporters and, if
+// actions were taken, the author.
+func (s *ReportService) ResolveReport(ctx context.Context, reportID, adminID string, req *models.ResolveReportRequest) (*models.Report, error) {
+	switch req.Status {
+	case models.ReportStatusReviewed, models.ReportStatusResolved, models.ReportStatusDismissed:
+	default:
+		return nil, ErrInvalidReportStatus
+	}
+	actions := uniqueStrings(req.Actions)
+	removes := false
+	for _, action := range actions {
+		switch action {
+		case models.ReportActionRemove:
+			removes = true
+		case models.ReportActionWarn, models.ReportActionSuspend:
+		default:
+			return nil, ErrInvalidReportAction
+		}
+	}
+	if len(actions) > 0 && req.Status != models.ReportStatusResolved {
+		return nil, ErrInvalidReportAction
+	}
+	// Spam tags train the spam classifier, so they only go on content that
+	// was removed
+	if req.Spam && !removes {
+		return nil, ErrInvalidReportSpam
+	}
+	suspensionScope, suspensionExpiry, err := validateSuspension(req.SuspensionScope, req.SuspensionHours)
+	if err != nil {
+		return nil, err
+	}
+	notes := strings.TrimSpace(req.AdminNotes)
+	summary := strings.TrimSpace(utils.SanitizeHTML(req.Summary))
+	if !utils.ValidateLength(notes, 0, constants.MaxReportNoteLength) || !utils.ValidateLength(summary, 0, constants.MaxReportNoteLength) {
+		return nil, ErrInvalidReportNote
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status, targetType, targetID string
+	var postID, commentID, authorID sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT r.status, r.target_type, r.target_id, r.post_id, r.comment_id,
+			COALESCE(p.author_id::text, c.author_id::text, r.snapshot->>'authorId')
+		FROM public.reports r
+		LEFT JOIN public.posts p ON p.id = r.post_id
+		LEFT JOIN public.comments c ON c.id = r.comment_id
+		WHERE r.id::text = $1
+		FOR UPDATE OF r
+	`, reportID).Scan(&status, &targetType, &targetID, &postID, &commentID, &authorID)
+	if err == sql.ErrNoRows {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == models.ReportStatusResolved || status == models.ReportStatusDismissed {
+		return nil, ErrReportClosed
+	}
+
+	now := time.Now().UTC()
+	if req.Status == models.ReportStatusReviewed {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.reports
+			SET status = 'reviewed', reviewed_at = $2, reviewed_by = $3,
+				admin_notes = COALESCE(NULLIF($4, ''), admin_notes), updated_at = $2
+			WHERE id::text = $1
+		`, reportID, now, adminID, notes)
+		if err != nil {
+			return nil, err
+		}
+		after := map[string]interface{}{"status": req.Status, "adminNotes": notes}
+		if err := recordAudit(ctx, tx, models.AuditActionReportReview, "report", reportID, map[string]interface{}{"status": status}, after); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return s.GetReport(ctx, reportID)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE public.reports
+		SET status = $3, reviewed_at = COALESCE(reviewed_at, $4), reviewed_by = COALESCE(reviewed_by, $5),
+			resolved_at = $4, resolved_by = $5, actions = $6,
+			admin_notes = COALESCE(NULLIF($7, ''), admin_notes), resolution_summary = NULLIF($8, ''), spam = $9, updated_at = $4
+		WHERE target_type = $1 AND target_id = $2 AND status IN ('pending', 'reviewed')
+		RETURNING id
+	`, targetType, targetID, req.Status, now, adminID, pq.Array(actions), notes, summary, req.Spam)
+	if err != nil {
+		return nil, err
+	}
+	var closedIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		closedIDs = append(closedIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// An upheld report costs the reported content's author reputation
+	removedPost := false
+	var suspension *models.Suspension
+	if req.Status == models.ReportStatusResolved && authorID.Valid {
+		if err := recordReputation(ctx, tx, authorID.String, ReputationReportUpheld, reportID, adminID, now); err != nil {
+			return nil, err
+		}
+		for _, action := range actions {
+			switch action {
+			case models.ReportActionRemove:
+				if removedPost, err = removeReportedContent(ctx, tx, postID.String, commentID.String, authorID.String); err != nil {
+					return nil, err
+				}
+			case models.ReportActionSuspend:
+				reason := summary
+				if reason == "" {
+					reason = "Your " + targetType + " was reported and breaks the community guidelines"
+				}
+				if _, suspension, err = suspendUserTx(ctx, tx, authorID.String, suspensionScope, reason, suspensionExpiry, adminID, reportID, now); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	action := models.AuditActionReportDismiss
+	if req.Status == models.ReportStatusResolved {
+		action = models.AuditActionReportResolve
+	}
+	after := map[string]interface{}{
+		"status":        req.Status,
+		"actions":       actions,
+		"spam":          req.Spam,
+		"adminNotes":    notes,
+		"summary":       summary,
+		"closedReports": closedIDs,
+		"targetType":    targetType,
+		"targetId":      targetID,
+		"suspension":    suspension,
+	}
+	if err := recordAudit(ctx, tx, action, "report", reportID, map[string]interface{}{"status": status}, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if req.Status == models.ReportStatusResolved {
+		refreshBadgesAsync(authorID.String)
+	}
+	if suspension != nil {
+		invalidateSuspension(authorID.String)
+	}
+	if removedPost && s.cache != nil {
+		s.cache.InvalidatePosts(ctx)
+	}
+	s.notifyOutcomeAsync(closedIDs, authorID.String, targetType, req.Status, actions, summary)
+
+	return s.GetReport(ctx, reportID)
+}
+
+// removeReportedContent deletes the reported post or comment if it still
+// exists, and reports whether a post was deleted
+func removeReportedContent(ctx context.Context, tx *sql.Tx, postID, commentID, authorID string) (bool, error) {
+	if postID != "" {
+		return true, deletePostTx(ctx, tx, postID, authorID)
+	}
+	if commentID == "" {
+		return false, nil
+	}
+
+	var commentPostID string
+	err := tx.QueryRowContext(ctx, "SELECT post_id FROM public.comments WHERE id = $1", commentID).Scan(&commentPostID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, deleteCommentTx(ctx, tx, commentID, commentPostID)
+}
+
+// notifyOutcomeAsync emails the reporters of closed reports, recording when
+// each was told, and the author if actions were taken against them
+func (s *ReportService) notifyOutcomeAsync(reportIDs []string, authorID, targetType, status string, actions []string, summary string) {
+	if s.emailService == nil || len(reportIDs) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		upheld := status == models.ReportStatusResolved
+		rows, err := database.QueryWithContext(ctx, `
+			SELECT r.id, u.email FROM public.reports r
+			JOIN public.users u ON u.id = r.reporter_id AND u.is_active = true
+			WHERE r.id::text = ANY($1) AND r.reporter_notified_at IS NULL
+		`, pq.Array(reportIDs))
+		if err != nil {
+			log.Printf("Failed to load reporters to notify: %v", err)
+			return
+		}
+		type reporter struct{ reportID, email string }
+		var reporters []reporter
+		for rows.Next() {
+			var r reporter
+			if err := rows.Scan(&r.reportID, &r.email); err != nil {
+				log.Printf("Failed to load reporters to notify: %v", err)
+				break
+			}
+			reporters = append(reporters, r)
+		}
+		rows.Close()
+
+		for _, r := range reporters {
+			if err := s.emailService.SendReportOutcomeEmail(ctx, r.email, targetType, upheld, summary); err != nil {
+				log.Printf("Failed to email outcome of report %s: %v", r.reportID, err)
+				continue
+			}
+			_, err := database.ExecWithContext(ctx,
+				"UPDATE public.reports SET reporter_notified_at = $1 WHERE id = $2", time.Now().UTC(), r.reportID)
+			if err != nil {
+				log.Printf("Failed to record notification of report %s: %v", r.reportID, err)
+			}
+		}
+
+		if !upheld || len(actions) == 0 || authorID == "" {
+			return
+		}
+		var email string
+		err = database.QueryRowWithContext(ctx, "SELECT email FROM public.users WHERE id = $1", authorID).Scan(&email)
+		if err != nil {
+			log.Printf("Failed to load author %s to notify: %v", authorID, err)
+			return
+		}
+		if err := s.emailService.SendModerationNoticeEmail(ctx, email, targetType, actions, summary); err != nil {
+			log.Printf("Failed to email moderation notice to user %s: %v", authorID, err)
+		}
+	}()
+}
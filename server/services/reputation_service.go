@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/lib/pq"
+)
+
+// Reputation event types
+const (
+	ReputationPostCreated         = "post_created"
+	ReputationCommentCreated      = "comment_created"
+	ReputationPostLikeReceived    = "post_like_received"
+	ReputationCommentLikeReceived = "comment_like_received"
+	ReputationAnswerAccepted      = "answer_accepted"
+	ReputationFollowerGained      = "follower_gained"
+	ReputationReportUpheld        = "report_upheld"
+)
+
+// reputationRule says what an event is worth. Events caused by others
+// (likes, follows, accepted answers) never count when the actor is the
+// user themselves.
+type reputationRule struct {
+	points     int
+	sourceType string
+	fromOthers bool
+}
+
+var reputationRules = map[string]reputationRule{
+	ReputationPostCreated:         {points: 2, sourceType: "post"},
+	ReputationCommentCreated:      {points: 1, sourceType: "comment"},
+	ReputationPostLikeReceived:    {points: 5, sourceType: "post", fromOthers: true},
+	ReputationCommentLikeReceived: {points: 2, sourceType: "comment", fromOthers: true},
+	ReputationAnswerAccepted:      {points: 15, sourceType: "comment", fromOthers: true},
+	ReputationFollowerGained:      {points: 1, sourceType: "user", fromOthers: true},
+	ReputationReportUpheld:        {points: -25, sourceType: "report", fromOthers: true},
+}
+
+// badgeRule awards a badge while condition (given the user ID as $1) is true
+type badgeRule struct {
+	badge     models.Badge
+	condition string
+}
+
+var badgeRules = []badgeRule{
+	{
+		badge:     models.Badge{ID: "first_post", Name: "First Post", Description: "Published a first post"},
+		condition: "SELECT EXISTS(SELECT 1 FROM public.posts WHERE author_id = $1)",
+	},
+	{
+		badge:     models.Badge{ID: "first_comment", Name: "Conversation Starter", Description: "Left a first comment"},
+		condition: "SELECT EXISTS(SELECT 1 FROM public.comments WHERE author_id = $1)",
+	},
+	{
+		badge: models.Badge{ID: "likes_100", Name: "100 Likes", Description: "Received 100 likes on posts and comments"},
+		condition: `SELECT COUNT(*) >= 100 FROM public.reputation_events
+			WHERE user_id = $1 AND event_type IN ('post_like_received', 'comment_like_received')`,
+	},
+	{
+		badge: models.Badge{ID: "problem_solver", Name: "Problem Solver", Description: "Had an answer accepted"},
+		condition: `SELECT EXISTS(SELECT 1 FROM public.reputation_events
+			WHERE user_id = $1 AND event_type = 'answer_accepted')`,
+	},
+	{
+		badge: models.Badge{ID: "helpful_answerer", Name: "Helpful Answerer", Description: "Had 10 answers accepted"},
+		condition: `SELECT COUNT(*) >= 10 FROM public.reputation_events
+			WHERE user_id = $1 AND event_type = 'answer_accepted'`,
+	},
+	{
+		badge:     models.Badge{ID: "popular", Name: "Popular", Description: "Reached 100 followers"},
+		condition: "SELECT COALESCE((SELECT followers_count >= 100 FROM public.users WHERE id = $1), false)",
+	},
+	{
+		badge:     models.Badge{ID: "trusted_contributor", Name: "Trusted Contributor", Description: "Earned 1,000 reputation"},
+		condition: "SELECT COALESCE((SELECT reputation >= 1000 FROM public.users WHERE id = $1), false)",
+	},
+}
+
+var ErrInvalidPeriod = errors.New("period must be 'week', 'month' or 'all'")
+
+const reputationRecomputeBatch = 500
+
+// ReputationService serves reputation, badges and the leaderboard. Events
+// are written by the services that own the underlying actions through
+// recordReputation/revokeReputation, inside their own transactions.
+type ReputationService struct {
+	db *sql.DB
+}
+
+// NewReputationService creates a new ReputationService instance
+func NewReputationService(db *sql.DB) *ReputationService {
+	return &ReputationService{db: db}
+}
+
+// recordReputation adds an event to the ledger and the user's cached total.
+// Recording the same event twice is a no-op.
+func recordReputation(ctx context.Context, tx *sql.Tx, userID, eventType, sourceID, actorID string, at time.Time) error {
+	rule, ok := reputationRules[eventType]
+	if !ok {
+		return fmt.Errorf("unknown reputation event %q", eventType)
+	}
+	if userID == "" || (rule.fromOthers && actorID == userID) {
+		return nil
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO public.reputation_events (user_id, event_type, points, source_type, source_id, actor_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, event_type, source_id, actor_id) DO NOTHING
+	`, userID, eventType, rule.points, rule.sourceType, sourceID, actorID, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE public.users SET reputation = reputation + $1 WHERE id = $2", rule.points, userID)
+	return err
+}
+
+// revokeReputation removes ledger events matching where (a condition on
+// reputation_events) and takes their points back off each user's total
+func revokeReputation(ctx context.Context, tx *sql.Tx, where string, args ...interface{}) error {
+	_, err := tx.ExecContext(ctx, `
+		WITH removed AS (
+			DELETE FROM public.reputation_events WHERE `+where+`
+			RETURNING user_id, points
+		)
+		UPDATE public.users u SET reputation = u.reputation - r.total
+		FROM (SELECT user_id, SUM(points) AS total FROM removed GROUP BY user_id) r
+		WHERE u.id = r.user_id
+	`, args...)
+	return err
+}
+
+// refreshBadges awards badges the user now qualifies for and takes back
+// ones whose rule no longer holds (e.g. after content was removed)
+func refreshBadges(ctx context.Context, userID string) error {
+	for _, rule := range badgeRules {
+		var qualifies bool
+		if err := database.QueryRowWithContext(ctx, rule.condition, userID).Scan(&qualifies); err != nil {
+			return fmt.Errorf("badge %s: %w", rule.badge.ID, err)
+		}
+
+		var err error
+		if qualifies {
+			_, err = database.ExecWithContext(ctx, `
+				INSERT INTO public.user_badges (user_id, badge_id, awarded_at) VALUES ($1, $2, $3)
+				ON CONFLICT (user_id, badge_id) DO NOTHING
+			`, userID, rule.badge.ID, time.Now().UTC())
+		} else {
+			_, err = database.ExecWithContext(ctx,
+				"DELETE FROM public.user_badges WHERE user_id = $1 AND badge_id = $2", userID, rule.badge.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshBadgesAsync re-evaluates badges after a reputation change without
+// holding up the request that caused it
+func refreshBadgesAsync(userIDs ...string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, id := range userIDs {
+			if id == "" {
+				continue
+			}
+			if err := refreshBadges(ctx, id); err != nil {
+				log.Printf("Failed to refresh badges for user %s: %v", id, err)
+			}
+		}
+	}()
+}
+
+// GetBadges returns the badges a user has earned, oldest first
+func (s *ReputationService) GetBadges(ctx context.Context, userID string) ([]models.Badge, error) {
+	rows, err := database.QueryWithContext(ctx,
+		"SELECT badge_id, awarded_at FROM public.user_badges WHERE user_id = $1 ORDER BY awarded_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	badges := []models.Badge{}
+	for rows.Next() {
+		var id string
+		var awardedAt time.Time
+		if err := rows.Scan(&id, &awardedAt); err != nil {
+			return nil, err
+		}
+		for _, rule := range badgeRules {
+			if rule.badge.ID == id {
+				badge := rule.badge
+				badge.AwardedAt = &awardedAt
+				badges = append(badges, badge)
+				break
+			}
+		}
+	}
+
+	return badges, rows.Err()
+}
+
+// GetLeaderboard ranks users by reputation earned in the period (week,
+// month or all time), leaving out users blocked either way by the viewer
+func (s *ReputationService) GetLeaderboard(ctx context.Context, viewerID, period string, limit int) ([]*models.LeaderboardEntry, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var since time.Time
+	switch period {
+	case "", "all":
+	case "week":
+		since = time.Now().UTC().AddDate(0, 0, -7)
+	case "month":
+		since = time.Now().UTC().AddDate(0, -1, 0)
+	default:
+		return nil, ErrInvalidPeriod
+	}
+
+	blocked, err := NewBlockService(s.db).BlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	if since.IsZero() {
+		rows, err = database.QueryWithContext(ctx, `
+			SELECT u.id, u.name, u.handle, u.avatar, u.is_verified, u.reputation
+			FROM public.users u
+			WHERE u.is_active = true AND u.deleted_at IS NULL AND u.reputation > 0
+				AND NOT (u.id::text = ANY($2)) AND NOT `+hiddenByShadowBan("u.id", "$3")+`
+			ORDER BY u.reputation DESC, u.id
+			LIMIT $1
+		`, limit, pq.Array(blocked), viewerID)
+	} else {
+		rows, err = database.QueryWithContext(ctx, `
+			SELECT u.id, u.name, u.handle, u.avatar, u.is_verified, e.points
+			FROM (
+				SELECT user_id, SUM(points) AS points FROM public.reputation_events
+				WHERE created_at >= $3
+				GROUP BY user_id
+			) e
+			JOIN public.users u ON u.id = e.user_id
+			WHERE u.is_active = true AND u.deleted_at IS NULL AND e.points > 0
+				AND NOT (u.id::text = ANY($2)) AND NOT `+hiddenByShadowBan("u.id", "$4")+`
+			ORDER BY e.points DESC, u.id
+			LIMIT $1
+		`, limit, pq.Array(blocked), since, viewerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.LeaderboardEntry{}
+	for rows.Next() {
+		var user models.User
+		var entry models.LeaderboardEntry
+		var handle, avatar sql.NullString
+		if err := rows.Scan(&user.ID, &user.Name, &handle, &avatar, &user.IsVerified, &entry.Points); err != nil {
+			return nil, err
+		}
+		if handle.Valid {
+			user.Handle = handle.String
+		}
+		if avatar.Valid {
+			user.Avatar = avatar.String
+		}
+		user.IsActive = true
+		entry.Rank = len(entries) + 1
+		entry.User = &user
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		badges, err := s.GetBadges(ctx, entry.User.ID)
+		if err == nil && len(badges) > 0 {
+			entry.User.Badges = badges
+		}
+	}
+
+	return entries, nil
+}
+
+// Recompute rebuilds the ledger from the source tables and resets every
+// user's total, then re-evaluates badges. Upheld reports are kept as
+// recorded since the reported content may since have been removed.
+func (s *ReputationService) Recompute(ctx context.Context) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM public.reputation_events WHERE event_type <> $1", ReputationReportUpheld); err != nil {
+		return err
+	}
+
+	sources := []struct {
+		eventType string
+		query     string // selects user_id, source_id, actor_id, created_at
+	}{
+		{ReputationPostCreated, `SELECT author_id, id, author_id, created_at FROM public.posts`},
+		{ReputationCommentCreated, `SELECT author_id, id, author_id, created_at FROM public.comments`},
+		{ReputationPostLikeReceived, `
+			SELECT p.author_id, p.id, l.user_id, l.created_at
+			FROM public.likes l JOIN public.posts p ON p.id = l.post_id`},
+		{ReputationCommentLikeReceived, `
+			SELECT c.author_id, c.id, l.user_id, l.created_at
+			FROM public.likes l JOIN public.comments c ON c.id = l.comment_id`},
+		{ReputationAnswerAccepted, `
+			SELECT c.author_id, c.id, p.author_id, COALESCE(p.accepted_at, c.created_at)
+			FROM public.posts p JOIN public.comments c ON c.id = p.accepted_comment_id`},
+		{ReputationFollowerGained, `SELECT following_id, follower_id, follower_id, created_at FROM public.follows`},
+	}
+	for _, src := range sources {
+		rule := reputationRules[src.eventType]
+		filter := ""
+		if rule.fromOthers {
+			filter = " AND src.user_id <> src.actor_id"
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO public.reputation_events (user_id, event_type, points, source_type, source_id, actor_id, created_at)
+			SELECT src.user_id, $1, $2, $3, src.source_id, src.actor_id, COALESCE(src.created_at, NOW())
+			FROM (`+src.query+`) AS src(user_id, source_id, actor_id, created_at)
+			WHERE src.user_id IS NOT NULL AND src.actor_id IS NOT NULL`+filter+`
+			ON CONFLICT (user_id, event_type, source_id, actor_id) DO NOTHING
+		`, src.eventType, rule.points, rule.sourceType)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild %s events: %w", src.eventType, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.users u SET reputation = COALESCE(
+			(SELECT SUM(points) FROM public.reputation_events e WHERE e.user_id = u.id), 0)
+	`)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.refreshAllBadges(ctx)
+}
+
+// refreshAllBadges re-evaluates badge rules for every user in batches
+func (s *ReputationService) refreshAllBadges(ctx context.Context) error {
+	lastID := "00000000-0000-0000-0000-000000000000"
+	for {
+		rows, err := database.QueryWithContext(ctx,
+			"SELECT id FROM public.users WHERE deleted_at IS NULL AND id > $1 ORDER BY id LIMIT $2",
+			lastID, reputationRecomputeBatch,
+		)
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := refreshBadges(ctx, id); err != nil {
+				log.Printf("Failed to refresh badges for user %s: %v", id, err)
+			}
+		}
+
+		if len(ids) < reputationRecomputeBatch {
+			return nil
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
+
+// StartReputationJob recomputes reputation from scratch once a day,
+// correcting any drift in the incrementally maintained totals
+func (s *ReputationService) StartReputationJob(ctx context.Context) {
+	ticker := time.NewTicker(24 * time.Hour)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 2*time.Hour)
+				if err := s.Recompute(jobCtx); err != nil {
+					log.Printf("Reputation recompute failed: %v", err)
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+)
+
+var (
+	ErrAlreadyShadowBanned    = errors.New("user is already shadow-banned")
+	ErrNotShadowBanned        = errors.New("user is not shadow-banned")
+	ErrCannotShadowBanAdmin   = errors.New("admins can't be shadow-banned")
+	ErrInvalidShadowBanReason = errors.New("shadow ban reason is required and must be at most 1000 characters")
+)
+
+// hiddenByShadowBan returns a condition that is true when the user in
+// userColumn is shadow-banned and isn't the viewer, so the rows it guards
+// are left out for everyone but their author. viewerParam is the
+// placeholder holding the viewer's ID (empty if anonymous).
+func hiddenByShadowBan(userColumn, viewerParam string) string {
+	return strings.NewReplacer("$user", userColumn, "$viewer", viewerParam).Replace(`EXISTS(
+		SELECT 1 FROM public.users sb
+		WHERE sb.id = $user AND sb.shadow_banned_at IS NOT NULL AND sb.id::text <> $viewer
+	)`)
+}
+
+// isShadowBanned reports whether a user is shadow-banned
+func isShadowBanned(ctx context.Context, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	var banned bool
+	err := database.QueryRowWithContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM public.users WHERE id::text = $1 AND shadow_banned_at IS NOT NULL)", userID,
+	).Scan(&banned)
+	return banned, err
+}
+
+// ShadowBanHides reports whether authorID's content is hidden from viewerID
+// by a shadow ban
+func ShadowBanHides(ctx context.Context, viewerID, authorID string) (bool, error) {
+	if viewerID == authorID {
+		return false, nil
+	}
+	return isShadowBanned(ctx, authorID)
+}
+
+// ShadowBanService lets admins shadow-ban users and review what they post
+type ShadowBanService struct {
+	db    *sql.DB
+	cache *CacheService
+}
+
+// NewShadowBanService creates a new ShadowBanService instance
+func NewShadowBanService(db *sql.DB, cache *CacheService) *ShadowBanService {
+	return &ShadowBanService{db: db, cache: cache}
+}
+
+// shadowBanState is a user's shadow ban as recorded in the audit log
+type shadowBanState struct {
+	ShadowBanned bool   `json:"shadowBanned"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// ShadowBan hides a user's posts, comments and activity from everyone but
+// themselves. The user isn't told.
+func (s *ShadowBanService) ShadowBan(ctx context.Context, userID, adminID, reason string) (*models.ShadowBan, error) {
+	reason = strings.TrimSpace(utils.SanitizeHTML(reason))
+	if reason == "" || !utils.ValidateLength(reason, 1, constants.MaxShadowBanReasonLength) {
+		return nil, ErrInvalidShadowBanReason
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := lockUserAdminState(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.IsAdmin {
+		return nil, ErrCannotShadowBanAdmin
+	}
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE public.users
+		SET shadow_banned_at = $2, shadow_banned_by = NULLIF($3, '')::uuid, shadow_ban_reason = $4
+		WHERE id::text = $1 AND shadow_banned_at IS NULL
+	`, userID, now, adminID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrAlreadyShadowBanned
+	}
+
+	after := shadowBanState{ShadowBanned: true, Reason: reason}
+	if err := recordAudit(ctx, tx, models.AuditActionUserShadowBan, "user", userID, shadowBanState{}, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.invalidateCaches(ctx)
+
+	return s.getShadowBan(ctx, userID)
+}
+
+// Lift makes a shadow-banned user's content visible again
+func (s *ShadowBanService) Lift(ctx context.Context, userID string) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockUserAdminState(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	var reason sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT shadow_ban_reason FROM public.users
+		WHERE id::text = $1 AND shadow_banned_at IS NOT NULL
+	`, userID).Scan(&reason)
+	if err == sql.ErrNoRows {
+		return ErrNotShadowBanned
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.users
+		SET shadow_banned_at = NULL, shadow_banned_by = NULL, shadow_ban_reason = NULL
+		WHERE id::text = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+
+	before := shadowBanState{ShadowBanned: true, Reason: reason.String}
+	if err := recordAudit(ctx, tx, models.AuditActionUserUnshadowBan, "user", userID, before, shadowBanState{}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.invalidateCaches(ctx)
+	return nil
+}
+
+// invalidateCaches drops cached post lists, which were built without or
+// with the user's posts
+func (s *ShadowBanService) invalidateCaches(ctx context.Context) {
+	if s.cache != nil {
+		s.cache.InvalidatePosts(ctx)
+	}
+}
+
+const shadowBanColumns = `u.id, u.name, u.handle, u.avatar, COALESCE(u.shadow_ban_reason, ''),
+	COALESCE(u.shadow_banned_by::text, ''), u.shadow_banned_at`
+
+func scanShadowBan(row rowScanner) (*models.ShadowBan, error) {
+	var ban models.ShadowBan
+	var user models.User
+	var handle, avatar sql.NullString
+	err := row.Scan(&user.ID, &user.Name, &handle, &avatar, &ban.Reason, &ban.ShadowBannedBy, &ban.ShadowBannedAt)
+	if err != nil {
+		return nil, err
+	}
+	user.Handle = handle.String
+	user.Avatar = avatar.String
+	ban.User = &user
+	return &ban, nil
+}
+
+func (s *ShadowBanService) getShadowBan(ctx context.Context, userID string) (*models.ShadowBan, error) {
+	ban, err := scanShadowBan(database.QueryRowWithContext(ctx, `
+		SELECT `+shadowBanColumns+` FROM public.users u
+		WHERE u.id::text = $1 AND u.shadow_banned_at IS NOT NULL
+	`, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotShadowBanned
+	}
+	return ban, err
+}
+
+// ListShadowBans returns shadow-banned users, most recently banned first
+func (s *ShadowBanService) ListShadowBans(ctx context.Context, limit, offset int) ([]*models.ShadowBan, error) {
+	limit, offset = clampPage(limit, offset)
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+shadowBanColumns+` FROM public.users u
+		WHERE u.shadow_banned_at IS NOT NULL
+		ORDER BY u.shadow_banned_at DESC, u.id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []*models.ShadowBan{}
+	for rows.Next() {
+		ban, err := scanShadowBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+// ListContent returns the posts and comments hidden by shadow bans, newest
+// first, optionally only those of one user
+func (s *ShadowBanService) ListContent(ctx context.Context, userID string, limit, offset int) ([]*models.ShadowBannedContent, error) {
+	limit, offset = clampPage(limit, offset)
+
+	rows, err := database.QueryWithContext(ctx, `
+		WITH banned AS (
+			SELECT id, name, handle, avatar FROM public.users
+			WHERE shadow_banned_at IS NOT NULL AND ($1 = '' OR id::text = $1)
+		)
+		SELECT c.type, c.id, c.post_id, c.title, c.content, c.created_at, b.id, b.name, b.handle, b.avatar
+		FROM (
+			SELECT 'post' AS type, p.id, p.id AS post_id, p.title, p.content, p.created_at, p.author_id
+			FROM public.posts p WHERE p.author_id IN (SELECT id FROM banned)
+			UNION ALL
+			SELECT 'comment', c.id, c.post_id, '', c.content, c.created_at, c.author_id
+			FROM public.comments c WHERE c.author_id IN (SELECT id FROM banned)
+		) c
+		JOIN banned b ON b.id = c.author_id
+		ORDER BY c.created_at DESC, c.id
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.ShadowBannedContent{}
+	for rows.Next() {
+		var item models.ShadowBannedContent
+		var author models.User
+		var handle, avatar sql.NullString
+		err := rows.Scan(&item.Type, &item.ID, &item.PostID, &item.Title, &item.Content, &item.CreatedAt,
+			&author.ID, &author.Name, &handle, &avatar)
+		if err != nil {
+			return nil, err
+		}
+		author.Handle = handle.String
+		author.Avatar = avatar.String
+		item.Author = &author
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
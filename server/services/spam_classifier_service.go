@@ -0,0 +1,682 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+)
+
+var (
+	ErrNoSpamModel         = errors.New("the spam classifier hasn't been trained yet")
+	ErrInvalidSpamLabel    = errors.New("label must be 'spam' or 'ham'")
+	ErrInvalidSpamTestText = fmt.Errorf("text is required and must be at most %d characters", constants.MaxSpamTestTextLength)
+	errSpamRetrainNotDue   = errors.New("spam model retraining is not due")
+)
+
+// Indexes of the spam and ham counts in a spamModel
+const (
+	spamClass = 0
+	hamClass  = 1
+)
+
+// spamRetrainLock is the advisory lock key that keeps instances from
+// retraining the spam model at the same time
+const spamRetrainLock = 7254104
+
+const spamExcerptLength = 280
+
+// spamModel is a Naive Bayes classifier over the distinct tokens of a post
+// or comment. counts holds, per token, how many spam and ham examples
+// contain it.
+type spamModel struct {
+	info      *models.SpamModel
+	counts    map[string][2]int
+	spamTotal int // Sum of the spam counts
+	hamTotal  int // Sum of the ham counts
+}
+
+// spamClassifier is the model this instance scores content with. It is
+// swapped whole on reload.
+var spamClassifier = struct {
+	sync.RWMutex
+	model *spamModel
+}{}
+
+func activeSpamModel() *spamModel {
+	spamClassifier.RLock()
+	defer spamClassifier.RUnlock()
+	return spamClassifier.model
+}
+
+// spamTokens returns the distinct lowercase words of texts, plus a token for
+// having links and one per linked domain
+func spamTokens(texts ...string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	for _, text := range texts {
+		text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+			add("__link__")
+			add("domain:" + strings.TrimPrefix(linkHost(link), "www."))
+			return " "
+		})
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if n := utf8.RuneCountInString(word); n >= 2 && n <= 30 {
+				add(word)
+			}
+		}
+	}
+	return tokens
+}
+
+// logOdds returns the log odds that tokens are spam. leaveOut is the class
+// of the training example tokens came from, which is taken out of the counts
+// first, or -1 to score new content.
+func (m *spamModel) logOdds(tokens []string, leaveOut int) float64 {
+	examples := [2]int{m.info.SpamExamples, m.info.HamExamples}
+	totals := [2]int{m.spamTotal, m.hamTotal}
+	if leaveOut >= 0 {
+		examples[leaveOut]--
+		for _, token := range tokens {
+			if _, ok := m.counts[token]; ok {
+				totals[leaveOut]--
+			}
+		}
+	}
+
+	vocabulary := float64(len(m.counts))
+	odds := math.Log(float64(examples[spamClass]+1) / float64(examples[hamClass]+1))
+	for _, token := range tokens {
+		count, ok := m.counts[token]
+		if !ok {
+			continue
+		}
+		if leaveOut >= 0 {
+			count[leaveOut]--
+		}
+		odds += math.Log(float64(count[spamClass]+1)/(float64(totals[spamClass])+vocabulary)) -
+			math.Log(float64(count[hamClass]+1)/(float64(totals[hamClass])+vocabulary))
+	}
+	return odds
+}
+
+// score returns the probability, from 0 to 1, that tokens are spam
+func (m *spamModel) score(tokens []string) float64 {
+	return 1 / (1 + math.Exp(-m.logOdds(tokens, -1)))
+}
+
+// feature returns a token's weight: the log ratio of how likely it is in
+// spam to how likely it is in ham
+func (m *spamModel) feature(token string) models.SpamFeature {
+	count := m.counts[token]
+	vocabulary := float64(len(m.counts))
+	weight := math.Log(float64(count[spamClass]+1)/(float64(m.spamTotal)+vocabulary)) -
+		math.Log(float64(count[hamClass]+1)/(float64(m.hamTotal)+vocabulary))
+	return models.SpamFeature{
+		Token:        token,
+		Weight:       math.Round(weight*1000) / 1000,
+		SpamExamples: count[spamClass],
+		HamExamples:  count[hamClass],
+	}
+}
+
+// spamAction is what the classifier does with content of a given score
+func spamAction(score float64) string {
+	switch {
+	case score >= constants.SpamHoldThreshold:
+		return models.FilterActionHold
+	case score >= constants.SpamFlagThreshold:
+		return models.FilterActionFlag
+	}
+	return models.FilterActionAllow
+}
+
+// holdSpam scores a post or comment, or an edit to one, with the current
+// spam model. Content scoring at least SpamHoldThreshold is queued for
+// review and ErrContentHeld returned; otherwise the score is returned for
+// flagSpamTx. Nothing is scored until the model is active.
+func holdSpam(ctx context.Context, userID, kind, targetID string, payload interface{}, texts ...string) (float64, error) {
+	if isApprovedContent(ctx) {
+		return 0, nil
+	}
+	model := activeSpamModel()
+	if model == nil || !model.info.Active {
+		return 0, nil
+	}
+
+	score := model.score(spamTokens(texts...))
+	if spamAction(score) != models.FilterActionHold {
+		return score, nil
+	}
+	reason := fmt.Sprintf("Spam classifier score %.2f", score)
+	if err := queueForReview(ctx, userID, kind, models.QueueSourceClassifier, targetID, payload, reason, nil); err != nil {
+		return 0, err
+	}
+	return score, ErrContentHeld
+}
+
+// flagSpamTx opens a report, without a reporter, on a post or comment that
+// scored at least SpamFlagThreshold
+func flagSpamTx(ctx context.Context, tx *sql.Tx, targetType, targetID string, score float64) error {
+	if spamAction(score) != models.FilterActionFlag {
+		return nil
+	}
+	return openSystemReportTx(ctx, tx, targetType, targetID, fmt.Sprintf("Flagged by the spam classifier: score %.2f", score))
+}
+
+// flagSpam is flagSpamTx for writes made outside a transaction
+func flagSpam(ctx context.Context, targetType, targetID string, score float64) error {
+	if spamAction(score) != models.FilterActionFlag {
+		return nil
+	}
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := flagSpamTx(ctx, tx, targetType, targetID, score); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// spamExample is a moderation outcome the classifier learns from
+type spamExample struct {
+	source     string
+	sourceID   string
+	targetType string
+	class      int
+	text       string
+	tokens     []string
+}
+
+// trainSpamModel builds a model from examples and evaluates it by scoring
+// each example with itself left out of training. It returns the model and
+// the examples it got wrong, most confidently wrong first.
+func trainSpamModel(examples []*spamExample) (*spamModel, []models.SpamMisclassification) {
+	all := map[string][2]int{}
+	info := &models.SpamModel{
+		HoldThreshold: constants.SpamHoldThreshold,
+		FlagThreshold: constants.SpamFlagThreshold,
+	}
+	for _, ex := range examples {
+		if ex.class == spamClass {
+			info.SpamExamples++
+		} else {
+			info.HamExamples++
+		}
+		for _, token := range ex.tokens {
+			count := all[token]
+			count[ex.class]++
+			all[token] = count
+		}
+	}
+
+	// Rare tokens are mostly noise, and the vocabulary is capped to the
+	// most common of the rest
+	var vocabulary []string
+	for token, count := range all {
+		if count[spamClass]+count[hamClass] >= constants.SpamMinTokenExamples {
+			vocabulary = append(vocabulary, token)
+		}
+	}
+	sort.Slice(vocabulary, func(i, j int) bool {
+		a, b := all[vocabulary[i]], all[vocabulary[j]]
+		if a[0]+a[1] != b[0]+b[1] {
+			return a[0]+a[1] > b[0]+b[1]
+		}
+		return vocabulary[i] < vocabulary[j]
+	})
+	if len(vocabulary) > constants.SpamMaxVocabulary {
+		vocabulary = vocabulary[:constants.SpamMaxVocabulary]
+	}
+
+	model := &spamModel{info: info, counts: make(map[string][2]int, len(vocabulary))}
+	for _, token := range vocabulary {
+		count := all[token]
+		model.counts[token] = count
+		model.spamTotal += count[spamClass]
+		model.hamTotal += count[hamClass]
+	}
+	info.Vocabulary = len(model.counts)
+	info.Active = info.SpamExamples >= constants.SpamMinExamples && info.HamExamples >= constants.SpamMinExamples
+
+	misclassified := []models.SpamMisclassification{}
+	eval := &info.Evaluation
+	for _, ex := range examples {
+		score := 1 / (1 + math.Exp(-model.logOdds(ex.tokens, ex.class)))
+		predictedSpam := score >= constants.SpamFlagThreshold
+		switch {
+		case ex.class == spamClass && predictedSpam:
+			eval.TruePositives++
+		case ex.class == spamClass:
+			eval.FalseNegatives++
+		case predictedSpam:
+			eval.FalsePositives++
+		default:
+			eval.TrueNegatives++
+		}
+		if predictedSpam != (ex.class == spamClass) {
+			label := "ham"
+			if ex.class == spamClass {
+				label = "spam"
+			}
+			misclassified = append(misclassified, models.SpamMisclassification{
+				Source:     ex.source,
+				SourceID:   ex.sourceID,
+				TargetType: ex.targetType,
+				Label:      label,
+				Score:      math.Round(score*1000) / 1000,
+				Excerpt:    truncateRunes(strings.TrimSpace(ex.text), spamExcerptLength),
+			})
+		}
+	}
+	if total := len(examples); total > 0 {
+		eval.Accuracy = float64(eval.TruePositives+eval.TrueNegatives) / float64(total)
+	}
+	if flagged := eval.TruePositives + eval.FalsePositives; flagged > 0 {
+		eval.Precision = float64(eval.TruePositives) / float64(flagged)
+	}
+	if spam := eval.TruePositives + eval.FalseNegatives; spam > 0 {
+		eval.Recall = float64(eval.TruePositives) / float64(spam)
+	}
+
+	wrongness := func(m models.SpamMisclassification) float64 {
+		if m.Label == "spam" {
+			return 1 - m.Score
+		}
+		return m.Score
+	}
+	sort.SliceStable(misclassified, func(i, j int) bool {
+		return wrongness(misclassified[i]) > wrongness(misclassified[j])
+	})
+	if len(misclassified) > constants.SpamMaxMisclassified {
+		misclassified = misclassified[:constants.SpamMaxMisclassified]
+	}
+	return model, misclassified
+}
+
+// SpamClassifierService trains the spam classifier from moderation outcomes
+// and keeps this instance's copy of the model in step with the database
+type SpamClassifierService struct {
+	db *sql.DB
+}
+
+// NewSpamClassifierService creates a new SpamClassifierService instance
+func NewSpamClassifierService(db *sql.DB) *SpamClassifierService {
+	return &SpamClassifierService{db: db}
+}
+
+// loadSpamExamples returns the most recent moderation outcomes on posts and
+// comments. Queue items rejected as spam and reported content removed as
+// spam are spam; approved queue items and content whose reports were
+// dismissed are ham. Content rejected or removed for any other reason is
+// left out, since it says nothing about spam.
+func (s *SpamClassifierService) loadSpamExamples(ctx context.Context) ([]*spamExample, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT source, source_id, target_type, spam, title, content, tags FROM (
+			SELECT 'queue' AS source, q.id::text AS source_id,
+				CASE WHEN q.kind IN ('post', 'post_update') THEN 'post' ELSE 'comment' END AS target_type,
+				q.status = 'rejected' AS spam,
+				COALESCE(q.payload->>'title', '') AS title, COALESCE(q.payload->>'content', '') AS content,
+				COALESCE((q.payload->'tags')::text, '') AS tags, q.reviewed_at AS decided_at
+			FROM public.moderation_queue q
+			WHERE q.kind IN ('post', 'post_update', 'comment', 'comment_update')
+				AND (q.status = 'approved' OR (q.status = 'rejected' AND q.spam))
+			UNION ALL
+			(
+				SELECT DISTINCT ON (r.target_type, r.target_id) 'report', r.id::text, r.target_type,
+					r.status = 'resolved', COALESCE(r.snapshot->>'title', ''), COALESCE(r.snapshot->>'content', ''),
+					COALESCE((r.snapshot->'tags')::text, ''), COALESCE(r.resolved_at, r.updated_at)
+				FROM public.reports r
+				WHERE r.snapshot IS NOT NULL
+					AND (r.status = 'dismissed' OR (r.status = 'resolved' AND 'remove' = ANY(r.actions) AND r.spam))
+				ORDER BY r.target_type, r.target_id, COALESCE(r.resolved_at, r.updated_at) DESC
+			)
+		) examples
+		ORDER BY decided_at DESC NULLS LAST
+		LIMIT $1
+	`, constants.SpamMaxTrainingExamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []*spamExample
+	for rows.Next() {
+		var ex spamExample
+		var spam bool
+		var title, content, tagsJSON string
+		if err := rows.Scan(&ex.source, &ex.sourceID, &ex.targetType, &spam, &title, &content, &tagsJSON); err != nil {
+			return nil, err
+		}
+		var tags []string
+		if tagsJSON != "" {
+			_ = json.Unmarshal([]byte(tagsJSON), &tags)
+		}
+		ex.class = hamClass
+		if spam {
+			ex.class = spamClass
+		}
+		ex.text = strings.TrimSpace(title + "\n" + content)
+		ex.tokens = spamTokens(title, content, strings.Join(tags, " "))
+		examples = append(examples, &ex)
+	}
+	return examples, rows.Err()
+}
+
+// Retrain trains a new model from the latest moderation outcomes, which
+// applies at once on this instance
+func (s *SpamClassifierService) Retrain(ctx context.Context, adminID string) (*models.SpamModel, error) {
+	info, err := s.retrain(ctx, adminID, false)
+	if err != nil {
+		return nil, err
+	}
+	s.reloadAfterRetrain(ctx)
+	return info, nil
+}
+
+// retrain trains and stores a model. Scheduled runs skip if another
+// instance is retraining or the latest model is recent; admin runs wait
+// for any retraining in progress and are audited.
+func (s *SpamClassifierService) retrain(ctx context.Context, adminID string, scheduled bool) (*models.SpamModel, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if scheduled {
+		var locked, due bool
+		err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", spamRetrainLock).Scan(&locked)
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			return nil, errSpamRetrainNotDue
+		}
+		err = tx.QueryRowContext(ctx, `
+			SELECT NOT EXISTS(SELECT 1 FROM public.spam_models WHERE trained_at > $1)
+		`, time.Now().UTC().Add(-constants.SpamRetrainInterval)).Scan(&due)
+		if err != nil {
+			return nil, err
+		}
+		if !due {
+			return nil, errSpamRetrainNotDue
+		}
+	} else if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", spamRetrainLock); err != nil {
+		return nil, err
+	}
+
+	examples, err := s.loadSpamExamples(ctx)
+	if err != nil {
+		return nil, err
+	}
+	model, misclassified := trainSpamModel(examples)
+	info := model.info
+	info.TrainedBy = adminID
+
+	counts, err := json.Marshal(model.counts)
+	if err != nil {
+		return nil, err
+	}
+	evaluation, err := json.Marshal(info.Evaluation)
+	if err != nil {
+		return nil, err
+	}
+	misclassifiedJSON, err := json.Marshal(misclassified)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.spam_models (spam_examples, ham_examples, token_counts, evaluation, misclassifications, trained_by, trained_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, $7)
+		RETURNING id, trained_at
+	`, info.SpamExamples, info.HamExamples, string(counts), string(evaluation), string(misclassifiedJSON),
+		adminID, time.Now().UTC()).Scan(&info.ID, &info.TrainedAt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM public.spam_models
+		WHERE id NOT IN (SELECT id FROM public.spam_models ORDER BY trained_at DESC LIMIT $1)
+	`, constants.SpamModelsKept)
+	if err != nil {
+		return nil, err
+	}
+
+	if !scheduled {
+		err := recordAudit(ctx, tx, models.AuditActionSpamModelRetrain, "spam_model", info.ID, nil, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// StartSpamClassifierJob loads the latest model now, picks up models
+// trained on other instances within SpamModelReloadInterval, and retrains
+// once the latest model is SpamRetrainInterval old
+func (s *SpamClassifierService) StartSpamClassifierJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.SpamModelReloadInterval)
+	go func() {
+		run := func() {
+			jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			defer cancel()
+			info, err := s.retrain(jobCtx, "", true)
+			switch {
+			case err == nil:
+				log.Printf("Spam model retrained on %d spam and %d ham examples", info.SpamExamples, info.HamExamples)
+			case !errors.Is(err, errSpamRetrainNotDue):
+				log.Printf("Spam model retraining error: %v", err)
+			}
+			if err := s.Reload(jobCtx); err != nil {
+				log.Printf("Spam model reload error: %v", err)
+			}
+		}
+
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// reloadAfterRetrain applies a new model on this instance right away
+func (s *SpamClassifierService) reloadAfterRetrain(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		log.Printf("Spam model reload error: %v", err)
+	}
+}
+
+// Reload loads the latest model if it isn't the one this instance has
+func (s *SpamClassifierService) Reload(ctx context.Context) error {
+	var id string
+	err := database.QueryRowWithContext(ctx, "SELECT id FROM public.spam_models ORDER BY trained_at DESC LIMIT 1").Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current := activeSpamModel(); current != nil && current.info.ID == id {
+		return nil
+	}
+
+	info := &models.SpamModel{
+		ID:            id,
+		HoldThreshold: constants.SpamHoldThreshold,
+		FlagThreshold: constants.SpamFlagThreshold,
+	}
+	var trainedBy sql.NullString
+	var counts, evaluation []byte
+	err = database.QueryRowWithContext(ctx, `
+		SELECT spam_examples, ham_examples, token_counts, evaluation, trained_by, trained_at
+		FROM public.spam_models WHERE id = $1
+	`, id).Scan(&info.SpamExamples, &info.HamExamples, &counts, &evaluation, &trainedBy, &info.TrainedAt)
+	if err == sql.ErrNoRows {
+		return nil // Deleted by a newer retrain since; the next reload gets that one
+	}
+	if err != nil {
+		return err
+	}
+	info.TrainedBy = trainedBy.String
+
+	model := &spamModel{info: info}
+	if err := json.Unmarshal(counts, &model.counts); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(evaluation, &info.Evaluation); err != nil {
+		return err
+	}
+	for _, count := range model.counts {
+		model.spamTotal += count[spamClass]
+		model.hamTotal += count[hamClass]
+	}
+	info.Vocabulary = len(model.counts)
+	info.Active = info.SpamExamples >= constants.SpamMinExamples && info.HamExamples >= constants.SpamMinExamples
+
+	spamClassifier.Lock()
+	spamClassifier.model = model
+	spamClassifier.Unlock()
+	return nil
+}
+
+// GetWeights returns the current model and its limit strongest spam and ham
+// features
+func (s *SpamClassifierService) GetWeights(limit int) (*models.SpamFeatureWeights, error) {
+	model := activeSpamModel()
+	if model == nil {
+		return nil, ErrNoSpamModel
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	features := make([]models.SpamFeature, 0, len(model.counts))
+	for token := range model.counts {
+		features = append(features, model.feature(token))
+	}
+	sort.Slice(features, func(i, j int) bool {
+		if features[i].Weight != features[j].Weight {
+			return features[i].Weight > features[j].Weight
+		}
+		return features[i].Token < features[j].Token
+	})
+
+	weights := &models.SpamFeatureWeights{Model: model.info, Spam: []models.SpamFeature{}, Ham: []models.SpamFeature{}}
+	for i := 0; i < len(features) && i < limit && features[i].Weight > 0; i++ {
+		weights.Spam = append(weights.Spam, features[i])
+	}
+	for i := len(features) - 1; i >= 0 && len(features)-i <= limit && features[i].Weight < 0; i-- {
+		weights.Ham = append(weights.Ham, features[i])
+	}
+	return weights, nil
+}
+
+// GetMisclassifications returns the latest model's misclassified training
+// examples, most confidently wrong first, optionally only those moderators
+// labelled label
+func (s *SpamClassifierService) GetMisclassifications(ctx context.Context, label string, limit, offset int) ([]models.SpamMisclassification, error) {
+	if label != "" && label != "spam" && label != "ham" {
+		return nil, ErrInvalidSpamLabel
+	}
+	limit, offset = clampPage(limit, offset)
+
+	var data []byte
+	err := database.QueryRowWithContext(ctx,
+		"SELECT misclassifications FROM public.spam_models ORDER BY trained_at DESC LIMIT 1").Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoSpamModel
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []models.SpamMisclassification
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	matching := []models.SpamMisclassification{}
+	for _, m := range all {
+		if label == "" || m.Label == label {
+			matching = append(matching, m)
+		}
+	}
+	if offset >= len(matching) {
+		return []models.SpamMisclassification{}, nil
+	}
+	return matching[offset:min(offset+limit, len(matching))], nil
+}
+
+// Test scores sample text with the current model without saving or holding
+// anything. Action is what would happen to a post with this text, which is
+// always allow while the model isn't active.
+func (s *SpamClassifierService) Test(req *models.SpamTestRequest) (*models.SpamTestResponse, error) {
+	if strings.TrimSpace(req.Text) == "" || len(req.Text) > constants.MaxSpamTestTextLength {
+		return nil, ErrInvalidSpamTestText
+	}
+	model := activeSpamModel()
+	if model == nil {
+		return nil, ErrNoSpamModel
+	}
+
+	tokens := spamTokens(req.Text)
+	score := model.score(tokens)
+	resp := &models.SpamTestResponse{
+		Score:    math.Round(score*1000) / 1000,
+		Action:   models.FilterActionAllow,
+		Features: []models.SpamFeature{},
+	}
+	if model.info.Active {
+		resp.Action = spamAction(score)
+	}
+	for _, token := range tokens {
+		if _, ok := model.counts[token]; ok {
+			resp.Features = append(resp.Features, model.feature(token))
+		}
+	}
+	sort.SliceStable(resp.Features, func(i, j int) bool {
+		return math.Abs(resp.Features[i].Weight) > math.Abs(resp.Features[j].Weight)
+	})
+	if len(resp.Features) > 20 {
+		resp.Features = resp.Features[:20]
+	}
+	return resp, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"tech-bant-community/server/config"
+)
+
+// Supabase Storage helpers over the HTTP API (the supabase-go client has no
+// storage support). All calls use the service role key.
+
+// storageUpload uploads (or replaces) an object in the configured bucket
+func storageUpload(ctx context.Context, cfg *config.Config, objectPath, contentType string, body io.Reader) error {
+	uploadURL := fmt.Sprintf("%s/storage/v1/object/%s/%s", cfg.SupabaseURL, cfg.StorageBucket, objectPath)
+	req, err := http.NewRequestWithContext(ctx, "POST", uploadURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.SupabaseServiceKey)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-upsert", "true")
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload to Supabase Storage: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to upload to Supabase Storage: %s", string(msg))
+	}
+	return nil
+}
+
+// storageDownload streams an object from the configured bucket. The caller closes the body.
+func storageDownload(ctx context.Context, cfg *config.Config, objectPath string) (io.ReadCloser, error) {
+	downloadURL := fmt.Sprintf("%s/storage/v1/object/%s/%s", cfg.SupabaseURL, cfg.StorageBucket, objectPath)
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.SupabaseServiceKey)
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("storage download failed: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// storageDelete removes objects from the configured bucket
+func storageDelete(ctx context.Context, cfg *config.Config, objectPaths ...string) error {
+	if len(objectPaths) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(map[string][]string{"prefixes": objectPaths})
+	if err != nil {
+		return err
+	}
+
+	deleteURL := fmt.Sprintf("%s/storage/v1/object/%s", cfg.SupabaseURL, cfg.StorageBucket)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", deleteURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.SupabaseServiceKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("storage delete failed: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// storageSignedURL returns a short-lived signed download URL for an object
+func storageSignedURL(ctx context.Context, cfg *config.Config, objectPath string, expiresIn time.Duration) (string, error) {
+	payload, err := json.Marshal(map[string]int{"expiresIn": int(expiresIn.Seconds())})
+	if err != nil {
+		return "", err
+	}
+
+	signURL := fmt.Sprintf("%s/storage/v1/object/sign/%s/%s", cfg.SupabaseURL, cfg.StorageBucket, objectPath)
+	req, err := http.NewRequestWithContext(ctx, "POST", signURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.SupabaseServiceKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("storage sign failed: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		SignedURL string `json:"signedURL"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.SignedURL == "" {
+		return "", fmt.Errorf("storage sign failed: empty URL")
+	}
+	if strings.HasPrefix(result.SignedURL, "http") {
+		return result.SignedURL, nil
+	}
+	return cfg.SupabaseURL + "/storage/v1" + result.SignedURL, nil
+}
+
+// storagePathFromURL extracts the object path from a public Storage URL
+func storagePathFromURL(cfg *config.Config, url string) string {
+	return extractFilePathFromURL(url, cfg.SupabaseURL, cfg.StorageBucket)
+}
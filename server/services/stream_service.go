@@ -0,0 +1,553 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrTooManyStreamPosts = fmt.Errorf("a stream can watch at most %d posts", constants.StreamMaxPosts)
+
+// Stream event types
+const (
+	StreamEventNotification = "notification"
+	StreamEventComment      = "comment"
+	StreamEventLikes        = "likes"
+	StreamEventMessage      = "message"
+	StreamEventMessageRead  = "message_read"
+)
+
+func userStreamChannel(userID string) string { return "stream:user:" + userID }
+func postStreamChannel(postID string) string { return "stream:post:" + postID }
+
+// replayKey is the Redis stream holding recent events of a channel
+func replayKey(channel string) string { return "replay:" + channel }
+
+// defaultStream is the StreamService producers publish through. It is set
+// once at startup; until then publishing is a no-op.
+var defaultStream *StreamService
+
+// StreamService fans live events out to connected clients. Events are
+// published through Redis pub/sub so every server instance sees them, and
+// the last few per channel are kept in a Redis stream whose entry IDs double
+// as event IDs for Last-Event-ID resume. Without Redis, events only reach
+// clients connected to this instance and can't be replayed.
+type StreamService struct {
+	cfg    *config.Config
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	mu   sync.Mutex
+	subs map[string]map[*StreamSubscription]struct{} // channel -> local subscribers
+
+	seq atomic.Uint64 // event IDs when running without Redis
+}
+
+// NewStreamService creates the StreamService and makes it the one producers
+// publish through
+func NewStreamService(cfg *config.Config) *StreamService {
+	s := &StreamService{
+		cfg:  cfg,
+		subs: make(map[string]map[*StreamSubscription]struct{}),
+	}
+
+	if cfg.RedisAddr != "" {
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := client.Ping(ctx).Err()
+		cancel()
+		if err != nil {
+			log.Printf("Warning: live stream running without Redis (events stay on this instance): %v", err)
+			client.Close()
+		} else {
+			s.client = client
+			s.pubsub = client.Subscribe(context.Background())
+			go s.run()
+		}
+	}
+
+	defaultStream = s
+	return s
+}
+
+// run delivers events arriving from Redis to local subscribers
+func (s *StreamService) run() {
+	for msg := range s.pubsub.Channel() {
+		var event models.StreamEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			log.Printf("Dropping malformed stream event on %s: %v", msg.Channel, err)
+			continue
+		}
+		s.dispatch(msg.Channel, &event)
+	}
+}
+
+// Close disconnects every local subscriber and stops listening to Redis
+func (s *StreamService) Close() error {
+	s.mu.Lock()
+	for _, subs := range s.subs {
+		for sub := range subs {
+			s.closeLocked(sub)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+	s.pubsub.Close()
+	return s.client.Close()
+}
+
+// Publish sends an event to everyone subscribed to channel. actorID, if set,
+// lets subscribers who blocked or muted the actor skip the event.
+func (s *StreamService) Publish(ctx context.Context, channel, eventType, actorID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := &models.StreamEvent{Type: eventType, ActorID: actorID, Data: payload}
+
+	if s.client == nil {
+		event.ID = fmt.Sprintf("%d-%d", time.Now().UnixMilli(), s.seq.Add(1))
+		s.dispatch(channel, event)
+		return nil
+	}
+
+	stored, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	key := replayKey(channel)
+	event.ID, err = s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: constants.StreamReplayLength,
+		Approx: true,
+		Values: map[string]interface{}{"event": stored},
+	}).Result()
+	if err != nil {
+		return err
+	}
+	s.client.Expire(ctx, key, constants.StreamReplayRetention)
+
+	msg, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, channel, msg).Err()
+}
+
+// Replay returns the events on sub's channels after lastEventID, oldest
+// first. Only the most recent events per channel are kept, so a client that
+// was away for long should refetch instead.
+func (s *StreamService) Replay(ctx context.Context, sub *StreamSubscription, lastEventID string) ([]*models.StreamEvent, error) {
+	if s.client == nil || lastEventID == "" {
+		return nil, nil
+	}
+	if _, _, ok := parseStreamEventID(lastEventID); !ok {
+		return nil, nil
+	}
+
+	var events []*models.StreamEvent
+	for _, channel := range sub.Channels() {
+		msgs, err := s.client.XRangeN(ctx, replayKey(channel), "("+lastEventID, "+", constants.StreamReplayLength).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			raw, _ := msg.Values["event"].(string)
+			var event models.StreamEvent
+			if err := json.Unmarshal([]byte(raw), &event); err != nil {
+				continue
+			}
+			event.ID = msg.ID
+			if !sub.hides(&event) {
+				events = append(events, &event)
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return StreamEventAfter(events[j].ID, events[i].ID) })
+	return events, nil
+}
+
+// StreamSubscription is one client's view of the stream: its own
+// notifications plus the posts it is watching
+type StreamSubscription struct {
+	// Events is closed when the subscription ends, including when the
+	// client falls too far behind; it should then reconnect and resume
+	Events chan *models.StreamEvent
+
+	hidden   map[string]bool
+	channels map[string]bool
+	closed   bool
+	svc      *StreamService
+}
+
+// Subscribe starts streaming userID's notifications and activity on postIDs
+func (s *StreamService) Subscribe(ctx context.Context, userID string, postIDs []string) (*StreamSubscription, error) {
+	if len(postIDs) > constants.StreamMaxPosts {
+		return nil, ErrTooManyStreamPosts
+	}
+	hiddenIDs, err := NewBlockService(database.GetDB()).HiddenUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &StreamSubscription{
+		Events:   make(chan *models.StreamEvent, 64),
+		hidden:   make(map[string]bool, len(hiddenIDs)),
+		channels: make(map[string]bool),
+		svc:      s,
+	}
+	for _, id := range hiddenIDs {
+		sub.hidden[id] = true
+	}
+
+	channels := append([]string{userStreamChannel(userID)}, postChannels(postIDs)...)
+	s.mu.Lock()
+	err = s.addLocked(ctx, sub, channels)
+	s.mu.Unlock()
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Watch adds posts whose new comments and like counts should be streamed
+func (sub *StreamSubscription) Watch(ctx context.Context, postIDs []string) error {
+	channels := postChannels(postIDs)
+
+	sub.svc.mu.Lock()
+	defer sub.svc.mu.Unlock()
+	watching := len(sub.channels) - 1 // Everything but the user's own channel
+	for _, ch := range channels {
+		if !sub.channels[ch] {
+			watching++
+		}
+	}
+	if watching > constants.StreamMaxPosts {
+		return ErrTooManyStreamPosts
+	}
+	return sub.svc.addLocked(ctx, sub, channels)
+}
+
+// Unwatch stops streaming activity on postIDs
+func (sub *StreamSubscription) Unwatch(ctx context.Context, postIDs []string) error {
+	sub.svc.mu.Lock()
+	defer sub.svc.mu.Unlock()
+	return sub.svc.removeLocked(ctx, sub, postChannels(postIDs))
+}
+
+func postChannels(postIDs []string) []string {
+	channels := make([]string, 0, len(postIDs))
+	for _, id := range postIDs {
+		channels = append(channels, postStreamChannel(id))
+	}
+	return channels
+}
+
+// Channels lists the channels the subscription currently receives
+func (sub *StreamSubscription) Channels() []string {
+	sub.svc.mu.Lock()
+	defer sub.svc.mu.Unlock()
+	channels := make([]string, 0, len(sub.channels))
+	for ch := range sub.channels {
+		channels = append(channels, ch)
+	}
+	return channels
+}
+
+// Close ends the subscription. Calling it more than once is harmless.
+func (sub *StreamSubscription) Close() {
+	sub.svc.mu.Lock()
+	defer sub.svc.mu.Unlock()
+	sub.svc.closeLocked(sub)
+}
+
+// hides reports whether the event comes from someone the subscriber blocked,
+// muted or was blocked by
+func (sub *StreamSubscription) hides(event *models.StreamEvent) bool {
+	return event.ActorID != "" && sub.hidden[event.ActorID]
+}
+
+// addLocked subscribes sub to channels, listening to Redis for channels no
+// other local subscriber needed yet. Redis subscriptions only change under
+// s.mu, so a concurrent add and remove of a channel can't leave it unheard.
+func (s *StreamService) addLocked(ctx context.Context, sub *StreamSubscription, channels []string) error {
+	if sub.closed {
+		return nil
+	}
+
+	var newChannels []string
+	for _, ch := range channels {
+		if sub.channels[ch] {
+			continue
+		}
+		sub.channels[ch] = true
+		if s.subs[ch] == nil {
+			s.subs[ch] = make(map[*StreamSubscription]struct{})
+			newChannels = append(newChannels, ch)
+		}
+		s.subs[ch][sub] = struct{}{}
+	}
+
+	if s.pubsub != nil && len(newChannels) > 0 {
+		return s.pubsub.Subscribe(ctx, newChannels...)
+	}
+	return nil
+}
+
+func (s *StreamService) removeLocked(ctx context.Context, sub *StreamSubscription, channels []string) error {
+	var unused []string
+	for _, ch := range channels {
+		if !sub.channels[ch] {
+			continue
+		}
+		delete(sub.channels, ch)
+		delete(s.subs[ch], sub)
+		if len(s.subs[ch]) == 0 {
+			delete(s.subs, ch)
+			unused = append(unused, ch)
+		}
+	}
+
+	if s.pubsub != nil && len(unused) > 0 {
+		return s.pubsub.Unsubscribe(ctx, unused...)
+	}
+	return nil
+}
+
+func (s *StreamService) closeLocked(sub *StreamSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.Events)
+
+	channels := make([]string, 0, len(sub.channels))
+	for ch := range sub.channels {
+		channels = append(channels, ch)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.removeLocked(ctx, sub, channels); err != nil {
+		log.Printf("Failed to unsubscribe stream channels: %v", err)
+	}
+}
+
+// dispatch hands an event to the local subscribers of channel. A subscriber
+// whose buffer is full is disconnected rather than holding everyone up.
+func (s *StreamService) dispatch(channel string, event *models.StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs[channel] {
+		if sub.hides(event) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+			s.closeLocked(sub)
+		}
+	}
+}
+
+// IssueTicket returns a short-lived ticket that authenticates a stream
+// connection, for browser clients that can't set an Authorization header on
+// EventSource or WebSocket requests
+func (s *StreamService) IssueTicket(userID string) (string, time.Time) {
+	expiresAt := time.Now().UTC().Add(constants.StreamTicketExpiry)
+	sig := utils.SignExpiring(s.cfg.SigningSecret, expiresAt, "stream", userID)
+	return fmt.Sprintf("%s.%d.%s", userID, expiresAt.Unix(), sig), expiresAt
+}
+
+// VerifyTicket returns the user a ticket from IssueTicket was issued to
+func (s *StreamService) VerifyTicket(ticket string) (string, bool) {
+	parts := strings.Split(ticket, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	if !utils.VerifyExpiring(s.cfg.SigningSecret, parts[2], parts[1], "stream", parts[0]) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// StreamEventAfter reports whether event ID a was published after b. IDs
+// are "<unix millis>-<sequence>", as assigned by Redis streams.
+func StreamEventAfter(a, b string) bool {
+	aMs, aSeq, aOK := parseStreamEventID(a)
+	bMs, bSeq, bOK := parseStreamEventID(b)
+	if !aOK || !bOK {
+		return aOK
+	}
+	if aMs != bMs {
+		return aMs > bMs
+	}
+	return aSeq > bSeq
+}
+
+func parseStreamEventID(id string) (uint64, uint64, bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
+
+// publishStreamEvent publishes through the default stream, if there is one
+func publishStreamEvent(ctx context.Context, channel, eventType, actorID string, data interface{}) {
+	if defaultStream == nil {
+		return
+	}
+	if err := defaultStream.Publish(ctx, channel, eventType, actorID, data); err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Failed to publish %s event on %s: %v", eventType, channel, err)
+	}
+}
+
+// publishCommentAsync streams a new comment to clients watching its post
+func publishCommentAsync(comment *models.Comment) {
+	event := *comment
+	if comment.Author != nil {
+		// Only the public profile fields; the author's email stays private
+		event.Author = &models.User{
+			ID:         comment.Author.ID,
+			Name:       comment.Author.Name,
+			Handle:     comment.Author.Handle,
+			Avatar:     comment.Author.Avatar,
+			IsVerified: comment.Author.IsVerified,
+		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		publishStreamEvent(ctx, postStreamChannel(event.PostID), StreamEventComment, event.AuthorID, &event)
+	}()
+}
+
+// publishLikesAsync streams the current like count of a post, or of one of
+// its comments if commentID is set, to clients watching the post
+func publishLikesAsync(postID, commentID string) {
+	if defaultStream == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var likes int
+		var err error
+		if commentID == "" {
+			err = database.QueryRowWithContext(ctx, "SELECT likes FROM public.posts WHERE id = $1", postID).Scan(&likes)
+		} else {
+			err = database.QueryRowWithContext(ctx, "SELECT COUNT(*) FROM public.likes WHERE comment_id = $1", commentID).Scan(&likes)
+		}
+		if err != nil {
+			log.Printf("Failed to read like count for post %s: %v", postID, err)
+			return
+		}
+
+		data := map[string]interface{}{"postId": postID, "likes": likes}
+		if commentID != "" {
+			data["commentId"] = commentID
+		}
+		publishStreamEvent(ctx, postStreamChannel(postID), StreamEventLikes, "", data)
+	}()
+}
+
+// publishMessageAsync streams a new, edited or deleted direct message to
+// every participant of its conversation, the sender's other sessions
+// included. A shadow-banned sender's messages only reach the sender.
+func publishMessageAsync(conversationID, senderID, action string, message *models.Message) {
+	if defaultStream == nil {
+		return
+	}
+	data := map[string]interface{}{"action": action, "message": message}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		shadowBanned, err := isShadowBanned(ctx, senderID)
+		if err != nil {
+			log.Printf("Failed to check shadow ban of %s: %v", senderID, err)
+			return
+		}
+		recipients := []string{senderID}
+		if !shadowBanned {
+			recipients = conversationMembers(ctx, conversationID, "")
+		}
+		for _, userID := range recipients {
+			publishStreamEvent(ctx, userStreamChannel(userID), StreamEventMessage, senderID, data)
+		}
+	}()
+}
+
+// publishMessageReadAsync tells the other participants of a conversation
+// that the reader has caught up to readAt
+func publishMessageReadAsync(conversationID, readerID string, readAt time.Time) {
+	if defaultStream == nil {
+		return
+	}
+	data := map[string]interface{}{"conversationId": conversationID, "userId": readerID, "readAt": readAt}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, userID := range conversationMembers(ctx, conversationID, readerID) {
+			publishStreamEvent(ctx, userStreamChannel(userID), StreamEventMessageRead, readerID, data)
+		}
+	}()
+}
+
+// conversationMembers lists the current participants of a conversation
+// other than exceptID
+func conversationMembers(ctx context.Context, conversationID, exceptID string) []string {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT user_id::text FROM public.conversation_participants
+		WHERE conversation_id::text = $1 AND left_at IS NULL AND user_id::text <> $2
+	`, conversationID, exceptID)
+	if err != nil {
+		log.Printf("Failed to load participants of conversation %s: %v", conversationID, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("Failed to load participants of conversation %s: %v", conversationID, err)
+			return nil
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs
+}
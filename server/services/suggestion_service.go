@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+)
+
+// Suggestion scoring weights. Each signal is capped so a single very
+// prolific or very connected account can't dominate everyone's list.
+const (
+	suggestionMutualWeight   = 3.0
+	suggestionCategoryWeight = 1.0
+	suggestionTagWeight      = 2.0
+	suggestionVerifiedBonus  = 2.0
+	suggestionSignalCap      = 10
+	suggestionsPerUser       = 50
+	suggestionRefreshBatch   = 500
+)
+
+// suggestionQuery ranks follow candidates for $1 and returns the top $2.
+// Blocked (either direction), muted, followed and banned users are excluded.
+// Signals: friends-of-friends, authors posting in the categories/tags the
+// user engages with (authored, liked, commented, bookmarked in the last 90
+// days), recent activity and verification.
+const suggestionQuery = `
+	WITH my_follows AS (
+		SELECT following_id FROM public.follows WHERE follower_id = $1
+	),
+	fof AS (
+		SELECT f.following_id AS candidate_id, COUNT(*) AS n
+		FROM public.follows f
+		JOIN my_follows m ON f.follower_id = m.following_id
+		GROUP BY f.following_id
+	),
+	engaged AS (
+		SELECT p.category, p.tags FROM public.posts p
+		WHERE p.author_id = $1 AND p.created_at > NOW() - INTERVAL '90 days'
+		UNION ALL
+		SELECT p.category, p.tags FROM public.likes l JOIN public.posts p ON p.id = l.post_id
+		WHERE l.user_id = $1 AND l.created_at > NOW() - INTERVAL '90 days'
+		UNION ALL
+		SELECT p.category, p.tags FROM public.comments c JOIN public.posts p ON p.id = c.post_id
+		WHERE c.author_id = $1 AND c.created_at > NOW() - INTERVAL '90 days'
+		UNION ALL
+		SELECT p.category, p.tags FROM public.bookmarks b JOIN public.posts p ON p.id = b.post_id
+		WHERE b.user_id = $1 AND b.created_at > NOW() - INTERVAL '90 days'
+	),
+	my_categories AS (
+		SELECT DISTINCT category FROM engaged
+	),
+	my_tags AS (
+		SELECT DISTINCT lower(t.tag) AS tag FROM engaged e CROSS JOIN LATERAL unnest(e.tags) AS t(tag)
+	),
+	category_match AS (
+		SELECT p.author_id AS candidate_id, COUNT(*) AS n
+		FROM public.posts p
+		JOIN my_categories c ON c.category = p.category
+		WHERE p.created_at > NOW() - INTERVAL '90 days'
+		GROUP BY p.author_id
+	),
+	tag_match AS (
+		SELECT p.author_id AS candidate_id, COUNT(DISTINCT mt.tag) AS n
+		FROM public.posts p
+		CROSS JOIN LATERAL unnest(p.tags) AS t(tag)
+		JOIN my_tags mt ON mt.tag = lower(t.tag)
+		WHERE p.created_at > NOW() - INTERVAL '90 days'
+		GROUP BY p.author_id
+	),
+	activity AS (
+		SELECT author_id AS candidate_id, COUNT(*) AS n
+		FROM public.posts
+		WHERE created_at > NOW() - INTERVAL '30 days'
+		GROUP BY author_id
+	),
+	candidates AS (
+		SELECT candidate_id FROM fof
+		UNION SELECT candidate_id FROM category_match
+		UNION SELECT candidate_id FROM tag_match
+		UNION (SELECT candidate_id FROM activity ORDER BY n DESC LIMIT 100)
+	),
+	scored AS (
+		SELECT
+			u.id AS candidate_id,
+			COALESCE(fof.n, 0) AS mutuals,
+			COALESCE(cm.n, 0) + COALESCE(tm.n, 0) AS interests,
+			u.is_verified,
+			$3::float8 * LEAST(COALESCE(fof.n, 0), $7::int)
+				+ $4::float8 * LEAST(COALESCE(cm.n, 0), $7::int)
+				+ $5::float8 * LEAST(COALESCE(tm.n, 0), $7::int)
+				+ LN(1 + COALESCE(a.n, 0))
+				+ CASE WHEN u.is_verified THEN $6::float8 ELSE 0 END AS score
+		FROM candidates c
+		JOIN public.users u ON u.id = c.candidate_id
+		LEFT JOIN fof ON fof.candidate_id = u.id
+		LEFT JOIN category_match cm ON cm.candidate_id = u.id
+		LEFT JOIN tag_match tm ON tm.candidate_id = u.id
+		LEFT JOIN activity a ON a.candidate_id = u.id
+		WHERE u.id <> $1
+			AND u.is_active = true
+			AND u.shadow_banned_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM my_follows m WHERE m.following_id = u.id)
+			AND NOT EXISTS (
+				SELECT 1 FROM public.user_blocks b
+				WHERE (b.blocker_id = $1 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $1)
+			)
+			AND NOT EXISTS (SELECT 1 FROM public.user_mutes m WHERE m.muter_id = $1 AND m.muted_id = u.id)
+	)
+	SELECT candidate_id, score, mutuals,
+		CASE
+			WHEN mutuals > 0 THEN 'mutual_follows'
+			WHEN interests > 0 THEN 'shared_interests'
+			WHEN is_verified THEN 'verified'
+			ELSE 'active'
+		END AS reason
+	FROM scored
+	WHERE score > 0
+	ORDER BY score DESC, candidate_id
+	LIMIT $2
+`
+
+// SuggestionService builds and serves who-to-follow suggestions
+type SuggestionService struct {
+	db *sql.DB
+}
+
+// NewSuggestionService creates a new SuggestionService instance
+func NewSuggestionService(db *sql.DB) *SuggestionService {
+	return &SuggestionService{db: db}
+}
+
+// GetSuggestions returns precomputed suggestions for a user, computing them
+// on demand for users the daily job hasn't reached yet (e.g. new signups).
+// Users followed since the last refresh are filtered out at read time.
+func (s *SuggestionService) GetSuggestions(ctx context.Context, userID string, limit int) ([]*models.FollowSuggestion, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > suggestionsPerUser {
+		limit = suggestionsPerUser
+	}
+
+	var computed bool
+	err := database.QueryRowWithContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM public.follow_suggestions WHERE user_id = $1)", userID,
+	).Scan(&computed)
+	if err != nil {
+		return nil, err
+	}
+	if !computed {
+		if err := s.RefreshUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		SELECT u.id, u.name, u.handle, u.avatar, u.bio, u.is_verified, u.followers_count, u.following_count,
+			fs.score, fs.reason, fs.mutual_count
+		FROM public.follow_suggestions fs
+		JOIN public.users u ON u.id = fs.candidate_id
+		WHERE fs.user_id = $1
+			AND u.is_active = true
+			AND u.shadow_banned_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM public.follows f WHERE f.follower_id = $1 AND f.following_id = fs.candidate_id
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM public.user_blocks b
+				WHERE (b.blocker_id = $1 AND b.blocked_id = fs.candidate_id)
+					OR (b.blocker_id = fs.candidate_id AND b.blocked_id = $1)
+			)
+			AND NOT EXISTS (SELECT 1 FROM public.user_mutes m WHERE m.muter_id = $1 AND m.muted_id = fs.candidate_id)
+		ORDER BY fs.score DESC
+		LIMIT $2
+	`
+	rows, err := database.QueryWithContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*models.FollowSuggestion{}
+	for rows.Next() {
+		var user models.User
+		var suggestion models.FollowSuggestion
+		var handle, avatar, bio sql.NullString
+		if err := rows.Scan(
+			&user.ID, &user.Name, &handle, &avatar, &bio, &user.IsVerified, &user.FollowersCount, &user.FollowingCount,
+			&suggestion.Score, &suggestion.Reason, &suggestion.MutualCount,
+		); err != nil {
+			return nil, err
+		}
+		if handle.Valid {
+			user.Handle = handle.String
+		}
+		if avatar.Valid {
+			user.Avatar = avatar.String
+		}
+		if bio.Valid {
+			user.Bio = bio.String
+		}
+		user.IsActive = true
+		suggestion.User = &user
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// RefreshUser recomputes and stores the suggestion list for a single user
+func (s *SuggestionService) RefreshUser(ctx context.Context, userID string) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, suggestionQuery, userID, suggestionsPerUser,
+		suggestionMutualWeight, suggestionCategoryWeight, suggestionTagWeight, suggestionVerifiedBonus, suggestionSignalCap)
+	if err != nil {
+		return err
+	}
+
+	type candidate struct {
+		id      string
+		score   float64
+		mutuals int
+		reason  string
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.score, &c.mutuals, &c.reason); err != nil {
+			rows.Close()
+			return err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM public.follow_suggestions WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, c := range candidates {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO public.follow_suggestions (user_id, candidate_id, score, reason, mutual_count, computed_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, userID, c.id, c.score, c.reason, c.mutuals, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RefreshAll recomputes suggestions for every active user in batches
+func (s *SuggestionService) RefreshAll(ctx context.Context) error {
+	lastID := "00000000-0000-0000-0000-000000000000"
+	for {
+		rows, err := database.QueryWithContext(ctx,
+			"SELECT id FROM public.users WHERE is_active = true AND id > $1 ORDER BY id LIMIT $2",
+			lastID, suggestionRefreshBatch,
+		)
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.RefreshUser(ctx, id); err != nil {
+				log.Printf("Failed to refresh follow suggestions for user %s: %v", id, err)
+			}
+		}
+
+		if len(ids) < suggestionRefreshBatch {
+			return nil
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
+
+// StartSuggestionJob precomputes suggestions once a day
+func (s *SuggestionService) StartSuggestionJob(ctx context.Context) {
+	ticker := time.NewTicker(24 * time.Hour)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 2*time.Hour)
+				if err := s.RefreshAll(jobCtx); err != nil {
+					log.Printf("Follow suggestion refresh failed: %v", err)
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
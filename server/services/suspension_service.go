@@ -0,0 +1,465 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+	"tech-bant-community/server/utils"
+)
+
+var (
+	ErrNotSuspended              = errors.New("user is not suspended")
+	ErrInvalidSuspensionScope    = errors.New("suspension scope must be 'read_only' or 'full'")
+	ErrInvalidSuspensionReason   = errors.New("suspension reason is required and must be at most 1000 characters")
+	ErrInvalidSuspensionDuration = errors.New("suspension duration must be 0 (until lifted) or up to 5 years in hours")
+)
+
+// suspensionExpiryBatch is how many expired suspensions one job run lifts
+const suspensionExpiryBatch = 100
+
+const suspensionColumns = `id, user_id, scope, reason, expires_at, report_id, created_by, created_at,
+	lifted_at, lifted_by, COALESCE(lift_reason, '')`
+
+// suspensionCache holds each user's suspension status, including "not
+// suspended", for the auth middleware. It is shared by every
+// SuspensionService so changes made through any of them are seen at once;
+// other server instances see them within SuspensionCacheTTL.
+var suspensionCache = struct {
+	sync.Mutex
+	entries map[string]suspensionCacheEntry
+}{entries: map[string]suspensionCacheEntry{}}
+
+type suspensionCacheEntry struct {
+	suspension *models.Suspension
+	expires    time.Time
+}
+
+// invalidateSuspension drops a user's cached suspension status
+func invalidateSuspension(userID string) {
+	suspensionCache.Lock()
+	delete(suspensionCache.entries, userID)
+	suspensionCache.Unlock()
+}
+
+// SuspensionService suspends users, lifts suspensions when they expire and
+// tells the auth middleware who is suspended
+type SuspensionService struct {
+	db           *sql.DB
+	emailService *EmailService
+}
+
+// NewSuspensionService creates a new SuspensionService instance
+func NewSuspensionService(db *sql.DB, emailService *EmailService) *SuspensionService {
+	return &SuspensionService{db: db, emailService: emailService}
+}
+
+// Suspend suspends a user, replacing any active suspension, and emails
+// them the notice
+func (s *SuspensionService) Suspend(ctx context.Context, userID, adminID string, req *models.SuspendUserRequest) (*models.Suspension, error) {
+	scope, expiresAt, err := validateSuspension(req.Scope, req.DurationHours)
+	if err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(utils.SanitizeHTML(req.Reason))
+	if reason == "" || !utils.ValidateLength(reason, 1, constants.MaxSuspensionReasonLength) {
+		return nil, ErrInvalidSuspensionReason
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	previous, suspension, err := suspendUserTx(ctx, tx, userID, scope, reason, expiresAt, adminID, "", time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionUserSuspend, "user", userID, previous, suspension); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	invalidateSuspension(userID)
+	s.notifySuspendedAsync(suspension)
+
+	return suspension, nil
+}
+
+// validateSuspension checks a requested scope and duration and returns the
+// scope (full by default) and expiry (nil for until lifted)
+func validateSuspension(scope string, hours int) (string, *time.Time, error) {
+	switch scope {
+	case "":
+		scope = models.SuspensionScopeFull
+	case models.SuspensionScopeReadOnly, models.SuspensionScopeFull:
+	default:
+		return "", nil, ErrInvalidSuspensionScope
+	}
+	if hours < 0 || hours > constants.MaxSuspensionHours {
+		return "", nil, ErrInvalidSuspensionDuration
+	}
+	if hours == 0 {
+		return scope, nil, nil
+	}
+	expiresAt := time.Now().UTC().Add(time.Duration(hours) * time.Hour)
+	return scope, &expiresAt, nil
+}
+
+// suspendUserTx replaces the user's active suspension, if any, with a new
+// one and returns both. A full suspension deactivates the user and queues
+// the user.banned webhook; a read-only one reactivates a fully suspended
+// user. Callers invalidate the cached status after committing.
+func suspendUserTx(ctx context.Context, tx *sql.Tx, userID, scope, reason string, expiresAt *time.Time, adminID, reportID string, now time.Time) (*models.Suspension, *models.Suspension, error) {
+	if _, err := lockUserAdminState(ctx, tx, userID); err != nil {
+		return nil, nil, err
+	}
+
+	previous, err := scanSuspension(tx.QueryRowContext(ctx, `
+		UPDATE public.user_suspensions
+		SET lifted_at = $2, lifted_by = NULLIF($3, '')::uuid, lift_reason = 'replaced'
+		WHERE user_id::text = $1 AND lifted_at IS NULL
+		RETURNING `+suspensionColumns,
+		userID, now, adminID))
+	if err == sql.ErrNoRows {
+		previous = nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	suspension, err := scanSuspension(tx.QueryRowContext(ctx, `
+		INSERT INTO public.user_suspensions (user_id, scope, reason, expires_at, report_id, created_by, created_at)
+		VALUES ($1::uuid, $2, $3, $4, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, $7)
+		RETURNING `+suspensionColumns,
+		userID, scope, reason, expiresAt, reportID, adminID, now))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if scope == models.SuspensionScopeFull {
+		err = banUserTx(ctx, tx, userID, now)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE public.users SET is_active = true, updated_at = $2 WHERE id::text = $1 AND is_active = false AND deleted_at IS NULL",
+			userID, now)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return previous, suspension, nil
+}
+
+// Lift ends a user's active suspension early and reactivates the account
+func (s *SuspensionService) Lift(ctx context.Context, userID, adminID string) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockUserAdminState(ctx, tx, userID); err != nil {
+		return err
+	}
+	suspension, err := liftSuspensionTx(ctx, tx, userID, adminID, "lifted", time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionUserUnsuspend, "user", userID, suspension, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	invalidateSuspension(userID)
+	return nil
+}
+
+// liftSuspensionTx lifts the user's active suspension and reactivates the
+// account, unless it was deleted. It returns the suspension as it was
+// before being lifted, or nil for an account deactivated without one.
+func liftSuspensionTx(ctx context.Context, tx *sql.Tx, userID, adminID, liftReason string, now time.Time) (*models.Suspension, error) {
+	suspension, err := scanSuspension(tx.QueryRowContext(ctx, `
+		SELECT `+suspensionColumns+`
+		FROM public.user_suspensions
+		WHERE user_id::text = $1 AND lifted_at IS NULL
+		FOR UPDATE
+	`, userID))
+	if err == sql.ErrNoRows {
+		suspension = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if suspension != nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.user_suspensions
+			SET lifted_at = $2, lifted_by = NULLIF($3, '')::uuid, lift_reason = $4
+			WHERE id = $1
+		`, suspension.ID, now, adminID, liftReason)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result, err := tx.ExecContext(ctx,
+		"UPDATE public.users SET is_active = true, updated_at = $2 WHERE id::text = $1 AND is_active = false AND deleted_at IS NULL",
+		userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); suspension == nil && n == 0 {
+		return nil, ErrNotSuspended
+	}
+
+	return suspension, nil
+}
+
+// ActiveSuspension returns the user's current suspension, or nil. Accounts
+// deactivated without a suspension record are treated as fully suspended.
+// Results are cached for SuspensionCacheTTL; the auth middleware calls
+// this on every request.
+func (s *SuspensionService) ActiveSuspension(ctx context.Context, userID string) (*models.Suspension, error) {
+	now := time.Now()
+	suspensionCache.Lock()
+	entry, ok := suspensionCache.entries[userID]
+	suspensionCache.Unlock()
+	if ok && now.Before(entry.expires) {
+		return currentSuspension(entry.suspension, now), nil
+	}
+
+	suspension, err := s.loadSuspension(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	suspensionCache.Lock()
+	if len(suspensionCache.entries) >= 10000 {
+		for id, cached := range suspensionCache.entries {
+			if now.After(cached.expires) {
+				delete(suspensionCache.entries, id)
+			}
+		}
+	}
+	suspensionCache.entries[userID] = suspensionCacheEntry{suspension: suspension, expires: now.Add(constants.SuspensionCacheTTL)}
+	suspensionCache.Unlock()
+
+	return currentSuspension(suspension, now), nil
+}
+
+// currentSuspension returns nil for a suspension that has expired but not
+// been lifted by the job yet
+func currentSuspension(suspension *models.Suspension, now time.Time) *models.Suspension {
+	if suspension == nil || (suspension.ExpiresAt != nil && !now.Before(*suspension.ExpiresAt)) {
+		return nil
+	}
+	return suspension
+}
+
+func (s *SuspensionService) loadSuspension(ctx context.Context, userID string) (*models.Suspension, error) {
+	var active bool
+	err := database.QueryRowWithContext(ctx,
+		"SELECT COALESCE(is_active, true) FROM public.users WHERE id::text = $1", userID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suspension, err := scanSuspension(database.QueryRowWithContext(ctx, `
+		SELECT `+suspensionColumns+`
+		FROM public.user_suspensions
+		WHERE user_id::text = $1 AND lifted_at IS NULL
+	`, userID))
+	if err == sql.ErrNoRows {
+		if active {
+			return nil, nil
+		}
+		return &models.Suspension{UserID: userID, Scope: models.SuspensionScopeFull}, nil
+	}
+	return suspension, err
+}
+
+// ListSuspensions returns a user's suspensions, newest first
+func (s *SuspensionService) ListSuspensions(ctx context.Context, userID string) ([]*models.Suspension, error) {
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+suspensionColumns+`
+		FROM public.user_suspensions
+		WHERE user_id::text = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suspensions := []*models.Suspension{}
+	for rows.Next() {
+		suspension, err := scanSuspension(rows)
+		if err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, suspension)
+	}
+	return suspensions, rows.Err()
+}
+
+// StartSuspensionJob lifts expired suspensions every minute. Until it runs,
+// the auth middleware already ignores them.
+func (s *SuspensionService) StartSuspensionJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.SuspensionPollInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+				for {
+					lifted, err := s.LiftExpired(jobCtx)
+					if err != nil {
+						log.Printf("Suspension job error: %v", err)
+					}
+					if lifted < suspensionExpiryBatch || err != nil {
+						break
+					}
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// LiftExpired lifts a batch of suspensions whose expiry has passed and
+// returns how many it lifted
+func (s *SuspensionService) LiftExpired(ctx context.Context) (int, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT user_id
+		FROM public.user_suspensions
+		WHERE lifted_at IS NULL AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, now, suspensionExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		suspension, err := liftSuspensionTx(ctx, tx, userID, "", "expired", now)
+		if err != nil {
+			return 0, err
+		}
+		if err := recordAudit(ctx, tx, models.AuditActionUserUnsuspend, "user", userID, suspension, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, userID := range userIDs {
+		invalidateSuspension(userID)
+	}
+	return len(userIDs), nil
+}
+
+// notifySuspendedAsync emails the suspended user the reason and expiry
+func (s *SuspensionService) notifySuspendedAsync(suspension *models.Suspension) {
+	if s.emailService == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var email string
+		err := database.QueryRowWithContext(ctx,
+			"SELECT email FROM public.users WHERE id::text = $1", suspension.UserID).Scan(&email)
+		if err != nil {
+			log.Printf("Failed to look up suspended user %s: %v", suspension.UserID, err)
+			return
+		}
+		if err := s.emailService.SendSuspensionEmail(ctx, email, suspension); err != nil {
+			log.Printf("Failed to send suspension email to user %s: %v", suspension.UserID, err)
+		}
+	}()
+}
+
+func scanSuspension(row rowScanner) (*models.Suspension, error) {
+	var suspension models.Suspension
+	var expiresAt, liftedAt sql.NullTime
+	var reportID, createdBy, liftedBy sql.NullString
+	err := row.Scan(&suspension.ID, &suspension.UserID, &suspension.Scope, &suspension.Reason, &expiresAt,
+		&reportID, &createdBy, &suspension.CreatedAt, &liftedAt, &liftedBy, &suspension.LiftReason)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		suspension.ExpiresAt = &expiresAt.Time
+	}
+	if liftedAt.Valid {
+		suspension.LiftedAt = &liftedAt.Time
+	}
+	suspension.ReportID = reportID.String
+	suspension.CreatedBy = createdBy.String
+	suspension.LiftedBy = liftedBy.String
+	return &suspension, nil
+}
+
+// SuspensionForUser is the suspension as shown to the suspended user,
+// without the moderators involved
+func SuspensionForUser(suspension *models.Suspension) *models.Suspension {
+	if suspension == nil {
+		return nil
+	}
+	shown := *suspension
+	shown.CreatedBy = ""
+	shown.LiftedBy = ""
+	return &shown
+}
+
+// SuspensionNotice is the user-facing description of a suspension
+func SuspensionNotice(suspension *models.Suspension) string {
+	notice := "Your account has been suspended"
+	if suspension.Scope == models.SuspensionScopeReadOnly {
+		notice = "Your account is read-only"
+	}
+	if suspension.ExpiresAt == nil {
+		return notice + " until a moderator lifts the suspension."
+	}
+	return fmt.Sprintf("%s until %s.", notice, suspension.ExpiresAt.UTC().Format("January 2, 2006 15:04 MST"))
+}
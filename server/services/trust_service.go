@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/lib/pq"
+)
+
+// trustRequirements lists what each level above New takes, lowest first.
+// A user reaches a level only if they also meet every level below it.
+var trustRequirements = []models.TrustRequirement{
+	{
+		Level: models.TrustLevelBasic, Name: "basic",
+		MinAccountAgeDays: 2, EmailVerified: true, MinPosts: constants.TrustPremoderatedPosts, MaxUpheldReports: 2,
+	},
+	{
+		Level: models.TrustLevelMember, Name: "member",
+		MinAccountAgeDays: 30, EmailVerified: true, MinPosts: 10, MinLikesReceived: 20, MaxUpheldReports: 0,
+	},
+	{
+		Level: models.TrustLevelTrusted, Name: "trusted",
+		MinAccountAgeDays: 180, EmailVerified: true, MinPosts: 50, MinLikesReceived: 200, MaxUpheldReports: 0,
+	},
+}
+
+const trustRecomputeBatch = 500
+
+// trustStatsQuery reads what trust levels are computed from. Callers add a
+// WHERE clause whose parameters start at $4, and pass trustStatsArgs.
+const trustStatsQuery = `
+	SELECT u.id, u.is_admin, u.trust_level, u.trust_level_updated_at,
+		GREATEST(EXTRACT(DAY FROM NOW() - u.created_at), 0)::int,
+		u.email_verified_at IS NOT NULL,
+		COALESCE(u.posts_count, 0),
+		(SELECT COUNT(*) FROM public.reputation_events e WHERE e.user_id = u.id AND e.event_type = ANY($1)),
+		(SELECT COUNT(*) FROM public.reputation_events e WHERE e.user_id = u.id AND e.event_type = $2 AND e.created_at >= $3)
+	FROM public.users u
+`
+
+type userTrust struct {
+	userID    string
+	isAdmin   bool
+	level     int
+	updatedAt sql.NullTime
+	stats     models.TrustStats
+}
+
+func trustStatsArgs(args ...interface{}) []interface{} {
+	return append([]interface{}{
+		pq.Array([]string{ReputationPostLikeReceived, ReputationCommentLikeReceived}),
+		ReputationReportUpheld, time.Now().UTC().Add(-constants.TrustUpheldReportWindow),
+	}, args...)
+}
+
+func scanUserTrust(row rowScanner) (*userTrust, error) {
+	var t userTrust
+	err := row.Scan(&t.userID, &t.isAdmin, &t.level, &t.updatedAt, &t.stats.AccountAgeDays, &t.stats.EmailVerified,
+		&t.stats.Posts, &t.stats.LikesReceived, &t.stats.UpheldReports)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// meetsTrustRequirement reports whether stats satisfy one level's requirement
+func meetsTrustRequirement(stats models.TrustStats, req models.TrustRequirement) bool {
+	return stats.AccountAgeDays >= req.MinAccountAgeDays &&
+		(stats.EmailVerified || !req.EmailVerified) &&
+		stats.Posts >= req.MinPosts &&
+		stats.LikesReceived >= req.MinLikesReceived &&
+		stats.UpheldReports <= req.MaxUpheldReports
+}
+
+// computeTrustLevel returns the highest level whose requirements, and those
+// of every level below it, stats meet
+func computeTrustLevel(stats models.TrustStats, isAdmin bool) int {
+	if isAdmin {
+		return models.TrustLevelTrusted
+	}
+	level := models.TrustLevelNew
+	for _, req := range trustRequirements {
+		if !meetsTrustRequirement(stats, req) {
+			break
+		}
+		level = req.Level
+	}
+	return level
+}
+
+func trustLevelName(level int) string {
+	for _, req := range trustRequirements {
+		if req.Level == level {
+			return req.Name
+		}
+	}
+	return "new"
+}
+
+// userTrustLevel returns the trust level in force for a user. Admins are
+// always trusted, whatever the last recompute stored.
+func userTrustLevel(ctx context.Context, userID string) (level, posts int, err error) {
+	err = database.QueryRowWithContext(ctx, `
+		SELECT CASE WHEN is_admin THEN $2 ELSE trust_level END, COALESCE(posts_count, 0)
+		FROM public.users WHERE id::text = $1
+	`, userID, models.TrustLevelTrusted).Scan(&level, &posts)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrUserNotFound
+	}
+	return level, posts, err
+}
+
+// premoderatePost queues a post, or an edit to one, from a new account for
+// review if it is one of the account's first posts or contains links. It
+// returns ErrContentHeld once queued and nil if the post can be published.
+func premoderatePost(ctx context.Context, userID, kind, targetID string, payload interface{}, title, content string) error {
+	if isApprovedContent(ctx) {
+		return nil
+	}
+	level, posts, err := userTrustLevel(ctx, userID)
+	if err != nil || level > models.TrustLevelNew {
+		return err
+	}
+
+	var reason string
+	switch {
+	case kind == models.QueueKindPost && posts < constants.TrustPremoderatedPosts:
+		reason = fmt.Sprintf("New account: the first %d posts are reviewed", constants.TrustPremoderatedPosts)
+	case linkPattern.MatchString(title) || linkPattern.MatchString(content):
+		reason = "New account: posts with links are reviewed"
+	default:
+		return nil
+	}
+
+	if err := queueForReview(ctx, userID, kind, models.QueueSourceTrust, targetID, payload, reason, nil); err != nil {
+		return err
+	}
+	return ErrContentHeld
+}
+
+// TrustService computes users' trust levels
+type TrustService struct {
+	db *sql.DB
+}
+
+// NewTrustService creates a new TrustService instance
+func NewTrustService(db *sql.DB) *TrustService {
+	return &TrustService{db: db}
+}
+
+// GetTrust returns a user's trust level, the stats it is computed from and
+// what the next level takes
+func (s *TrustService) GetTrust(ctx context.Context, userID string) (*models.TrustStatus, error) {
+	t, err := scanUserTrust(database.QueryRowWithContext(ctx,
+		trustStatsQuery+" WHERE u.id::text = $4 AND u.deleted_at IS NULL",
+		trustStatsArgs(userID)...))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	level := t.level
+	if t.isAdmin {
+		level = models.TrustLevelTrusted
+	}
+	status := &models.TrustStatus{
+		UserID: t.userID,
+		Level:  level,
+		Name:   trustLevelName(level),
+		Stats:  t.stats,
+	}
+	if t.updatedAt.Valid {
+		status.UpdatedAt = &t.updatedAt.Time
+	}
+	for _, req := range trustRequirements {
+		if req.Level == level+1 {
+			next := req
+			status.Next = &next
+		}
+	}
+	return status, nil
+}
+
+// StartTrustJob recomputes every trust level at startup and then on a
+// schedule, promoting and demoting users as their stats change
+func (s *TrustService) StartTrustJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.TrustRecomputeInterval)
+	go func() {
+		run := func() {
+			jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			defer cancel()
+			promoted, demoted, err := s.RecomputeAll(jobCtx)
+			if err != nil {
+				log.Printf("Trust level job error: %v", err)
+			}
+			if promoted > 0 || demoted > 0 {
+				log.Printf("Trust levels recomputed: %d promoted, %d demoted", promoted, demoted)
+			}
+		}
+
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// RecomputeAll recomputes the trust level of every active user in batches,
+// writing only the levels that changed
+func (s *TrustService) RecomputeAll(ctx context.Context) (promoted, demoted int, err error) {
+	cursor := ""
+	for {
+		rows, err := database.QueryWithContext(ctx,
+			trustStatsQuery+" WHERE u.id::text > $4 AND u.deleted_at IS NULL ORDER BY u.id::text LIMIT $5",
+			trustStatsArgs(cursor, trustRecomputeBatch)...)
+		if err != nil {
+			return promoted, demoted, err
+		}
+		var batch []*userTrust
+		for rows.Next() {
+			t, err := scanUserTrust(rows)
+			if err != nil {
+				rows.Close()
+				return promoted, demoted, err
+			}
+			batch = append(batch, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return promoted, demoted, err
+		}
+
+		for _, t := range batch {
+			level := computeTrustLevel(t.stats, t.isAdmin)
+			if level == t.level {
+				continue
+			}
+			_, err := database.ExecWithContext(ctx, `
+				UPDATE public.users SET trust_level = $2, trust_level_updated_at = $3 WHERE id::text = $1
+			`, t.userID, level, time.Now().UTC())
+			if err != nil {
+				return promoted, demoted, err
+			}
+			if level > t.level {
+				promoted++
+			} else {
+				demoted++
+			}
+		}
+
+		if len(batch) < trustRecomputeBatch {
+			return promoted, demoted, nil
+		}
+		cursor = batch[len(batch)-1].userID
+	}
+}
@@ -2,16 +2,42 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"regexp"
 	"strings"
 	"time"
 
+	"tech-bant-community/server/constants"
 	"tech-bant-community/server/database"
 	"tech-bant-community/server/models"
 	"tech-bant-community/server/utils"
+
+	"github.com/lib/pq"
+)
+
+// userColumns is the column list scanned by scanUserRow
+const userColumns = `id, name, handle, email, avatar, cover_photo, bio, location, website, social_links, skills, pronouns, employer, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, reputation, email_verified_at IS NOT NULL, created_at, updated_at`
+
+// Errors returned by UserService
+var (
+	ErrInvalidProfile      = errors.New("invalid profile")
+	ErrInvalidHandle       = errors.New("invalid handle")
+	ErrHandleTaken         = errors.New("handle is already taken")
+	ErrHandleChangeTooSoon = errors.New("handle was changed too recently")
 )
 
+var handleBaseRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
 // UserService handles user operations
 type UserService struct {
 	db    *sql.DB
@@ -39,13 +65,13 @@ func (s *UserService) GetOrCreateUser(ctx context.Context, userID, email, name s
 	// Create new user
 	now := time.Now().UTC()
 	avatar := "https://images.pexels.com/photos/774909/pexels-photo-774909.jpeg?auto=compress&cs=tinysrgb&w=40&h=40&fit=crop"
+	handle := s.GenerateHandle(ctx, name)
 
 	query := `
-		INSERT INTO public.users (id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		INSERT INTO public.users (id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at, handle, handle_skeleton)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 		ON CONFLICT (id) DO UPDATE SET updated_at = $17
-		RETURNING id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at
-	`
+		RETURNING ` + userColumns
 
 	row := database.QueryRowWithContext(ctx, query,
 		userID, name, email, avatar,
@@ -54,6 +80,7 @@ func (s *UserService) GetOrCreateUser(ctx context.Context, userID, email, name s
 		models.RoleUser, "email",
 		0, 0, 0, // posts_count, followers_count, following_count
 		now, now,
+		handle, utils.HandleSkeleton(handle),
 	)
 
 	return s.scanUser(row)
@@ -61,47 +88,42 @@ func (s *UserService) GetOrCreateUser(ctx context.Context, userID, email, name s
 
 // GetUser gets a user by ID (uses counter cache for posts count)
 func (s *UserService) GetUser(ctx context.Context, userID string) (*models.User, error) {
-	query := `
-		SELECT u.id, u.name, u.email, u.avatar, u.bio, u.location, u.website, 
-		       u.is_admin, u.is_verified, u.is_active, u.role, u.provider,
-		       u.posts_count, u.followers_count, u.following_count,
-		       u.created_at, u.updated_at
-		FROM public.users u
-		WHERE u.id = $1
-	`
-
-	row := database.QueryRowWithContext(ctx, query, userID)
-	var user models.User
-	var avatar, bio, location, website sql.NullString
+	query := "SELECT " + userColumns + " FROM public.users WHERE id = $1"
+	return s.scanUser(database.QueryRowWithContext(ctx, query, userID))
+}
 
-	err := row.Scan(
-		&user.ID, &user.Name, &user.Email, &avatar, &bio, &location, &website,
-		&user.IsAdmin, &user.IsVerified, &user.IsActive, &user.Role, &user.Provider,
-		&user.PostsCount, &user.FollowersCount, &user.FollowingCount,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
+// UpdateUser updates user profile. Validation failures wrap ErrInvalidProfile.
+// Updates matching a hold or flag filter rule are queued for review and
+// return ErrContentHeld.
+func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models.UpdateProfileRequest) (*models.User, error) {
+	fields := []filterField{
+		{name: "name", value: &req.Name},
+		{name: "bio", value: &req.Bio},
+		{name: "location", value: &req.Location},
+		{name: "website", value: &req.Website, noMask: true},
+		{name: "pronouns", value: req.Pronouns},
+		{name: "employer", value: req.Employer},
 	}
-
-	if avatar.Valid {
-		user.Avatar = avatar.String
+	for i := range req.Skills {
+		fields = append(fields, filterField{name: "skills", value: &req.Skills[i]})
 	}
-	if bio.Valid {
-		user.Bio = bio.String
+	if links := req.SocialLinks; links != nil {
+		// Links, like the website, are rejected rather than masked
+		fields = append(fields,
+			filterField{name: "socialLinks.github", value: &links.GitHub, noMask: true},
+			filterField{name: "socialLinks.linkedin", value: &links.LinkedIn, noMask: true},
+			filterField{name: "socialLinks.x", value: &links.X, noMask: true},
+			filterField{name: "socialLinks.mastodon", value: &links.Mastodon, noMask: true},
+		)
 	}
-	if location.Valid {
-		user.Location = location.String
+	verdict, err := filterContent(ctx, models.FilterTargetProfile, fields...)
+	if err != nil {
+		return nil, err
 	}
-	if website.Valid {
-		user.Website = website.String
+	if verdict.Action == models.FilterActionHold || verdict.Action == models.FilterActionFlag {
+		return nil, holdContent(ctx, userID, models.QueueKindProfile, "", req, verdict)
 	}
 
-	return &user, nil
-}
-
-// UpdateUser updates user profile
-func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models.UpdateProfileRequest) (*models.User, error) {
 	updates := []string{"updated_at = $1"}
 	args := []interface{}{time.Now().UTC()}
 	argIndex := 2
@@ -109,7 +131,7 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 	if req.Name != "" {
 		name := utils.SanitizeString(req.Name)
 		if !utils.ValidateLength(name, 1, 100) {
-			return nil, fmt.Errorf("name must be between 1 and 100 characters")
+			return nil, fmt.Errorf("%w: name must be between 1 and 100 characters", ErrInvalidProfile)
 		}
 		updates = append(updates, fmt.Sprintf("name = $%d", argIndex))
 		args = append(args, name)
@@ -119,7 +141,7 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 	if req.Bio != "" {
 		bio := utils.SanitizeString(req.Bio)
 		if !utils.ValidateLength(bio, 0, 500) {
-			return nil, fmt.Errorf("bio must be less than 500 characters")
+			return nil, fmt.Errorf("%w: bio must be less than 500 characters", ErrInvalidProfile)
 		}
 		updates = append(updates, fmt.Sprintf("bio = $%d", argIndex))
 		args = append(args, bio)
@@ -129,7 +151,7 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 	if req.Location != "" {
 		location := utils.SanitizeString(req.Location)
 		if !utils.ValidateLength(location, 0, 100) {
-			return nil, fmt.Errorf("location must be less than 100 characters")
+			return nil, fmt.Errorf("%w: location must be less than 100 characters", ErrInvalidProfile)
 		}
 		updates = append(updates, fmt.Sprintf("location = $%d", argIndex))
 		args = append(args, location)
@@ -138,7 +160,7 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 
 	if req.Website != "" {
 		if !utils.ValidateURL(req.Website) {
-			return nil, fmt.Errorf("invalid website URL")
+			return nil, fmt.Errorf("%w: invalid website URL", ErrInvalidProfile)
 		}
 		updates = append(updates, fmt.Sprintf("website = $%d", argIndex))
 		args = append(args, req.Website)
@@ -147,13 +169,66 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 
 	if req.Avatar != "" {
 		if !utils.ValidateURL(req.Avatar) {
-			return nil, fmt.Errorf("invalid avatar URL")
+			return nil, fmt.Errorf("%w: invalid avatar URL", ErrInvalidProfile)
 		}
 		updates = append(updates, fmt.Sprintf("avatar = $%d", argIndex))
 		args = append(args, req.Avatar)
 		argIndex++
 	}
 
+	if req.CoverPhoto != "" {
+		if !utils.ValidateURL(req.CoverPhoto) {
+			return nil, fmt.Errorf("%w: invalid cover photo URL", ErrInvalidProfile)
+		}
+		updates = append(updates, fmt.Sprintf("cover_photo = $%d", argIndex))
+		args = append(args, req.CoverPhoto)
+		argIndex++
+	}
+
+	if req.SocialLinks != nil {
+		links, err := utils.SanitizeSocialLinks(req.SocialLinks)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+		}
+		linksJSON, err := json.Marshal(links)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, fmt.Sprintf("social_links = $%d", argIndex))
+		args = append(args, string(linksJSON))
+		argIndex++
+	}
+
+	if req.Skills != nil {
+		skills, err := utils.SanitizeSkills(req.Skills)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+		}
+		updates = append(updates, fmt.Sprintf("skills = $%d", argIndex))
+		args = append(args, pq.Array(skills))
+		argIndex++
+	}
+
+	if req.Pronouns != nil {
+		pronouns, err := utils.SanitizePronouns(*req.Pronouns)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+		}
+		updates = append(updates, fmt.Sprintf("pronouns = NULLIF($%d, '')", argIndex))
+		args = append(args, pronouns)
+		argIndex++
+	}
+
+	if req.Employer != nil {
+		employer, err := utils.SanitizeEmployer(*req.Employer)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+		}
+		updates = append(updates, fmt.Sprintf("employer = NULLIF($%d, '')", argIndex))
+		args = append(args, employer)
+		argIndex++
+	}
+
 	if len(updates) == 1 {
 		// Only updated_at, no other changes
 		return s.GetUser(ctx, userID)
@@ -161,7 +236,7 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 
 	args = append(args, userID)
 	query := fmt.Sprintf("UPDATE public.users SET %s WHERE id = $%d", strings.Join(updates, ", "), argIndex)
-	_, err := database.ExecWithContext(ctx, query, args...)
+	_, err = database.ExecWithContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -169,8 +244,10 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req *models
 	return s.GetUser(ctx, userID)
 }
 
-// SearchUsers searches users by name
-func (s *UserService) SearchUsers(ctx context.Context, query string, limit int) ([]*models.User, error) {
+// SearchUsers searches users by name or handle, hiding users blocked in
+// either direction, users whose privacy settings exclude the viewer and
+// shadow-banned users
+func (s *UserService) SearchUsers(ctx context.Context, viewerID, query string, limit int) ([]*models.User, error) {
 	if limit <= 0 {
 		limit = 10
 	}
@@ -179,15 +256,24 @@ func (s *UserService) SearchUsers(ctx context.Context, query string, limit int)
 	}
 
 	sqlQuery := `
-		SELECT id, name, email, avatar, bio, location, website, is_admin, is_verified, is_active, role, provider, posts_count, followers_count, following_count, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM public.users
-		WHERE name ILIKE $1 OR email ILIKE $1
+		WHERE (name ILIKE $1 OR handle ILIKE $1)
+			AND deleted_at IS NULL
+			AND NOT (id::text = ANY($3))
+			AND id NOT IN (` + hiddenFromSearch("$4") + `)
+			AND NOT ` + hiddenByShadowBan("users.id", "$4") + `
 		ORDER BY name
 		LIMIT $2
 	`
 
-	searchPattern := "%" + query + "%"
-	rows, err := database.QueryWithContext(ctx, sqlQuery, searchPattern, limit)
+	blocked, err := NewBlockService(s.db).BlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	searchPattern := "%" + strings.TrimPrefix(query, "@") + "%"
+	rows, err := database.QueryWithContext(ctx, sqlQuery, searchPattern, limit, pq.Array(blocked), viewerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search users: %w", err)
 	}
@@ -195,40 +281,82 @@ func (s *UserService) SearchUsers(ctx context.Context, query string, limit int)
 
 	var users []*models.User
 	for rows.Next() {
-		var user models.User
-		var avatar, bio, location, website sql.NullString
-
-		err := rows.Scan(
-			&user.ID, &user.Name, &user.Email, &avatar, &bio, &location, &website,
-			&user.IsAdmin, &user.IsVerified, &user.IsActive, &user.Role, &user.Provider,
-			&user.PostsCount, &user.FollowersCount, &user.FollowingCount,
-			&user.CreatedAt, &user.UpdatedAt,
-		)
+		user, err := scanUserRow(rows)
 		if err != nil {
 			continue
 		}
+		users = append(users, user)
+	}
 
-		if avatar.Valid {
-			user.Avatar = avatar.String
-		}
-		if bio.Valid {
-			user.Bio = bio.String
-		}
-		if location.Valid {
-			user.Location = location.String
-		}
-		if website.Valid {
-			user.Website = website.String
-		}
+	return users, nil
+}
 
-		users = append(users, &user)
+// GetUserPosts gets posts by a user. A shadow-banned user's posts are only
+// listed for themselves.
+func (s *UserService) GetUserPosts(ctx context.Context, viewerID, userID string, limit, offset int) ([]*models.Post, error) {
+	limit, offset = clampPage(limit, offset)
+
+	query := `
+		SELECT ` + userPostColumns + `
+		FROM public.posts p
+		WHERE p.author_id = $1 AND NOT ` + hiddenByShadowBan("p.author_id", "$4") + `
+		ORDER BY p.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	posts, err := s.queryPosts(ctx, query, userID, limit, offset, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user posts: %w", err)
 	}
+	return posts, nil
+}
 
-	return users, nil
+// GetUserLikedPosts gets the posts a user has liked, most recent like
+// first, leaving out posts by shadow-banned users other than the viewer
+func (s *UserService) GetUserLikedPosts(ctx context.Context, viewerID, userID string, limit, offset int) ([]*models.Post, error) {
+	limit, offset = clampPage(limit, offset)
+
+	query := `
+		SELECT ` + userPostColumns + `
+		FROM public.likes l
+		JOIN public.posts p ON p.id = l.post_id
+		WHERE l.user_id = $1 AND NOT ` + hiddenByShadowBan("p.author_id", "$4") + `
+		ORDER BY l.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	posts, err := s.queryPosts(ctx, query, userID, limit, offset, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liked posts: %w", err)
+	}
+	return posts, nil
 }
 
-// GetUserPosts gets posts by a user
-func (s *UserService) GetUserPosts(ctx context.Context, userID string, limit, offset int) ([]*models.Post, error) {
+// GetUserBookmarkedPosts gets the posts a user has bookmarked, most recent
+// first, leaving out posts by shadow-banned users other than the viewer
+func (s *UserService) GetUserBookmarkedPosts(ctx context.Context, viewerID, userID string, limit, offset int) ([]*models.Post, error) {
+	limit, offset = clampPage(limit, offset)
+
+	query := `
+		SELECT ` + userPostColumns + `
+		FROM public.bookmarks b
+		JOIN public.posts p ON p.id = b.post_id
+		WHERE b.user_id = $1 AND NOT ` + hiddenByShadowBan("p.author_id", "$4") + `
+		ORDER BY b.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	posts, err := s.queryPosts(ctx, query, userID, limit, offset, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bookmarked posts: %w", err)
+	}
+	return posts, nil
+}
+
+const userPostColumns = "p.id, p.title, p.content, p.author_id, p.category, p.tags, p.likes, p.comments, p.views, p.shares, p.is_pinned, p.is_hot, p.location, p.published_at, p.created_at, p.updated_at"
+
+// clampPage applies the default and maximum page size used by the user post lists
+func clampPage(limit, offset int) (int, int) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -238,18 +366,14 @@ func (s *UserService) GetUserPosts(ctx context.Context, userID string, limit, of
 	if offset < 0 {
 		offset = 0
 	}
+	return limit, offset
+}
 
-	query := `
-		SELECT id, title, content, author_id, category, tags, likes, comments, views, shares, is_pinned, is_hot, location, published_at, created_at, updated_at
-		FROM public.posts
-		WHERE author_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
-
-	rows, err := database.QueryWithContext(ctx, query, userID, limit, offset)
+// queryPosts runs a query selecting userPostColumns and scans the posts
+func (s *UserService) queryPosts(ctx context.Context, query string, args ...interface{}) ([]*models.Post, error) {
+	rows, err := database.QueryWithContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user posts: %w", err)
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -278,21 +402,231 @@ func (s *UserService) GetUserPosts(ctx context.Context, userID string, limit, of
 	return posts, nil
 }
 
+// GetUserByEmail gets a user by email address (case-insensitive). It is for
+// login flows only; email is never a public search key.
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM public.users WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL"
+	return s.scanUser(database.QueryRowWithContext(ctx, query, email))
+}
+
+// GetUserByHandle gets a user by handle (case-insensitive). If the handle
+// belonged to a user who has since changed it, the user is nil and the
+// current handle is returned so callers can redirect.
+func (s *UserService) GetUserByHandle(ctx context.Context, handle string) (*models.User, string, error) {
+	handle = utils.NormalizeHandle(handle)
+
+	query := "SELECT " + userColumns + " FROM public.users WHERE LOWER(handle) = LOWER($1)"
+	user, err := s.scanUser(database.QueryRowWithContext(ctx, query, handle))
+	if err == nil {
+		return user, "", nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, "", err
+	}
+
+	historyQuery := `
+		SELECT u.handle
+		FROM public.handle_history h
+		JOIN public.users u ON u.id = h.user_id
+		WHERE h.handle = LOWER($1) AND h.changed_at > $2 AND u.handle IS NOT NULL
+	`
+	cutoff := time.Now().UTC().Add(-constants.HandleRedirectRetention)
+	var current string
+	if err := database.QueryRowWithContext(ctx, historyQuery, handle, cutoff).Scan(&current); err != nil {
+		return nil, "", err
+	}
+	return nil, current, nil
+}
+
+// IsHandleAvailable reports whether a handle can be claimed by userID
+// (empty for users that do not exist yet). Handles are compared by their
+// confusable skeleton, and recently released handles stay reserved for
+// their previous owner.
+func (s *UserService) IsHandleAvailable(ctx context.Context, handle, userID string) (bool, error) {
+	if err := utils.ValidateHandle(handle); err != nil {
+		return false, nil
+	}
+
+	skeleton := utils.HandleSkeleton(handle)
+	cutoff := time.Now().UTC().Add(-constants.HandleRedirectRetention)
+	query := `
+		SELECT EXISTS (SELECT 1 FROM public.users WHERE handle_skeleton = $1 AND id::text <> $2)
+		    OR EXISTS (SELECT 1 FROM public.handle_history WHERE skeleton = $1 AND user_id::text <> $2 AND changed_at > $3)
+	`
+	var taken bool
+	if err := database.QueryRowWithContext(ctx, query, skeleton, userID, cutoff).Scan(&taken); err != nil {
+		return false, err
+	}
+	return !taken, nil
+}
+
+// GenerateHandle derives an available handle from a display name
+func (s *UserService) GenerateHandle(ctx context.Context, name string) string {
+	base := handleBaseRegex.ReplaceAllString(name, "")
+	if len(base) > 20 {
+		base = base[:20]
+	}
+	if len(base) < constants.MinHandleLength || utils.ValidateHandle(base) != nil {
+		base = "user" + base
+		if len(base) > 20 {
+			base = base[:20]
+		}
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 10; attempt++ {
+		if available, err := s.IsHandleAvailable(ctx, candidate, ""); err == nil && available {
+			return candidate
+		}
+		n, _ := rand.Int(rand.Reader, big.NewInt(10000))
+		candidate = fmt.Sprintf("%s_%04d", base, n.Int64())
+	}
+
+	n, _ := rand.Int(rand.Reader, big.NewInt(1<<32))
+	return fmt.Sprintf("user_%08x", n.Int64())
+}
+
+// ChangeHandle changes a user's handle. Changes are limited to one per
+// HandleChangeCooldown; case-only changes are always allowed. The old handle
+// keeps redirecting to the user for HandleRedirectRetention.
+func (s *UserService) ChangeHandle(ctx context.Context, userID, handle string) (*models.User, error) {
+	handle = utils.NormalizeHandle(handle)
+	if err := utils.ValidateHandle(handle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHandle, err)
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	var changedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT handle, handle_changed_at FROM public.users WHERE id = $1 FOR UPDATE", userID).Scan(&current, &changedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.String == handle {
+		return s.GetUser(ctx, userID)
+	}
+
+	now := time.Now().UTC()
+	caseOnly := strings.EqualFold(current.String, handle)
+	if !caseOnly {
+		if changedAt.Valid && now.Sub(changedAt.Time) < constants.HandleChangeCooldown {
+			return nil, ErrHandleChangeTooSoon
+		}
+		available, err := s.IsHandleAvailable(ctx, handle, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			return nil, ErrHandleTaken
+		}
+	}
+
+	if current.Valid && current.String != "" && !caseOnly {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO public.handle_history (handle, skeleton, user_id, changed_at)
+			VALUES (LOWER($1), $2, $3, $4)
+			ON CONFLICT (handle) DO UPDATE SET skeleton = $2, user_id = $3, changed_at = $4
+		`, current.String, utils.HandleSkeleton(current.String), userID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record handle history: %w", err)
+		}
+	}
+
+	// Reclaiming one of our own former handles removes its redirect
+	_, err = tx.ExecContext(ctx, "DELETE FROM public.handle_history WHERE handle = LOWER($1) AND user_id = $2", handle, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changedAtValue := changedAt
+	if !caseOnly {
+		changedAtValue = sql.NullTime{Time: now, Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.users
+		SET handle = $1, handle_skeleton = $2, handle_changed_at = $3, updated_at = $4
+		WHERE id = $5
+	`, handle, utils.HandleSkeleton(handle), changedAtValue, now, userID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrHandleTaken
+		}
+		return nil, fmt.Errorf("failed to update handle: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if s.cache != nil {
+		_ = s.cache.InvalidateUser(ctx, userID)
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// ResolveHandles maps lower-cased handles (e.g. from utils.ExtractMentions)
+// to user IDs. Unknown handles are omitted.
+func (s *UserService) ResolveHandles(ctx context.Context, handles []string) (map[string]string, error) {
+	resolved := make(map[string]string, len(handles))
+	if len(handles) == 0 {
+		return resolved, nil
+	}
+
+	query := "SELECT LOWER(handle), id FROM public.users WHERE LOWER(handle) = ANY($1)"
+	rows, err := database.QueryWithContext(ctx, query, pq.Array(handles))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve handles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var handle, id string
+		if err := rows.Scan(&handle, &id); err != nil {
+			continue
+		}
+		resolved[handle] = id
+	}
+
+	return resolved, nil
+}
+
 // scanUser scans a user from database row
 func (s *UserService) scanUser(row *sql.Row) (*models.User, error) {
+	return scanUserRow(row)
+}
+
+// scanUserRow scans the userColumns of a row into a models.User
+func scanUserRow(row rowScanner) (*models.User, error) {
 	var user models.User
-	var avatar, bio, location, website sql.NullString
+	var handle, avatar, coverPhoto, bio, location, website, pronouns, employer sql.NullString
+	var socialLinks []byte
+	var skills pq.StringArray
+	var reputation sql.NullInt64
 
 	err := row.Scan(
-		&user.ID, &user.Name, &user.Email, &avatar, &bio, &location, &website,
+		&user.ID, &user.Name, &handle, &user.Email, &avatar, &coverPhoto, &bio, &location, &website,
+		&socialLinks, &skills, &pronouns, &employer,
 		&user.IsAdmin, &user.IsVerified, &user.IsActive, &user.Role, &user.Provider,
-		&user.PostsCount, &user.FollowersCount, &user.FollowingCount,
-		&user.CreatedAt, &user.UpdatedAt,
+		&user.PostsCount, &user.FollowersCount, &user.FollowingCount, &reputation,
+		&user.EmailVerified, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if reputation.Valid {
+		user.Reputation = int(reputation.Int64)
+	}
+	if handle.Valid {
+		user.Handle = handle.String
+	}
 	if avatar.Valid {
 		user.Avatar = avatar.String
 	}
@@ -305,7 +639,24 @@ func (s *UserService) scanUser(row *sql.Row) (*models.User, error) {
 	if website.Valid {
 		user.Website = website.String
 	}
+	if coverPhoto.Valid {
+		user.CoverPhoto = coverPhoto.String
+	}
+	if pronouns.Valid {
+		user.Pronouns = pronouns.String
+	}
+	if employer.Valid {
+		user.Employer = employer.String
+	}
+	if len(skills) > 0 {
+		user.Skills = skills
+	}
+	if len(socialLinks) > 0 {
+		var links models.SocialLinks
+		if err := json.Unmarshal(socialLinks, &links); err == nil && links != (models.SocialLinks{}) {
+			user.SocialLinks = &links
+		}
+	}
 
 	return &user, nil
 }
-
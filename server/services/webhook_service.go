@@ -0,0 +1,724 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mrand "math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"tech-bant-community/server/config"
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/database"
+	"tech-bant-community/server/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrWebhookDisabled         = errors.New("webhook is disabled")
+	ErrInvalidWebhookURL       = errors.New("webhook URL must be a public http(s) URL")
+	ErrInvalidWebhookEvents    = errors.New("webhook events must be one or more of post.created, comment.created, report.created, user.banned")
+	ErrInvalidWebhookDesc      = errors.New("webhook description must be at most 500 characters")
+
+	errWebhookPrivateAddress = errors.New("webhook URL resolves to a private address")
+)
+
+// webhookEvents are the events webhooks can subscribe to
+var webhookEvents = map[string]bool{
+	models.WebhookEventPostCreated:    true,
+	models.WebhookEventCommentCreated: true,
+	models.WebhookEventReportCreated:  true,
+	models.WebhookEventUserBanned:     true,
+}
+
+// webhookLease is how long a claimed delivery stays claimed. A worker that
+// dies mid-delivery leaves it to be retried once the lease runs out.
+const webhookLease = 5 * time.Minute
+
+// WebhookService manages outgoing webhooks and sends their deliveries
+type WebhookService struct {
+	db     *sql.DB
+	cfg    *config.Config
+	client *http.Client
+}
+
+// NewWebhookService creates a new WebhookService instance
+func NewWebhookService(db *sql.DB, cfg *config.Config) *WebhookService {
+	return &WebhookService{db: db, cfg: cfg, client: newWebhookClient(cfg.WebhookAllowPrivateNetworks)}
+}
+
+// enqueueWebhookEvent queues a delivery of the event to every active webhook
+// subscribed to it. It runs in the caller's transaction so the event is sent
+// if and only if the change that caused it is committed.
+func enqueueWebhookEvent(ctx context.Context, tx *sql.Tx, event string, data interface{}) error {
+	now := time.Now().UTC()
+	payload := models.WebhookPayload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		CreatedAt: now,
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at, created_at)
+		SELECT id, $1, $2, $3, $4, $4 FROM public.webhooks
+		WHERE is_active = true AND $2 = ANY(events)
+	`, payload.ID, event, string(body), now)
+	return err
+}
+
+// webhookUser is the public part of a user included in webhook payloads
+func webhookUser(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     user.ID,
+		"name":   user.Name,
+		"handle": user.Handle,
+		"avatar": user.Avatar,
+	}
+}
+
+// CreateWebhook registers a webhook and returns it with its signing secret,
+// which is not shown again
+func (s *WebhookService) CreateWebhook(ctx context.Context, ownerID string, req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	if err := s.validateURL(req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Description) > 500 {
+		return nil, ErrInvalidWebhookDesc
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := "whsec_" + hex.EncodeToString(raw)
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	webhook, err := scanWebhook(tx.QueryRowContext(ctx, `
+		INSERT INTO public.webhooks (owner_id, url, secret, events, description)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+webhookColumns,
+		ownerID, req.URL, secret, pq.Array(events), req.Description))
+	if err != nil {
+		return nil, err
+	}
+	// Recorded before the secret is set, so it stays out of the log
+	if err := recordAudit(ctx, tx, models.AuditActionWebhookCreate, "webhook", webhook.ID, nil, webhook); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	webhook.Secret = secret
+	return webhook, nil
+}
+
+// ListWebhooks returns every registered webhook, newest first
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	rows, err := database.QueryWithContext(ctx,
+		"SELECT "+webhookColumns+" FROM public.webhooks ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// UpdateWebhook changes the fields set in req. Re-activating a webhook
+// resets its failure count.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, webhookID string, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	var urlValue, description sql.NullString
+	var events interface{}
+	var active sql.NullBool
+	if req.URL != nil {
+		if err := s.validateURL(*req.URL); err != nil {
+			return nil, err
+		}
+		urlValue = sql.NullString{String: *req.URL, Valid: true}
+	}
+	if req.Events != nil {
+		normalized, err := normalizeWebhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		events = pq.Array(normalized)
+	}
+	if req.Description != nil {
+		if len(*req.Description) > 500 {
+			return nil, ErrInvalidWebhookDesc
+		}
+		description = sql.NullString{String: *req.Description, Valid: true}
+	}
+	if req.IsActive != nil {
+		active = sql.NullBool{Bool: *req.IsActive, Valid: true}
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanWebhook(tx.QueryRowContext(ctx,
+		"SELECT "+webhookColumns+" FROM public.webhooks WHERE id::text = $1 FOR UPDATE", webhookID))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := scanWebhook(tx.QueryRowContext(ctx, `
+		UPDATE public.webhooks SET
+			url = COALESCE($2, url),
+			events = COALESCE($3, events),
+			description = COALESCE($4, description),
+			is_active = COALESCE($5, is_active),
+			consecutive_failures = CASE WHEN $5 THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN $5 THEN NULL WHEN $5 = false THEN COALESCE(disabled_at, $6) ELSE disabled_at END,
+			disabled_reason = CASE WHEN $5 THEN NULL WHEN $5 = false THEN COALESCE(disabled_reason, 'Disabled by an admin') ELSE disabled_reason END,
+			updated_at = $6
+		WHERE id::text = $1
+		RETURNING `+webhookColumns,
+		webhookID, urlValue, events, description, active, time.Now().UTC()))
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionWebhookUpdate, "webhook", webhook.ID, before, webhook); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, webhookID string) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanWebhook(tx.QueryRowContext(ctx,
+		"DELETE FROM public.webhooks WHERE id::text = $1 RETURNING "+webhookColumns, webhookID))
+	if err == sql.ErrNoRows {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionWebhookDelete, "webhook", before.ID, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first, optionally
+// filtered by status
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var exists bool
+	err := database.QueryRowWithContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM public.webhooks WHERE id::text = $1)", webhookID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	rows, err := database.QueryWithContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM public.webhook_deliveries
+		WHERE webhook_id::text = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues the payload of an earlier delivery again, as a new
+// delivery with the same event ID
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	var active bool
+	err := database.QueryRowWithContext(ctx,
+		"SELECT is_active FROM public.webhooks WHERE id::text = $1", webhookID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrWebhookDisabled
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	delivery, err := scanDelivery(tx.QueryRowContext(ctx, `
+		INSERT INTO public.webhook_deliveries (webhook_id, event_id, event, payload, redelivery_of, next_attempt_at, created_at)
+		SELECT webhook_id, event_id, event, payload, id, $3, $3
+		FROM public.webhook_deliveries
+		WHERE id::text = $1 AND webhook_id::text = $2
+		RETURNING `+deliveryColumns,
+		deliveryID, webhookID, now))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	after := map[string]interface{}{
+		"deliveryId":   delivery.ID,
+		"redeliveryOf": delivery.RedeliveryOf,
+		"event":        delivery.Event,
+		"eventId":      delivery.EventID,
+	}
+	if err := recordAudit(ctx, tx, models.AuditActionWebhookRedeliver, "webhook", webhookID, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// StartWebhookJob sends due webhook deliveries every few seconds
+func (s *WebhookService) StartWebhookJob(ctx context.Context) {
+	ticker := time.NewTicker(constants.WebhookPollInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				jobCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+				for {
+					sent, err := s.DeliverPending(jobCtx)
+					if err != nil {
+						log.Printf("Webhook job error: %v", err)
+					}
+					if sent < constants.WebhookBatchSize || err != nil {
+						break
+					}
+				}
+				cancel()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// claimedDelivery is a delivery claimed by DeliverPending
+type claimedDelivery struct {
+	id, webhookID, event string
+	payload              []byte
+	attempts             int
+	url, secret          string
+}
+
+// DeliverPending claims up to WebhookBatchSize due deliveries, sends them
+// concurrently and records the outcome. Returns how many it sent.
+func (s *WebhookService) DeliverPending(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	rows, err := database.QueryWithContext(ctx, `
+		UPDATE public.webhook_deliveries d
+		SET attempts = d.attempts + 1, last_attempt_at = $1, next_attempt_at = $2
+		FROM public.webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM public.webhook_deliveries dd
+			JOIN public.webhooks ww ON ww.id = dd.webhook_id AND ww.is_active = true
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= $1
+			ORDER BY dd.next_attempt_at
+			FOR UPDATE OF dd SKIP LOCKED
+			LIMIT $3
+		)
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+	`, now, now.Add(webhookLease), constants.WebhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var claimed []claimedDelivery
+	for rows.Next() {
+		var d claimedDelivery
+		if err := rows.Scan(&d.id, &d.webhookID, &d.event, &d.payload, &d.attempts, &d.url, &d.secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		claimed = append(claimed, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range claimed {
+		wg.Add(1)
+		go func(d claimedDelivery) {
+			defer wg.Done()
+			if err := s.recordAttempt(ctx, d, s.send(ctx, d)); err != nil {
+				log.Printf("Failed to record webhook delivery %s: %v", d.id, err)
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	return len(claimed), nil
+}
+
+// webhookAttempt is the outcome of one HTTP request to a webhook
+type webhookAttempt struct {
+	status   int
+	body     string
+	err      error
+	duration time.Duration
+}
+
+func (a webhookAttempt) succeeded() bool {
+	return a.err == nil && a.status >= 200 && a.status < 300
+}
+
+// send POSTs the payload, signed with the webhook's secret. The signature
+// header is "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">".
+func (s *WebhookService) send(ctx context.Context, d claimedDelivery) webhookAttempt {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(d.payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return webhookAttempt{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TechBant-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", d.id)
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set("X-Webhook-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil))))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return webhookAttempt{err: err, duration: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, constants.WebhookMaxResponseLogged))
+	// The rest of the body is drained so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	return webhookAttempt{
+		status:   resp.StatusCode,
+		body:     strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", ""),
+		duration: time.Since(start),
+	}
+}
+
+// recordAttempt logs an attempt and schedules the retry, if any. Failures
+// count towards disabling the webhook; a success resets the count.
+func (s *WebhookService) recordAttempt(ctx context.Context, d claimedDelivery, attempt webhookAttempt) error {
+	now := time.Now().UTC()
+	var errMsg sql.NullString
+	if attempt.err != nil {
+		errMsg = sql.NullString{String: attempt.err.Error(), Valid: true}
+	} else if !attempt.succeeded() {
+		errMsg = sql.NullString{String: fmt.Sprintf("endpoint responded with HTTP %d", attempt.status), Valid: true}
+	}
+	var responseStatus sql.NullInt64
+	if attempt.status != 0 {
+		responseStatus = sql.NullInt64{Int64: int64(attempt.status), Valid: true}
+	}
+
+	status := "pending"
+	var nextAttempt, completedAt sql.NullTime
+	switch {
+	case attempt.succeeded():
+		status = "succeeded"
+		completedAt = sql.NullTime{Time: now, Valid: true}
+	case d.attempts >= constants.WebhookMaxAttempts:
+		status = "failed"
+		completedAt = sql.NullTime{Time: now, Valid: true}
+	default:
+		nextAttempt = sql.NullTime{Time: now.Add(webhookBackoff(d.attempts)), Valid: true}
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.webhook_deliveries
+		SET status = $2, next_attempt_at = $3, completed_at = $4, response_status = $5,
+			response_body = $6, error = $7, duration_ms = $8
+		WHERE id = $1
+	`, d.id, status, nextAttempt, completedAt, responseStatus, attempt.body, errMsg, attempt.duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	if attempt.succeeded() {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE public.webhooks SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures <> 0", d.webhookID)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var failures int
+	var active bool
+	err = tx.QueryRowContext(ctx, `
+		UPDATE public.webhooks SET consecutive_failures = consecutive_failures + 1
+		WHERE id = $1
+		RETURNING consecutive_failures, is_active
+	`, d.webhookID).Scan(&failures, &active)
+	if err != nil {
+		return err
+	}
+	if active && failures >= constants.WebhookDisableAfter {
+		reason := fmt.Sprintf("Disabled after %d failed deliveries in a row", failures)
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.webhooks
+			SET is_active = false, disabled_at = $2, disabled_reason = $3, updated_at = $2
+			WHERE id = $1
+		`, d.webhookID, now, reason)
+		if err != nil {
+			return err
+		}
+		// Queued deliveries fail now rather than flood the endpoint when it
+		// is re-enabled; they can still be redelivered one by one
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.webhook_deliveries
+			SET status = 'failed', error = 'webhook disabled', next_attempt_at = NULL, completed_at = $2
+			WHERE webhook_id = $1 AND status = 'pending'
+		`, d.webhookID, now)
+		if err != nil {
+			return err
+		}
+		log.Printf("Webhook %s disabled after %d consecutive failures", d.webhookID, failures)
+	}
+	return tx.Commit()
+}
+
+// webhookBackoff returns the delay before the attempt after the given one:
+// exponential with up to 10% jitter, capped at WebhookRetryMaxDelay
+func webhookBackoff(attempts int) time.Duration {
+	delay := constants.WebhookRetryMaxDelay
+	if attempts < 20 {
+		delay = min(constants.WebhookRetryBaseDelay<<(attempts-1), constants.WebhookRetryMaxDelay)
+	}
+	return delay + mrand.N(delay/10+1)
+}
+
+// normalizeWebhookEvents validates and de-duplicates subscribed events
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	seen := make(map[string]bool, len(events))
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return nil, ErrInvalidWebhookEvents
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, ErrInvalidWebhookEvents
+	}
+	return normalized, nil
+}
+
+// validateURL rejects anything but absolute http(s) URLs. Hosts are checked
+// again when connecting, since a public name can resolve to a private address.
+func (s *WebhookService) validateURL(raw string) error {
+	if len(raw) > 2048 {
+		return ErrInvalidWebhookURL
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrInvalidWebhookURL
+	}
+	if s.cfg.WebhookAllowPrivateNetworks {
+		return nil
+	}
+	host := parsed.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrInvalidWebhookURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrInvalidWebhookURL
+	}
+	return nil
+}
+
+// newWebhookClient returns an HTTP client that doesn't follow redirects and,
+// unless allowPrivate is set, refuses to connect to non-public addresses
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errWebhookPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: constants.WebhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598)
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is a globally routable unicast address
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+const webhookColumns = `id, owner_id, url, events, COALESCE(description, ''), is_active,
+	consecutive_failures, disabled_at, COALESCE(disabled_reason, ''), created_at, updated_at`
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var disabledAt sql.NullTime
+	err := row.Scan(&webhook.ID, &webhook.OwnerID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Description,
+		&webhook.IsActive, &webhook.ConsecutiveFailures, &disabledAt, &webhook.DisabledReason,
+		&webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if disabledAt.Valid {
+		webhook.DisabledAt = &disabledAt.Time
+	}
+	return &webhook, nil
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at,
+	last_attempt_at, response_status, COALESCE(response_body, ''), COALESCE(error, ''), duration_ms,
+	redelivery_of, created_at, completed_at`
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload []byte
+	var nextAttempt, lastAttempt, completedAt sql.NullTime
+	var responseStatus, duration sql.NullInt64
+	var redeliveryOf sql.NullString
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &payload,
+		&delivery.Status, &delivery.Attempts, &nextAttempt, &lastAttempt, &responseStatus,
+		&delivery.ResponseBody, &delivery.Error, &duration, &redeliveryOf, &delivery.CreatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if nextAttempt.Valid {
+		delivery.NextAttemptAt = &nextAttempt.Time
+	}
+	if lastAttempt.Valid {
+		delivery.LastAttemptAt = &lastAttempt.Time
+	}
+	if responseStatus.Valid {
+		delivery.ResponseStatus = int(responseStatus.Int64)
+	}
+	if duration.Valid {
+		delivery.DurationMs = int(duration.Int64)
+	}
+	if redeliveryOf.Valid {
+		delivery.RedeliveryOf = redeliveryOf.String
+	}
+	if completedAt.Valid {
+		delivery.CompletedAt = &completedAt.Time
+	}
+	return &delivery, nil
+}
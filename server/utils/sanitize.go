@@ -3,10 +3,14 @@ package utils
 import (
 	"fmt"
 	"html"
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode"
 
+	"tech-bant-community/server/constants"
+	"tech-bant-community/server/models"
+
 	"github.com/microcosm-cc/bluemonday"
 )
 
@@ -179,3 +183,304 @@ func ValidateCategory(category string) bool {
 	}
 	return validCategories[strings.ToLower(category)]
 }
+
+var (
+	// Handle regex (ASCII letters, digits and underscores; must start with a letter)
+	handleRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+	// Mention regex (@handle not preceded by a word character, e.g. not an email)
+	mentionRegex = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@.])@([a-zA-Z][a-zA-Z0-9_]{2,29})`)
+
+	// Characters that look alike in most fonts, folded to a common form
+	handleConfusables = strings.NewReplacer(
+		"0", "o", "1", "l", "i", "l", "3", "e", "4", "a",
+		"5", "s", "7", "t", "8", "b", "9", "g",
+	)
+
+	// Handles that cannot be claimed because they collide with routes or
+	// could be used to impersonate staff
+	reservedHandles = []string{
+		"admin", "administrator", "root", "system", "support", "help",
+		"moderator", "mod", "staff", "team", "official", "security",
+		"api", "auth", "login", "logout", "signup", "register", "settings",
+		"me", "users", "user", "posts", "post", "comments", "search",
+		"notifications", "messages", "null", "undefined", "anonymous",
+		"deleted", "everyone", "here", "techbant", "tech_bant",
+	}
+)
+
+// NormalizeHandle trims whitespace and a leading "@" from a handle
+func NormalizeHandle(handle string) string {
+	return strings.TrimPrefix(strings.TrimSpace(handle), "@")
+}
+
+// HandleSkeleton folds a handle to its confusable skeleton so that
+// look-alike handles ("adm1n", "rnod") compare equal to the original.
+// Must stay in sync with public.handle_skeleton in the database.
+func HandleSkeleton(handle string) string {
+	s := handleConfusables.Replace(strings.ToLower(handle))
+	s = strings.ReplaceAll(s, "rn", "m")
+	s = strings.ReplaceAll(s, "vv", "w")
+	for strings.Contains(s, "__") {
+		s = strings.ReplaceAll(s, "__", "_")
+	}
+	return strings.Trim(s, "_")
+}
+
+// IsReservedHandle reports whether a handle (or a look-alike of it) is reserved
+func IsReservedHandle(handle string) bool {
+	skeleton := HandleSkeleton(handle)
+	for _, reserved := range reservedHandles {
+		if skeleton == HandleSkeleton(reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateHandle validates handle format, confusable characters and reserved words
+func ValidateHandle(handle string) error {
+	for _, r := range handle {
+		if r > unicode.MaxASCII {
+			return fmt.Errorf("handle may only contain letters, numbers and underscores")
+		}
+	}
+	if !ValidateLength(handle, constants.MinHandleLength, constants.MaxHandleLength) {
+		return fmt.Errorf("handle must be between %d and %d characters", constants.MinHandleLength, constants.MaxHandleLength)
+	}
+	if !handleRegex.MatchString(handle) {
+		return fmt.Errorf("handle must start with a letter and contain only letters, numbers and underscores")
+	}
+	if len(HandleSkeleton(handle)) < constants.MinHandleLength {
+		return fmt.Errorf("handle is too short")
+	}
+	if IsReservedHandle(handle) {
+		return fmt.Errorf("handle is reserved")
+	}
+	return nil
+}
+
+// ExtractMentions returns the unique, lower-cased handles mentioned as @handle in content
+func ExtractMentions(content string) []string {
+	matches := mentionRegex.FindAllStringSubmatch(content, -1)
+	mentions := make([]string, 0, len(matches))
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		handle := strings.ToLower(match[1])
+		if !seen[handle] {
+			seen[handle] = true
+			mentions = append(mentions, handle)
+		}
+	}
+	return mentions
+}
+
+var (
+	// GitHub usernames: alphanumeric or single hyphens, no leading/trailing hyphen, max 39
+	githubUserRegex = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,37}[a-zA-Z0-9])?$`)
+
+	// LinkedIn public profile slugs (linkedin.com/in/<slug>)
+	linkedInSlugRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{3,100}$`)
+
+	// X (Twitter) handles: letters, digits and underscores, max 15
+	xHandleRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{1,15}$`)
+
+	// Mastodon usernames and instance domains
+	mastodonUserRegex   = regexp.MustCompile(`^[a-zA-Z0-9_]{1,30}$`)
+	mastodonDomainRegex = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,}$`)
+
+	// Skills keep characters used in stack names (C++, C#, Node.js, CI/CD)
+	skillRegex = regexp.MustCompile(`[^a-zA-Z0-9+#./ -]`)
+
+	// Pronouns such as "she/her", "they/them", "he / they"
+	pronounsRegex = regexp.MustCompile(`^[a-zA-Z]+(?:\s*/\s*[a-zA-Z]+)*$`)
+)
+
+// socialPath parses a profile URL (or bare host/path) and returns its path
+// segments if the host is one of hosts
+func socialPath(input string, hosts ...string) ([]string, bool) {
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+	u, err := url.Parse(input)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for _, h := range hosts {
+		if host == h {
+			return strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' }), true
+		}
+	}
+	return nil, false
+}
+
+// NormalizeGitHubLink accepts a GitHub username or profile URL and returns the canonical URL
+func NormalizeGitHubLink(input string) (string, error) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "@")
+	user := input
+	if strings.Contains(input, "/") || strings.Contains(input, ".") {
+		parts, ok := socialPath(input, "github.com")
+		if !ok || len(parts) != 1 {
+			return "", fmt.Errorf("github link must be a github.com profile URL or username")
+		}
+		user = parts[0]
+	}
+	if !githubUserRegex.MatchString(user) || strings.Contains(user, "--") {
+		return "", fmt.Errorf("invalid GitHub username")
+	}
+	return "https://github.com/" + user, nil
+}
+
+// NormalizeLinkedInLink accepts a LinkedIn profile slug or linkedin.com/in/ URL and returns the canonical URL
+func NormalizeLinkedInLink(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	slug := input
+	if strings.Contains(input, "/") || strings.Contains(input, ".") {
+		parts, ok := socialPath(input, "linkedin.com")
+		if !ok || len(parts) != 2 || parts[0] != "in" {
+			return "", fmt.Errorf("linkedin link must be a linkedin.com/in/ profile URL")
+		}
+		slug = parts[1]
+	}
+	if !linkedInSlugRegex.MatchString(slug) {
+		return "", fmt.Errorf("invalid LinkedIn profile")
+	}
+	return "https://www.linkedin.com/in/" + slug, nil
+}
+
+// NormalizeXLink accepts an X handle or x.com/twitter.com URL and returns the canonical URL
+func NormalizeXLink(input string) (string, error) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "@")
+	handle := input
+	if strings.Contains(input, "/") || strings.Contains(input, ".") {
+		parts, ok := socialPath(input, "x.com", "twitter.com", "mobile.twitter.com")
+		if !ok || len(parts) != 1 {
+			return "", fmt.Errorf("x link must be an x.com profile URL or handle")
+		}
+		handle = parts[0]
+	}
+	if !xHandleRegex.MatchString(handle) {
+		return "", fmt.Errorf("invalid X handle")
+	}
+	return "https://x.com/" + handle, nil
+}
+
+// NormalizeMastodonLink accepts @user@instance or https://instance/@user and returns the canonical URL
+func NormalizeMastodonLink(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	var user, domain string
+	if strings.HasPrefix(input, "@") || !strings.Contains(input, "/") {
+		parts := strings.Split(strings.TrimPrefix(input, "@"), "@")
+		if len(parts) != 2 {
+			return "", fmt.Errorf("mastodon link must look like @user@instance or https://instance/@user")
+		}
+		user, domain = parts[0], strings.ToLower(parts[1])
+	} else {
+		if !strings.Contains(input, "://") {
+			input = "https://" + input
+		}
+		u, err := url.Parse(input)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return "", fmt.Errorf("invalid Mastodon URL")
+		}
+		parts := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+		if len(parts) != 1 || !strings.HasPrefix(parts[0], "@") {
+			return "", fmt.Errorf("mastodon link must look like https://instance/@user")
+		}
+		user, domain = strings.TrimPrefix(parts[0], "@"), strings.ToLower(u.Hostname())
+	}
+	if !mastodonUserRegex.MatchString(user) {
+		return "", fmt.Errorf("invalid Mastodon username")
+	}
+	if !mastodonDomainRegex.MatchString(domain) || len(domain) > 253 {
+		return "", fmt.Errorf("invalid Mastodon instance")
+	}
+	return "https://" + domain + "/@" + user, nil
+}
+
+// SanitizeSocialLinks validates each provided link for its platform and
+// returns the links in canonical URL form. Empty fields stay empty.
+func SanitizeSocialLinks(links *models.SocialLinks) (*models.SocialLinks, error) {
+	if links == nil {
+		return nil, nil
+	}
+
+	normalized := &models.SocialLinks{}
+	fields := []struct {
+		in        string
+		out       *string
+		normalize func(string) (string, error)
+	}{
+		{links.GitHub, &normalized.GitHub, NormalizeGitHubLink},
+		{links.LinkedIn, &normalized.LinkedIn, NormalizeLinkedInLink},
+		{links.X, &normalized.X, NormalizeXLink},
+		{links.Mastodon, &normalized.Mastodon, NormalizeMastodonLink},
+	}
+	for _, f := range fields {
+		if strings.TrimSpace(f.in) == "" {
+			continue
+		}
+		value, err := f.normalize(f.in)
+		if err != nil {
+			return nil, err
+		}
+		*f.out = value
+	}
+
+	return normalized, nil
+}
+
+// SanitizeSkills sanitizes a skills/stack list, keeping display case but
+// removing case-insensitive duplicates
+func SanitizeSkills(skills []string) ([]string, error) {
+	if len(skills) > constants.MaxSkills {
+		return nil, fmt.Errorf("maximum %d skills allowed", constants.MaxSkills)
+	}
+
+	sanitized := make([]string, 0, len(skills))
+	seen := make(map[string]bool)
+
+	for _, skill := range skills {
+		skill = skillRegex.ReplaceAllString(strings.TrimSpace(skill), "")
+		skill = strings.Join(strings.Fields(skill), " ")
+		if skill == "" {
+			continue
+		}
+		if !ValidateLength(skill, 1, constants.MaxSkillLength) {
+			return nil, fmt.Errorf("skills must be at most %d characters", constants.MaxSkillLength)
+		}
+
+		key := strings.ToLower(skill)
+		if !seen[key] {
+			seen[key] = true
+			sanitized = append(sanitized, skill)
+		}
+	}
+
+	return sanitized, nil
+}
+
+// SanitizePronouns validates an optional pronouns field ("she/her", "they/them")
+func SanitizePronouns(pronouns string) (string, error) {
+	pronouns = strings.Join(strings.Fields(pronouns), " ")
+	if pronouns == "" {
+		return "", nil
+	}
+	if !ValidateLength(pronouns, 1, constants.MaxPronounsLength) {
+		return "", fmt.Errorf("pronouns must be at most %d characters", constants.MaxPronounsLength)
+	}
+	if !pronounsRegex.MatchString(pronouns) {
+		return "", fmt.Errorf("pronouns may only contain letters separated by slashes")
+	}
+	return pronouns, nil
+}
+
+// SanitizeEmployer sanitizes and validates an optional current-employer field
+func SanitizeEmployer(employer string) (string, error) {
+	employer = SanitizeString(employer)
+	if !ValidateLength(employer, 0, constants.MaxEmployerLength) {
+		return "", fmt.Errorf("employer must be at most %d characters", constants.MaxEmployerLength)
+	}
+	return employer, nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignExpiring returns a hex HMAC-SHA256 signature over the given parts and
+// expiry time, for links that must stop working after a deadline
+func SignExpiring(secret string, expires time.Time, parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(parts, "|")))
+	mac.Write([]byte("|" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns a hex HMAC-SHA256 signature over the given parts, for links
+// that must keep working indefinitely (e.g. unsubscribe links)
+func Sign(secret string, parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign
+func Verify(secret, signature string, parts ...string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, parts...)), []byte(signature))
+}
+
+// VerifyExpiring checks a signature produced by SignExpiring and that the
+// expiry (unix seconds) has not passed
+func VerifyExpiring(secret, signature, expiresUnix string, parts ...string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiresUnix, 10, 64)
+	if err != nil {
+		return false
+	}
+	expires := time.Unix(unix, 0)
+	if time.Now().After(expires) {
+		return false
+	}
+	expected := SignExpiring(secret, expires, parts...)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,61 @@
+-- Unique, case-insensitive user handles (@handle) with redirect history
+-- Run in Supabase SQL Editor after 008_additional_performance_indexes.sql
+
+-- Confusable-character skeleton used to stop look-alike handles
+-- ("adm1n" vs "admin", "rn" vs "m"). Must stay in sync with
+-- utils.HandleSkeleton in the Go server.
+CREATE OR REPLACE FUNCTION public.handle_skeleton(h TEXT)
+RETURNS TEXT AS $$
+    SELECT trim(both '_' FROM regexp_replace(
+        replace(replace(translate(lower(h), '01345789i', 'oleastbgl'), 'rn', 'm'), 'vv', 'w'),
+        '_+', '_', 'g'
+    ));
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS handle TEXT,
+    ADD COLUMN IF NOT EXISTS handle_skeleton TEXT,
+    ADD COLUMN IF NOT EXISTS handle_changed_at TIMESTAMPTZ;
+
+-- Previous handles keep redirecting (and stay reserved for their former
+-- owner) for a retention window after a change.
+CREATE TABLE IF NOT EXISTS public.handle_history (
+    handle TEXT PRIMARY KEY, -- lower-cased former handle
+    skeleton TEXT NOT NULL,
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    changed_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_handle_history_user ON public.handle_history(user_id);
+CREATE INDEX IF NOT EXISTS idx_handle_history_skeleton ON public.handle_history(skeleton);
+
+-- Backfill handles for existing users from their display name plus a short
+-- id suffix, retrying until the skeleton is free.
+DO $$
+DECLARE
+    u RECORD;
+    base TEXT;
+    candidate TEXT;
+    attempt INTEGER;
+BEGIN
+    FOR u IN SELECT id, name FROM public.users WHERE handle IS NULL LOOP
+        base := left(regexp_replace(coalesce(u.name, ''), '[^a-zA-Z0-9_]', '', 'g'), 20);
+        IF length(base) < 3 OR base !~ '^[a-zA-Z]' THEN
+            base := 'user' || base;
+        END IF;
+        attempt := 0;
+        LOOP
+            candidate := left(base, 20) || '_' || substr(md5(u.id::text || attempt::text), 1, 6);
+            EXIT WHEN NOT EXISTS (
+                SELECT 1 FROM public.users WHERE handle_skeleton = public.handle_skeleton(candidate)
+            );
+            attempt := attempt + 1;
+        END LOOP;
+        UPDATE public.users
+        SET handle = candidate, handle_skeleton = public.handle_skeleton(candidate)
+        WHERE id = u.id;
+    END LOOP;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle_lower ON public.users(LOWER(handle));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle_skeleton ON public.users(handle_skeleton);
@@ -0,0 +1,21 @@
+-- Precomputed "who to follow" suggestions
+-- Run in Supabase SQL Editor after 009_user_handles.sql
+
+CREATE TABLE IF NOT EXISTS public.follow_suggestions (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    candidate_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    reason TEXT NOT NULL, -- mutual_follows, shared_interests, verified, active
+    mutual_count INTEGER DEFAULT 0,
+    computed_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, candidate_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_suggestions_user_score
+    ON public.follow_suggestions(user_id, score DESC);
+
+-- Engagement lookups used by the suggestion query
+CREATE INDEX IF NOT EXISTS idx_likes_user_created ON public.likes(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_tags ON public.posts USING gin (tags);
+
+ALTER TABLE public.follow_suggestions ENABLE ROW LEVEL SECURITY;
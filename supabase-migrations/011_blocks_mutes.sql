@@ -0,0 +1,23 @@
+-- User blocks (mutual invisibility) and mutes (one-way hide)
+-- Run in Supabase SQL Editor after 010_follow_suggestions.sql
+
+CREATE TABLE IF NOT EXISTS public.user_blocks (
+    blocker_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    blocked_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id != blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON public.user_blocks(blocked_id);
+
+CREATE TABLE IF NOT EXISTS public.user_mutes (
+    muter_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    muted_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id != muted_id)
+);
+
+ALTER TABLE public.user_blocks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.user_mutes ENABLE ROW LEVEL SECURITY;
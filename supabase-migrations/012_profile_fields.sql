@@ -0,0 +1,9 @@
+-- Richer profiles: social links, skills, pronouns and employer
+-- Run in Supabase SQL Editor after 011_blocks_mutes.sql
+-- (cover_photo was added in 004_user_cover_photo.sql)
+
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS social_links JSONB DEFAULT '{}'::jsonb,
+    ADD COLUMN IF NOT EXISTS skills TEXT[] DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS pronouns TEXT,
+    ADD COLUMN IF NOT EXISTS employer TEXT;
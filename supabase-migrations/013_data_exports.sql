@@ -0,0 +1,21 @@
+-- Self-service data export (takeout archive) jobs
+-- Run in Supabase SQL Editor after 012_profile_fields.sql
+
+CREATE TABLE IF NOT EXISTS public.data_exports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'ready', 'failed', 'expired')),
+    object_path TEXT, -- Storage path of the ZIP archive
+    size_bytes BIGINT,
+    error TEXT,
+    attempts INTEGER DEFAULT 0,
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON public.data_exports(user_id, requested_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON public.data_exports(status, requested_at);
+
+ALTER TABLE public.data_exports ENABLE ROW LEVEL SECURITY;
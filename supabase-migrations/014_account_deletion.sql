@@ -0,0 +1,26 @@
+-- Self-service account deletion with a grace period
+-- Run in Supabase SQL Editor after 013_data_exports.sql
+
+-- Set on accounts that were anonymized rather than deleted; the row stays
+-- so their posts and comments can be shown as "[deleted user]"
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- One row per account that asked to be deleted. No foreign key: the row
+-- outlives the user as a record that the deletion was carried out.
+CREATE TABLE IF NOT EXISTS public.account_deletions (
+    user_id UUID PRIMARY KEY,
+    mode TEXT NOT NULL CHECK (mode IN ('delete', 'anonymize')),
+    status TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'processing', 'completed', 'cancelled')),
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    attempts INTEGER DEFAULT 0,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_due ON public.account_deletions(status, scheduled_for);
+
+ALTER TABLE public.account_deletions ENABLE ROW LEVEL SECURITY;
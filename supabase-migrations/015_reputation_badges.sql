@@ -0,0 +1,43 @@
+-- Reputation ledger, accepted answers and badges
+-- Run in Supabase SQL Editor after 014_account_deletion.sql
+
+-- Cached sum of the user's reputation_events (rebuilt by the daily recompute)
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS reputation INTEGER DEFAULT 0;
+
+-- A post's author can accept one comment as the answer
+ALTER TABLE public.posts
+    ADD COLUMN IF NOT EXISTS accepted_comment_id UUID REFERENCES public.comments(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS accepted_at TIMESTAMPTZ;
+
+-- One row per reputation-changing event. created_at is when the underlying
+-- event happened (e.g. the like), so period leaderboards survive a recompute.
+CREATE TABLE IF NOT EXISTS public.reputation_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    event_type TEXT NOT NULL,
+    points INTEGER NOT NULL,
+    source_type TEXT NOT NULL, -- post, comment, user, report
+    source_id UUID NOT NULL,
+    actor_id UUID NOT NULL, -- who caused it (the user themselves for authored content)
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reputation_events_unique ON public.reputation_events(user_id, event_type, source_id, actor_id);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_user ON public.reputation_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_created ON public.reputation_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_source ON public.reputation_events(source_id);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_actor ON public.reputation_events(actor_id);
+
+-- Badges earned by users; the rules themselves live in the server
+CREATE TABLE IF NOT EXISTS public.user_badges (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    badge_id TEXT NOT NULL,
+    awarded_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, badge_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_reputation ON public.users(reputation DESC);
+
+ALTER TABLE public.reputation_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.user_badges ENABLE ROW LEVEL SECURITY;
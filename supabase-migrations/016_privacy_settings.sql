@@ -0,0 +1,21 @@
+-- Per-user privacy settings
+-- Run in Supabase SQL Editor after 015_reputation_badges.sql
+
+-- Users without a row get the defaults below (public profile, searchable,
+-- anyone can comment or mention, likes visible, bookmarks private)
+CREATE TABLE IF NOT EXISTS public.user_privacy_settings (
+    user_id UUID PRIMARY KEY REFERENCES public.users(id) ON DELETE CASCADE,
+    profile_visibility TEXT NOT NULL DEFAULT 'public' CHECK (profile_visibility IN ('public', 'members', 'followers')),
+    searchable BOOLEAN NOT NULL DEFAULT true,
+    comment_permission TEXT NOT NULL DEFAULT 'everyone' CHECK (comment_permission IN ('everyone', 'followers', 'nobody')),
+    mention_permission TEXT NOT NULL DEFAULT 'everyone' CHECK (mention_permission IN ('everyone', 'followers', 'nobody')),
+    show_likes BOOLEAN NOT NULL DEFAULT true,
+    show_bookmarks BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Search excludes hidden users with a NOT EXISTS on this table
+CREATE INDEX IF NOT EXISTS idx_user_privacy_settings_hidden ON public.user_privacy_settings(user_id)
+    WHERE searchable = false OR profile_visibility <> 'public';
+
+ALTER TABLE public.user_privacy_settings ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,25 @@
+-- Email change with confirmation on both addresses and a revert window
+-- Run in Supabase SQL Editor after 016_privacy_settings.sql
+
+-- Allow OTPs issued for email changes
+ALTER TABLE public.otps DROP CONSTRAINT IF EXISTS otps_type_check;
+ALTER TABLE public.otps
+    ADD CONSTRAINT otps_type_check CHECK (type IN ('2fa', 'password_reset', 'email_change'));
+
+-- One row per requested change. Completed changes can be reverted from the
+-- old address until revert_expires_at.
+CREATE TABLE IF NOT EXISTS public.email_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    old_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'cancelled', 'reverted')),
+    requested_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    revert_expires_at TIMESTAMPTZ,
+    reverted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user ON public.email_changes(user_id, requested_at DESC);
+
+ALTER TABLE public.email_changes ENABLE ROW LEVEL SECURITY;
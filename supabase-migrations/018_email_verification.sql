@@ -0,0 +1,12 @@
+-- Mandatory email verification for email/password signups
+-- Run in Supabase SQL Editor after 017_email_change.sql
+
+-- NULL until the user follows the link emailed at signup. Unverified
+-- accounts cannot post, comment or upload, and are removed after 7 days.
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- Accounts that existed before verification was required are trusted
+UPDATE public.users SET email_verified_at = COALESCE(created_at, NOW()) WHERE email_verified_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_unverified ON public.users(created_at) WHERE email_verified_at IS NULL;
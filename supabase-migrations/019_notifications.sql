@@ -0,0 +1,20 @@
+-- In-app notifications for likes, comments, replies, mentions and follows
+-- Run in Supabase SQL Editor after 018_email_verification.sql
+
+-- One row per recipient, group and actor. Rows sharing a group_key (e.g.
+-- every like on one post) are shown together as "Alice and 4 others ...".
+CREATE TABLE IF NOT EXISTS public.notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('like', 'comment', 'reply', 'mention', 'follow')),
+    actor_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    post_id UUID REFERENCES public.posts(id) ON DELETE CASCADE,
+    comment_id UUID REFERENCES public.comments(id) ON DELETE CASCADE,
+    group_key TEXT NOT NULL,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, group_key, actor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON public.notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON public.notifications(user_id, group_key) WHERE read_at IS NULL;
@@ -0,0 +1,27 @@
+-- Notification delivery preferences, email digests and unsubscribe
+-- Run in Supabase SQL Editor after 019_notifications.sql
+
+-- Per-user, per-type delivery channel. Missing rows use the server defaults.
+-- top_posts is the "top posts from people you follow" digest section.
+CREATE TABLE IF NOT EXISTS public.notification_preferences (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('like', 'comment', 'reply', 'mention', 'follow', 'top_posts')),
+    channel TEXT NOT NULL CHECK (channel IN ('in_app', 'email', 'daily', 'weekly', 'off')),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, type)
+);
+
+-- Set once a notification has gone out by email, so it is sent only once
+ALTER TABLE public.notifications
+    ADD COLUMN IF NOT EXISTS emailed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unemailed ON public.notifications(created_at)
+    WHERE emailed_at IS NULL AND read_at IS NULL;
+
+-- When each user's daily and weekly digest was last considered
+CREATE TABLE IF NOT EXISTS public.notification_digests (
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    last_sent_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, frequency)
+);
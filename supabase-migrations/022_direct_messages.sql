@@ -0,0 +1,51 @@
+-- Direct messages: 1:1 and small group conversations
+-- Run in Supabase SQL Editor after 021_webhooks.sql
+
+-- Who may start a conversation with the user
+ALTER TABLE public.user_privacy_settings
+    ADD COLUMN IF NOT EXISTS message_permission TEXT NOT NULL DEFAULT 'everyone'
+    CHECK (message_permission IN ('everyone', 'followers', 'nobody'));
+
+-- direct_key ("<smaller user id>:<larger user id>") keeps one 1:1
+-- conversation per pair; groups have none
+CREATE TABLE IF NOT EXISTS public.conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    is_group BOOLEAN NOT NULL DEFAULT false,
+    title TEXT,
+    direct_key TEXT UNIQUE,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_message_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Members; last_read_at drives unread counts and read receipts. Leaving a
+-- 1:1 conversation only hides it until the next message.
+CREATE TABLE IF NOT EXISTS public.conversation_participants (
+    conversation_id UUID REFERENCES public.conversations(id) ON DELETE CASCADE NOT NULL,
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    left_at TIMESTAMPTZ,
+    last_read_at TIMESTAMPTZ,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_user ON public.conversation_participants(user_id)
+    WHERE left_at IS NULL;
+
+-- Deleted messages keep their place in the history without their content
+CREATE TABLE IF NOT EXISTS public.messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID REFERENCES public.conversations(id) ON DELETE CASCADE NOT NULL,
+    sender_id UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    content TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created ON public.messages(conversation_id, created_at DESC, id DESC);
+
+-- Attachments are uploaded through /media/upload like post media
+ALTER TABLE public.media ADD COLUMN IF NOT EXISTS message_id UUID REFERENCES public.messages(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_media_message ON public.media(message_id) WHERE message_id IS NOT NULL;
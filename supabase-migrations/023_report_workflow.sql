@@ -0,0 +1,33 @@
+-- Report workflow: one report per reporter and target, snapshots of the
+-- reported content, resolution actions, and reports that outlive removals
+-- Run in Supabase SQL Editor after 022_direct_messages.sql
+
+-- Removing reported content keeps its reports; the snapshot shows what it was
+ALTER TABLE public.reports DROP CONSTRAINT IF EXISTS reports_post_id_fkey;
+ALTER TABLE public.reports ADD CONSTRAINT reports_post_id_fkey
+    FOREIGN KEY (post_id) REFERENCES public.posts(id) ON DELETE SET NULL;
+ALTER TABLE public.reports DROP CONSTRAINT IF EXISTS reports_comment_id_fkey;
+ALTER TABLE public.reports ADD CONSTRAINT reports_comment_id_fkey
+    FOREIGN KEY (comment_id) REFERENCES public.comments(id) ON DELETE SET NULL;
+ALTER TABLE public.reports DROP CONSTRAINT IF EXISTS reports_check;
+ALTER TABLE public.reports ADD CONSTRAINT reports_check CHECK (post_id IS NULL OR comment_id IS NULL);
+
+-- target_type/target_id still identify the content once it is gone
+ALTER TABLE public.reports ADD COLUMN IF NOT EXISTS target_type TEXT CHECK (target_type IN ('post', 'comment'));
+ALTER TABLE public.reports ADD COLUMN IF NOT EXISTS target_id UUID;
+ALTER TABLE public.reports ADD COLUMN IF NOT EXISTS actions TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE public.reports
+SET target_type = CASE WHEN post_id IS NOT NULL THEN 'post' ELSE 'comment' END,
+    target_id = COALESCE(post_id, comment_id)
+WHERE target_id IS NULL;
+
+-- Keep the first of any duplicate reports before enforcing one per reporter and target
+DELETE FROM public.reports r
+USING public.reports d
+WHERE r.reporter_id = d.reporter_id AND r.target_type = d.target_type AND r.target_id = d.target_id
+    AND (r.created_at, r.id) > (d.created_at, d.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_reporter_target ON public.reports(reporter_id, target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_reports_open_target ON public.reports(target_type, target_id)
+    WHERE status IN ('pending', 'reviewed');
@@ -0,0 +1,47 @@
+-- Append-only audit log of admin and super admin actions
+-- Run in Supabase SQL Editor after 023_report_workflow.sql
+
+-- One row per admin mutation. actor_id and target_id carry no foreign keys
+-- so entries outlive the accounts they mention. Every row stores the hash
+-- of the row before it (prev_hash) and its own hash over its contents and
+-- prev_hash, so editing or removing a row breaks the chain from there on.
+CREATE TABLE IF NOT EXISTS public.admin_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID,
+    actor_role TEXT,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    before JSONB,
+    after JSONB,
+    reason TEXT,
+    ip_address TEXT,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor ON public.admin_audit_log(actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_action ON public.admin_audit_log(action, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target ON public.admin_audit_log(target_type, target_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created ON public.admin_audit_log(created_at);
+
+-- Rows can only be inserted. The hash chain still catches changes made by
+-- anyone able to drop the trigger.
+CREATE OR REPLACE FUNCTION public.reject_admin_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS admin_audit_log_append_only ON public.admin_audit_log;
+CREATE TRIGGER admin_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON public.admin_audit_log
+    FOR EACH ROW EXECUTE FUNCTION public.reject_admin_audit_log_change();
+
+DROP TRIGGER IF EXISTS admin_audit_log_no_truncate ON public.admin_audit_log;
+CREATE TRIGGER admin_audit_log_no_truncate
+    BEFORE TRUNCATE ON public.admin_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION public.reject_admin_audit_log_change();
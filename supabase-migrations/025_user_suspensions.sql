@@ -0,0 +1,35 @@
+-- Timed suspensions with a reason and a scope
+-- Run in Supabase SQL Editor after 024_admin_audit_log.sql
+
+-- scope 'read_only' keeps the account signed in and able to read; 'full'
+-- also deactivates it (users.is_active = false). expires_at NULL means
+-- until an admin lifts it. A user has at most one active (unlifted)
+-- suspension; lift_reason is 'expired' when the expiry job lifted it.
+CREATE TABLE IF NOT EXISTS public.user_suspensions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    scope TEXT NOT NULL CHECK (scope IN ('read_only', 'full')),
+    reason TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    report_id UUID REFERENCES public.reports(id) ON DELETE SET NULL,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    lifted_at TIMESTAMPTZ,
+    lifted_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    lift_reason TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_suspensions_active ON public.user_suspensions(user_id)
+    WHERE lifted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_expiry ON public.user_suspensions(expires_at)
+    WHERE lifted_at IS NULL AND expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user ON public.user_suspensions(user_id, created_at DESC);
+
+-- Existing bans become indefinite full suspensions
+INSERT INTO public.user_suspensions (user_id, scope, reason, created_at)
+SELECT u.id, 'full', 'Banned before suspensions had reasons', COALESCE(u.updated_at, NOW())
+FROM public.users u
+WHERE u.is_active = false AND u.deleted_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM public.user_suspensions s WHERE s.user_id = u.id AND s.lifted_at IS NULL
+    );
@@ -0,0 +1,43 @@
+-- Admin-managed word and link filter rules, and the queue of writes they
+-- hold for review
+-- Run in Supabase SQL Editor after 025_user_suspensions.sql
+
+-- kind 'word' matches a word or phrase case-insensitively, 'regex' an RE2
+-- expression, 'domain_block' links to a domain or its subdomains, and
+-- 'domain_allow' exempts a domain from domain_block rules (so it has no
+-- action). The server reloads the rules when COUNT(*) or MAX(updated_at)
+-- changes.
+CREATE TABLE IF NOT EXISTS public.content_filter_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('word', 'regex', 'domain_block', 'domain_allow')),
+    pattern TEXT NOT NULL,
+    action TEXT CHECK (action IN ('reject', 'hold', 'flag', 'mask')),
+    applies_to TEXT[] NOT NULL DEFAULT '{post,comment,profile}',
+    description TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((kind = 'domain_allow') = (action IS NULL))
+);
+
+-- Writes held for review. payload is the request replayed on approval;
+-- target_id is the post commented on or the post or comment being edited.
+CREATE TABLE IF NOT EXISTS public.moderation_queue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('post', 'post_update', 'comment', 'comment_update', 'profile')),
+    target_id UUID,
+    payload JSONB NOT NULL,
+    reason TEXT NOT NULL,
+    rule_ids UUID[] NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMPTZ,
+    reviewed_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    review_note TEXT,
+    published_id UUID -- The post or comment created on approval
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_status ON public.moderation_queue(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_user ON public.moderation_queue(user_id, created_at DESC);
@@ -0,0 +1,27 @@
+-- Automatic trust levels and premoderation of new accounts
+-- Run in Supabase SQL Editor after 026_content_filters.sql
+
+-- 0 new, 1 basic, 2 member, 3 trusted. Recomputed by the server at startup
+-- and hourly from account age, verified email, posts, likes received and
+-- upheld reports.
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS trust_level SMALLINT NOT NULL DEFAULT 0 CHECK (trust_level BETWEEN 0 AND 3),
+    ADD COLUMN IF NOT EXISTS trust_level_updated_at TIMESTAMPTZ;
+
+-- New accounts' uploads stay pending, and can't be attached, until approved
+ALTER TABLE public.media
+    ADD COLUMN IF NOT EXISTS moderation_status TEXT NOT NULL DEFAULT 'approved'
+        CHECK (moderation_status IN ('pending', 'approved'));
+
+-- The queue also holds new accounts' posts and uploads. source says whether
+-- a filter rule or the author's trust level held the item; for media,
+-- target_id is the upload.
+ALTER TABLE public.moderation_queue
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'filter' CHECK (source IN ('filter', 'trust'));
+
+ALTER TABLE public.moderation_queue DROP CONSTRAINT IF EXISTS moderation_queue_kind_check;
+ALTER TABLE public.moderation_queue
+    ADD CONSTRAINT moderation_queue_kind_check
+        CHECK (kind IN ('post', 'post_update', 'comment', 'comment_update', 'profile', 'media'));
+
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_source ON public.moderation_queue(source, status, created_at);
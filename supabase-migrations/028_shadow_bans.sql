@@ -0,0 +1,11 @@
+-- Shadow bans
+-- Run in Supabase SQL Editor after 027_trust_levels.sql
+
+-- A shadow-banned user's posts, comments, follows, likes and mentions are
+-- hidden from everyone but themselves. NULL when not shadow-banned.
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS shadow_banned_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS shadow_banned_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS shadow_ban_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_shadow_banned ON public.users(shadow_banned_at DESC) WHERE shadow_banned_at IS NOT NULL;
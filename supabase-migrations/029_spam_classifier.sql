@@ -0,0 +1,24 @@
+-- Local spam classifier trained from moderation outcomes
+-- Run in Supabase SQL Editor after 028_shadow_bans.sql
+
+-- Trained Naive Bayes models, newest used. token_counts maps each token to
+-- how many spam and ham examples contained it; misclassifications are the
+-- training examples the model got wrong when each was left out.
+CREATE TABLE IF NOT EXISTS public.spam_models (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    spam_examples INTEGER NOT NULL,
+    ham_examples INTEGER NOT NULL,
+    token_counts JSONB NOT NULL,
+    evaluation JSONB NOT NULL,
+    misclassifications JSONB NOT NULL DEFAULT '[]',
+    trained_by UUID REFERENCES public.users(id) ON DELETE SET NULL, -- NULL for scheduled retraining
+    trained_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_spam_models_trained ON public.spam_models(trained_at DESC);
+
+-- Posts and comments the classifier scores above the hold threshold are
+-- queued with source 'classifier'
+ALTER TABLE public.moderation_queue DROP CONSTRAINT IF EXISTS moderation_queue_source_check;
+ALTER TABLE public.moderation_queue
+    ADD CONSTRAINT moderation_queue_source_check CHECK (source IN ('filter', 'trust', 'classifier'));
@@ -0,0 +1,23 @@
+-- Appeals of suspensions and upheld reports
+-- Run in Supabase SQL Editor after 029_spam_classifier.sql
+
+-- One appeal per action. action_id is a user_suspensions or reports ID but
+-- has no foreign key, so appeals outlive the action; action keeps what the
+-- user saw when they appealed.
+CREATE TABLE IF NOT EXISTS public.appeals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES public.users(id) ON DELETE CASCADE NOT NULL,
+    action_type TEXT NOT NULL CHECK (action_type IN ('suspension', 'report')),
+    action_id UUID NOT NULL,
+    action JSONB NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'denied')),
+    decision_note TEXT,
+    decided_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (action_type, action_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appeals_status ON public.appeals(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_appeals_user ON public.appeals(user_id, created_at DESC);
@@ -0,0 +1,38 @@
+-- Bulk moderation operations run by a background job
+-- Run in Supabase SQL Editor after 030_appeals.sql
+
+-- Super admins' bulk operations. targets is resolved when the job starts
+-- and processed counts how many of them have been acted on, so an
+-- interrupted job resumes where it stopped. The actor columns are what
+-- every audit entry the job writes is recorded with.
+CREATE TABLE IF NOT EXISTS public.bulk_operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('suspend_users', 'remove_content', 'resolve_reports', 'purge')),
+    params JSONB NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT false,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
+    targets JSONB,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    succeeded INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    failures JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES public.users(id) ON DELETE SET NULL,
+    actor_role TEXT,
+    ip_address TEXT,
+    request_id TEXT,
+    audit_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_status ON public.bulk_operations(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_bulk_operations_created ON public.bulk_operations(created_at DESC);
+
+-- Purges look users up by the addresses they signed up or signed in from
+CREATE INDEX IF NOT EXISTS idx_security_events_ip ON public.security_events(ip_address);
+CREATE INDEX IF NOT EXISTS idx_sessions_ip ON public.sessions(ip_address);
@@ -0,0 +1,20 @@
+-- One in-progress data export per user
+-- Run in Supabase SQL Editor after 031_bulk_operations.sql
+
+-- Fail exports left stuck in processing after their last retry, and all
+-- but the newest in-progress export of users who managed to queue two
+UPDATE public.data_exports
+SET status = 'failed', error = 'interrupted too many times', completed_at = NOW()
+WHERE status = 'processing' AND started_at < NOW() - INTERVAL '1 hour' AND attempts >= 3;
+
+UPDATE public.data_exports e
+SET status = 'failed', error = 'superseded by a newer export', completed_at = NOW()
+WHERE e.status IN ('pending', 'processing')
+    AND EXISTS (
+        SELECT 1 FROM public.data_exports n
+        WHERE n.user_id = e.user_id AND n.status IN ('pending', 'processing')
+            AND (n.requested_at, n.id) > (e.requested_at, e.id)
+    );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_user_in_progress ON public.data_exports(user_id)
+    WHERE status IN ('pending', 'processing');
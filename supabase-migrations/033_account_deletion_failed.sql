@@ -0,0 +1,12 @@
+-- Terminal failed status for account deletions
+-- Run in Supabase SQL Editor after 032_data_exports_in_progress.sql
+
+-- Deletions still failing after their last retry are marked failed instead
+-- of staying in processing forever
+ALTER TABLE public.account_deletions DROP CONSTRAINT IF EXISTS account_deletions_status_check;
+ALTER TABLE public.account_deletions ADD CONSTRAINT account_deletions_status_check
+    CHECK (status IN ('scheduled', 'processing', 'completed', 'cancelled', 'failed'));
+
+UPDATE public.account_deletions
+SET status = 'failed', completed_at = NOW()
+WHERE status = 'processing' AND started_at < NOW() - INTERVAL '1 hour' AND attempts >= 5;
@@ -0,0 +1,19 @@
+-- Spam tags on moderation outcomes
+-- Run in Supabase SQL Editor after 033_account_deletion_failed.sql
+
+-- The spam classifier trains only on rejections and removals an admin
+-- tagged as spam; content rejected or removed for other reasons (off-topic,
+-- abusive, duplicate) isn't spam
+ALTER TABLE public.reports ADD COLUMN IF NOT EXISTS spam BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE public.moderation_queue ADD COLUMN IF NOT EXISTS spam BOOLEAN NOT NULL DEFAULT false;
+
+-- Existing outcomes are tagged where they were plainly about spam: items the
+-- classifier held that were rejected, and removals the report gave spam as
+-- the reason for
+UPDATE public.moderation_queue
+SET spam = true
+WHERE status = 'rejected' AND source = 'classifier';
+
+UPDATE public.reports
+SET spam = true
+WHERE status = 'resolved' AND 'remove' = ANY(actions) AND reason ILIKE '%spam%';